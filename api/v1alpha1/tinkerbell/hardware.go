@@ -1,6 +1,9 @@
 package tinkerbell
 
 import (
+	"fmt"
+	"net/netip"
+
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -31,6 +34,7 @@ type HardwareList struct {
 // +kubebuilder:resource:path=hardware,scope=Namespaced,categories=tinkerbell,singular=hardware,shortName=hw
 // +kubebuilder:storageversion
 // +kubebuilder:printcolumn:JSONPath=".status.state",name=State,type=string
+// +kubebuilder:printcolumn:JSONPath=".status.netboot.lastStep",name=Netboot,type=string,priority=1
 // +kubebuilder:metadata:labels=clusterctl.cluster.x-k8s.io=
 // +kubebuilder:metadata:labels=clusterctl.cluster.x-k8s.io/move=
 
@@ -151,6 +155,12 @@ type Netboot struct {
 
 	//+optional
 	OSIE *OSIE `json:"osie,omitempty"`
+
+	// SecureBoot, when true, boots UEFI clients through a signed shim and GRUB from Smee's boot files directory
+	// instead of iPXE, for machines with UEFI Secure Boot enforced. GRUB loads the OSIE with a configuration
+	// generated by Smee. See the Secure Boot documentation for more details.
+	//+optional
+	SecureBoot *bool `json:"secureBoot,omitempty"`
 }
 
 // Isoboot configuration for booting a client using an ISO image.
@@ -172,8 +182,33 @@ type IPXE struct {
 	// - ipxe.efi
 	// - snp-arm64.efi
 	// - snp-x86_64.efi
-	// See the iPXE Architecture Mapping documentation for more details.
+	// It can also be the path of a file in Smee's boot files directory, for example shim/shimx64.efi.
+	// See the iPXE Architecture Mapping and Boot Files documentation for more details.
 	Binary string `json:"binary,omitempty"`
+	// TemplateRef is the name of an IPXEScript, in the namespace of the Hardware, that is rendered and served
+	// instead of the built-in Hook script. URL and Contents take precedence over TemplateRef.
+	// +optional
+	TemplateRef string `json:"templateRef,omitempty"`
+	// Menu are custom entries added to the Smee iPXE boot menu of this Hardware, when the menu is enabled in Smee.
+	// An entry with the same name as a built-in or Smee configured entry replaces it.
+	// +optional
+	Menu []IPXEMenuEntry `json:"menu,omitempty"`
+}
+
+// IPXEMenuEntry is an entry in the Smee iPXE boot menu.
+type IPXEMenuEntry struct {
+	// Name identifies the entry in the menu.
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9_-]+$`
+	Name string `json:"name"`
+	// Label is the text shown in the menu. Defaults to Name.
+	// +optional
+	Label string `json:"label,omitempty"`
+	// URL is an http or https URL that is chain loaded when the entry is selected.
+	// +optional
+	URL string `json:"url,omitempty"`
+	// Script are iPXE commands that are run when the entry is selected. URL takes precedence over Script.
+	// +optional
+	Script string `json:"script,omitempty"`
 }
 
 // OSIE configuration.
@@ -181,6 +216,23 @@ type OSIE struct {
 	BaseURL string `json:"baseURL,omitempty"`
 	Kernel  string `json:"kernel,omitempty"`
 	Initrd  string `json:"initrd,omitempty"`
+
+	// Version selects a HookOS version. The kernel and initrd are downloaded from <OSIE URL>/<version>
+	// and the ISO from <directory of the source ISO>/<version>/<ISO file name>.
+	// BaseURL and an ISO set in Isoboot take precedence.
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9._-]+$`
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// KernelParams are extra kernel parameters for this Hardware.
+	// They're appended to the kernel parameters configured in Smee, in both the iPXE script and the ISO.
+	// +optional
+	KernelParams []string `json:"kernelParams,omitempty"`
+
+	// Consoles replace the default console kernel parameters, for example ["tty0", "ttyS0,115200"].
+	// Each entry becomes a console=<entry> kernel parameter.
+	// +optional
+	Consoles []string `json:"consoles,omitempty"`
 }
 
 // DHCP configuration.
@@ -197,6 +249,13 @@ type DHCP struct {
 	UEFI        bool     `json:"uefi,omitempty"`
 	IfaceName   string   `json:"iface_name,omitempty"`
 	IP          *IP      `json:"ip,omitempty"`
+	// IPv6 holds the IPv6 addresses that are handed out to the interface by DHCPv6.
+	//+optional
+	IPv6 *IPv6 `json:"ipv6,omitempty"`
+	// RelayAgentInfo identifies the Hardware by the switch port it is connected to, as reported by a DHCP relay agent in option 82.
+	// It is used when no Hardware matches the MAC address of a DHCP request, so that a replaced NIC in the same port keeps the same identity.
+	//+optional
+	RelayAgentInfo *RelayAgentInfo `json:"relay_agent_info,omitempty"`
 	// validation pattern for VLANDID is a string number between 0-4096
 	// +kubebuilder:validation:Pattern="^(([0-9][0-9]{0,2}|[1-3][0-9][0-9][0-9]|40([0-8][0-9]|9[0-6]))(,[1-9][0-9]{0,2}|[1-3][0-9][0-9][0-9]|40([0-8][0-9]|9[0-6]))*)$"
 	VLANID string `json:"vlan_id,omitempty"`
@@ -215,6 +274,32 @@ type DHCP struct {
 	// If specified, TFTPServerName must also be specified.
 	//+optional
 	BootFileName string `json:"boot_file_name,omitempty"`
+	// Options are raw DHCP options sent to the interface. They are applied after all other options
+	// and replace any option with the same code, including the global and subnet default options.
+	//+optional
+	Options []DHCPOption `json:"options,omitempty"`
+}
+
+// DHCPOption is a raw DHCPv4 option.
+type DHCPOption struct {
+	// Code is the DHCP option code.
+	// Codes that define the DHCP exchange and the lease are set by Smee and can't be set:
+	// 51, 52, 53, 54, 58, 59, 61 and 82.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=254
+	// +kubebuilder:validation:XValidation:rule="!(self in [51, 52, 53, 54, 58, 59, 61, 82])",message="DHCP options 51, 52, 53, 54, 58, 59, 61 and 82 are set by Smee and can't be set"
+	Code int32 `json:"code"`
+	// Type is how Value is encoded in the option.
+	// ip: a single IPv4 address.
+	// ip-list: IPv4 addresses separated by commas or spaces.
+	// string: the value as is.
+	// uint8, uint16, uint32: an unsigned integer in network byte order.
+	// bool: true or false, encoded as a single byte.
+	// hex: raw bytes as hex, optionally separated by colons, for example "01:04:c0:a8:01:01".
+	// +kubebuilder:validation:Enum=ip;ip-list;string;uint8;uint16;uint32;bool;hex
+	Type string `json:"type"`
+	// Value is the option value, encoded according to Type.
+	Value string `json:"value"`
 }
 
 // IP configuration.
@@ -225,6 +310,37 @@ type IP struct {
 	Family  int64  `json:"family,omitempty"`
 }
 
+// IPv6 configuration.
+type IPv6 struct {
+	// Addresses are the IPv6 addresses that are handed out to the interface in a DHCPv6 IA_NA option.
+	// Most machines only need one address, more can be specified when multiple addresses on the same link are required.
+	// All addresses identify the Hardware when it is looked up by IP address, for example when serving iPXE scripts.
+	//+optional
+	//+listType=set
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+// RelayAgentInfo holds DHCP relay agent information (option 82) sub-options.
+// Values that are not printable are written as hex with a 0x prefix, for example "0x0004000a0001".
+type RelayAgentInfo struct {
+	// CircuitID is sub-option 1, it usually identifies the switch port.
+	// +kubebuilder:validation:MinLength=1
+	CircuitID string `json:"circuit_id"`
+	// RemoteID is sub-option 2, it usually identifies the switch.
+	// When empty, only the CircuitID has to match.
+	//+optional
+	RemoteID string `json:"remote_id,omitempty"`
+}
+
+// Key returns the identifier used to look up Hardware by relay agent information.
+func (r *RelayAgentInfo) Key() string {
+	if r == nil || r.CircuitID == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("circuit_id=%s,remote_id=%s", r.CircuitID, r.RemoteID)
+}
+
 // ClasslessStaticRoute represents a classless static route for DHCP option 121 (RFC 3442).
 type ClasslessStaticRoute struct {
 	// DestinationDescriptor is the network address and prefix length.
@@ -358,6 +474,224 @@ type Disk struct {
 type HardwareStatus struct {
 	//+optional
 	State HardwareState `json:"state,omitempty"`
+
+	// Inventory is the hardware inventory most recently reported by the Agent running on the machine.
+	//+optional
+	Inventory *HardwareInventory `json:"inventory,omitempty"`
+
+	// Netboot is the netboot progress of the machine, as most recently observed by Smee.
+	//+optional
+	Netboot *NetbootStatus `json:"netboot,omitempty"`
+
+	// Conditions are the latest available observations of the Hardware's current state.
+	//
+	// +optional
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=atomic
+	Conditions []HardwareCondition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// HardwareConditionType is the type of a Hardware condition.
+type HardwareConditionType string
+
+const (
+	// InventoryDrift is True when the inventory reported by an Agent differs from the previously reported inventory.
+	InventoryDrift HardwareConditionType = "InventoryDrift"
+)
+
+// HardwareCondition describes the current state of an aspect of a Hardware object.
+type HardwareCondition struct {
+	// Type of the condition.
+	Type HardwareConditionType `json:"type"`
+	// Status of the condition, one of True, False, Unknown.
+	Status metav1.ConditionStatus `json:"status"`
+	// Reason is a (brief) reason for the condition's last transition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable message indicating details about last transition.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// Time when the condition was created.
+	// +optional
+	Time *metav1.Time `json:"time,omitempty"`
+}
+
+// NetbootStep is a step in netbooting a machine.
+// +kubebuilder:validation:Enum=DHCP;Binary;Script;ISO
+type NetbootStep string
+
+const (
+	// NetbootStepDHCP is a DHCP lease sent to the machine.
+	NetbootStepDHCP NetbootStep = "DHCP"
+	// NetbootStepBinary is an iPXE binary or boot file downloaded by the machine.
+	NetbootStepBinary NetbootStep = "Binary"
+	// NetbootStepScript is an iPXE script or GRUB configuration served to the machine.
+	NetbootStepScript NetbootStep = "Script"
+	// NetbootStepISO is an ISO served to the machine.
+	NetbootStepISO NetbootStep = "ISO"
+)
+
+// NetbootStatus is the netboot progress of a machine. Smee records the most recent time of each step.
+type NetbootStatus struct {
+	// LastStep is the most recent step, for example Binary for a machine that downloaded iPXE but didn't request a script.
+	//+optional
+	LastStep NetbootStep `json:"lastStep,omitempty"`
+	// BootCount is the number of times the machine started to netboot, counted by the DHCP discovers of its firmware.
+	//+optional
+	BootCount int64 `json:"bootCount,omitempty"`
+	// DHCP is the most recent DHCP lease sent to the machine.
+	//+optional
+	DHCP *NetbootDHCP `json:"dhcp,omitempty"`
+	// Binary is the most recent iPXE binary or boot file downloaded by the machine.
+	//+optional
+	Binary *NetbootFile `json:"binary,omitempty"`
+	// Script is the most recent iPXE script or GRUB configuration served to the machine.
+	//+optional
+	Script *NetbootFile `json:"script,omitempty"`
+	// ISO is the most recent ISO served to the machine.
+	//+optional
+	ISO *NetbootFile `json:"iso,omitempty"`
+}
+
+// NetbootDHCP is a DHCP lease sent to a machine.
+type NetbootDHCP struct {
+	// Time the lease was sent.
+	Time metav1.Time `json:"time"`
+	// MessageType is the type of the DHCP message, for example OFFER or ACK.
+	//+optional
+	MessageType string `json:"messageType,omitempty"`
+	// MAC is the MAC address of the machine's interface.
+	//+optional
+	MAC string `json:"mac,omitempty"`
+	// IP is the IP address in the lease.
+	//+optional
+	IP string `json:"ip,omitempty"`
+	// Interface is the name of Smee's network interface that received the DHCP request.
+	//+optional
+	Interface string `json:"interface,omitempty"`
+}
+
+// NetbootFile is a file served to a machine.
+type NetbootFile struct {
+	// Time the file was served.
+	Time metav1.Time `json:"time"`
+	// Name of the file.
+	//+optional
+	Name string `json:"name,omitempty"`
+	// Protocol the file was served with, http or tftp.
+	//+optional
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// HardwareInventory is the hardware inventory of a machine as reported by an Agent.
+type HardwareInventory struct {
+	// UpdatedAt is the time the inventory last changed.
+	//+optional
+	UpdatedAt *metav1.Time `json:"updatedAt,omitempty"`
+	//+optional
+	CPU *InventoryCPU `json:"cpu,omitempty"`
+	//+optional
+	Memory *InventoryMemory `json:"memory,omitempty"`
+	//+optional
+	Disks []InventoryDisk `json:"disks,omitempty"`
+	//+optional
+	NICs []InventoryNIC `json:"nics,omitempty"`
+	//+optional
+	PCIDevices []InventoryPCIDevice `json:"pciDevices,omitempty"`
+	//+optional
+	BIOS *InventoryBIOS `json:"bios,omitempty"`
+	//+optional
+	Baseboard *InventoryBaseboard `json:"baseboard,omitempty"`
+}
+
+// InventoryCPU describes the processors of a machine.
+type InventoryCPU struct {
+	Cores      int64                `json:"cores,omitempty"`
+	Threads    int64                `json:"threads,omitempty"`
+	Processors []InventoryProcessor `json:"processors,omitempty"`
+}
+
+// InventoryProcessor describes a single physical processor.
+type InventoryProcessor struct {
+	ID      int64  `json:"id"`
+	Cores   int64  `json:"cores,omitempty"`
+	Threads int64  `json:"threads,omitempty"`
+	Vendor  string `json:"vendor,omitempty"`
+	Model   string `json:"model,omitempty"`
+}
+
+// InventoryMemory describes the memory of a machine.
+// Sizes are in the format reported by the Agent, for example 16GB.
+type InventoryMemory struct {
+	Total  string `json:"total,omitempty"`
+	Usable string `json:"usable,omitempty"`
+}
+
+// InventoryDisk describes a block device.
+type InventoryDisk struct {
+	Name           string `json:"name"`
+	Size           string `json:"size,omitempty"`
+	DriveType      string `json:"driveType,omitempty"`
+	ControllerType string `json:"controllerType,omitempty"`
+	Vendor         string `json:"vendor,omitempty"`
+	Model          string `json:"model,omitempty"`
+	SerialNumber   string `json:"serialNumber,omitempty"`
+	WWN            string `json:"wwn,omitempty"`
+}
+
+// InventoryNIC describes a network interface.
+type InventoryNIC struct {
+	Name  string `json:"name"`
+	MAC   string `json:"mac,omitempty"`
+	Speed string `json:"speed,omitempty"`
+}
+
+// InventoryPCIDevice describes a PCI device.
+type InventoryPCIDevice struct {
+	Vendor  string `json:"vendor,omitempty"`
+	Product string `json:"product,omitempty"`
+	Class   string `json:"class,omitempty"`
+	Driver  string `json:"driver,omitempty"`
+}
+
+// InventoryBIOS describes the BIOS of a machine.
+type InventoryBIOS struct {
+	Vendor      string `json:"vendor,omitempty"`
+	Version     string `json:"version,omitempty"`
+	ReleaseDate string `json:"releaseDate,omitempty"`
+}
+
+// InventoryBaseboard describes the baseboard of a machine.
+type InventoryBaseboard struct {
+	Vendor       string `json:"vendor,omitempty"`
+	Product      string `json:"product,omitempty"`
+	Version      string `json:"version,omitempty"`
+	SerialNumber string `json:"serialNumber,omitempty"`
+}
+
+// HasCondition checks if the hct condition is present with status cs.
+func (h *HardwareStatus) HasCondition(hct HardwareConditionType, cs metav1.ConditionStatus) bool {
+	for _, c := range h.Conditions {
+		if c.Type == hct {
+			return c.Status == cs
+		}
+	}
+
+	return false
+}
+
+// SetCondition updates conditions. If the condition already exists, it updates it.
+// If the condition doesn't exist then it appends the new one (hc).
+func (h *HardwareStatus) SetCondition(hc HardwareCondition) {
+	for i, c := range h.Conditions {
+		if c.Type == hc.Type {
+			h.Conditions[i] = hc
+			return
+		}
+	}
+
+	h.Conditions = append(h.Conditions, hc)
 }
 
 // AutoCapabilities defines the configuration for the automatic capabilities of this Hardware.
@@ -367,3 +701,34 @@ type AutoCapabilities struct {
 	// +kubebuilder:default=false
 	EnrollmentEnabled bool `json:"enrollmentEnabled,omitempty"`
 }
+
+// IPAddresses returns all IP addresses of the DHCP configuration, the address in IP followed by the IPv6 addresses.
+// IPv6 addresses are returned in their canonical form so that they can be compared with addresses seen on the network.
+func (d *DHCP) IPAddresses() []string {
+	if d == nil {
+		return nil
+	}
+	var ips []string
+	if d.IP != nil && d.IP.Address != "" {
+		ips = append(ips, canonicalIP(d.IP.Address))
+	}
+	if d.IPv6 != nil {
+		for _, a := range d.IPv6.Addresses {
+			if a != "" {
+				ips = append(ips, canonicalIP(a))
+			}
+		}
+	}
+
+	return ips
+}
+
+// canonicalIP returns the canonical form of an IP address. Values that are not IP addresses are returned unchanged.
+func canonicalIP(s string) string {
+	a, err := netip.ParseAddr(s)
+	if err != nil {
+		return s
+	}
+
+	return a.String()
+}
@@ -0,0 +1,39 @@
+package tinkerbell
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=ipxescripts,scope=Namespaced,categories=tinkerbell,shortName=ipxe,singular=ipxescript
+// +kubebuilder:storageversion
+// +kubebuilder:printcolumn:JSONPath=".spec.facilities",name=Facilities,type=string
+
+// IPXEScript is a named iPXE script template that Smee serves to Hardware instead of the built-in Hook script.
+type IPXEScript struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec IPXEScriptSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// IPXEScriptList contains a list of IPXEScript.
+type IPXEScriptList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []IPXEScript `json:"items"`
+}
+
+// IPXEScriptSpec defines the iPXE script template and the Hardware it applies to.
+type IPXEScriptSpec struct {
+	// Script is a Go text/template that renders to an iPXE script.
+	// It has access to the same values as the built-in Hook script, for example {{ .DownloadURL }}, {{ .Arch }} and {{ .WorkerID }},
+	// and to the full Hardware object as {{ .Hardware }}.
+	// +kubebuilder:validation:MinLength=1
+	Script string `json:"script"`
+	// Facilities are the facility codes of the Hardware this script is served to, when the Hardware doesn't reference a script itself.
+	// The facility code of a Hardware is set in .spec.metadata.facility.facility_code.
+	// When more than one IPXEScript in a namespace matches a facility, the first by name is used.
+	// +optional
+	Facilities []string `json:"facilities,omitempty"`
+}
@@ -15,10 +15,89 @@ const (
 	TemplateReady = TemplateState("Ready")
 )
 
+// TemplateParameterType is the type of a Template parameter value.
+type TemplateParameterType string
+
+const (
+	// TemplateParameterString is a string parameter.
+	TemplateParameterString = TemplateParameterType("string")
+
+	// TemplateParameterInteger is a whole number parameter.
+	TemplateParameterInteger = TemplateParameterType("integer")
+
+	// TemplateParameterNumber is a floating point parameter.
+	TemplateParameterNumber = TemplateParameterType("number")
+
+	// TemplateParameterBoolean is a true/false parameter.
+	TemplateParameterBoolean = TemplateParameterType("boolean")
+
+	// TemplateParameterArray is a list parameter.
+	TemplateParameterArray = TemplateParameterType("array")
+
+	// TemplateParameterObject is a map parameter.
+	TemplateParameterObject = TemplateParameterType("object")
+)
+
 // TemplateSpec defines the desired state of Template.
 type TemplateSpec struct {
 	// +optional
 	Data *string `json:"data,omitempty"`
+
+	// Parameters declares the values a Workflow must or may supply when using this Template.
+	// Resolved parameters are available in the Template under .parameters, for example {{ .parameters.disk }}.
+	// Parameters are validated and defaulted before the Template is rendered.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Parameters []TemplateParameter `json:"parameters,omitempty"`
+}
+
+// TemplateParameter defines a single typed input to a Template.
+type TemplateParameter struct {
+	// Name of the parameter. This is the key used to access the value in the Template.
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z_][a-zA-Z0-9_]*$`
+	Name string `json:"name"`
+
+	// Type of the parameter value.
+	// +kubebuilder:validation:Enum=string;integer;number;boolean;array;object
+	// +kubebuilder:default=string
+	// +optional
+	Type TemplateParameterType `json:"type,omitempty"`
+
+	// Required indicates that a Workflow must supply a value when no Default is defined.
+	// +optional
+	Required bool `json:"required,omitempty"`
+
+	// Default is the value used when a Workflow does not supply one.
+	// +optional
+	Default *ParameterValue `json:"default,omitempty"`
+
+	// Description is a human readable explanation of the parameter.
+	// +optional
+	Description string `json:"description,omitempty"`
+}
+
+// ParameterValue holds an arbitrary JSON value (string, number, boolean, array, or object) for a Template parameter.
+// +kubebuilder:validation:Type=""
+// +kubebuilder:pruning:PreserveUnknownFields
+type ParameterValue struct {
+	Raw []byte `json:"-"`
+}
+
+// MarshalJSON returns the raw JSON value.
+func (p ParameterValue) MarshalJSON() ([]byte, error) {
+	if len(p.Raw) == 0 {
+		return []byte("null"), nil
+	}
+	return p.Raw, nil
+}
+
+// UnmarshalJSON stores the raw JSON value.
+func (p *ParameterValue) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && string(b) != "null" {
+		p.Raw = append(p.Raw[0:0], b...)
+	}
+	return nil
 }
 
 // TemplateStatus defines the observed state of Template.
@@ -30,6 +30,7 @@ const (
 	ToggleAllowNetbootTrue  WorkflowConditionType = "AllowNetbootTrue"
 	ToggleAllowNetbootFalse WorkflowConditionType = "AllowNetbootFalse"
 	TemplateRenderedSuccess WorkflowConditionType = "TemplateRenderedSuccess"
+	TemplateParametersValid WorkflowConditionType = "TemplateParametersValid"
 
 	TemplateRenderingSuccessful TemplateRendering = "successful"
 	TemplateRenderingFailed     TemplateRendering = "failed"
@@ -85,6 +86,11 @@ type WorkflowSpec struct {
 	// A mapping of template devices to hardware mac addresses.
 	HardwareMap map[string]string `json:"hardwareMap,omitempty"`
 
+	// Parameters are typed values for the parameters declared by the referenced Template.
+	// A declared parameter not found here is looked up in HardwareMap and converted to the declared type.
+	// +optional
+	Parameters map[string]ParameterValue `json:"parameters,omitempty"`
+
 	// BootOptions are options that control the booting of Hardware.
 	// These are only applicable when a HardwareRef is provided.
 	BootOptions BootOptions `json:"bootOptions,omitempty,omitzero"`
@@ -6,6 +6,11 @@ import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 // +kubebuilder:object:root=true
 // +kubebuilder:resource:path=workflowrulesets,scope=Namespaced,categories=tinkerbell,shortName=wrs,singular=workflowruleset
 // +kubebuilder:storageversion
+// +kubebuilder:printcolumn:JSONPath=".spec.priority",name=Priority,type=integer
+// +kubebuilder:printcolumn:JSONPath=".spec.workflow.template.ref",name=Template,type=string
+// +kubebuilder:printcolumn:JSONPath=".status.matchCount",name=Matches,type=integer
+// +kubebuilder:printcolumn:JSONPath=".status.lastMatchedAgentID",name=Last-Agent,type=string,priority=1
+// +kubebuilder:printcolumn:JSONPath=".status.lastMatchedTime",name=Last-Matched,type=date
 
 // Workflow is the Schema for the Workflows API.
 type WorkflowRuleSet struct {
@@ -29,9 +34,18 @@ type WorkflowRuleSetList struct {
 type WorkflowRuleSetSpec struct {
 	// Rules is a list of Quamina patterns used to match against the attributes of an Agent.
 	// See https://github.com/timbray/quamina/blob/main/PATTERNS.md for more information on the required format.
-	// All rules are combined using the OR operator.
+	// All rules are combined using the OR operator, unless MatchAll is true.
 	// If any rule matches, the corresponding Workflow will be created.
 	Rules []string `json:"rules,omitempty"`
+	// MatchAll requires every rule to match, combining all rules using the AND operator.
+	// +optional
+	MatchAll bool `json:"matchAll,omitempty"`
+	// Priority determines the order in which WorkflowRuleSets are considered.
+	// A matching WorkflowRuleSet with a higher Priority is always chosen over one with a lower Priority.
+	// Matching WorkflowRuleSets with the same Priority are ranked by the number of matching rules and then by namespace and name.
+	// +kubebuilder:default=0
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
 	// Workflow holds the data used to configure the created Workflow.
 	Workflow WorkflowRuleSetWorkflow `json:"workflow,omitempty"`
 }
@@ -49,6 +63,38 @@ type WorkflowRuleSetWorkflow struct {
 	AddAttributes bool `json:"addAttributes,omitempty"`
 	// Template is the Template specific configuration to use when creating the Workflow.
 	Template TemplateConfig `json:"template,omitempty"`
+	// ReEnrollmentPolicy determines whether a new Workflow is created for an Agent whose most recent Workflow has finished.
+	// Each re-enrollment creates a new Workflow so that previous Workflows are kept as history.
+	// +kubebuilder:default=never
+	// +optional
+	ReEnrollmentPolicy ReEnrollmentPolicy `json:"reEnrollmentPolicy,omitempty"`
+}
+
+// ReEnrollmentPolicy defines when an Agent with a finished Workflow is auto enrolled again.
+// +kubebuilder:validation:Enum=never;afterSuccess;afterFailure;always
+type ReEnrollmentPolicy string
+
+const (
+	// ReEnrollmentNever never creates another Workflow for an Agent that already has one.
+	ReEnrollmentNever ReEnrollmentPolicy = "never"
+	// ReEnrollmentAfterSuccess creates a new Workflow when the most recent Workflow succeeded.
+	ReEnrollmentAfterSuccess ReEnrollmentPolicy = "afterSuccess"
+	// ReEnrollmentAfterFailure creates a new Workflow when the most recent Workflow failed or timed out.
+	ReEnrollmentAfterFailure ReEnrollmentPolicy = "afterFailure"
+	// ReEnrollmentAlways creates a new Workflow whenever the most recent Workflow has finished.
+	ReEnrollmentAlways ReEnrollmentPolicy = "always"
+)
+
+// Allows reports whether p allows re-enrollment after a Workflow that finished in state.
+func (p ReEnrollmentPolicy) Allows(state WorkflowState) bool {
+	switch state {
+	case WorkflowStateSuccess:
+		return p == ReEnrollmentAfterSuccess || p == ReEnrollmentAlways
+	case WorkflowStateFailed, WorkflowStateTimeout:
+		return p == ReEnrollmentAfterFailure || p == ReEnrollmentAlways
+	default:
+		return false
+	}
 }
 
 // TemplateConfig defines the Template specific configuration to use when creating the Workflow.
@@ -59,8 +105,29 @@ type TemplateConfig struct {
 	// KVs are a mapping of key/value pairs usable in the referenced Template.
 	// +optional
 	KVs map[string]string `json:"kvs,omitempty"`
+	// Parameters are typed values for the parameters declared by the referenced Template.
+	// They are copied to the created Workflow's spec.parameters.
+	// +optional
+	Parameters map[string]ParameterValue `json:"parameters,omitempty"`
 	// Ref is the name of an existing in cluster Template object to use in the Workflow.
 	Ref string `json:"ref,omitempty"`
 }
 
-type WorkflowRuleSetStatus struct{}
+// WorkflowRuleSetStatus defines the observed state of a WorkflowRuleSet.
+type WorkflowRuleSetStatus struct {
+	// MatchCount is the number of times this WorkflowRuleSet was chosen and a Workflow was created from it.
+	// +optional
+	MatchCount int64 `json:"matchCount,omitempty"`
+	// LastMatchedAgentID is the ID of the last Agent for which a Workflow was created from this WorkflowRuleSet.
+	// +optional
+	LastMatchedAgentID string `json:"lastMatchedAgentID,omitempty"`
+	// LastMatchedTime is the time a Workflow was last created from this WorkflowRuleSet.
+	// +optional
+	LastMatchedTime *metav1.Time `json:"lastMatchedTime,omitempty"`
+	// LastError is the last error encountered while evaluating this WorkflowRuleSet or creating a Workflow from it.
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+	// LastErrorTime is the time LastError was recorded.
+	// +optional
+	LastErrorTime *metav1.Time `json:"lastErrorTime,omitempty"`
+}
@@ -213,11 +213,26 @@ func (in *DHCP) DeepCopyInto(out *DHCP) {
 		*out = new(IP)
 		**out = **in
 	}
+	if in.IPv6 != nil {
+		in, out := &in.IPv6, &out.IPv6
+		*out = new(IPv6)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RelayAgentInfo != nil {
+		in, out := &in.RelayAgentInfo, &out.RelayAgentInfo
+		*out = new(RelayAgentInfo)
+		**out = **in
+	}
 	if in.ClasslessStaticRoutes != nil {
 		in, out := &in.ClasslessStaticRoutes, &out.ClasslessStaticRoutes
 		*out = make([]ClasslessStaticRoute, len(*in))
 		copy(*out, *in)
 	}
+	if in.Options != nil {
+		in, out := &in.Options, &out.Options
+		*out = make([]DHCPOption, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DHCP.
@@ -230,6 +245,21 @@ func (in *DHCP) DeepCopy() *DHCP {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DHCPOption) DeepCopyInto(out *DHCPOption) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DHCPOption.
+func (in *DHCPOption) DeepCopy() *DHCPOption {
+	if in == nil {
+		return nil
+	}
+	out := new(DHCPOption)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Disk) DeepCopyInto(out *Disk) {
 	*out = *in
@@ -251,7 +281,7 @@ func (in *Hardware) DeepCopyInto(out *Hardware) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Hardware.
@@ -272,6 +302,79 @@ func (in *Hardware) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HardwareCondition) DeepCopyInto(out *HardwareCondition) {
+	*out = *in
+	if in.Time != nil {
+		in, out := &in.Time, &out.Time
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwareCondition.
+func (in *HardwareCondition) DeepCopy() *HardwareCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(HardwareCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HardwareInventory) DeepCopyInto(out *HardwareInventory) {
+	*out = *in
+	if in.UpdatedAt != nil {
+		in, out := &in.UpdatedAt, &out.UpdatedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.CPU != nil {
+		in, out := &in.CPU, &out.CPU
+		*out = new(InventoryCPU)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Memory != nil {
+		in, out := &in.Memory, &out.Memory
+		*out = new(InventoryMemory)
+		**out = **in
+	}
+	if in.Disks != nil {
+		in, out := &in.Disks, &out.Disks
+		*out = make([]InventoryDisk, len(*in))
+		copy(*out, *in)
+	}
+	if in.NICs != nil {
+		in, out := &in.NICs, &out.NICs
+		*out = make([]InventoryNIC, len(*in))
+		copy(*out, *in)
+	}
+	if in.PCIDevices != nil {
+		in, out := &in.PCIDevices, &out.PCIDevices
+		*out = make([]InventoryPCIDevice, len(*in))
+		copy(*out, *in)
+	}
+	if in.BIOS != nil {
+		in, out := &in.BIOS, &out.BIOS
+		*out = new(InventoryBIOS)
+		**out = **in
+	}
+	if in.Baseboard != nil {
+		in, out := &in.Baseboard, &out.Baseboard
+		*out = new(InventoryBaseboard)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwareInventory.
+func (in *HardwareInventory) DeepCopy() *HardwareInventory {
+	if in == nil {
+		return nil
+	}
+	out := new(HardwareInventory)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HardwareList) DeepCopyInto(out *HardwareList) {
 	*out = *in
@@ -404,6 +507,23 @@ func (in *HardwareSpec) DeepCopy() *HardwareSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HardwareStatus) DeepCopyInto(out *HardwareStatus) {
 	*out = *in
+	if in.Inventory != nil {
+		in, out := &in.Inventory, &out.Inventory
+		*out = new(HardwareInventory)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Netboot != nil {
+		in, out := &in.Netboot, &out.Netboot
+		*out = new(NetbootStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]HardwareCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwareStatus.
@@ -434,6 +554,11 @@ func (in *IP) DeepCopy() *IP {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IPXE) DeepCopyInto(out *IPXE) {
 	*out = *in
+	if in.Menu != nil {
+		in, out := &in.Menu, &out.Menu
+		*out = make([]IPXEMenuEntry, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPXE.
@@ -446,6 +571,119 @@ func (in *IPXE) DeepCopy() *IPXE {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPXEMenuEntry) DeepCopyInto(out *IPXEMenuEntry) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPXEMenuEntry.
+func (in *IPXEMenuEntry) DeepCopy() *IPXEMenuEntry {
+	if in == nil {
+		return nil
+	}
+	out := new(IPXEMenuEntry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPXEScript) DeepCopyInto(out *IPXEScript) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPXEScript.
+func (in *IPXEScript) DeepCopy() *IPXEScript {
+	if in == nil {
+		return nil
+	}
+	out := new(IPXEScript)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *IPXEScript) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPXEScriptList) DeepCopyInto(out *IPXEScriptList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]IPXEScript, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPXEScriptList.
+func (in *IPXEScriptList) DeepCopy() *IPXEScriptList {
+	if in == nil {
+		return nil
+	}
+	out := new(IPXEScriptList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *IPXEScriptList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPXEScriptSpec) DeepCopyInto(out *IPXEScriptSpec) {
+	*out = *in
+	if in.Facilities != nil {
+		in, out := &in.Facilities, &out.Facilities
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPXEScriptSpec.
+func (in *IPXEScriptSpec) DeepCopy() *IPXEScriptSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(IPXEScriptSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPv6) DeepCopyInto(out *IPv6) {
+	*out = *in
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPv6.
+func (in *IPv6) DeepCopy() *IPv6 {
+	if in == nil {
+		return nil
+	}
+	out := new(IPv6)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Interface) DeepCopyInto(out *Interface) {
 	*out = *in
@@ -476,6 +714,131 @@ func (in *Interface) DeepCopy() *Interface {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InventoryBIOS) DeepCopyInto(out *InventoryBIOS) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InventoryBIOS.
+func (in *InventoryBIOS) DeepCopy() *InventoryBIOS {
+	if in == nil {
+		return nil
+	}
+	out := new(InventoryBIOS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InventoryBaseboard) DeepCopyInto(out *InventoryBaseboard) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InventoryBaseboard.
+func (in *InventoryBaseboard) DeepCopy() *InventoryBaseboard {
+	if in == nil {
+		return nil
+	}
+	out := new(InventoryBaseboard)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InventoryCPU) DeepCopyInto(out *InventoryCPU) {
+	*out = *in
+	if in.Processors != nil {
+		in, out := &in.Processors, &out.Processors
+		*out = make([]InventoryProcessor, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InventoryCPU.
+func (in *InventoryCPU) DeepCopy() *InventoryCPU {
+	if in == nil {
+		return nil
+	}
+	out := new(InventoryCPU)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InventoryDisk) DeepCopyInto(out *InventoryDisk) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InventoryDisk.
+func (in *InventoryDisk) DeepCopy() *InventoryDisk {
+	if in == nil {
+		return nil
+	}
+	out := new(InventoryDisk)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InventoryMemory) DeepCopyInto(out *InventoryMemory) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InventoryMemory.
+func (in *InventoryMemory) DeepCopy() *InventoryMemory {
+	if in == nil {
+		return nil
+	}
+	out := new(InventoryMemory)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InventoryNIC) DeepCopyInto(out *InventoryNIC) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InventoryNIC.
+func (in *InventoryNIC) DeepCopy() *InventoryNIC {
+	if in == nil {
+		return nil
+	}
+	out := new(InventoryNIC)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InventoryPCIDevice) DeepCopyInto(out *InventoryPCIDevice) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InventoryPCIDevice.
+func (in *InventoryPCIDevice) DeepCopy() *InventoryPCIDevice {
+	if in == nil {
+		return nil
+	}
+	out := new(InventoryPCIDevice)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InventoryProcessor) DeepCopyInto(out *InventoryProcessor) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InventoryProcessor.
+func (in *InventoryProcessor) DeepCopy() *InventoryProcessor {
+	if in == nil {
+		return nil
+	}
+	out := new(InventoryProcessor)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Isoboot) DeepCopyInto(out *Isoboot) {
 	*out = *in
@@ -848,11 +1211,16 @@ func (in *Netboot) DeepCopyInto(out *Netboot) {
 	if in.IPXE != nil {
 		in, out := &in.IPXE, &out.IPXE
 		*out = new(IPXE)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.OSIE != nil {
 		in, out := &in.OSIE, &out.OSIE
 		*out = new(OSIE)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecureBoot != nil {
+		in, out := &in.SecureBoot, &out.SecureBoot
+		*out = new(bool)
 		**out = **in
 	}
 }
@@ -867,9 +1235,86 @@ func (in *Netboot) DeepCopy() *Netboot {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetbootDHCP) DeepCopyInto(out *NetbootDHCP) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetbootDHCP.
+func (in *NetbootDHCP) DeepCopy() *NetbootDHCP {
+	if in == nil {
+		return nil
+	}
+	out := new(NetbootDHCP)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetbootFile) DeepCopyInto(out *NetbootFile) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetbootFile.
+func (in *NetbootFile) DeepCopy() *NetbootFile {
+	if in == nil {
+		return nil
+	}
+	out := new(NetbootFile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetbootStatus) DeepCopyInto(out *NetbootStatus) {
+	*out = *in
+	if in.DHCP != nil {
+		in, out := &in.DHCP, &out.DHCP
+		*out = new(NetbootDHCP)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Binary != nil {
+		in, out := &in.Binary, &out.Binary
+		*out = new(NetbootFile)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Script != nil {
+		in, out := &in.Script, &out.Script
+		*out = new(NetbootFile)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ISO != nil {
+		in, out := &in.ISO, &out.ISO
+		*out = new(NetbootFile)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetbootStatus.
+func (in *NetbootStatus) DeepCopy() *NetbootStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NetbootStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OSIE) DeepCopyInto(out *OSIE) {
 	*out = *in
+	if in.KernelParams != nil {
+		in, out := &in.KernelParams, &out.KernelParams
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Consoles != nil {
+		in, out := &in.Consoles, &out.Consoles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSIE.
@@ -882,6 +1327,26 @@ func (in *OSIE) DeepCopy() *OSIE {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ParameterValue) DeepCopyInto(out *ParameterValue) {
+	*out = *in
+	if in.Raw != nil {
+		in, out := &in.Raw, &out.Raw
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ParameterValue.
+func (in *ParameterValue) DeepCopy() *ParameterValue {
+	if in == nil {
+		return nil
+	}
+	out := new(ParameterValue)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Reference) DeepCopyInto(out *Reference) {
 	*out = *in
@@ -897,6 +1362,21 @@ func (in *Reference) DeepCopy() *Reference {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RelayAgentInfo) DeepCopyInto(out *RelayAgentInfo) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RelayAgentInfo.
+func (in *RelayAgentInfo) DeepCopy() *RelayAgentInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(RelayAgentInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Task) DeepCopyInto(out *Task) {
 	*out = *in
@@ -968,6 +1448,13 @@ func (in *TemplateConfig) DeepCopyInto(out *TemplateConfig) {
 			(*out)[key] = val
 		}
 	}
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]ParameterValue, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateConfig.
@@ -1012,6 +1499,26 @@ func (in *TemplateList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateParameter) DeepCopyInto(out *TemplateParameter) {
+	*out = *in
+	if in.Default != nil {
+		in, out := &in.Default, &out.Default
+		*out = new(ParameterValue)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateParameter.
+func (in *TemplateParameter) DeepCopy() *TemplateParameter {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateParameter)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TemplateSpec) DeepCopyInto(out *TemplateSpec) {
 	*out = *in
@@ -1020,6 +1527,13 @@ func (in *TemplateSpec) DeepCopyInto(out *TemplateSpec) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make([]TemplateParameter, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateSpec.
@@ -1131,7 +1645,7 @@ func (in *WorkflowRuleSet) DeepCopyInto(out *WorkflowRuleSet) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkflowRuleSet.
@@ -1208,6 +1722,14 @@ func (in *WorkflowRuleSetSpec) DeepCopy() *WorkflowRuleSetSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkflowRuleSetStatus) DeepCopyInto(out *WorkflowRuleSetStatus) {
 	*out = *in
+	if in.LastMatchedTime != nil {
+		in, out := &in.LastMatchedTime, &out.LastMatchedTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastErrorTime != nil {
+		in, out := &in.LastErrorTime, &out.LastErrorTime
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkflowRuleSetStatus.
@@ -1256,6 +1778,13 @@ func (in *WorkflowSpec) DeepCopyInto(out *WorkflowSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]ParameterValue, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
 	in.BootOptions.DeepCopyInto(&out.BootOptions)
 }
 
@@ -7,10 +7,12 @@ import (
 	"errors"
 	"fmt"
 	"net/netip"
+	"os"
 	"time"
 
 	"github.com/avast/retry-go/v4"
 	"github.com/go-logr/logr"
+	"github.com/insomniacslk/dhcp/iana"
 	"github.com/peterbourgon/ff/v4"
 	"github.com/peterbourgon/ff/v4/ffhelp"
 	"github.com/tinkerbell/tinkerbell/cmd/tinkerbell/flag"
@@ -26,6 +28,7 @@ import (
 	"github.com/tinkerbell/tinkerbell/ui"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc/credentials"
+	"k8s.io/client-go/rest"
 )
 
 var (
@@ -135,15 +138,27 @@ func Execute(ctx context.Context, cancel context.CancelFunc, args []string) erro
 		flag.RegisterEmbeddedGlobals(&flag.Set{FlagSet: gfs}, globals)
 	}
 
+	simc := &flag.SimulateConfig{Arch: uint(iana.EFI_X86_64)}
+	simfs := ff.NewFlagSet("simulate - Offline netboot simulation").SetParent(gfs)
+	flag.RegisterSimulateFlags(&flag.Set{FlagSet: simfs}, simc)
+	simulate := &ff.Command{
+		Name:      "simulate",
+		Usage:     "tinkerbell simulate [flags] <mac>",
+		ShortHelp: "print the DHCP replies and iPXE script Smee would send a machine, without using the network",
+		LongHelp:  "Simulate the netboot of the machine with the MAC address through Smee's DHCP and iPXE script handlers, using the Hardware in the backend.",
+		Flags:     simfs,
+	}
+
 	cli := &ff.Command{
-		Name:     "tinkerbell",
-		Usage:    "tinkerbell [flags]",
-		LongHelp: "Tinkerbell stack.",
-		Flags:    gfs,
+		Name:        "tinkerbell",
+		Usage:       "tinkerbell [flags]",
+		LongHelp:    "Tinkerbell stack.",
+		Flags:       gfs,
+		Subcommands: []*ff.Command{simulate},
 	}
 
 	if err := cli.Parse(args, ff.WithEnvVarPrefix("TINKERBELL")); err != nil {
-		e := errors.New(ffhelp.Command(cli).String())
+		e := errors.New(ffhelp.Command(ternary(cli.GetSelected() != nil, cli.GetSelected(), cli)).String())
 		if !errors.Is(err, ff.ErrHelp) {
 			e = fmt.Errorf("%w\n%s", e, err)
 		}
@@ -193,6 +208,10 @@ func Execute(ctx context.Context, cancel context.CancelFunc, args []string) erro
 		s.Config.TLS.Certs = []tls.Certificate{cert}
 	}
 
+	if cli.GetSelected() == simulate {
+		return runSimulate(ctx, log.WithName("simulate"), globals, s, simc, simfs.GetArgs(), os.Stdout)
+	}
+
 	// Tink Server
 	ts.Convert(globals.BindAddr)
 	// Configure TLS if cert and key files are provided
@@ -278,6 +297,9 @@ func Execute(ctx context.Context, cancel context.CancelFunc, args []string) erro
 	if numEnabled(globals) == 0 {
 		globals.Backend = "pass"
 	}
+	// kubeConfig is the rest config of the kube backend. HTTP handlers that authenticate their clients
+	// with Kubernetes tokens are only served when it's set.
+	var kubeConfig *rest.Config
 	switch globals.Backend {
 	case "kube":
 		if globals.EnableCRDMigrations {
@@ -306,7 +328,12 @@ func Execute(ctx context.Context, cancel context.CancelFunc, args []string) erro
 		if err != nil {
 			return fmt.Errorf("failed to create kube backend: %w", err)
 		}
+		kubeConfig = b.ClientConfig
 		s.Config.Backend = b
+		s.Config.DHCP.HA.KubeConfig = b.ClientConfig
+		if s.Config.DHCP.HA.LeaseNamespace == "" {
+			s.Config.DHCP.HA.LeaseNamespace = ternary(globals.BackendKubeNamespace != "", globals.BackendKubeNamespace, defaultLeaderElectionNamespace)
+		}
 		h.Config.SetBackendFromFilterer(b)
 		ts.Config.SetBackends(b)
 		tc.Config.Client = b.ClientConfig
@@ -368,7 +395,7 @@ func Execute(ctx context.Context, cancel context.CancelFunc, args []string) erro
 
 	// HTTP server
 	g.Go(func() error {
-		return startHTTPServer(ctx, globals, s, h, uic, startTime)
+		return startHTTPServer(ctx, globals, s, h, ts, uic, kubeConfig, startTime)
 	})
 
 	// Tink Server
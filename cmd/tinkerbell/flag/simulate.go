@@ -0,0 +1,33 @@
+package flag
+
+import (
+	"github.com/peterbourgon/ff/v4/ffval"
+)
+
+// SimulateConfig is the configuration of the simulate subcommand.
+type SimulateConfig struct {
+	Arch        uint
+	UserClass   string
+	VendorClass string
+}
+
+func RegisterSimulateFlags(fs *Set, sc *SimulateConfig) {
+	fs.Register(SimulateArch, ffval.NewValueDefault(&sc.Arch, sc.Arch))
+	fs.Register(SimulateUserClass, ffval.NewValueDefault(&sc.UserClass, sc.UserClass))
+	fs.Register(SimulateVendorClass, ffval.NewValueDefault(&sc.VendorClass, sc.VendorClass))
+}
+
+var SimulateArch = Config{
+	Name:  "arch",
+	Usage: "[simulate] client system architecture sent in DHCP option 93, for example 0 for x86 BIOS, 7 for x86-64 UEFI, 11 for ARM64 UEFI",
+}
+
+var SimulateUserClass = Config{
+	Name:  "user-class",
+	Usage: "[simulate] user class sent in DHCP option 77, empty for the firmware of most machines, iPXE for a stock iPXE",
+}
+
+var SimulateVendorClass = Config{
+	Name:  "vendor-class",
+	Usage: "[simulate] vendor class identifier sent in DHCP option 60, defaults to the one of a PXE client of the architecture",
+}
@@ -5,6 +5,7 @@ import (
 	"net/netip"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ccoveille/go-safecast/v2"
 	"github.com/insomniacslk/dhcp/iana"
@@ -14,6 +15,7 @@ import (
 	ntip "github.com/tinkerbell/tinkerbell/pkg/flag/netip"
 	"github.com/tinkerbell/tinkerbell/pkg/flag/url"
 	"github.com/tinkerbell/tinkerbell/smee"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 type SmeeConfig struct {
@@ -28,8 +30,9 @@ type SmeeConfig struct {
 }
 
 var KubeIndexesSmee = map[kube.IndexType]kube.Index{
-	kube.IndexTypeMACAddr: kube.Indexes[kube.IndexTypeMACAddr],
-	kube.IndexTypeIPAddr:  kube.Indexes[kube.IndexTypeIPAddr],
+	kube.IndexTypeMACAddr:        kube.Indexes[kube.IndexTypeMACAddr],
+	kube.IndexTypeIPAddr:         kube.Indexes[kube.IndexTypeIPAddr],
+	kube.IndexTypeRelayAgentInfo: kube.Indexes[kube.IndexTypeRelayAgentInfo],
 }
 
 // URLBuilder breaks out the fields of a url.URL so they can be set individually from the CLI.
@@ -61,6 +64,34 @@ func RegisterSmeeFlags(fs *Set, sc *SmeeConfig) {
 	fs.Register(DHCPIPXEHTTPScriptHost, ffval.NewValueDefault(&sc.DHCPIPXEScript.Host, sc.DHCPIPXEScript.Host))
 	fs.Register(DHCPIPXEHTTPScriptPort, ffval.NewValueDefault(&sc.DHCPIPXEScript.Port, sc.DHCPIPXEScript.Port))
 	fs.Register(DHCPIPXEHTTPScriptPath, ffval.NewValueDefault(&sc.Config.DHCP.IPXEHTTPScript.URL.Path, sc.Config.DHCP.IPXEHTTPScript.URL.Path))
+	fs.Register(DHCPPools, &ffval.Value[[]smee.DHCPPool]{
+		ParseFunc: dhcpPoolsParser,
+		Pointer:   &sc.Config.DHCP.Pools,
+		Default:   sc.Config.DHCP.Pools,
+	})
+	fs.Register(DHCPLeaseFile, ffval.NewValueDefault(&sc.Config.DHCP.LeaseFile, sc.Config.DHCP.LeaseFile))
+	fs.Register(DHCPOptions, &ffval.Value[[]smee.DHCPOption]{
+		ParseFunc: dhcpOptionsParser,
+		Pointer:   &sc.Config.DHCP.Options,
+		Default:   sc.Config.DHCP.Options,
+	})
+	fs.Register(DHCPTrustedRelays, &ntip.PrefixList{PrefixList: &sc.Config.DHCP.TrustedRelays})
+	fs.Register(DHCPHAMode, &sc.Config.DHCP.HA.Mode)
+	fs.Register(DHCPHALeaseName, ffval.NewValueDefault(&sc.Config.DHCP.HA.LeaseName, sc.Config.DHCP.HA.LeaseName))
+	fs.Register(DHCPHALeaseNamespace, ffval.NewValueDefault(&sc.Config.DHCP.HA.LeaseNamespace, sc.Config.DHCP.HA.LeaseNamespace))
+	fs.Register(DHCPHAReplicas, ffval.NewValueDefault(&sc.Config.DHCP.HA.Replicas, sc.Config.DHCP.HA.Replicas))
+	fs.Register(DHCPHAReplicaIndex, ffval.NewValueDefault(&sc.Config.DHCP.HA.ReplicaIndex, sc.Config.DHCP.HA.ReplicaIndex))
+	fs.Register(DHCPHAMaxElapsed, ffval.NewValueDefault(&sc.Config.DHCP.HA.MaxElapsed, sc.Config.DHCP.HA.MaxElapsed))
+	fs.Register(DHCPRateLimitPerClient, ffval.NewValueDefault(&sc.Config.DHCP.RateLimit.PerClient, sc.Config.DHCP.RateLimit.PerClient))
+	fs.Register(DHCPRateLimitPerClientBurst, ffval.NewValueDefault(&sc.Config.DHCP.RateLimit.PerClientBurst, sc.Config.DHCP.RateLimit.PerClientBurst))
+	fs.Register(DHCPRateLimitGlobal, ffval.NewValueDefault(&sc.Config.DHCP.RateLimit.Global, sc.Config.DHCP.RateLimit.Global))
+	fs.Register(DHCPRateLimitGlobalBurst, ffval.NewValueDefault(&sc.Config.DHCP.RateLimit.GlobalBurst, sc.Config.DHCP.RateLimit.GlobalBurst))
+	fs.Register(DHCPNotFoundTTL, ffval.NewValueDefault(&sc.Config.DHCP.RateLimit.NotFoundTTL, sc.Config.DHCP.RateLimit.NotFoundTTL))
+
+	// DHCPv6 flags
+	fs.Register(DHCPv6Enabled, ffval.NewValueDefault(&sc.Config.DHCPv6.Enabled, sc.Config.DHCPv6.Enabled))
+	fs.Register(DHCPv6BindAddr, &ntip.Addr{Addr: &sc.Config.DHCPv6.BindAddr})
+	fs.Register(DHCPv6IPForPacket, &ntip.Addr{Addr: &sc.Config.DHCPv6.IPForPacket})
 
 	// IPXE flags
 	fs.Register(IPXEArchMapping, &ffval.Value[map[iana.Arch]constant.IPXEBinary]{
@@ -106,6 +137,14 @@ func RegisterSmeeFlags(fs *Set, sc *SmeeConfig) {
 	fs.Register(IPXEHTTPScriptRetries, ffval.NewValueDefault(&sc.Config.IPXE.HTTPScriptServer.Retries, sc.Config.IPXE.HTTPScriptServer.Retries))
 	fs.Register(IPXEHTTPScriptRetryDelay, ffval.NewValueDefault(&sc.Config.IPXE.HTTPScriptServer.RetryDelay, sc.Config.IPXE.HTTPScriptServer.RetryDelay))
 	fs.Register(IPXEHTTPScriptOSIEURL, &url.URL{URL: sc.Config.IPXE.HTTPScriptServer.OSIEURL})
+	fs.Register(IPXEMenuEnabled, ffval.NewValueDefault(&sc.Config.IPXE.HTTPScriptServer.Menu.Enabled, sc.Config.IPXE.HTTPScriptServer.Menu.Enabled))
+	fs.Register(IPXEMenuTimeout, ffval.NewValueDefault(&sc.Config.IPXE.HTTPScriptServer.Menu.Timeout, sc.Config.IPXE.HTTPScriptServer.Menu.Timeout))
+	fs.Register(IPXEMenuDefault, ffval.NewValueDefault(&sc.Config.IPXE.HTTPScriptServer.Menu.Default, sc.Config.IPXE.HTTPScriptServer.Menu.Default))
+	fs.Register(IPXEMenuEntries, &ffval.Value[[]smee.IPXEMenuEntry]{
+		ParseFunc: ipxeMenuEntriesParser,
+		Pointer:   &sc.Config.IPXE.HTTPScriptServer.Menu.Entries,
+		Default:   sc.Config.IPXE.HTTPScriptServer.Menu.Entries,
+	})
 	fs.Register(IPXEBinaryInjectMacAddrFormat, &ffval.Enum[constant.MACFormat]{
 		ParseFunc: macAddrFormatParser,
 		Valid:     []constant.MACFormat{constant.MacAddrFormatColon, constant.MacAddrFormatDot, constant.MacAddrFormatDash, constant.MacAddrFormatNoDelimiter},
@@ -118,11 +157,45 @@ func RegisterSmeeFlags(fs *Set, sc *SmeeConfig) {
 	fs.Register(TinkServerUseTLS, ffval.NewValueDefault(&sc.Config.TinkServer.UseTLS, sc.Config.TinkServer.UseTLS))
 	fs.Register(TinkServerInsecureTLS, ffval.NewValueDefault(&sc.Config.TinkServer.InsecureTLS, sc.Config.TinkServer.InsecureTLS))
 
+	// OSIE cache Flags
+	fs.Register(OSIECacheEnabled, ffval.NewValueDefault(&sc.Config.OSIECache.Enabled, sc.Config.OSIECache.Enabled))
+	fs.Register(OSIECacheDir, ffval.NewValueDefault(&sc.Config.OSIECache.Dir, sc.Config.OSIECache.Dir))
+	fs.Register(OSIECacheChecksumFile, ffval.NewValueDefault(&sc.Config.OSIECache.ChecksumFile, sc.Config.OSIECache.ChecksumFile))
+	fs.Register(OSIEProfiles, &ffval.Value[[]smee.OSIEProfile]{
+		ParseFunc: osieProfilesParser,
+		Pointer:   &sc.Config.OSIEProfiles,
+		Default:   sc.Config.OSIEProfiles,
+	})
+
+	// Boot files Flags
+	fs.Register(BootFilesDir, ffval.NewValueDefault(&sc.Config.BootFiles.Dir, sc.Config.BootFiles.Dir))
+	fs.Register(BootFilesOCIRef, ffval.NewValueDefault(&sc.Config.BootFiles.OCIRef, sc.Config.BootFiles.OCIRef))
+	fs.Register(BootFilesPathTemplates, &ffval.Value[[]string]{
+		ParseFunc: commaListParser,
+		Pointer:   &sc.Config.BootFiles.PathTemplates,
+		Default:   sc.Config.BootFiles.PathTemplates,
+	})
+
+	// Secure Boot Flags
+	fs.Register(SecureBootShimX86_64, ffval.NewValueDefault(&sc.Config.SecureBoot.ShimX86_64, sc.Config.SecureBoot.ShimX86_64))
+	fs.Register(SecureBootShimARM64, ffval.NewValueDefault(&sc.Config.SecureBoot.ShimARM64, sc.Config.SecureBoot.ShimARM64))
+
+	// Netboot Status Flags
+	fs.Register(NetbootStatusEnabled, ffval.NewValueDefault(&sc.Config.NetbootStatus.Enabled, sc.Config.NetbootStatus.Enabled))
+	fs.Register(NetbootStatusInterval, ffval.NewValueDefault(&sc.Config.NetbootStatus.Interval, sc.Config.NetbootStatus.Interval))
+
 	// ISO Flags
 	fs.Register(ISOEnabled, ffval.NewValueDefault(&sc.Config.ISO.Enabled, sc.Config.ISO.Enabled))
 	fs.Register(ISOUpstreamURL, &url.URL{URL: sc.Config.ISO.UpstreamURL})
 	fs.Register(ISOPatchMagicString, ffval.NewValueDefault(&sc.Config.ISO.PatchMagicString, sc.Config.ISO.PatchMagicString))
 	fs.Register(ISOStaticIPAMEnabled, ffval.NewValueDefault(&sc.Config.ISO.StaticIPAMEnabled, sc.Config.ISO.StaticIPAMEnabled))
+	fs.Register(ISOCacheEnabled, ffval.NewValueDefault(&sc.Config.ISO.Cache.Enabled, sc.Config.ISO.Cache.Enabled))
+	fs.Register(ISOCacheDir, ffval.NewValueDefault(&sc.Config.ISO.Cache.Dir, sc.Config.ISO.Cache.Dir))
+	fs.Register(ISOCacheMaxBytes, ffval.NewValueDefault(&sc.Config.ISO.Cache.MaxBytes, sc.Config.ISO.Cache.MaxBytes))
+	fs.Register(ISOCacheHosts, ffval.NewList(&sc.Config.ISO.Cache.Hosts))
+	fs.Register(ISOGenerateEnabled, ffval.NewValueDefault(&sc.Config.ISO.Generate.Enabled, sc.Config.ISO.Generate.Enabled))
+	fs.Register(ISOGenerateDir, ffval.NewValueDefault(&sc.Config.ISO.Generate.Dir, sc.Config.ISO.Generate.Dir))
+	fs.Register(ISOGenerateMaxBytes, ffval.NewValueDefault(&sc.Config.ISO.Generate.MaxBytes, sc.Config.ISO.Generate.MaxBytes))
 
 	// Log level
 	fs.Register(SmeeLogLevel, ffval.NewValueDefault(&sc.LogLevel, sc.LogLevel))
@@ -131,6 +204,19 @@ func RegisterSmeeFlags(fs *Set, sc *SmeeConfig) {
 	fs.Register(SyslogEnabled, ffval.NewValueDefault(&sc.Config.Syslog.Enabled, sc.Config.Syslog.Enabled))
 	fs.Register(SyslogBindAddr, &ntip.Addr{Addr: &sc.Config.Syslog.BindAddr})
 	fs.Register(SyslogBindPort, ffval.NewValueDefault(&sc.Config.Syslog.BindPort, sc.Config.Syslog.BindPort))
+	fs.Register(SyslogTCPEnabled, ffval.NewValueDefault(&sc.Config.Syslog.TCPEnabled, sc.Config.Syslog.TCPEnabled))
+	fs.Register(SyslogTCPBindPort, ffval.NewValueDefault(&sc.Config.Syslog.TCPBindPort, sc.Config.Syslog.TCPBindPort))
+	fs.Register(SyslogTLSEnabled, ffval.NewValueDefault(&sc.Config.Syslog.TLSEnabled, sc.Config.Syslog.TLSEnabled))
+	fs.Register(SyslogTLSBindPort, ffval.NewValueDefault(&sc.Config.Syslog.TLSBindPort, sc.Config.Syslog.TLSBindPort))
+	fs.Register(SyslogStoreEnabled, ffval.NewValueDefault(&sc.Config.Syslog.Store.Enabled, sc.Config.Syslog.Store.Enabled))
+	fs.Register(SyslogStoreMaxEntries, ffval.NewValueDefault(&sc.Config.Syslog.Store.MaxEntries, sc.Config.Syslog.Store.MaxEntries))
+	fs.Register(SyslogStoreMaxHosts, ffval.NewValueDefault(&sc.Config.Syslog.Store.MaxHosts, sc.Config.Syslog.Store.MaxHosts))
+	fs.Register(SyslogStoreHTTPEnabled, ffval.NewValueDefault(&sc.Config.Syslog.Store.HTTPEnabled, sc.Config.Syslog.Store.HTTPEnabled))
+	fs.Register(SyslogForwardURLs, &ffval.Value[[]string]{
+		ParseFunc: commaListParser,
+		Pointer:   &sc.Config.Syslog.ForwardURLs,
+		Default:   sc.Config.Syslog.ForwardURLs,
+	})
 
 	// TFTP Flags
 	fs.Register(TFTPServerEnabled, ffval.NewValueDefault(&sc.Config.TFTP.Enabled, sc.Config.TFTP.Enabled))
@@ -188,6 +274,9 @@ func (s *SmeeConfig) Convert(trustedProxies *[]netip.Prefix, publicIP netip.Addr
 	if publicIP.IsUnspecified() || !publicIP.IsValid() {
 		return
 	}
+	if publicIP.Is6() && !publicIP.Is4In6() && !s.Config.DHCPv6.IPForPacket.IsValid() {
+		s.Config.DHCPv6.IPForPacket = publicIP
+	}
 	// the order of precedence is: CLI flag, publicIP, default.
 	if s.Config.DHCP.IPForPacket.IsUnspecified() || !s.Config.DHCP.IPForPacket.IsValid() {
 		s.Config.DHCP.IPForPacket = publicIP
@@ -233,6 +322,162 @@ func macAddrFormatParser(s string) (constant.MACFormat, error) {
 	}
 }
 
+// dhcpPoolsParser parses dynamic DHCP pools. Pools are separated by ";" and each pool is a comma separated list of key=value pairs.
+// The range, dns and option keys can be repeated. Option values are in the format of dhcpOptionParser. For example:
+// subnet=192.168.2.0/24,range=192.168.2.100-192.168.2.200,gateway=192.168.2.1,dns=1.1.1.1,dns=8.8.8.8,lease-time=1h,option=26:uint16:9000.
+func dhcpPoolsParser(s string) ([]smee.DHCPPool, error) {
+	var pools []smee.DHCPPool
+	for _, ps := range strings.Split(s, ";") {
+		if strings.TrimSpace(ps) == "" {
+			continue
+		}
+		var p smee.DHCPPool
+		for _, pair := range strings.Split(ps, ",") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid format for DHCP pool: %q, expected <key>=<value>", pair)
+			}
+			var err error
+			switch k, v := kv[0], kv[1]; k {
+			case "subnet":
+				p.Subnet, err = netip.ParsePrefix(v)
+			case "range":
+				se := strings.SplitN(v, "-", 2)
+				if len(se) != 2 {
+					return nil, fmt.Errorf("invalid DHCP pool range: %q, expected <start>-<end>", v)
+				}
+				var r smee.DHCPPoolRange
+				if r.Start, err = netip.ParseAddr(se[0]); err == nil {
+					r.End, err = netip.ParseAddr(se[1])
+				}
+				p.Ranges = append(p.Ranges, r)
+			case "gateway":
+				p.Gateway, err = netip.ParseAddr(v)
+			case "dns":
+				var ns netip.Addr
+				ns, err = netip.ParseAddr(v)
+				p.NameServers = append(p.NameServers, ns)
+			case "lease-time":
+				p.LeaseTime, err = time.ParseDuration(v)
+			case "option":
+				var o smee.DHCPOption
+				o, err = dhcpOptionParser(v)
+				p.Options = append(p.Options, o)
+			default:
+				return nil, fmt.Errorf("unknown DHCP pool key: %q, must be one of [subnet, range, gateway, dns, lease-time, option]", k)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid DHCP pool %s: %w", kv[0], err)
+			}
+		}
+		pools = append(pools, p)
+	}
+
+	return pools, nil
+}
+
+// dhcpOptionsParser parses raw DHCP options separated by ";", for example: 26:uint16:9000;119:hex:0765786d706c6503636f6d00.
+func dhcpOptionsParser(s string) ([]smee.DHCPOption, error) {
+	var opts []smee.DHCPOption
+	for _, raw := range strings.Split(s, ";") {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		o, err := dhcpOptionParser(raw)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, o)
+	}
+
+	return opts, nil
+}
+
+// dhcpOptionParser parses a raw DHCP option in the format <code>:<type>:<value>, for example 26:uint16:9000.
+// The value is everything after the second colon, so hex values can contain colons.
+func dhcpOptionParser(s string) (smee.DHCPOption, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), ":", 3)
+	if len(parts) != 3 {
+		return smee.DHCPOption{}, fmt.Errorf("invalid format for DHCP option: %q, expected <code>:<type>:<value>", s)
+	}
+	code, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return smee.DHCPOption{}, fmt.Errorf("invalid DHCP option code: %q: %w", parts[0], err)
+	}
+
+	return smee.DHCPOption{Code: code, Type: parts[1], Value: parts[2]}, nil
+}
+
+// ipxeMenuEntriesParser parses iPXE boot menu entries separated by ";", for example: netbootxyz|netboot.xyz|https://boot.netboot.xyz.
+func ipxeMenuEntriesParser(s string) ([]smee.IPXEMenuEntry, error) {
+	var entries []smee.IPXEMenuEntry
+	for _, raw := range strings.Split(s, ";") {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimSpace(raw), "|", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid format for iPXE menu entry: %q, expected <name>|<label>|<url>", raw)
+		}
+		entries = append(entries, smee.IPXEMenuEntry{Name: parts[0], Label: parts[1], URL: parts[2]})
+	}
+
+	return entries, nil
+}
+
+// commaListParser parses a comma separated list, so that a single environment variable can hold all values.
+// Empty values are dropped.
+func commaListParser(s string) ([]string, error) {
+	var l []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			l = append(l, v)
+		}
+	}
+
+	return l, nil
+}
+
+// osieProfilesParser parses OSIE profiles. Profiles are separated by ";" and each profile is a "|" separated list of key=value pairs.
+// The console and kernel-param keys can be repeated. The selector is a Kubernetes label selector. For example:
+// facility=lab|selector=rack in (a,b)|version=v0.10.0|console=tty0|console=ttyS0,115200|kernel-param=intel_iommu=off.
+func osieProfilesParser(s string) ([]smee.OSIEProfile, error) {
+	var profiles []smee.OSIEProfile
+	for _, ps := range strings.Split(s, ";") {
+		if strings.TrimSpace(ps) == "" {
+			continue
+		}
+		var p smee.OSIEProfile
+		for _, pair := range strings.Split(ps, "|") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) != 2 || kv[1] == "" {
+				return nil, fmt.Errorf("invalid format for OSIE profile: %q, expected <key>=<value>", pair)
+			}
+			var err error
+			switch k, v := kv[0], kv[1]; k {
+			case "facility":
+				p.Facility = v
+			case "selector":
+				p.Selector, err = labels.Parse(v)
+			case "version":
+				p.Version = v
+			case "console":
+				p.Consoles = append(p.Consoles, v)
+			case "kernel-param":
+				p.KernelParams = append(p.KernelParams, v)
+			default:
+				return nil, fmt.Errorf("unknown OSIE profile key: %q, must be one of [facility, selector, version, console, kernel-param]", k)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid OSIE profile %s: %w", kv[0], err)
+			}
+		}
+		profiles = append(profiles, p)
+	}
+
+	return profiles, nil
+}
+
 // DHCP flags.
 var DHCPEnabled = Config{
 	Name:  "dhcp-enabled",
@@ -319,6 +564,97 @@ var DHCPIPXEHTTPScriptInjectMac = Config{
 	Usage: "[dhcp] prepend the hardware MAC address to iPXE script URL base, http://1.2.3.4/auto.ipxe -> http://1.2.3.4/40:15:ff:89:cc:0e/auto.ipxe",
 }
 
+var DHCPPools = Config{
+	Name:  "dhcp-pools",
+	Usage: "[dhcp] dynamic address pools for machines without a Hardware object in reservation mode, pools are separated by ';', for example: subnet=192.168.2.0/24,range=192.168.2.100-192.168.2.200,gateway=192.168.2.1,dns=1.1.1.1,lease-time=1h",
+}
+
+var DHCPLeaseFile = Config{
+	Name:  "dhcp-lease-file",
+	Usage: "[dhcp] file to persist dynamic pool leases to, leases are only kept in memory when empty",
+}
+
+var DHCPOptions = Config{
+	Name:  "dhcp-options",
+	Usage: "[dhcp] raw DHCP options sent to all clients, in the format <code>:<type>:<value> separated by ';', types are ip, ip-list, string, uint8, uint16, uint32, bool and hex, for example: 26:uint16:9000;42:ip-list:10.0.0.1 10.0.0.2",
+}
+
+var DHCPTrustedRelays = Config{
+	Name:  "dhcp-trusted-relays",
+	Usage: "[dhcp] DHCP relay agents in CIDR notation whose relay agent information (option 82) is used to match Hardware in reservation mode, matching by option 82 is disabled when empty",
+}
+
+var DHCPHAMode = Config{
+	Name:  "dhcp-ha-mode",
+	Usage: fmt.Sprintf("[dhcp] how DHCP clients are shared between Smee replicas, %s answers from the replica that holds a Kubernetes Lease, %s splits clients by a hash of their MAC address; every replica answers every client when empty", smee.DHCPHAModeLeaderElection, smee.DHCPHAModeLoadBalance),
+}
+
+var DHCPHALeaseName = Config{
+	Name:  "dhcp-ha-lease-name",
+	Usage: "[dhcp] name of the Kubernetes Lease that elects the replica that answers DHCP in leader-election mode",
+}
+
+var DHCPHALeaseNamespace = Config{
+	Name:  "dhcp-ha-lease-namespace",
+	Usage: "[dhcp] namespace of the Kubernetes Lease in leader-election mode, defaults to the backend namespace",
+}
+
+var DHCPHAReplicas = Config{
+	Name:  "dhcp-ha-replicas",
+	Usage: "[dhcp] number of Smee replicas that share the DHCP clients in load-balance mode",
+}
+
+var DHCPHAReplicaIndex = Config{
+	Name:  "dhcp-ha-replica-index",
+	Usage: "[dhcp] index of this replica, from 0 to the number of replicas minus 1, in load-balance mode; when negative the ordinal at the end of the hostname is used, like smee-1 of a StatefulSet",
+}
+
+var DHCPHAMaxElapsed = Config{
+	Name:  "dhcp-ha-max-elapsed",
+	Usage: "[dhcp] how long a client tries to get a lease before every replica answers it in load-balance mode, so that the clients of a replica that is down are still answered; 0 disables it",
+}
+
+var DHCPRateLimitPerClient = Config{
+	Name:  "dhcp-rate-limit-per-client",
+	Usage: "[dhcp] number of DHCP messages per second answered for a single MAC address, messages over the limit are dropped; 0 disables it",
+}
+
+var DHCPRateLimitPerClientBurst = Config{
+	Name:  "dhcp-rate-limit-per-client-burst",
+	Usage: "[dhcp] number of DHCP messages of a single MAC address answered at once",
+}
+
+var DHCPRateLimitGlobal = Config{
+	Name:  "dhcp-rate-limit-global",
+	Usage: "[dhcp] number of DHCP messages per second answered for all clients together, messages over the limit are dropped; 0 disables it",
+}
+
+var DHCPRateLimitGlobalBurst = Config{
+	Name:  "dhcp-rate-limit-global-burst",
+	Usage: "[dhcp] number of DHCP messages answered at once for all clients together",
+}
+
+var DHCPNotFoundTTL = Config{
+	Name:  "dhcp-not-found-ttl",
+	Usage: "[dhcp] how long a Hardware lookup that found nothing is remembered, so that messages of machines without Hardware don't each cause a backend lookup; 0 disables it",
+}
+
+// DHCPv6 flags.
+var DHCPv6Enabled = Config{
+	Name:  "dhcpv6-enabled",
+	Usage: "[dhcp] enable DHCPv6 server, uses the DHCP mode, bind interface and netboot settings",
+}
+
+var DHCPv6BindAddr = Config{
+	Name:  "dhcpv6-bind-addr",
+	Usage: "[dhcp] DHCPv6 server bind address, when unspecified the DHCPv6 multicast groups are joined",
+}
+
+var DHCPv6IPForPacket = Config{
+	Name:  "dhcpv6-ip-for-packet",
+	Usage: "[dhcp] IPv6 address of Smee to use in DHCPv6 netboot URLs",
+}
+
 // iPXE HTTP script flags.
 var IPXEHTTPScriptEnabled = Config{
 	Name:  "ipxe-http-script-enabled",
@@ -350,6 +686,26 @@ var IPXEHTTPScriptOSIEURL = Config{
 	Usage: "[ipxe] URL where OSIE (HookOS) images are located",
 }
 
+var IPXEMenuEnabled = Config{
+	Name:  "ipxe-menu-enabled",
+	Usage: "[ipxe] serve an iPXE boot menu (HookOS, rescue shell, local disk and custom entries) as auto.ipxe, Hardware that doesn't allow netbooting is only offered the local disk entry",
+}
+
+var IPXEMenuTimeout = Config{
+	Name:  "ipxe-menu-timeout",
+	Usage: "[ipxe] time after which the default iPXE boot menu entry is booted, 0 waits for a selection",
+}
+
+var IPXEMenuDefault = Config{
+	Name:  "ipxe-menu-default",
+	Usage: "[ipxe] name of the iPXE boot menu entry to boot after the timeout, defaults to hook. Hardware that doesn't allow netbooting only has the disk entry",
+}
+
+var IPXEMenuEntries = Config{
+	Name:  "ipxe-menu-entries",
+	Usage: "[ipxe] custom iPXE boot menu entries that chain load a URL, separated by ';', in the format <name>|<label>|<url>",
+}
+
 var IPXEHTTPScriptRetries = Config{
 	Name:  "ipxe-http-script-retries",
 	Usage: "[ipxe] number of retries to attempt when fetching kernel and initrd files in the iPXE script",
@@ -429,6 +785,110 @@ var SyslogBindPort = Config{
 	Usage: "[syslog] local port to listen on for Syslog messages",
 }
 
+var SyslogTCPEnabled = Config{
+	Name:  "syslog-tcp-enabled",
+	Usage: "[syslog] also receive Syslog messages over TCP, framed with octet counting or newlines (RFC 6587)",
+}
+
+var SyslogTCPBindPort = Config{
+	Name:  "syslog-tcp-bind-port",
+	Usage: "[syslog] local TCP port to listen on for Syslog messages",
+}
+
+var SyslogTLSEnabled = Config{
+	Name:  "syslog-tls-enabled",
+	Usage: "[syslog] also receive Syslog messages over TLS (RFC 5425), requires the TLS certificate and key files",
+}
+
+var SyslogTLSBindPort = Config{
+	Name:  "syslog-tls-bind-port",
+	Usage: "[syslog] local TCP port to listen on for Syslog messages over TLS",
+}
+
+var SyslogStoreEnabled = Config{
+	Name:  "syslog-store-enabled",
+	Usage: "[syslog] keep the most recent Syslog messages of each machine in memory, to be viewed in the UI",
+}
+
+var SyslogStoreMaxEntries = Config{
+	Name:  "syslog-store-max-entries",
+	Usage: "[syslog] number of Syslog messages kept per machine",
+}
+
+var SyslogStoreMaxHosts = Config{
+	Name:  "syslog-store-max-hosts",
+	Usage: "[syslog] number of machines for which Syslog messages are kept, the machine heard from least recently is dropped first",
+}
+
+var SyslogStoreHTTPEnabled = Config{
+	Name:  "syslog-store-http-enabled",
+	Usage: "[syslog] serve the kept Syslog messages at /syslog/<ip or mac>; requires the kube backend and a Kubernetes token allowed to get Hardware",
+}
+
+var SyslogForwardURLs = Config{
+	Name:  "syslog-forward-urls",
+	Usage: "[syslog] comma separated list of URLs to forward Syslog messages to, supported schemes are udp, tcp and tls for Syslog servers and otlp and otlp+insecure for OpenTelemetry gRPC logs endpoints, for example tls://logs.example.com:6514",
+}
+
+// OSIE cache flags.
+var OSIECacheEnabled = Config{
+	Name:  "osie-cache-enabled",
+	Usage: "[osie] serve the OSIE kernel and initrd from Smee through a disk-backed cache of the iPXE HTTP script OSIE URL",
+}
+
+var OSIECacheDir = Config{
+	Name:  "osie-cache-dir",
+	Usage: "[osie] directory in which OSIE artifacts are cached",
+}
+
+var OSIECacheChecksumFile = Config{
+	Name:  "osie-cache-checksum-file",
+	Usage: "[osie] name of a sha256sum or sha512sum formatted file, relative to the OSIE URL, used to verify OSIE artifacts before they are cached",
+}
+
+var OSIEProfiles = Config{
+	Name:  "osie-profiles",
+	Usage: "[osie] OSIE settings for Hardware matching a facility or label selector, applied in order before the settings of the Hardware; profiles are separated by ';' and each is a '|' separated list of key=value pairs, for example: facility=lab|selector=rack in (a,b)|version=v0.10.0|console=ttyS0,115200|kernel-param=intel_iommu=off",
+}
+
+// Boot files flags.
+var BootFilesDir = Config{
+	Name:  "boot-files-dir",
+	Usage: "[boot files] directory of boot files, like Raspberry Pi firmware or shim and GRUB, served over TFTP and HTTP when a requested file isn't an embedded iPXE binary",
+}
+
+var BootFilesOCIRef = Config{
+	Name:  "boot-files-oci-ref",
+	Usage: "[boot files] OCI artifact, for example ghcr.io/example/bootfiles:v1, extracted into the boot files directory at startup",
+}
+
+var BootFilesPathTemplates = Config{
+	Name:  "boot-files-path-templates",
+	Usage: "[boot files] comma separated list of Go templates that map a requested file to a path in the boot files directory, tried in order; .MAC, .IP and .Path are available, for example: machines/{{ .MAC }}/{{ .Path }},{{ .Path }}",
+}
+
+// Secure Boot flags.
+var SecureBootShimX86_64 = Config{
+	Name:  "secure-boot-shim-x86-64",
+	Usage: "[secure boot] path of the signed shim, in the boot files directory, sent to x86_64 UEFI clients of Hardware with Secure Boot enabled; GRUB is loaded from the same directory",
+}
+
+var SecureBootShimARM64 = Config{
+	Name:  "secure-boot-shim-arm64",
+	Usage: "[secure boot] path of the signed shim, in the boot files directory, sent to arm64 UEFI clients of Hardware with Secure Boot enabled; GRUB is loaded from the same directory",
+}
+
+// Netboot status flags.
+var NetbootStatusEnabled = Config{
+	Name:  "netboot-status-enabled",
+	Usage: "[netboot status] record the DHCP responses, iPXE binaries, scripts and ISOs served to machines in the status of their Hardware; requires a backend that can update Hardware",
+}
+
+var NetbootStatusInterval = Config{
+	Name:  "netboot-status-interval",
+	Usage: "[netboot status] minimum time between status updates of a Hardware, events in between are batched",
+}
+
 // ISO flags.
 var ISOEnabled = Config{
 	Name:  "iso-enabled",
@@ -450,6 +910,41 @@ var ISOStaticIPAMEnabled = Config{
 	Usage: "[iso] enable static IPAM when patching the source (upstream) ISO",
 }
 
+var ISOCacheEnabled = Config{
+	Name:  "iso-cache-enabled",
+	Usage: "[iso] cache upstream ISOs locally and patch them when they are read, instead of streaming them from upstream for every request",
+}
+
+var ISOCacheDir = Config{
+	Name:  "iso-cache-dir",
+	Usage: "[iso] directory in which upstream ISOs are cached",
+}
+
+var ISOCacheMaxBytes = Config{
+	Name:  "iso-cache-max-bytes",
+	Usage: "[iso] maximum total size, in bytes, of the cached ISOs, the least recently used ISOs are evicted when it is exceeded, 0 means no limit",
+}
+
+var ISOCacheHosts = Config{
+	Name:  "iso-cache-hosts",
+	Usage: "[iso] hosts, with the port if their URLs have one, whose ISOs are cached in addition to the host of the upstream URL, ISOs from other hosts are proxied without being cached",
+}
+
+var ISOGenerateEnabled = Config{
+	Name:  "iso-generate-enabled",
+	Usage: "[iso] serve UEFI bootable ISOs generated for the machine, with iPXE and a script for the machine, as /iso/<mac>/ipxe.iso, which chains the iPXE script, and /iso/<mac>/osie.iso, which downloads the OSIE kernel and initrd",
+}
+
+var ISOGenerateDir = Config{
+	Name:  "iso-generate-dir",
+	Usage: "[iso] directory in which generated ISOs are kept",
+}
+
+var ISOGenerateMaxBytes = Config{
+	Name:  "iso-generate-max-bytes",
+	Usage: "[iso] maximum total size, in bytes, of the generated ISOs, the least recently used ISOs are removed when it is exceeded, 0 means no limit",
+}
+
 // Tink Server flags.
 var TinkServerAddrPort = Config{
 	Name:  "ipxe-script-tink-server-addr-port",
@@ -19,6 +19,7 @@ type TinkServerConfig struct {
 var KubeIndexesTinkServer = map[kube.IndexType]kube.Index{
 	kube.IndexTypeWorkflowAgentID: kube.Indexes[kube.IndexTypeWorkflowAgentID],
 	kube.IndexTypeHardwareAgentID: kube.Indexes[kube.IndexTypeHardwareAgentID],
+	kube.IndexTypeMACAddr:         kube.Indexes[kube.IndexTypeMACAddr],
 }
 
 func RegisterTinkServerFlags(fs *Set, t *TinkServerConfig) {
@@ -26,9 +27,13 @@ func RegisterTinkServerFlags(fs *Set, t *TinkServerConfig) {
 	fs.Register(TinkServerBindPort, ffval.NewValueDefault(&t.BindPort, t.BindPort))
 	fs.Register(TinkServerLogLevel, ffval.NewValueDefault(&t.LogLevel, t.LogLevel))
 	fs.Register(TinkServerAutoEnrollmentEnabled, ffval.NewValueDefault(&t.Config.Auto.Enrollment.Enabled, t.Config.Auto.Enrollment.Enabled))
+	fs.Register(TinkServerAutoEnrollmentHardwareMatch, ffval.NewList(&t.Config.Auto.Enrollment.HardwareMatch))
+	fs.Register(TinkServerAutoEnrollmentReEnrollmentCooldown, ffval.NewValueDefault(&t.Config.Auto.Enrollment.ReEnrollmentCooldown, t.Config.Auto.Enrollment.ReEnrollmentCooldown))
+	fs.Register(TinkServerDryRunEnabled, ffval.NewValueDefault(&t.Config.EnableDryRun, t.Config.EnableDryRun))
 	fs.Register(TinkerbellAutoDiscoveryEnabled, ffval.NewValueDefault(&t.Config.Auto.Discovery.Enabled, t.Config.Auto.Discovery.Enabled))
 	fs.Register(TinkerbellAutoDiscoveryAutoEnrollmentEnabled, ffval.NewValueDefault(&t.Config.Auto.Discovery.EnrollmentEnabled, t.Config.Auto.Discovery.EnrollmentEnabled))
 	fs.Register(TinkerbellAutoDiscoveryNamespace, ffval.NewValueDefault(&t.Config.Auto.Discovery.Namespace, t.Config.Auto.Discovery.Namespace))
+	fs.Register(TinkerbellAutoDiscoveryInterfaceProfile, ffval.NewValueDefault(&t.Config.Auto.Discovery.InterfaceProfile, t.Config.Auto.Discovery.InterfaceProfile))
 }
 
 // Convert TinkServerConfig data types to tink server server.Config data types.
@@ -60,6 +65,21 @@ var TinkServerAutoEnrollmentEnabled = Config{
 	Usage: "enable auto enrollment capabilities for the Tink server",
 }
 
+var TinkServerAutoEnrollmentHardwareMatch = Config{
+	Name:  "tink-server-auto-enrollment-hardware-match",
+	Usage: "[chassis-serial, product-serial, mac] Agent attributes used, in order, to link an Agent to existing Hardware that has no matching spec.agentID, comma separated or specified multiple times",
+}
+
+var TinkServerAutoEnrollmentReEnrollmentCooldown = Config{
+	Name:  "tink-server-auto-enrollment-re-enrollment-cooldown",
+	Usage: "minimum time between creating a Workflow for an Agent and re-enrolling it, 0 uses the default of 10m, negative disables the cooldown",
+}
+
+var TinkServerDryRunEnabled = Config{
+	Name:  "tink-server-dry-run-enabled",
+	Usage: "enable the HTTP API for testing WorkflowRuleSets and Templates against Agent attributes without creating Workflows; requires the kube backend and a Kubernetes token allowed to create Workflows",
+}
+
 var TinkerbellAutoDiscoveryEnabled = Config{
 	Name:  "tink-server-auto-discovery-enabled",
 	Usage: "enable auto discovery capabilities for the Tink server",
@@ -74,3 +94,8 @@ var TinkerbellAutoDiscoveryAutoEnrollmentEnabled = Config{
 	Name:  "tink-server-auto-discovery-auto-enrollment-enabled",
 	Usage: "this tells auto discovery the value to set for the hardware.spec.auto.enrollmentEnabled field when creating Hardware objects",
 }
+
+var TinkerbellAutoDiscoveryInterfaceProfile = Config{
+	Name:  "tink-server-auto-discovery-interface-profile",
+	Usage: "JSON or YAML Hardware interface used as the template for each interface of auto discovered Hardware objects, for example {\"netboot\":{\"allowPXE\":true,\"allowWorkflow\":true}}",
+}
@@ -18,6 +18,9 @@ import (
 	httpserver "github.com/tinkerbell/tinkerbell/pkg/http/server"
 	"github.com/tinkerbell/tinkerbell/smee"
 	"github.com/tinkerbell/tinkerbell/tink/server"
+	"github.com/tinkerbell/tinkerbell/ui/templates"
+	authv1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/rest"
 	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
@@ -26,8 +29,10 @@ const (
 	routeHealthcheck       = "/healthcheck"
 	routeHealthz           = "/healthz"
 	routeReadyz            = "/readyz"
+	routeReadyzDHCP        = "/readyz/dhcp"
 	routeSmeeMetrics       = "/smee/metrics"
 	routeTinkServerMetrics = "/tink-server/metrics"
+	routeTinkServerDryRun  = "/tink-server/v1/enrollment/dry-run"
 	routeControllerMetrics = "/controllers/metrics"
 	routeHTTPMetrics       = "/http/metrics"
 	routeEC2Metadata       = "/2009-04-04/"
@@ -36,11 +41,14 @@ const (
 	routeISO               = smee.ISOURI
 	routeIPXEBinary        = smee.IPXEBinaryURI
 	routeIPXEScript        = smee.IPXEScriptURI
+	routeOSIECache         = smee.OSIECacheURI
+	routeSyslog            = smee.SyslogURI
+	routeBootFiles         = smee.BootFilesURI
 )
 
 // startHTTPServer registers all HTTP/HTTPS routes, applies middleware, and
 // starts the consolidated HTTP server. It blocks until ctx is cancelled.
-func startHTTPServer(ctx context.Context, globals *flag.GlobalConfig, s *flag.SmeeConfig, h *flag.TootlesConfig, uic *flag.UIConfig, startTime time.Time) error {
+func startHTTPServer(ctx context.Context, globals *flag.GlobalConfig, s *flag.SmeeConfig, h *flag.TootlesConfig, ts *flag.TinkServerConfig, uic *flag.UIConfig, kubeConfig *rest.Config, startTime time.Time) error {
 	httpLog := getLogger(globals.LogLevel).WithName("http")
 	routeList := &httpserver.Routes{}
 	tlsEnabled := len(s.Config.TLS.Certs) > 0
@@ -62,6 +70,34 @@ func startHTTPServer(ctx context.Context, globals *flag.GlobalConfig, s *flag.Sm
 				"smee iPXE script handler",
 			)
 		}
+		if oh := s.Config.OSIECacheHandler(smeeLog); oh != nil {
+			routeList.Register(routeOSIECache,
+				middleware.WithLogLevel(middleware.LogLevelNever, oh),
+				"smee OSIE cache handler",
+			)
+		}
+		if slh := s.Config.SyslogHandler(); slh != nil && kubeConfig != nil {
+			// Boot logs are shown in the UI on the Hardware page, so callers must be allowed to get Hardware.
+			auth := middleware.KubeAuth(smeeLog, middleware.KubeTokenClient(kubeConfig), func(*http.Request) authv1.ResourceAttributes {
+				return authv1.ResourceAttributes{Verb: "get", Group: "tinkerbell.org", Resource: "hardware", Namespace: globals.BackendKubeNamespace}
+			})
+			routeList.Register(routeSyslog,
+				middleware.WithLogLevel(middleware.LogLevelNever, auth(slh)),
+				"smee syslog handler",
+				httpserver.WithHTTPSEnabled(tlsEnabled),
+			)
+		} else if slh != nil {
+			smeeLog.Info("not serving kept syslog messages over HTTP, it requires the kube backend", "backend", globals.Backend)
+		}
+		if bfh, err := s.Config.BootFilesHandler(smeeLog); err == nil && bfh != nil {
+			routeList.Register(routeBootFiles,
+				middleware.WithLogLevel(middleware.LogLevelNever, bfh),
+				"smee boot files handler",
+				httpserver.WithHTTPSEnabled(tlsEnabled),
+			)
+		} else if err != nil {
+			return fmt.Errorf("failed to create smee boot files handler: %w", err)
+		}
 		if isoH, err := s.Config.ISOHandler(smeeLog); err == nil && isoH != nil {
 			routeList.Register(routeISO,
 				middleware.WithLogLevel(middleware.LogLevelNever, isoH),
@@ -98,11 +134,34 @@ func startHTTPServer(ctx context.Context, globals *flag.GlobalConfig, s *flag.Sm
 		)
 	}
 
+	// Tink Server HTTP handlers
+	if globals.EnableTinkServer && ts.Config.EnableDryRun {
+		ll := ternary((ts.LogLevel != 0), ts.LogLevel, globals.LogLevel)
+		tsLog := getLogger(ll).WithName("tink-server")
+		if kubeConfig != nil {
+			// A dry run can render any Template against any Hardware, so callers must be allowed to create Workflows.
+			auth := middleware.KubeAuth(tsLog, middleware.KubeTokenClient(kubeConfig), func(*http.Request) authv1.ResourceAttributes {
+				return authv1.ResourceAttributes{Verb: "create", Group: "tinkerbell.org", Resource: "workflows", Namespace: globals.BackendKubeNamespace}
+			})
+			routeList.Register(routeTinkServerDryRun,
+				middleware.WithLogLevel(middleware.LogLevelAlways, auth(ts.Config.DryRunHandler(tsLog))),
+				"Tink server auto enrollment dry run handler",
+				httpserver.WithHTTPSEnabled(tlsEnabled),
+				httpserver.WithRewriteHTTPToHTTPS(tlsEnabled),
+			)
+		} else {
+			tsLog.Info("not serving the auto enrollment dry run API, it requires the kube backend", "backend", globals.Backend)
+		}
+	}
+
 	// UI HTTP handler
 	if globals.EnableUI {
 		ll := ternary((uic.LogLevel != 0), uic.LogLevel, globals.LogLevel)
 		uiLog := getLogger(ll).WithName("ui")
 
+		if globals.EnableSmee && s.Config.Syslog.Enabled && s.Config.Syslog.Store.Enabled {
+			uic.Config.BootLogs = smeeBootLogs{smee: s.Config}
+		}
 		uiHandler, err := uic.Config.Handler(uiLog)
 		if err != nil {
 			return fmt.Errorf("failed to create ui handler: %w", err)
@@ -164,6 +223,9 @@ func startHTTPServer(ctx context.Context, globals *flag.GlobalConfig, s *flag.Sm
 	routeList.Register(routeHealthcheck, middleware.WithLogLevel(middleware.LogLevelNever, handler.HealthCheck(httpLog, startTime)), "Healthcheck handler")
 	routeList.Register(routeHealthz, middleware.WithLogLevel(middleware.LogLevelNever, handler.Healthz()), "Liveness probe handler")
 	routeList.Register(routeReadyz, middleware.WithLogLevel(middleware.LogLevelNever, handler.Readyz()), "Readiness probe handler")
+	if globals.EnableSmee && (s.Config.DHCP.Enabled || s.Config.DHCPv6.Enabled) {
+		routeList.Register(routeReadyzDHCP, middleware.WithLogLevel(middleware.LogLevelNever, handler.ReadyzCheck(s.Config.DHCPReady)), "Smee DHCP readiness probe handler")
+	}
 
 	httpMux, httpsMux := routeList.Muxes(httpLog, globals.HTTPSPort, !globals.TLS.DisableHTTPToHTTPSRedirect && tlsEnabled)
 
@@ -276,3 +338,17 @@ func (f filteredGatherer) Gather() ([]*dto.MetricFamily, error) {
 	}
 	return filtered, err
 }
+
+// smeeBootLogs provides the UI with the syslog messages stored by Smee.
+type smeeBootLogs struct {
+	smee *smee.Config
+}
+
+func (b smeeBootLogs) BootLogs(mac string) []templates.BootLog {
+	entries := b.smee.SyslogEntries(mac)
+	logs := make([]templates.BootLog, 0, len(entries))
+	for _, e := range entries {
+		logs = append(logs, templates.BootLog{Time: e.Time, Host: e.Host, Severity: e.Severity, App: e.App, Message: e.Message})
+	}
+	return logs
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/go-logr/logr"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/tinkerbell/tinkerbell/cmd/tinkerbell/flag"
+	"github.com/tinkerbell/tinkerbell/smee"
+)
+
+// runSimulate prints the DHCP replies and the iPXE script that Smee would send the machine with the MAC address in args,
+// using the Hardware in the backend. Nothing is sent on the network.
+func runSimulate(ctx context.Context, log logr.Logger, globals *flag.GlobalConfig, s *flag.SmeeConfig, sc *flag.SimulateConfig, args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("simulate requires exactly one argument, the MAC address of the machine")
+	}
+	mac, err := net.ParseMAC(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid MAC address %q: %w", args[0], err)
+	}
+
+	switch globals.Backend {
+	case "kube":
+		b, err := newKubeBackend(ctx, globals.BackendKubeConfig, "", globals.BackendKubeNamespace, enabledIndexes(true, false, false, false), WithQPS(globals.BackendKubeOptions.QPS), WithBurst(globals.BackendKubeOptions.Burst))
+		if err != nil {
+			return fmt.Errorf("failed to create kube backend: %w", err)
+		}
+		s.Config.Backend = b
+	case "file":
+		b, err := newFileBackend(ctx, log, globals.BackendFilePath)
+		if err != nil {
+			return fmt.Errorf("failed to create file backend: %w", err)
+		}
+		s.Config.Backend = b
+	case "none":
+		s.Config.Backend = newNoopBackend()
+	default:
+		return fmt.Errorf("simulate doesn't support the %q backend", globals.Backend)
+	}
+
+	s.Config.InitMetrics()
+	sim, err := s.Config.Simulate(ctx, log, smee.SimulateRequest{
+		MAC:         mac,
+		Arch:        iana.Arch(sc.Arch),
+		UserClass:   sc.UserClass,
+		VendorClass: sc.VendorClass,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to simulate the netboot of %s: %w", mac, err)
+	}
+	_, err = io.WriteString(w, sim.String())
+
+	return err
+}
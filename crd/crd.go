@@ -31,6 +31,9 @@ var WorkflowCRD []byte
 //go:embed bases/tinkerbell.org_workflowrulesets.yaml
 var WorkflowRuleSetCRD []byte
 
+//go:embed bases/tinkerbell.org_ipxescripts.yaml
+var IPXEScriptCRD []byte
+
 //go:embed bases/bmc.tinkerbell.org_jobs.yaml
 var JobCRD []byte
 
@@ -58,6 +61,8 @@ const (
 	WorkflowCRDName = "workflows.tinkerbell.org"
 	// WorkflowRuleSetCRDName is the name of the WorkflowRuleSet CRD.
 	WorkflowRuleSetCRDName = "workflowrulesets.tinkerbell.org"
+	// IPXEScriptCRDName is the name of the IPXEScript CRD.
+	IPXEScriptCRDName = "ipxescripts.tinkerbell.org"
 	// JobCRDName is the name of the Job CRD.
 	JobCRDName = "jobs.bmc.tinkerbell.org"
 	// MachineCRDName is the name of the Machine CRD.
@@ -72,6 +77,7 @@ var TinkerbellDefaults = map[string][]byte{
 	TemplateCRDName:        TemplateCRD,
 	WorkflowCRDName:        WorkflowCRD,
 	WorkflowRuleSetCRDName: WorkflowRuleSetCRD,
+	IPXEScriptCRDName:      IPXEScriptCRD,
 	JobCRDName:             JobCRD,
 	MachineCRDName:         MachineCRD,
 	TaskCRDName:            TaskCRD,
@@ -29,6 +29,7 @@ func init() {
 	SchemeBuilderTinkerbell.Register(&tinkerbell.Template{}, &tinkerbell.TemplateList{})
 	SchemeBuilderTinkerbell.Register(&tinkerbell.Workflow{}, &tinkerbell.WorkflowList{})
 	SchemeBuilderTinkerbell.Register(&tinkerbell.WorkflowRuleSet{}, &tinkerbell.WorkflowRuleSetList{})
+	SchemeBuilderTinkerbell.Register(&tinkerbell.IPXEScript{}, &tinkerbell.IPXEScriptList{})
 
 	SchemeBuilderBMC.Register(&bmc.Job{}, &bmc.JobList{})
 	SchemeBuilderBMC.Register(&bmc.Machine{}, &bmc.MachineList{})
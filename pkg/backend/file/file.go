@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 
@@ -185,8 +186,25 @@ func matchHardware(hw *tinkerbell.Hardware, opts data.HardwareFilter) bool {
 	if opts.ByInstanceID != "" && (hw.Spec.Metadata == nil || hw.Spec.Metadata.Instance == nil || hw.Spec.Metadata.Instance.ID != opts.ByInstanceID) {
 		return false
 	}
+	if opts.ByRelayAgentInfo != "" && !hardwareHasRelayAgentInfo(hw, opts.ByRelayAgentInfo) {
+		return false
+	}
+	for k, v := range opts.ByLabels {
+		if hw.Labels[k] != v {
+			return false
+		}
+	}
 	// At least one selector must be set for a match.
-	return opts.ByName != "" || opts.ByAgentID != "" || opts.ByMACAddress != "" || opts.ByIPAddress != "" || opts.ByInstanceID != ""
+	return opts.ByName != "" || opts.ByAgentID != "" || opts.ByMACAddress != "" || opts.ByIPAddress != "" || opts.ByInstanceID != "" || opts.ByRelayAgentInfo != "" || len(opts.ByLabels) > 0
+}
+
+func hardwareHasRelayAgentInfo(hw *tinkerbell.Hardware, key string) bool {
+	for _, iface := range hw.Spec.Interfaces {
+		if iface.DHCP != nil && iface.DHCP.RelayAgentInfo.Key() == key {
+			return true
+		}
+	}
+	return false
 }
 
 func hardwareHasMAC(hw *tinkerbell.Hardware, mac string) bool {
@@ -200,7 +218,7 @@ func hardwareHasMAC(hw *tinkerbell.Hardware, mac string) bool {
 
 func hardwareHasIP(hw *tinkerbell.Hardware, ip string) bool {
 	for _, iface := range hw.Spec.Interfaces {
-		if iface.DHCP != nil && iface.DHCP.IP != nil && iface.DHCP.IP.Address == ip {
+		if slices.Contains(iface.DHCP.IPAddresses(), ip) {
 			return true
 		}
 	}
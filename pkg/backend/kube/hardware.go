@@ -85,6 +85,12 @@ func filterQueryDesc(opts data.HardwareFilter) string {
 	if opts.ByInstanceID != "" {
 		desc = fmt.Sprintf("%s with instanceID %q", desc, opts.ByInstanceID)
 	}
+	if opts.ByRelayAgentInfo != "" {
+		desc = fmt.Sprintf("%s with relay agent info %q", desc, opts.ByRelayAgentInfo)
+	}
+	if len(opts.ByLabels) > 0 {
+		desc = fmt.Sprintf("%s with labels %v", desc, opts.ByLabels)
+	}
 	return desc
 }
 
@@ -118,10 +124,21 @@ func hardwareListOptions(opts data.HardwareFilter) []client.ListOption {
 	if opts.ByInstanceID != "" {
 		los = append(los, client.MatchingFields{InstanceIDIndex: opts.ByInstanceID})
 	}
+	if opts.ByRelayAgentInfo != "" {
+		los = append(los, client.MatchingFields{RelayAgentInfoIndex: opts.ByRelayAgentInfo})
+	}
+	if len(opts.ByLabels) > 0 {
+		los = append(los, client.MatchingLabels(opts.ByLabels))
+	}
 
 	return los
 }
 
+// RecordHardwareEvent records a Kubernetes Event for hw. eventType is either Normal or Warning.
+func (b *Backend) RecordHardwareEvent(_ context.Context, hw *v1alpha1.Hardware, eventType, reason, message string) {
+	b.cluster.GetEventRecorderFor("tink-server").Event(hw, eventType, reason, message)
+}
+
 func (b *Backend) UpdateHardware(ctx context.Context, hw *v1alpha1.Hardware, opts data.UpdateOptions) error {
 	cc := b.cluster.GetClient()
 
@@ -16,6 +16,7 @@ const (
 	IndexTypeWorkflowAgentID IndexType = WorkflowAgentIDIndex
 	IndexTypeHardwareAgentID IndexType = HardwareAgentIDIndex
 	IndexTypeInstanceID      IndexType = InstanceIDIndex
+	IndexTypeRelayAgentInfo  IndexType = RelayAgentInfoIndex
 
 	// MACAddrIndex is an index used with a controller-runtime client to lookup hardware by MAC.
 	MACAddrIndex = ".Spec.Interfaces.MAC"
@@ -35,6 +36,8 @@ const (
 	// InstanceIDIndex is an index used with a controller-runtime client to lookup hardware by its metadata instance id.
 	InstanceIDIndex = ".Spec.Metadata.Instance.ID" // #nosec G101 - This is a field path, not a credential
 
+	// RelayAgentInfoIndex is an index used with a controller-runtime client to lookup hardware by DHCP relay agent information (option 82).
+	RelayAgentInfoIndex = ".Spec.Interfaces.DHCP.RelayAgentInfo"
 )
 
 // Indexes that are currently known.
@@ -74,6 +77,11 @@ var Indexes = map[IndexType]Index{
 		Field:        InstanceIDIndex,
 		ExtractValue: InstanceID,
 	},
+	IndexTypeRelayAgentInfo: {
+		Obj:          &tinkerbell.Hardware{},
+		Field:        RelayAgentInfoIndex,
+		ExtractValue: RelayAgentInfos,
+	},
 }
 
 // MACAddrs returns a list of MAC addresses for a Hardware object.
@@ -110,13 +118,29 @@ func IPAddrs(obj client.Object) []string {
 func GetIPs(h *tinkerbell.Hardware) []string {
 	var ips []string
 	for _, i := range h.Spec.Interfaces {
-		if i.DHCP != nil && i.DHCP.IP != nil && i.DHCP.IP.Address != "" {
-			ips = append(ips, i.DHCP.IP.Address)
-		}
+		ips = append(ips, i.DHCP.IPAddresses()...)
 	}
 	return ips
 }
 
+// RelayAgentInfos returns the relay agent information keys of a Hardware object.
+func RelayAgentInfos(obj client.Object) []string {
+	hw, ok := obj.(*tinkerbell.Hardware)
+	if !ok {
+		return nil
+	}
+	var keys []string
+	for _, i := range hw.Spec.Interfaces {
+		if i.DHCP == nil {
+			continue
+		}
+		if k := i.DHCP.RelayAgentInfo.Key(); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
 // HardwareName extracts the name of a Hardware object for field indexing.
 func HardwareName(obj client.Object) []string {
 	hw, ok := obj.(*tinkerbell.Hardware)
@@ -0,0 +1,19 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+
+	v1alpha1 "github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ListIPXEScripts returns all IPXEScripts in namespace.
+func (b *Backend) ListIPXEScripts(ctx context.Context, namespace string) ([]v1alpha1.IPXEScript, error) {
+	list := &v1alpha1.IPXEScriptList{}
+	if err := b.cluster.GetClient().List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list ipxe scripts in namespace %s: %w", namespace, err)
+	}
+
+	return list.Items, nil
+}
@@ -2,6 +2,7 @@ package kube
 
 import (
 	"context"
+	"fmt"
 
 	v1alpha1 "github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
 	"github.com/tinkerbell/tinkerbell/pkg/data"
@@ -21,3 +22,34 @@ func (b *Backend) ListWorkflowRuleSets(ctx context.Context, opts data.WorkflowFi
 
 	return list.Items, nil
 }
+
+func (b *Backend) UpdateWorkflowRuleSet(ctx context.Context, wrs *v1alpha1.WorkflowRuleSet, opts data.UpdateOptions) error {
+	cc := b.cluster.GetClient()
+
+	if p, err := patchFromOpts(opts); err != nil {
+		return fmt.Errorf("invalid patch options for workflow rule set %s: %w", wrs.Name, err)
+	} else if p != nil {
+		if opts.StatusOnly {
+			if err := cc.Status().Patch(ctx, wrs, p); err != nil {
+				return fmt.Errorf("failed to patch workflow rule set status %s: %w", wrs.Name, err)
+			}
+			return nil
+		}
+		if err := cc.Patch(ctx, wrs, p); err != nil {
+			return fmt.Errorf("failed to patch workflow rule set %s: %w", wrs.Name, err)
+		}
+		return nil
+	}
+
+	if opts.StatusOnly {
+		if err := cc.Status().Update(ctx, wrs); err != nil {
+			return fmt.Errorf("failed to update workflow rule set status %s: %w", wrs.Name, err)
+		}
+		return nil
+	}
+	if err := cc.Update(ctx, wrs); err != nil {
+		return fmt.Errorf("failed to update workflow rule set %s: %w", wrs.Name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,17 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+
+	v1alpha1 "github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func (b *Backend) ReadTemplate(ctx context.Context, name, namespace string) (*v1alpha1.Template, error) {
+	tpl := &v1alpha1.Template{}
+	if err := b.cluster.GetClient().Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, tpl); err != nil {
+		return nil, fmt.Errorf("failed to get template %s/%s: %w", namespace, name, err)
+	}
+	return tpl, nil
+}
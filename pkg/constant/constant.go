@@ -28,6 +28,21 @@ const (
 
 	// AttributesAnnotation is the annotation key used to store agent attributes on any object.
 	AttributesAnnotation = "tinkerbell.org/agent-attributes"
+	// ChassisSerialLabel is the label key used to identify Hardware by the chassis serial number reported by an Agent.
+	ChassisSerialLabel = "tinkerbell.org/chassis-serial"
+	// ProductSerialLabel is the label key used to identify Hardware by the product serial number reported by an Agent.
+	ProductSerialLabel = "tinkerbell.org/product-serial"
+	// AutoDiscoveredLabel is the label key set to "true" on Hardware created by auto discovery.
+	AutoDiscoveredLabel = "tinkerbell.org/auto-discovered"
+	// VendorLabel is the label key used to identify Hardware by the vendor reported by an Agent.
+	VendorLabel = "tinkerbell.org/vendor"
+	// ModelLabel is the label key used to identify Hardware by the product name reported by an Agent.
+	ModelLabel = "tinkerbell.org/model"
+	// GPULabel is the label key set to "true" on Hardware for which an Agent reported GPU devices.
+	GPULabel = "tinkerbell.org/gpu"
+	// InventoryDriftAcknowledgedAnnotation is the annotation key used to acknowledge the inventory drift of a Hardware object.
+	// Its value is an RFC 3339 time. Drift recorded at or before that time is cleared on the next Agent check-in.
+	InventoryDriftAcknowledgedAnnotation = "tinkerbell.org/inventory-drift-acknowledged"
 )
 
 // MACFormat is a format for a MAC address.
@@ -10,6 +10,11 @@ type HardwareFilter struct {
 	ByMACAddress string
 	ByIPAddress  string
 	ByInstanceID string
+	// ByRelayAgentInfo matches Hardware with an interface whose DHCP relay agent information has this key.
+	// See tinkerbell.RelayAgentInfo.Key.
+	ByRelayAgentInfo string
+	// ByLabels matches Hardware that has all of the given labels.
+	ByLabels map[string]string
 }
 
 // WorkflowFilter holds selectors for listing Workflows.
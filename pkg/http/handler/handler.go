@@ -68,6 +68,23 @@ func Readyz() http.Handler {
 	})
 }
 
+// ReadyzCheck returns an http.Handler that responds with 200 OK and the body "ok"
+// when check returns nil, and with 503 Service Unavailable and the error otherwise.
+// It's used for readiness probes of a single service, for example whether a
+// replica is the one that answers DHCP.
+func ReadyzCheck(check func() error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		if err := check(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "ok")
+	})
+}
+
 // RedirectToHTTPS returns an http.Handler that redirects incoming HTTP requests to the corresponding HTTPS URL on the specified port.
 func RedirectToHTTPS(log logr.Logger, port int) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
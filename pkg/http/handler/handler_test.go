@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -96,3 +97,28 @@ func TestRedirectToHTTPS(t *testing.T) {
 		})
 	}
 }
+
+func TestReadyzCheck(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		wantCode int
+		wantBody string
+	}{
+		"ready":     {wantCode: http.StatusOK, wantBody: "ok"},
+		"not ready": {err: errors.New("another replica is the DHCP leader"), wantCode: http.StatusServiceUnavailable, wantBody: "another replica is the DHCP leader"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/readyz/dhcp", nil)
+			ReadyzCheck(func() error { return tt.err }).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Fatalf("body = %q, want %q", got, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-logr/logr"
+	authv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// KubeClientFunc returns a Kubernetes client that authenticates with a bearer token.
+type KubeClientFunc func(token string) (kubernetes.Interface, error)
+
+// KubeTokenClient returns a KubeClientFunc for the API server of cfg. The clients only use the bearer token
+// they're created with, never the credentials of cfg.
+func KubeTokenClient(cfg *rest.Config) KubeClientFunc {
+	return func(token string) (kubernetes.Interface, error) {
+		c := rest.AnonymousClientConfig(cfg)
+		c.BearerToken = token
+		return kubernetes.NewForConfig(c)
+	}
+}
+
+// KubeAuth returns middleware that only passes requests with a Kubernetes bearer token, in the
+// "Authorization: Bearer <token>" header, that is allowed the access returned by attrs for the request.
+// The access is checked with a SelfSubjectAccessReview sent to the API server with the token, the same way
+// the UI checks the tokens it's given. Requests without a valid token get a 401 response, requests whose
+// token isn't allowed the access get a 403 response.
+func KubeAuth(log logr.Logger, newClient KubeClientFunc, attrs func(*http.Request) authv1.ResourceAttributes) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if token = strings.TrimSpace(token); !ok || token == "" {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			client, err := newClient(token)
+			if err != nil {
+				log.Error(err, "unable to create Kubernetes client")
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			ra := attrs(r)
+			sar := &authv1.SelfSubjectAccessReview{Spec: authv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &ra}}
+			res, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(r.Context(), sar, metav1.CreateOptions{})
+			switch {
+			case apierrors.IsUnauthorized(err):
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			case err != nil:
+				log.Error(err, "unable to review access", "path", r.URL.Path)
+				http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+				return
+			case !res.Status.Allowed:
+				log.V(1).Info("access denied", "path", r.URL.Path, "verb", ra.Verb, "resource", ra.Resource, "namespace", ra.Namespace)
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
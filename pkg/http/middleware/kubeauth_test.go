@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	authv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestKubeAuth(t *testing.T) {
+	want := authv1.ResourceAttributes{Verb: "get", Group: "tinkerbell.org", Resource: "hardware", Namespace: "tinkerbell"}
+	// newClient returns clients whose access reviews allow the "allowed" token, reject the "invalid" token
+	// and deny any other token.
+	newClient := func(token string) (kubernetes.Interface, error) {
+		c := fake.NewClientset()
+		c.PrependReactor("create", "selfsubjectaccessreviews", func(a k8stesting.Action) (bool, runtime.Object, error) {
+			sar := a.(k8stesting.CreateAction).GetObject().(*authv1.SelfSubjectAccessReview)
+			if token == "invalid" {
+				return true, nil, apierrors.NewUnauthorized("invalid token")
+			}
+			if token == "broken" {
+				return true, nil, errors.New("connection refused")
+			}
+			if diff := cmp.Diff(&want, sar.Spec.ResourceAttributes); diff != "" {
+				t.Errorf("unexpected resource attributes (-want +got):\n%s", diff)
+			}
+			sar.Status.Allowed = token == "allowed"
+			return true, sar, nil
+		})
+		return c, nil
+	}
+	tests := map[string]struct {
+		header     string
+		wantStatus int
+	}{
+		"allowed":         {header: "Bearer allowed", wantStatus: http.StatusOK},
+		"denied":          {header: "Bearer denied", wantStatus: http.StatusForbidden},
+		"invalid token":   {header: "Bearer invalid", wantStatus: http.StatusUnauthorized},
+		"no token":        {wantStatus: http.StatusUnauthorized},
+		"basic auth":      {header: "Basic YTpi", wantStatus: http.StatusUnauthorized},
+		"api server down": {header: "Bearer broken", wantStatus: http.StatusBadGateway},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := KubeAuth(logr.Discard(), newClient, func(*http.Request) authv1.ResourceAttributes { return want })(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+// Package bootfile serves a tree of user supplied boot files, like Raspberry Pi firmware, shim and GRUB or vendor NBPs,
+// over TFTP and HTTP. The tree is a local directory, optionally populated from an OCI artifact.
+package bootfile
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"github.com/tinkerbell/tinkerbell/pkg/data"
+)
+
+// DefaultPathTemplate looks up the requested path as is.
+const DefaultPathTemplate = "{{ .Path }}"
+
+var defaultPathTemplates = []*template.Template{template.Must(template.New(DefaultPathTemplate).Parse(DefaultPathTemplate))}
+
+// BackendReader is the interface for getting data from a backend.
+type BackendReader interface {
+	FilterHardware(ctx context.Context, opts data.HardwareFilter) (*tinkerbell.Hardware, error)
+}
+
+// Tree serves the files in a directory.
+//
+// The name of a requested file is a path relative to the directory, optionally preceded by the MAC address
+// of the client, for example 52:54:00:12:34:01/grub/grub.cfg. Smee adds the MAC address to the boot file names it
+// hands out. The path is looked up using each of the PathTemplates in order, the first file that exists is served.
+type Tree struct {
+	Log logr.Logger
+	// Root is the directory with the boot files. Files outside of it, also through symlinks, are never served.
+	Root string
+	// Prefix is removed from HTTP request paths.
+	Prefix string
+	// PathTemplates map a requested file to a path in Root. See ParsePathTemplates. Defaults to DefaultPathTemplate.
+	PathTemplates []*template.Template
+	// Backend resolves the MAC address of clients that don't request a path with a MAC address, by their IP address.
+	// It's only used when a path template uses the MAC address.
+	Backend BackendReader
+	// Generate, when set, generates the content of requested files that don't exist in Root, like per machine
+	// GRUB configurations. name is the requested path without a MAC address and mac is nil when the path has none.
+	// It returns an error wrapping fs.ErrNotExist for files it doesn't generate.
+	Generate func(ctx context.Context, name string, mac net.HardwareAddr, client netip.Addr) ([]byte, error)
+}
+
+// File is a boot file, either from Root or generated.
+type File interface {
+	io.ReadSeekCloser
+	Stat() (fs.FileInfo, error)
+}
+
+// pathData is the data available to path templates.
+type pathData struct {
+	// MAC is the MAC address of the client, lower case and colon separated.
+	MAC string
+	// IP is the IP address of the client.
+	IP string
+	// Path is the requested path, without a MAC address.
+	Path string
+}
+
+// ParsePathTemplates parses path templates. Templates are Go text/templates with the sprig functions, for example:
+//
+//	machines/{{ .MAC | replace ":" "-" }}/{{ .Path }}
+//
+// Templates that use .MAC are skipped for clients whose MAC address isn't known.
+func ParsePathTemplates(templates []string) ([]*template.Template, error) {
+	ts := make([]*template.Template, 0, len(templates))
+	for _, s := range templates {
+		t, err := template.New(s).Funcs(sprig.HermeticTxtFuncMap()).Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid boot files path template %q: %w", s, err)
+		}
+		ts = append(ts, t)
+	}
+
+	return ts, nil
+}
+
+// Open opens the file with the name, for the client with the IP address.
+// Files in Root take precedence over generated files.
+// It returns an error wrapping fs.ErrNotExist when no file matches.
+func (t *Tree) Open(ctx context.Context, name string, client netip.Addr) (File, error) {
+	d := pathData{Path: name}
+	if client.IsValid() {
+		d.IP = client.Unmap().String()
+	}
+	if first, rest, ok := strings.Cut(strings.TrimPrefix(name, "/"), "/"); ok {
+		if mac, err := net.ParseMAC(first); err == nil {
+			d.MAC, d.Path = mac.String(), rest
+		}
+	}
+	d.Path = strings.TrimPrefix(path.Clean("/"+d.Path), "/")
+	if d.Path == "" {
+		return nil, fmt.Errorf("no file name: %w", fs.ErrNotExist)
+	}
+
+	root, err := os.OpenRoot(t.Root)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	templates := t.PathTemplates
+	if len(templates) == 0 {
+		templates = defaultPathTemplates
+	}
+	resolved := false
+	for _, tmpl := range templates {
+		if strings.Contains(tmpl.Name(), ".MAC") && d.MAC == "" {
+			if resolved {
+				continue
+			}
+			resolved = true
+			if d.MAC = t.resolveMAC(ctx, d.IP); d.MAC == "" {
+				continue
+			}
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, d); err != nil {
+			t.Log.V(1).Info("unable to execute boot files path template", "template", tmpl.Name(), "error", err.Error())
+			continue
+		}
+		p := strings.TrimPrefix(path.Clean("/"+b.String()), "/")
+		// Files of an artifact that is being pulled are only served once they're moved into place.
+		if p == "" || strings.HasPrefix(p, StagingPrefix) {
+			continue
+		}
+		f, err := root.Open(p)
+		if err != nil {
+			// Paths that escape the root are treated like files that don't exist.
+			continue
+		}
+		if fi, err := f.Stat(); err != nil || !fi.Mode().IsRegular() {
+			f.Close()
+			continue
+		}
+		return f, nil
+	}
+	if t.Generate != nil {
+		mac, _ := net.ParseMAC(d.MAC)
+		b, err := t.Generate(ctx, d.Path, mac, client)
+		if err != nil {
+			return nil, fmt.Errorf("boot file %q: %w", d.Path, err)
+		}
+		return &generatedFile{Reader: bytes.NewReader(b), info: generatedInfo{name: path.Base(d.Path), size: int64(len(b)), modTime: time.Now()}}, nil
+	}
+
+	return nil, fmt.Errorf("boot file %q: %w", d.Path, fs.ErrNotExist)
+}
+
+// generatedFile is a File with content from Tree.Generate.
+type generatedFile struct {
+	*bytes.Reader
+	info generatedInfo
+}
+
+func (g *generatedFile) Close() error { return nil }
+
+func (g *generatedFile) Stat() (fs.FileInfo, error) { return g.info, nil }
+
+// Generated reports whether fi is the info of a file generated by Tree.Generate.
+func Generated(fi fs.FileInfo) bool {
+	_, ok := fi.(generatedInfo)
+
+	return ok
+}
+
+type generatedInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (g generatedInfo) Name() string       { return g.name }
+func (g generatedInfo) Size() int64        { return g.size }
+func (g generatedInfo) Mode() fs.FileMode  { return 0o444 }
+func (g generatedInfo) ModTime() time.Time { return g.modTime }
+func (g generatedInfo) IsDir() bool        { return false }
+func (g generatedInfo) Sys() any           { return nil }
+
+// resolveMAC returns the MAC address of the Hardware interface with the IP address, or an empty string.
+func (t *Tree) resolveMAC(ctx context.Context, ip string) string {
+	if t.Backend == nil || ip == "" {
+		return ""
+	}
+	hw, err := t.Backend.FilterHardware(ctx, data.HardwareFilter{ByIPAddress: ip})
+	if err != nil || hw == nil {
+		return ""
+	}
+	for _, iface := range hw.Spec.Interfaces {
+		if iface.DHCP != nil && iface.DHCP.IP != nil && iface.DHCP.IP.Address == ip {
+			if mac, err := net.ParseMAC(iface.DHCP.MAC); err == nil {
+				return mac.String()
+			}
+		}
+	}
+
+	return ""
+}
+
+// ServeHTTP serves the file named by the request path, after Prefix.
+func (t *Tree) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	_, _ = t.Serve(w, r, strings.TrimPrefix(r.URL.Path, t.Prefix))
+}
+
+// Serve serves the file with the name over HTTP. Range requests are supported.
+// It returns the info of the served file, or an error when the file isn't served. The response is written in both cases.
+func (t *Tree) Serve(w http.ResponseWriter, r *http.Request, name string) (fs.FileInfo, error) {
+	var client netip.Addr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		client, _ = netip.ParseAddr(host)
+	}
+	f, err := t.Open(r.Context(), name, client)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			t.Log.Info("boot file not found", "name", name, "client", client)
+			http.NotFound(w, r)
+			return nil, err
+		}
+		t.Log.Error(err, "unable to open boot file", "name", name, "client", client)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		t.Log.Error(err, "unable to stat boot file", "name", name)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, err
+	}
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+	t.Log.Info("boot file served", "name", name, "file", fi.Name(), "client", client, "method", r.Method)
+
+	return fi, nil
+}
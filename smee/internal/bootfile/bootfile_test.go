@@ -0,0 +1,208 @@
+package bootfile
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"github.com/tinkerbell/tinkerbell/pkg/data"
+)
+
+type fakeBackend struct {
+	hw *tinkerbell.Hardware
+}
+
+func (f fakeBackend) FilterHardware(_ context.Context, opts data.HardwareFilter) (*tinkerbell.Hardware, error) {
+	if f.hw == nil || opts.ByIPAddress != "192.168.2.10" {
+		return nil, errors.New("not found")
+	}
+	return f.hw, nil
+}
+
+func newTree(t *testing.T, files map[string]string, templates ...string) *Tree {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ts, err := ParsePathTemplates(templates)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Tree{Log: logr.Discard(), Root: dir, Prefix: "/boot/", PathTemplates: ts}
+}
+
+func TestOpen(t *testing.T) {
+	files := map[string]string{
+		"grub/grub.cfg": "default",
+		"machines/52-54-00-12-34-01/grub/grub.cfg": "machine",
+		"bootcode.bin":                      "firmware",
+		StagingPrefix + "1234/bootcode.bin": "being pulled",
+	}
+	templates := []string{`machines/{{ .MAC | replace ":" "-" }}/{{ .Path }}`, DefaultPathTemplate}
+	tests := map[string]struct {
+		name    string
+		client  string
+		backend BackendReader
+		want    string
+		wantErr error
+	}{
+		"default":                {name: "grub/grub.cfg", want: "default"},
+		"leading slash":          {name: "/grub/grub.cfg", want: "default"},
+		"mac in path":            {name: "52:54:00:12:34:01/grub/grub.cfg", want: "machine"},
+		"mac without match":      {name: "52:54:00:12:34:02/grub/grub.cfg", want: "default"},
+		"mac from other machine": {name: "52:54:00:12:34:02/bootcode.bin", want: "firmware"},
+		"mac from backend": {
+			name:   "grub/grub.cfg",
+			client: "192.168.2.10",
+			backend: fakeBackend{hw: &tinkerbell.Hardware{Spec: tinkerbell.HardwareSpec{Interfaces: []tinkerbell.Interface{
+				{DHCP: &tinkerbell.DHCP{MAC: "52:54:00:12:34:01", IP: &tinkerbell.IP{Address: "192.168.2.10"}}},
+			}}}},
+			want: "machine",
+		},
+		"unknown client":  {name: "grub/grub.cfg", client: "192.168.2.11", backend: fakeBackend{}, want: "default"},
+		"not found":       {name: "grub/x86_64-efi/normal.mod", wantErr: fs.ErrNotExist},
+		"directory":       {name: "grub", wantErr: fs.ErrNotExist},
+		"escape":          {name: "../../etc/passwd", wantErr: fs.ErrNotExist},
+		"empty":           {name: "52:54:00:12:34:01/", wantErr: fs.ErrNotExist},
+		"no file in tree": {name: "", wantErr: fs.ErrNotExist},
+		"being pulled":    {name: StagingPrefix + "1234/bootcode.bin", wantErr: fs.ErrNotExist},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tree := newTree(t, files, templates...)
+			tree.Backend = tt.backend
+			var client netip.Addr
+			if tt.client != "" {
+				client = netip.MustParseAddr(tt.client)
+			}
+			f, err := tree.Open(context.Background(), tt.name, client)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Open() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer f.Close()
+			got, err := io.ReadAll(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Open() content = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenSymlinkEscape(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(outside, []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tree := newTree(t, nil)
+	if err := os.Symlink(outside, filepath.Join(tree.Root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.Open(context.Background(), "link", netip.Addr{}); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open() error = %v, want %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestOpenGenerate(t *testing.T) {
+	tree := newTree(t, map[string]string{"secureboot/grub.cfg": "static"})
+	tree.Generate = func(_ context.Context, name string, mac net.HardwareAddr, client netip.Addr) ([]byte, error) {
+		if path.Base(name) != "grub.cfg-01-52-54-00-12-34-01" {
+			return nil, fs.ErrNotExist
+		}
+		return []byte(name + " " + mac.String() + " " + client.String()), nil
+	}
+	tests := map[string]struct {
+		name    string
+		want    string
+		wantErr error
+	}{
+		"file in root":  {name: "secureboot/grub.cfg", want: "static"},
+		"generated":     {name: "52:54:00:12:34:01/secureboot/grub.cfg-01-52-54-00-12-34-01", want: "secureboot/grub.cfg-01-52-54-00-12-34-01 52:54:00:12:34:01 192.168.2.10"},
+		"not generated": {name: "secureboot/grub.cfg-C0A8020A", wantErr: fs.ErrNotExist},
+		"not in root":   {name: "secureboot/grubx64.efi", wantErr: fs.ErrNotExist},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := tree.Open(context.Background(), tt.name, netip.MustParseAddr("192.168.2.10"))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Open() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer f.Close()
+			got, err := io.ReadAll(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Open() content = %q, want %q", got, tt.want)
+			}
+			fi, err := f.Stat()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Size() != int64(len(tt.want)) {
+				t.Errorf("Stat() size = %d, want %d", fi.Size(), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestParsePathTemplatesInvalid(t *testing.T) {
+	if _, err := ParsePathTemplates([]string{"{{ .Path "}); err == nil {
+		t.Error("ParsePathTemplates() expected an error")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	tree := newTree(t, map[string]string{"shim/shimx64.efi": "shim"})
+	tests := map[string]struct {
+		method   string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		"found":      {method: http.MethodGet, path: "/boot/shim/shimx64.efi", wantCode: http.StatusOK, wantBody: "shim"},
+		"with mac":   {method: http.MethodGet, path: "/boot/52:54:00:12:34:01/shim/shimx64.efi", wantCode: http.StatusOK, wantBody: "shim"},
+		"head":       {method: http.MethodHead, path: "/boot/shim/shimx64.efi", wantCode: http.StatusOK},
+		"not found":  {method: http.MethodGet, path: "/boot/shim/mmx64.efi", wantCode: http.StatusNotFound},
+		"bad method": {method: http.MethodPost, path: "/boot/shim/shimx64.efi", wantCode: http.StatusMethodNotAllowed},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tree.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
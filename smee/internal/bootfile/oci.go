@@ -0,0 +1,268 @@
+package bootfile
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/containerd/containerd/v2/core/images"
+	"github.com/containerd/containerd/v2/core/remotes"
+	"github.com/containerd/containerd/v2/core/remotes/docker"
+	"github.com/containerd/platforms"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// maxManifestSize is the largest manifest or index that is read.
+const maxManifestSize = 4 << 20
+
+// StagingPrefix is the name prefix of the directory in the boot files directory that an artifact is extracted into
+// before its files are moved into place. Tree doesn't serve files from it.
+const StagingPrefix = ".pull-"
+
+// Pull extracts the layers of an OCI artifact, for example ghcr.io/example/bootfiles:v1, into dir.
+// Layers that are tar archives, optionally gzip compressed, are extracted. Any other layer is a single file
+// that is written to the path in its org.opencontainers.image.title annotation, as pushed by oras.
+// Existing files are overwritten, files that aren't in the artifact are kept. Only regular files and directories are extracted.
+// The artifact is extracted and verified in a staging directory inside dir first, and its files are then moved into place
+// with a rename each, so that a file being served is never partially written and a failed pull leaves dir unchanged.
+// When ref is an index, the manifest for the platform of the host is used, see selectManifest. Registries are accessed anonymously.
+// It returns the digest of the manifest.
+func Pull(ctx context.Context, ref, dir string) (string, error) {
+	resolver := docker.NewResolver(docker.ResolverOptions{})
+	name, desc, err := resolver.Resolve(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("resolve %q: %w", ref, err)
+	}
+	fetcher, err := resolver.Fetcher(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("fetcher for %q: %w", ref, err)
+	}
+
+	if images.IsIndexType(desc.MediaType) {
+		var index ocispec.Index
+		if err := fetchJSON(ctx, fetcher, desc, &index); err != nil {
+			return "", err
+		}
+		if len(index.Manifests) == 0 {
+			return "", fmt.Errorf("index %v of %q has no manifests", desc.Digest, ref)
+		}
+		m, err := selectManifest(index, platforms.DefaultSpec())
+		if err != nil {
+			return "", fmt.Errorf("index %v of %q: %w", desc.Digest, ref, err)
+		}
+		desc = m
+	}
+	if !images.IsManifestType(desc.MediaType) {
+		return "", fmt.Errorf("unsupported media type %q of %q", desc.MediaType, ref)
+	}
+	var manifest ocispec.Manifest
+	if err := fetchJSON(ctx, fetcher, desc, &manifest); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	staging, err := os.MkdirTemp(dir, StagingPrefix+"*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(staging)
+	sroot, err := os.OpenRoot(staging)
+	if err != nil {
+		return "", err
+	}
+	defer sroot.Close()
+	for _, layer := range manifest.Layers {
+		if err := extractLayer(ctx, fetcher, layer, sroot); err != nil {
+			return "", fmt.Errorf("layer %v of %q: %w", layer.Digest, ref, err)
+		}
+	}
+	if err := moveInto(dir, filepath.Base(staging)); err != nil {
+		return "", fmt.Errorf("artifact %q: %w", ref, err)
+	}
+
+	return desc.Digest.String(), nil
+}
+
+// moveInto moves the regular files of the directory staging, a name in dir, to the same paths in dir, replacing existing files.
+func moveInto(dir, staging string) error {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	return fs.WalkDir(root.FS(), staging, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		name := strings.TrimPrefix(p, staging+"/")
+		if err := root.MkdirAll(path.Dir(name), 0o755); err != nil {
+			return err
+		}
+		return root.Rename(p, name)
+	})
+}
+
+// selectManifest returns the manifest of index that best matches platform. An index with a single manifest is used
+// whatever its platform, as artifacts that aren't platform specific don't always set one.
+// An index with more than one manifest must have one for platform.
+func selectManifest(index ocispec.Index, platform ocispec.Platform) (ocispec.Descriptor, error) {
+	if len(index.Manifests) == 1 {
+		return index.Manifests[0], nil
+	}
+	matcher := platforms.Only(platform)
+	var (
+		best      *ocispec.Descriptor
+		available []string
+	)
+	for i, m := range index.Manifests {
+		if m.Platform == nil {
+			available = append(available, "unknown")
+			continue
+		}
+		available = append(available, platforms.Format(*m.Platform))
+		if !matcher.Match(*m.Platform) {
+			continue
+		}
+		if best == nil || matcher.Less(*m.Platform, *best.Platform) {
+			best = &index.Manifests[i]
+		}
+	}
+	if best == nil {
+		return ocispec.Descriptor{}, fmt.Errorf("no manifest for platform %s, available: %s", platforms.Format(platform), strings.Join(available, ", "))
+	}
+
+	return *best, nil
+}
+
+// fetch returns a reader of the blob that fails on EOF when the content doesn't match the digest of the descriptor.
+func fetch(ctx context.Context, fetcher remotes.Fetcher, desc ocispec.Descriptor) (io.ReadCloser, error) {
+	rc, err := fetcher.Fetch(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+
+	verifier := desc.Digest.Verifier()
+
+	return &verifiedReader{ReadCloser: rc, r: io.TeeReader(rc, verifier), verifier: verifier, digest: desc.Digest}, nil
+}
+
+type verifiedReader struct {
+	io.ReadCloser
+	r        io.Reader
+	verifier digest.Verifier
+	digest   digest.Digest
+}
+
+func (v *verifiedReader) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	if errors.Is(err, io.EOF) && !v.verifier.Verified() {
+		return n, fmt.Errorf("content of %v doesn't match its digest", v.digest)
+	}
+	return n, err
+}
+
+func fetchJSON(ctx context.Context, fetcher remotes.Fetcher, desc ocispec.Descriptor, v any) error {
+	rc, err := fetch(ctx, fetcher, desc)
+	if err != nil {
+		return fmt.Errorf("fetch %v: %w", desc.Digest, err)
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(io.LimitReader(rc, maxManifestSize))
+	if err != nil {
+		return fmt.Errorf("read %v: %w", desc.Digest, err)
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+func extractLayer(ctx context.Context, fetcher remotes.Fetcher, desc ocispec.Descriptor, root *os.Root) error {
+	rc, err := fetch(ctx, fetcher, desc)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if !strings.Contains(desc.MediaType, ".tar") {
+		name := desc.Annotations[ocispec.AnnotationTitle]
+		if name == "" {
+			return fmt.Errorf("layer of media type %q has no %s annotation", desc.MediaType, ocispec.AnnotationTitle)
+		}
+		return writeFile(root, name, 0o644, rc)
+	}
+
+	var r io.Reader = rc
+	switch {
+	case strings.HasSuffix(desc.MediaType, "gzip"):
+		gr, err := gzip.NewReader(rc)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		r = gr
+	case strings.HasSuffix(desc.MediaType, ".tar"):
+	default:
+		return fmt.Errorf("unsupported layer media type %q", desc.MediaType)
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := root.MkdirAll(cleanName(hdr.Name), 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(root, hdr.Name, hdr.FileInfo().Mode().Perm(), tr); err != nil {
+				return err
+			}
+		}
+	}
+	// Read any trailing data so that the digest is verified.
+	_, err = io.Copy(io.Discard, rc)
+
+	return err
+}
+
+func writeFile(root *os.Root, name string, perm os.FileMode, r io.Reader) error {
+	name = cleanName(name)
+	if name == "." {
+		return fmt.Errorf("invalid file name %q", name)
+	}
+	if err := root.MkdirAll(path.Dir(name), 0o755); err != nil {
+		return err
+	}
+	f, err := root.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// cleanName returns a path relative to the root. Paths that escape the root are rejected by os.Root.
+func cleanName(name string) string {
+	return path.Clean(strings.TrimPrefix(path.Clean("/"+name), "/"))
+}
@@ -0,0 +1,263 @@
+package bootfile
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containerd/platforms"
+	"github.com/google/go-cmp/cmp"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// registry is a minimal read-only OCI distribution API serving a single repository.
+type registry struct {
+	repo      string
+	manifests map[string]ocispec.Descriptor
+	blobs     map[digest.Digest][]byte
+}
+
+func (r *registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/v2/" {
+		return
+	}
+	prefix := "/v2/" + r.repo + "/"
+	rest, ok := strings.CutPrefix(req.URL.Path, prefix)
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	kind, ref, _ := strings.Cut(rest, "/")
+	var desc ocispec.Descriptor
+	switch kind {
+	case "manifests":
+		if d, ok := r.manifests[ref]; ok {
+			desc = d
+		} else {
+			desc = ocispec.Descriptor{Digest: digest.Digest(ref), MediaType: ocispec.MediaTypeImageManifest}
+		}
+	case "blobs":
+		desc = ocispec.Descriptor{Digest: digest.Digest(ref), MediaType: "application/octet-stream"}
+	}
+	b, ok := r.blobs[desc.Digest]
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", desc.MediaType)
+	w.Header().Set("Docker-Content-Digest", desc.Digest.String())
+	http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(b))
+}
+
+func (r *registry) add(mediaType string, b []byte, annotations map[string]string) ocispec.Descriptor {
+	d := ocispec.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(b), Size: int64(len(b)), Annotations: annotations}
+	r.blobs[d.Digest] = b
+
+	return d
+}
+
+func tarGzip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "../escape", Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestPull(t *testing.T) {
+	reg := &registry{repo: "boot/files", manifests: map[string]ocispec.Descriptor{}, blobs: map[digest.Digest][]byte{}}
+	layers := []ocispec.Descriptor{
+		reg.add(ocispec.MediaTypeImageLayerGzip, tarGzip(t, map[string]string{"grub/grub.cfg": "grub config", "shimx64.efi": "shim"}), nil),
+		reg.add("application/vnd.example.file", []byte("firmware"), map[string]string{ocispec.AnnotationTitle: "rpi/start4.elf"}),
+	}
+	config := reg.add(ocispec.MediaTypeEmptyJSON, []byte("{}"), nil)
+	mb, err := json.Marshal(ocispec.Manifest{Versioned: specs.Versioned{SchemaVersion: 2}, MediaType: ocispec.MediaTypeImageManifest, Config: config, Layers: layers})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.manifests["v1"] = reg.add(ocispec.MediaTypeImageManifest, mb, nil)
+
+	srv := httptest.NewServer(reg)
+	defer srv.Close()
+
+	dir := filepath.Join(t.TempDir(), "boot")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"shimx64.efi": "old shim", "local.cfg": "local"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d, err := Pull(context.Background(), strings.TrimPrefix(srv.URL, "http://")+"/boot/files:v1", dir)
+	if err != nil {
+		t.Fatalf("Pull() error = %v", err)
+	}
+	if d != reg.manifests["v1"].Digest.String() {
+		t.Errorf("Pull() digest = %s, want %s", d, reg.manifests["v1"].Digest)
+	}
+	for name, want := range map[string]string{"grub/grub.cfg": "grub config", "shimx64.efi": "shim", "rpi/start4.elf": "firmware", "local.cfg": "local"} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("reading %s: %v", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(filepath.Dir(dir), "escape")); !os.IsNotExist(err) {
+		t.Errorf("expected symlinks not to be extracted, got %v", err)
+	}
+	if staged, _ := filepath.Glob(filepath.Join(dir, StagingPrefix+"*")); len(staged) != 0 {
+		t.Errorf("expected the staging directory to be removed, got %v", staged)
+	}
+}
+
+func TestPullDigestMismatch(t *testing.T) {
+	reg := &registry{repo: "boot/files", manifests: map[string]ocispec.Descriptor{}, blobs: map[digest.Digest][]byte{}}
+	layer := reg.add("application/vnd.example.file", []byte("firmware"), map[string]string{ocispec.AnnotationTitle: "start4.elf"})
+	reg.blobs[layer.Digest] = []byte("tampered")
+	config := reg.add(ocispec.MediaTypeEmptyJSON, []byte("{}"), nil)
+	mb, err := json.Marshal(ocispec.Manifest{Versioned: specs.Versioned{SchemaVersion: 2}, MediaType: ocispec.MediaTypeImageManifest, Config: config, Layers: []ocispec.Descriptor{layer}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.manifests["v1"] = reg.add(ocispec.MediaTypeImageManifest, mb, nil)
+
+	srv := httptest.NewServer(reg)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "start4.elf"), []byte("old firmware"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Pull(context.Background(), strings.TrimPrefix(srv.URL, "http://")+"/boot/files:v1", dir); err == nil {
+		t.Error("Pull() expected a digest mismatch error")
+	}
+	// A failed pull leaves the directory unchanged.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the existing file, got %v", entries)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "start4.elf")); string(got) != "old firmware" {
+		t.Errorf("start4.elf = %q, want %q", got, "old firmware")
+	}
+}
+
+func TestPullIndex(t *testing.T) {
+	reg := &registry{repo: "boot/files", manifests: map[string]ocispec.Descriptor{}, blobs: map[digest.Digest][]byte{}}
+	config := reg.add(ocispec.MediaTypeEmptyJSON, []byte("{}"), nil)
+	host := platforms.DefaultSpec()
+	other := ocispec.Platform{OS: "plan9", Architecture: "386"}
+	var manifests []ocispec.Descriptor
+	for _, p := range []ocispec.Platform{other, host} {
+		layer := reg.add("application/vnd.example.file", []byte(platforms.Format(p)), map[string]string{ocispec.AnnotationTitle: "platform"})
+		mb, err := json.Marshal(ocispec.Manifest{Versioned: specs.Versioned{SchemaVersion: 2}, MediaType: ocispec.MediaTypeImageManifest, Config: config, Layers: []ocispec.Descriptor{layer}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := reg.add(ocispec.MediaTypeImageManifest, mb, nil)
+		m.Platform = &p
+		manifests = append(manifests, m)
+	}
+	ib, err := json.Marshal(ocispec.Index{Versioned: specs.Versioned{SchemaVersion: 2}, MediaType: ocispec.MediaTypeImageIndex, Manifests: manifests})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.manifests["v1"] = reg.add(ocispec.MediaTypeImageIndex, ib, nil)
+	ob, err := json.Marshal(ocispec.Index{Versioned: specs.Versioned{SchemaVersion: 2}, MediaType: ocispec.MediaTypeImageIndex, Manifests: manifests[:1:1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.manifests["other"] = reg.add(ocispec.MediaTypeImageIndex, ob, nil)
+
+	srv := httptest.NewServer(reg)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	d, err := Pull(context.Background(), strings.TrimPrefix(srv.URL, "http://")+"/boot/files:v1", dir)
+	if err != nil {
+		t.Fatalf("Pull() error = %v", err)
+	}
+	if d != manifests[1].Digest.String() {
+		t.Errorf("Pull() digest = %s, want the manifest of the host platform %s", d, manifests[1].Digest)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "platform"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != platforms.Format(host) {
+		t.Errorf("platform = %q, want %q", got, platforms.Format(host))
+	}
+
+	// A single manifest is used whatever its platform.
+	if d, err := Pull(context.Background(), strings.TrimPrefix(srv.URL, "http://")+"/boot/files:other", dir); err != nil || d != manifests[0].Digest.String() {
+		t.Errorf("Pull() = %s, %v, want %s", d, err, manifests[0].Digest)
+	}
+}
+
+func TestSelectManifest(t *testing.T) {
+	amd64 := ocispec.Descriptor{Digest: digest.FromString("amd64"), Platform: &ocispec.Platform{OS: "linux", Architecture: "amd64"}}
+	arm64 := ocispec.Descriptor{Digest: digest.FromString("arm64"), Platform: &ocispec.Platform{OS: "linux", Architecture: "arm64"}}
+	armv6 := ocispec.Descriptor{Digest: digest.FromString("armv6"), Platform: &ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}}
+	armv7 := ocispec.Descriptor{Digest: digest.FromString("armv7"), Platform: &ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}}
+	unknown := ocispec.Descriptor{Digest: digest.FromString("unknown")}
+
+	tests := map[string]struct {
+		manifests []ocispec.Descriptor
+		platform  ocispec.Platform
+		want      ocispec.Descriptor
+		wantErr   bool
+	}{
+		"matching platform":           {manifests: []ocispec.Descriptor{amd64, arm64}, platform: ocispec.Platform{OS: "linux", Architecture: "arm64"}, want: arm64},
+		"best variant":                {manifests: []ocispec.Descriptor{armv6, armv7}, platform: ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, want: armv7},
+		"compatible variant":          {manifests: []ocispec.Descriptor{amd64, armv6}, platform: ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, want: armv6},
+		"single manifest":             {manifests: []ocispec.Descriptor{amd64}, platform: ocispec.Platform{OS: "linux", Architecture: "arm64"}, want: amd64},
+		"single manifest no platform": {manifests: []ocispec.Descriptor{unknown}, platform: ocispec.Platform{OS: "linux", Architecture: "arm64"}, want: unknown},
+		"no matching platform":        {manifests: []ocispec.Descriptor{amd64, unknown}, platform: ocispec.Platform{OS: "linux", Architecture: "arm64"}, wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := selectManifest(ocispec.Index{Manifests: tt.manifests}, tt.platform)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectManifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -2,11 +2,13 @@ package dhcp
 
 import (
 	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net"
 	"net/netip"
 	"net/url"
+	"slices"
 
 	"github.com/ccoveille/go-safecast/v2"
 	"github.com/insomniacslk/dhcp/dhcpv4"
@@ -67,7 +69,7 @@ func ConvertByIP(ctx context.Context, ip net.IP, hw *v1alpha1.Hardware) (Hardwar
 	}
 	i := v1alpha1.Interface{}
 	for _, iface := range hw.Spec.Interfaces {
-		if iface.DHCP != nil && iface.DHCP.IP != nil && iface.DHCP.IP.Address == ip.String() {
+		if slices.Contains(iface.DHCP.IPAddresses(), ip.String()) {
 			i = iface
 			break
 		}
@@ -99,6 +101,80 @@ func ConvertByIP(ctx context.Context, ip net.IP, hw *v1alpha1.Hardware) (Hardwar
 	return result, nil
 }
 
+// ConvertByRelayAgentInfo converts the interface of hw whose relay agent information has key.
+func ConvertByRelayAgentInfo(ctx context.Context, key string, hw *v1alpha1.Hardware) (Hardware, error) {
+	tracer := otel.Tracer(tracerName)
+	_, span := tracer.Start(ctx, "smee.internal.data.ConvertByRelayAgentInfo")
+	defer span.End()
+	if hw == nil {
+		return Hardware{}, errors.New("hardware is nil")
+	}
+	i := v1alpha1.Interface{}
+	for _, iface := range hw.Spec.Interfaces {
+		if iface.DHCP != nil && iface.DHCP.RelayAgentInfo.Key() == key {
+			i = iface
+			break
+		}
+	}
+
+	d, n, err := transform(i, hw.Spec.Metadata)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return Hardware{}, err
+	}
+
+	result := Hardware{DHCP: d, Netboot: n, AgentID: hw.Spec.AgentID}
+
+	if i.Isoboot != nil && i.Isoboot.SourceISO != "" {
+		si, err := url.Parse(i.Isoboot.SourceISO)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+
+			return Hardware{}, fmt.Errorf("failed to parse source ISO as a URL %q: %w", i.Isoboot.SourceISO, err)
+		}
+		result.Isoboot = &Isoboot{SourceISO: si}
+	}
+
+	span.SetAttributes(d.EncodeToAttributes()...)
+	span.SetAttributes(n.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "")
+
+	return result, nil
+}
+
+// RelayAgentInfo returns the circuit ID and remote ID from the relay agent information (option 82) of pkt.
+// nil is returned when pkt has no circuit ID. Values that are not printable are formatted as hex with a 0x prefix.
+func RelayAgentInfo(pkt *dhcpv4.DHCPv4) *v1alpha1.RelayAgentInfo {
+	ro := pkt.RelayAgentInfo()
+	if ro == nil {
+		return nil
+	}
+	circuit := ro.Get(dhcpv4.AgentCircuitIDSubOption)
+	if len(circuit) == 0 {
+		return nil
+	}
+
+	return &v1alpha1.RelayAgentInfo{
+		CircuitID: relayAgentString(circuit),
+		RemoteID:  relayAgentString(ro.Get(dhcpv4.AgentRemoteIDSubOption)),
+	}
+}
+
+// relayAgentString returns b as a string when it is printable ASCII, otherwise as hex with a 0x prefix.
+func relayAgentString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	for _, c := range b {
+		if c < 0x20 || c > 0x7e {
+			return "0x" + hex.EncodeToString(b)
+		}
+	}
+
+	return string(b)
+}
+
 // toDHCPData converts a v1alpha1.DHCP to a DHCP data structure.
 // Fields that are set are checked for correctness of their types.
 func toDHCPData(h *v1alpha1.DHCP) (*DHCP, error) {
@@ -136,6 +212,25 @@ func toDHCPData(h *v1alpha1.DHCP) (*DHCP, error) {
 		}
 	}
 
+	// IPv6 addresses, optional
+	if d.IPAddress.Is6() && !d.IPAddress.Is4In6() {
+		d.IPv6Addresses = append(d.IPv6Addresses, d.IPAddress)
+	}
+	if h.IPv6 != nil {
+		for _, s := range h.IPv6.Addresses {
+			a, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, err
+			}
+			if !a.Is6() || a.Is4In6() {
+				return nil, fmt.Errorf("%q is not an IPv6 address", s)
+			}
+			if !slices.Contains(d.IPv6Addresses, a) {
+				d.IPv6Addresses = append(d.IPv6Addresses, a)
+			}
+		}
+	}
+
 	// Gateway is optional, but should be a valid IP address if present
 	if h.IP != nil && h.IP.Gateway != "" {
 		if d.DefaultGateway, err = netip.ParseAddr(h.IP.Gateway); err != nil {
@@ -204,6 +299,17 @@ func toDHCPData(h *v1alpha1.DHCP) (*DHCP, error) {
 	// vlanid
 	d.VLANID = h.VLANID
 
+	// raw options, optional
+	// An option whose value can't be encoded is skipped, instead of failing the conversion, so that the machine still gets DHCP.
+	for _, o := range h.Options {
+		opt, err := EncodeOption(int(o.Code), OptionType(o.Type), o.Value)
+		if err != nil {
+			d.InvalidOptions = append(d.InvalidOptions, err)
+			continue
+		}
+		d.Options = append(d.Options, opt)
+	}
+
 	// classless static routes, optional
 	for _, route := range h.ClasslessStaticRoutes {
 		_, destNetwork, err := net.ParseCIDR(route.DestinationDescriptor)
@@ -256,6 +362,23 @@ func toNetbootData(i *v1alpha1.Netboot, facility string) (*Netboot, error) {
 		n.IPXEBinary = i.IPXE.Binary
 	}
 
+	// ipxe script template
+	if i.IPXE != nil {
+		n.IPXETemplateRef = i.IPXE.TemplateRef
+	}
+
+	// ipxe menu entries
+	if i.IPXE != nil {
+		for _, e := range i.IPXE.Menu {
+			n.IPXEMenu = append(n.IPXEMenu, IPXEMenuEntry{Name: e.Name, Label: e.Label, URL: e.URL, Script: e.Script})
+		}
+	}
+
+	// secure boot
+	if i.SecureBoot != nil {
+		n.SecureBoot = *i.SecureBoot
+	}
+
 	// console
 	n.Console = ""
 
@@ -270,6 +393,9 @@ func toNetbootData(i *v1alpha1.Netboot, facility string) (*Netboot, error) {
 		}
 		n.OSIE.Kernel = i.OSIE.Kernel
 		n.OSIE.Initrd = i.OSIE.Initrd
+		n.OSIE.Version = i.OSIE.Version
+		n.OSIE.KernelParams = i.OSIE.KernelParams
+		n.OSIE.Consoles = i.OSIE.Consoles
 	}
 
 	return n, nil
@@ -9,6 +9,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
 )
 
@@ -71,8 +72,9 @@ func TestConvertByMac(t *testing.T) {
 								},
 							},
 							Netboot: &tinkerbell.Netboot{
-								AllowPXE: boolPtr(true),
-								IPXE:     &tinkerbell.IPXE{URL: "http://example.com/auto.ipxe"},
+								AllowPXE:   boolPtr(true),
+								IPXE:       &tinkerbell.IPXE{URL: "http://example.com/auto.ipxe"},
+								SecureBoot: boolPtr(true),
 							},
 						},
 					},
@@ -91,6 +93,7 @@ func TestConvertByMac(t *testing.T) {
 				Netboot: &Netboot{
 					AllowNetboot:  true,
 					IPXEScriptURL: &url.URL{Scheme: "http", Host: "example.com", Path: "/auto.ipxe"},
+					SecureBoot:    true,
 				},
 			},
 		},
@@ -264,10 +267,11 @@ func TestConvertByMac(t *testing.T) {
 			},
 			want: Hardware{
 				DHCP: &DHCP{
-					MACAddress: net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff},
-					IPAddress:  netip.MustParseAddr("2001:db8::1"),
-					Hostname:   "ipv6-host",
-					LeaseTime:  0,
+					MACAddress:    net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff},
+					IPAddress:     netip.MustParseAddr("2001:db8::1"),
+					IPv6Addresses: []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+					Hostname:      "ipv6-host",
+					LeaseTime:     0,
 				},
 				Netboot: &Netboot{
 					AllowNetboot: true,
@@ -317,6 +321,7 @@ func TestConvertByMac(t *testing.T) {
 				DHCP: &DHCP{
 					MACAddress:     net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff},
 					IPAddress:      netip.MustParseAddr("2001:db8::1"),
+					IPv6Addresses:  []netip.Addr{netip.MustParseAddr("2001:db8::1")},
 					DefaultGateway: netip.MustParseAddr("2001:db8::fffe"),
 					Hostname:       "ipv6-gw",
 					LeaseTime:      0,
@@ -359,6 +364,56 @@ func TestConvertByMac(t *testing.T) {
 				},
 			},
 		},
+		"dual stack with ipv6 addresses": {
+			mac: net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff},
+			hw: &tinkerbell.Hardware{
+				Spec: tinkerbell.HardwareSpec{
+					Interfaces: []tinkerbell.Interface{
+						{
+							DHCP: &tinkerbell.DHCP{
+								MAC: "aa:bb:cc:dd:ee:ff",
+								IP: &tinkerbell.IP{
+									Address: "10.0.0.1",
+									Netmask: "255.255.255.0",
+								},
+								IPv6: &tinkerbell.IPv6{
+									Addresses: []string{"2001:db8::1", "2001:db8:0:1::1", "2001:db8::1"},
+								},
+							},
+							Netboot: &tinkerbell.Netboot{AllowPXE: boolPtr(true)},
+						},
+					},
+				},
+			},
+			want: Hardware{
+				DHCP: &DHCP{
+					MACAddress:       net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff},
+					IPAddress:        netip.MustParseAddr("10.0.0.1"),
+					SubnetMask:       net.IPv4Mask(255, 255, 255, 0),
+					BroadcastAddress: netip.MustParseAddr("10.0.0.255"),
+					IPv6Addresses:    []netip.Addr{netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("2001:db8:0:1::1")},
+				},
+				Netboot: &Netboot{
+					AllowNetboot: true,
+				},
+			},
+		},
+		"ipv4 address in ipv6 addresses errors": {
+			mac: net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff},
+			hw: &tinkerbell.Hardware{
+				Spec: tinkerbell.HardwareSpec{
+					Interfaces: []tinkerbell.Interface{
+						{
+							DHCP: &tinkerbell.DHCP{
+								MAC:  "aa:bb:cc:dd:ee:ff",
+								IPv6: &tinkerbell.IPv6{Addresses: []string{"10.0.0.1"}},
+							},
+						},
+					},
+				},
+			},
+			shouldErr: true,
+		},
 		"ipv4 netmask as ipv6 errors": {
 			mac: net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff},
 			hw: &tinkerbell.Hardware{
@@ -572,3 +627,119 @@ func TestConvertByIP(t *testing.T) {
 		})
 	}
 }
+
+func TestConvertByRelayAgentInfo(t *testing.T) {
+	port := &tinkerbell.RelayAgentInfo{CircuitID: "Gi1/0/10", RemoteID: "tor-1"}
+	hw := &tinkerbell.Hardware{
+		Spec: tinkerbell.HardwareSpec{
+			Interfaces: []tinkerbell.Interface{
+				{
+					DHCP: &tinkerbell.DHCP{
+						MAC:      "aa:bb:cc:dd:ee:ff",
+						Hostname: "other-port",
+						IP:       &tinkerbell.IP{Address: "10.0.0.1", Netmask: "255.255.255.0"},
+						RelayAgentInfo: &tinkerbell.RelayAgentInfo{
+							CircuitID: "Gi1/0/11",
+						},
+					},
+				},
+				{
+					DHCP: &tinkerbell.DHCP{
+						MAC:            "00:11:22:33:44:55",
+						Hostname:       "by-port",
+						IP:             &tinkerbell.IP{Address: "10.0.0.2", Netmask: "255.255.255.0"},
+						RelayAgentInfo: port,
+					},
+					Netboot: &tinkerbell.Netboot{AllowPXE: boolPtr(true)},
+				},
+			},
+		},
+	}
+	got, err := ConvertByRelayAgentInfo(context.Background(), port.Key(), hw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Hardware{
+		DHCP: &DHCP{
+			MACAddress:       net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+			IPAddress:        netip.MustParseAddr("10.0.0.2"),
+			SubnetMask:       net.IPv4Mask(255, 255, 255, 0),
+			Hostname:         "by-port",
+			BroadcastAddress: netip.MustParseAddr("10.0.0.255"),
+		},
+		Netboot: &Netboot{AllowNetboot: true},
+	}
+	if diff := cmp.Diff(got, want, cmpopts.IgnoreUnexported(netip.Addr{})); diff != "" {
+		t.Fatalf("mismatch (-got +want):\n%s", diff)
+	}
+	if _, err := ConvertByRelayAgentInfo(context.Background(), "circuit_id=unknown,remote_id=", hw); err == nil {
+		t.Fatal("expected error for unknown relay agent information")
+	}
+}
+
+func TestConvertByMacSkipsInvalidOptions(t *testing.T) {
+	hw := &tinkerbell.Hardware{
+		Spec: tinkerbell.HardwareSpec{
+			Interfaces: []tinkerbell.Interface{{
+				DHCP: &tinkerbell.DHCP{
+					MAC: "00:11:22:33:44:55",
+					IP:  &tinkerbell.IP{Address: "10.0.0.2", Netmask: "255.255.255.0"},
+					Options: []tinkerbell.DHCPOption{
+						{Code: 150, Type: "ip", Value: "10.0.0.300"},
+						{Code: 224, Type: "string", Value: "rack-1"},
+						{Code: 225, Type: "uint8", Value: "256"},
+					},
+				},
+				Netboot: &tinkerbell.Netboot{},
+			}},
+		},
+	}
+	got, err := ConvertByMac(context.Background(), net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}, hw)
+	if err != nil {
+		t.Fatalf("ConvertByMac() error = %v", err)
+	}
+	if diff := cmp.Diff([]dhcpv4.Option{dhcpv4.OptGeneric(dhcpv4.GenericOptionCode(224), []byte("rack-1"))}, got.DHCP.Options); diff != "" {
+		t.Errorf("unexpected options (-want +got):\n%s", diff)
+	}
+	if len(got.DHCP.InvalidOptions) != 2 {
+		t.Errorf("expected 2 invalid options, got %v", got.DHCP.InvalidOptions)
+	}
+}
+
+func TestRelayAgentInfo(t *testing.T) {
+	tests := map[string]struct {
+		opts []dhcpv4.Option
+		want *tinkerbell.RelayAgentInfo
+	}{
+		"no option 82": {},
+		"no circuit id": {
+			opts: []dhcpv4.Option{dhcpv4.OptGeneric(dhcpv4.AgentRemoteIDSubOption, []byte("tor-1"))},
+		},
+		"circuit id only": {
+			opts: []dhcpv4.Option{dhcpv4.OptGeneric(dhcpv4.AgentCircuitIDSubOption, []byte("Gi1/0/10"))},
+			want: &tinkerbell.RelayAgentInfo{CircuitID: "Gi1/0/10"},
+		},
+		"binary values": {
+			opts: []dhcpv4.Option{
+				dhcpv4.OptGeneric(dhcpv4.AgentCircuitIDSubOption, []byte{0x00, 0x04, 0x00, 0x0a}),
+				dhcpv4.OptGeneric(dhcpv4.AgentRemoteIDSubOption, []byte{0x00, 0x06, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}),
+			},
+			want: &tinkerbell.RelayAgentInfo{CircuitID: "0x0004000a", RemoteID: "0x0006aabbccddeeff"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var mods []dhcpv4.Modifier
+			if tt.opts != nil {
+				mods = append(mods, dhcpv4.WithOption(dhcpv4.OptRelayAgentInfo(tt.opts...)))
+			}
+			pkt, err := dhcpv4.New(mods...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(RelayAgentInfo(pkt), tt.want); diff != "" {
+				t.Fatalf("mismatch (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
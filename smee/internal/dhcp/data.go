@@ -43,17 +43,31 @@ type DHCP struct {
 	DomainSearch          []string         // DHCP option 119.
 	ClasslessStaticRoutes dhcpv4.Routes    // DHCP option 121 - RFC 3442.
 	Disabled              bool             // If true, no DHCP response should be sent.
+	IPv6Addresses         []netip.Addr     // DHCPv6 option 3 (IA_NA) addresses.
+	Options               []dhcpv4.Option  // Raw DHCP options, applied after all other options.
+	InvalidOptions        []error          // Raw DHCP options that couldn't be encoded. They are skipped so that the other options are still sent.
 }
 
 // Netboot holds info used in netbooting a client.
 type Netboot struct {
-	AllowNetboot  bool     // If true, the client will be provided netboot options in the DHCP offer/ack.
-	IPXEScriptURL *url.URL // Overrides a default value that is passed into DHCP on startup.
-	IPXEScript    string   // Overrides a default value that is passed into DHCP on startup.
-	IPXEBinary    string   // Overrides Smee's default architecture to binary mapping.
-	Console       string
-	Facility      string
-	OSIE          OSIE
+	AllowNetboot    bool            // If true, the client will be provided netboot options in the DHCP offer/ack.
+	IPXEScriptURL   *url.URL        // Overrides a default value that is passed into DHCP on startup.
+	IPXEScript      string          // Overrides a default value that is passed into DHCP on startup.
+	IPXEBinary      string          // Overrides Smee's default architecture to binary mapping.
+	IPXETemplateRef string          // Name of an IPXEScript to serve instead of the built-in Hook script.
+	IPXEMenu        []IPXEMenuEntry // Custom entries for the iPXE boot menu.
+	SecureBoot      bool            // If true, UEFI clients are booted through a signed shim and GRUB instead of iPXE.
+	Console         string
+	Facility        string
+	OSIE            OSIE
+}
+
+// IPXEMenuEntry is a custom entry in the iPXE boot menu.
+type IPXEMenuEntry struct {
+	Name   string // Name identifies the entry in the menu.
+	Label  string // Label is the text shown in the menu.
+	URL    string // URL is chain loaded when the entry is selected.
+	Script string // Script are iPXE commands run when the entry is selected.
 }
 
 // Isoboot holds info used in booting a client using an ISO image.
@@ -72,6 +86,12 @@ type OSIE struct {
 	Kernel string
 	// Initrd is the name of the initrd file.
 	Initrd string
+	// Version is the HookOS version, a directory under the OSIE URL.
+	Version string
+	// KernelParams are extra kernel parameters.
+	KernelParams []string
+	// Consoles replace the default consoles, for example ttyS0,115200.
+	Consoles []string
 }
 
 // EncodeToAttributes returns a slice of opentelemetry attributes that can be used to set span.SetAttributes.
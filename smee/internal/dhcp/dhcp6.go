@@ -0,0 +1,280 @@
+package dhcp
+
+import (
+	"crypto/sha256"
+	"errors"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+// Packet6 holds the data that is passed to a DHCPv6 handler.
+type Packet6 struct {
+	// Peer is the address of the client or relay agent that sent the DHCPv6 message.
+	Peer net.Addr
+	// Pkt is the DHCPv6 message from the client. Relay messages are already decapsulated.
+	Pkt *dhcpv6.Message
+	// Relay is the outermost relay message when the client message was forwarded by a relay agent, otherwise it is nil.
+	Relay *dhcpv6.RelayMessage
+	// Md is the metadata that was passed to the DHCPv6 server.
+	Md *Metadata
+}
+
+// ErrNoMAC is used when the MAC address of a DHCPv6 client can not be determined.
+var ErrNoMAC = errors.New("could not determine client MAC address")
+
+// MAC returns the MAC address of the DHCPv6 client.
+// DHCPv6 messages don't have a chaddr header so the MAC address is taken from, in order of preference:
+// the client link-layer address option (79) added by a relay agent, a DUID-LL or DUID-LLT client identifier,
+// or the EUI-64 interface identifier of a link-local source address.
+func (p Packet6) MAC() (net.HardwareAddr, error) {
+	var linkPeer net.IP
+	if p.Relay != nil {
+		var r dhcpv6.DHCPv6 = p.Relay
+		for r.IsRelay() {
+			rm, ok := r.(*dhcpv6.RelayMessage)
+			if !ok {
+				break
+			}
+			// The relay agent closest to the client, the innermost relay message, knows the client link-layer address.
+			linkPeer = rm.PeerAddr
+			if typ, mac := rm.Options.ClientLinkLayerAddress(); typ == iana.HWTypeEthernet && len(mac) == 6 {
+				return mac, nil
+			}
+			next, err := dhcpv6.DecapsulateRelay(rm)
+			if err != nil {
+				break
+			}
+			r = next
+		}
+	}
+
+	if p.Pkt != nil {
+		switch duid := p.Pkt.Options.ClientID().(type) {
+		case *dhcpv6.DUIDLL:
+			if duid.HWType == iana.HWTypeEthernet && len(duid.LinkLayerAddr) == 6 {
+				return duid.LinkLayerAddr, nil
+			}
+		case *dhcpv6.DUIDLLT:
+			if duid.HWType == iana.HWTypeEthernet && len(duid.LinkLayerAddr) == 6 {
+				return duid.LinkLayerAddr, nil
+			}
+		}
+	}
+
+	if linkPeer == nil {
+		if u, ok := p.Peer.(*net.UDPAddr); ok {
+			linkPeer = u.IP
+		}
+	}
+	if mac := macFromEUI64(linkPeer); mac != nil {
+		return mac, nil
+	}
+
+	return nil, ErrNoMAC
+}
+
+// macFromEUI64 returns the MAC address encoded in the modified EUI-64 interface identifier of a link-local address.
+// It returns nil if ip is not a link-local address with an EUI-64 interface identifier.
+func macFromEUI64(ip net.IP) net.HardwareAddr {
+	if ip == nil || !ip.IsLinkLocalUnicast() || ip.To4() != nil {
+		return nil
+	}
+	ip = ip.To16()
+	if ip[11] != 0xff || ip[12] != 0xfe {
+		return nil
+	}
+
+	return net.HardwareAddr{ip[8] ^ 0x02, ip[9], ip[10], ip[13], ip[14], ip[15]}
+}
+
+// NewInfo6 returns the details about a DHCPv6 request that are needed to determine netboot options.
+// The Pkt field of the returned Info is always nil as it only holds DHCPv4 packets.
+func NewInfo6(msg *dhcpv6.Message, mac net.HardwareAddr, opts ...InfoOption) Info {
+	i := Info{Mac: mac}
+	for _, opt := range opts {
+		opt(&i)
+	}
+	if msg != nil {
+		i.Arch = Arch6(msg)
+		i.UserClass = userClass6(msg)
+		i.ClientType = clientType6(msg)
+		i.IsNetbootClient = IsNetbootClient6(msg)
+		if i.IPXEBinary == "" {
+			i.IPXEBinary = i.IPXEBinaryFrom()
+		}
+	}
+
+	return i
+}
+
+// Arch6 returns the client architecture from DHCPv6 option 61.
+// iana.Arch(255) is returned when the architecture is not known.
+func Arch6(msg *dhcpv6.Message) iana.Arch {
+	if archs := msg.Options.ArchTypes(); len(archs) > 0 {
+		if _, ok := ArchToBootFile()[archs[0]]; ok {
+			return archs[0]
+		}
+	}
+
+	return iana.Arch(255) // unknown arch
+}
+
+// userClass6 returns the first user class from DHCPv6 option 15.
+func userClass6(msg *dhcpv6.Message) UserClass {
+	if uc := msg.Options.UserClasses(); len(uc) > 0 {
+		return UserClass(string(uc[0]))
+	}
+
+	return ""
+}
+
+// clientType6 returns the client type from the DHCPv6 vendor class option (16).
+// UEFI clients use the same "PXEClient" and "HTTPClient" prefixes as in DHCPv4 option 60.
+func clientType6(msg *dhcpv6.Message) ClientType {
+	var c ClientType
+	for _, vc := range msg.Options.VendorClasses() {
+		for _, d := range vc.Data {
+			switch {
+			case strings.HasPrefix(string(d), HTTPClient.String()):
+				return HTTPClient
+			case strings.HasPrefix(string(d), PXEClient.String()):
+				c = PXEClient
+			}
+		}
+	}
+	if c == "" && len(msg.Options.ArchTypes()) > 0 {
+		c = PXEClient
+	}
+
+	return c
+}
+
+// IsNetbootClient6 returns nil if the client is a valid DHCPv6 netboot client. Otherwise it returns an error.
+//
+// A valid netboot client will have the following in its DHCPv6 request:
+// 1. is a Solicit, Request, Renew, Rebind or Information-request message type.
+// 2. option 61 (client system architecture) is set or option 59 (boot file URL) is requested.
+//
+// See: https://www.rfc-editor.org/rfc/rfc5970.html
+func IsNetbootClient6(msg *dhcpv6.Message) error {
+	var err error
+	switch msg.Type() {
+	case dhcpv6.MessageTypeSolicit, dhcpv6.MessageTypeRequest, dhcpv6.MessageTypeRenew, dhcpv6.MessageTypeRebind, dhcpv6.MessageTypeInformationRequest:
+	default:
+		err = wrapNonNil(err, "message type must be one of Solicit, Request, Renew, Rebind or Information-request")
+	}
+	if len(msg.Options.ArchTypes()) == 0 && !msg.IsOptionRequested(dhcpv6.OptionBootfileURL) {
+		err = wrapNonNil(err, "option 61 not set and option 59 not requested")
+	}
+
+	return err
+}
+
+// BootFileURL returns the value for the DHCPv6 boot file URL option (59).
+// Unlike the DHCPv4 boot file name, the value must always be a URL so PXE clients are given a tftp URL.
+// An empty string is returned when no URL can be determined.
+func (i Info) BootFileURL(customUC UserClass, ipxeScript, ipxeHTTPBinServer *url.URL, ipxeTFTPBinServer netip.AddrPort) string {
+	paths := []string{i.IPXEBinary}
+	if i.Mac != nil {
+		paths = append([]string{macAddrFormat(i.Mac, i.MacAddrFormat)}, paths...)
+	}
+
+	// order matters here.
+	switch {
+	case i.UserClass == Tinkerbell, (customUC != "" && i.UserClass == customUC): // this case gets us out of an ipxe boot loop.
+		if ipxeScript != nil {
+			return ipxeScript.String()
+		}
+		return ""
+	case i.IPXEBinary == "":
+		return ""
+	case i.ClientType == HTTPClient:
+		if ipxeHTTPBinServer != nil {
+			return ipxeHTTPBinServer.JoinPath(paths...).String()
+		}
+		return ""
+	case ipxeTFTPBinServer.IsValid() && !ipxeTFTPBinServer.Addr().IsUnspecified():
+		t := url.URL{
+			Scheme: "tftp",
+			Host:   ipxeTFTPBinServer.String(),
+		}
+		return t.JoinPath(paths...).String()
+	case ipxeHTTPBinServer != nil:
+		return ipxeHTTPBinServer.JoinPath(paths...).String()
+	}
+
+	return ""
+}
+
+// ServerDUID returns a stable DHCPv6 server identifier.
+// A DUID-LL is created from the first interface, with a MAC address, that has addr assigned.
+// If no such interface exists the first non loopback interface with a MAC address is used.
+// If there are no interfaces with a MAC address, a DUID-UUID derived from the host name is returned.
+func ServerDUID(addr netip.Addr) dhcpv6.DUID {
+	ifaces, _ := net.Interfaces()
+	var fallback net.HardwareAddr
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 || len(iface.HardwareAddr) != 6 {
+			continue
+		}
+		if fallback == nil {
+			fallback = iface.HardwareAddr
+		}
+		addrs, _ := iface.Addrs()
+		for _, a := range addrs {
+			if p, err := netip.ParsePrefix(a.String()); err == nil && p.Addr() == addr {
+				return &dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: iface.HardwareAddr}
+			}
+		}
+	}
+	if fallback != nil {
+		return &dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: fallback}
+	}
+
+	hostname, _ := os.Hostname()
+	sum := sha256.Sum256([]byte(hostname))
+	var uuid [16]byte
+	copy(uuid[:], sum[:16])
+
+	return &dhcpv6.DUIDUUID{UUID: uuid}
+}
+
+// Reply returns the message to send back to the client and the destination address.
+// If the client message was forwarded by a relay agent, reply is encapsulated in a Relay-reply message.
+func (p Packet6) Reply(reply *dhcpv6.Message) (dhcpv6.DHCPv6, net.Addr, error) {
+	if p.Relay == nil {
+		return reply, p.Peer, nil
+	}
+	r, err := dhcpv6.NewRelayReplFromRelayForw(p.Relay, reply)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r, p.Peer, nil
+}
+
+// WithNetboot6 returns the DHCPv6 modifiers that set the boot file URL option (59).
+// When the client identifies as an HTTPClient in its vendor class option (16), the vendor class is echoed back,
+// UEFI HTTP boot clients ignore replies that don't include it.
+func WithNetboot6(msg *dhcpv6.Message, bootFileURL string) []dhcpv6.Modifier {
+	if bootFileURL == "" {
+		return nil
+	}
+	mods := []dhcpv6.Modifier{dhcpv6.WithOption(dhcpv6.OptBootFileURL(bootFileURL))}
+	for _, vc := range msg.Options.VendorClasses() {
+		for _, d := range vc.Data {
+			if strings.HasPrefix(string(d), HTTPClient.String()) {
+				mods = append(mods, dhcpv6.WithOption(&dhcpv6.OptVendorClass{EnterpriseNumber: vc.EnterpriseNumber, Data: [][]byte{[]byte(HTTPClient)}}))
+				return mods
+			}
+		}
+	}
+
+	return mods
+}
@@ -0,0 +1,200 @@
+package dhcp
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+func TestPacket6MAC(t *testing.T) {
+	mac := net.HardwareAddr{0x52, 0x54, 0x00, 0x12, 0x34, 0x56}
+	other := net.HardwareAddr{0x52, 0x54, 0x00, 0xaa, 0xbb, 0xcc}
+	msg := func(duid dhcpv6.DUID) *dhcpv6.Message {
+		m, err := dhcpv6.NewMessage(dhcpv6.WithClientID(duid))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	relay := func(m *dhcpv6.Message, peer net.IP, mods ...dhcpv6.Modifier) *dhcpv6.RelayMessage {
+		r, err := dhcpv6.EncapsulateRelay(m, dhcpv6.MessageTypeRelayForward, net.ParseIP("2001:db8::1"), peer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, mod := range mods {
+			mod(r)
+		}
+		return r
+	}
+	uuid := &dhcpv6.DUIDUUID{UUID: [16]byte{1}}
+	eui64 := net.ParseIP("fe80::5054:ff:fe12:3456")
+
+	tests := map[string]struct {
+		packet  Packet6
+		want    net.HardwareAddr
+		wantErr error
+	}{
+		"duid-ll": {
+			packet: Packet6{Pkt: msg(&dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: mac})},
+			want:   mac,
+		},
+		"duid-llt": {
+			packet: Packet6{Pkt: msg(&dhcpv6.DUIDLLT{HWType: iana.HWTypeEthernet, LinkLayerAddr: mac})},
+			want:   mac,
+		},
+		"relay client link-layer address is preferred": {
+			packet: func() Packet6 {
+				m := msg(&dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: other})
+				return Packet6{Pkt: m, Relay: relay(m, eui64, dhcpv6.WithClientLinkLayerAddress(iana.HWTypeEthernet, mac))}
+			}(),
+			want: mac,
+		},
+		"link-local eui-64 source": {
+			packet: Packet6{Pkt: msg(uuid), Peer: &net.UDPAddr{IP: eui64, Port: dhcpv6.DefaultClientPort}},
+			want:   mac,
+		},
+		"relay peer link-local eui-64": {
+			packet: func() Packet6 {
+				m := msg(uuid)
+				return Packet6{Pkt: m, Relay: relay(m, eui64), Peer: &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: dhcpv6.DefaultServerPort}}
+			}(),
+			want: mac,
+		},
+		"no mac": {
+			packet:  Packet6{Pkt: msg(uuid), Peer: &net.UDPAddr{IP: net.ParseIP("fe80::1"), Port: dhcpv6.DefaultClientPort}},
+			wantErr: ErrNoMAC,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := tt.packet.MAC()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("MAC() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestBootFileURL(t *testing.T) {
+	mac := net.HardwareAddr{0x52, 0x54, 0x00, 0x12, 0x34, 0x56}
+	script := &url.URL{Scheme: "http", Host: "[2001:db8::1]:7080", Path: "/auto.ipxe"}
+	bin := &url.URL{Scheme: "http", Host: "[2001:db8::1]:7080", Path: "/ipxe/binary"}
+	tftp := netip.MustParseAddrPort("[2001:db8::1]:69")
+
+	tests := map[string]struct {
+		mods []dhcpv6.Modifier
+		tftp netip.AddrPort
+		want string
+	}{
+		"pxe client gets tftp url": {
+			mods: []dhcpv6.Modifier{dhcpv6.WithArchType(iana.EFI_X86_64)},
+			tftp: tftp,
+			want: "tftp://[2001:db8::1]:69/52:54:00:12:34:56/ipxe.efi",
+		},
+		"pxe client gets http url without tftp": {
+			mods: []dhcpv6.Modifier{dhcpv6.WithArchType(iana.EFI_ARM64)},
+			want: "http://[2001:db8::1]:7080/ipxe/binary/52:54:00:12:34:56/snp-arm64.efi",
+		},
+		"http client": {
+			mods: []dhcpv6.Modifier{
+				dhcpv6.WithArchType(iana.EFI_X86_64_HTTP),
+				dhcpv6.WithOption(&dhcpv6.OptVendorClass{EnterpriseNumber: 343, Data: [][]byte{[]byte("HTTPClient:Arch:00016:UNDI:003016")}}),
+			},
+			tftp: tftp,
+			want: "http://[2001:db8::1]:7080/ipxe/binary/52:54:00:12:34:56/ipxe.efi",
+		},
+		"tinkerbell ipxe gets script": {
+			mods: []dhcpv6.Modifier{dhcpv6.WithArchType(iana.EFI_X86_64), dhcpv6.WithUserClass([]byte("Tinkerbell"))},
+			tftp: tftp,
+			want: "http://[2001:db8::1]:7080/auto.ipxe",
+		},
+		"unknown arch": {
+			mods: []dhcpv6.Modifier{dhcpv6.WithArchType(iana.Arch(99))},
+			tftp: tftp,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m, err := dhcpv6.NewSolicit(mac, tt.mods...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			i := NewInfo6(m, mac)
+			if i.IsNetbootClient != nil {
+				t.Fatalf("expected a netboot client, got %v", i.IsNetbootClient)
+			}
+			if diff := cmp.Diff(tt.want, i.BootFileURL("", script, bin, tt.tftp)); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestIsNetbootClient6(t *testing.T) {
+	mac := net.HardwareAddr{0x52, 0x54, 0x00, 0x12, 0x34, 0x56}
+	tests := map[string]struct {
+		mods    []dhcpv6.Modifier
+		msgType dhcpv6.MessageType
+		wantErr bool
+	}{
+		"arch type set": {
+			mods:    []dhcpv6.Modifier{dhcpv6.WithArchType(iana.EFI_X86_64)},
+			msgType: dhcpv6.MessageTypeSolicit,
+		},
+		"boot file url requested": {
+			mods:    []dhcpv6.Modifier{dhcpv6.WithRequestedOptions(dhcpv6.OptionBootfileURL)},
+			msgType: dhcpv6.MessageTypeRequest,
+		},
+		"not a netboot client": {
+			msgType: dhcpv6.MessageTypeSolicit,
+			wantErr: true,
+		},
+		"release": {
+			mods:    []dhcpv6.Modifier{dhcpv6.WithArchType(iana.EFI_X86_64)},
+			msgType: dhcpv6.MessageTypeRelease,
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m, err := dhcpv6.NewSolicit(mac, tt.mods...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.MessageType = tt.msgType
+			if err := IsNetbootClient6(m); (err != nil) != tt.wantErr {
+				t.Fatalf("IsNetbootClient6() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithNetboot6(t *testing.T) {
+	m, err := dhcpv6.NewMessage(dhcpv6.WithOption(&dhcpv6.OptVendorClass{EnterpriseNumber: 343, Data: [][]byte{[]byte("HTTPClient:Arch:00016")}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := dhcpv6.NewMessage(WithNetboot6(m, "http://[2001:db8::1]/ipxe.efi")...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reply.Options.BootFileURL(); got != "http://[2001:db8::1]/ipxe.efi" {
+		t.Errorf("unexpected boot file url: %q", got)
+	}
+	want := [][]byte{[]byte("HTTPClient")}
+	if diff := cmp.Diff(want, reply.Options.VendorClass(343)); diff != "" {
+		t.Errorf("unexpected vendor class (-want +got):\n%s", diff)
+	}
+	if mods := WithNetboot6(m, ""); mods != nil {
+		t.Errorf("expected no modifiers for an empty boot file url, got %d", len(mods))
+	}
+}
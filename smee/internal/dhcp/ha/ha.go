@@ -0,0 +1,90 @@
+// Package ha decides which of several Smee replicas answers a DHCP message, so that DHCP can run in more than one replica.
+// Either one replica, elected with a Kubernetes Lease, answers all messages or the clients are split between the replicas by a hash of their MAC address.
+package ha
+
+import (
+	"context"
+	"hash/fnv"
+	"net"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp/server"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Gate decides whether this replica answers the DHCP messages of a client.
+type Gate interface {
+	// Serve reports whether this replica answers a message from the client with the MAC address.
+	// elapsed is how long the client has been trying to get a lease, from the secs field or the DHCPv6 elapsed time option.
+	Serve(mac net.HardwareAddr, elapsed time.Duration) bool
+}
+
+// LoadBalance splits clients between replicas by a hash of their MAC address, like the load balancing of RFC 3074.
+// Every replica must use the same number of replicas and a different index.
+// The hash isn't the one of RFC 3074, so replicas can't share the clients with other DHCP servers.
+type LoadBalance struct {
+	// Replicas is the number of replicas. Every replica answers all clients when it's 1 or less.
+	Replicas int
+	// Index is the index of this replica, from 0 to Replicas-1.
+	Index int
+	// MaxElapsed is how long a client tries to get a lease before every replica answers it, so that the clients of
+	// a replica that is down still get a lease. Zero means clients are only ever answered by their own replica.
+	MaxElapsed time.Duration
+}
+
+// Serve implements Gate.
+func (l LoadBalance) Serve(mac net.HardwareAddr, elapsed time.Duration) bool {
+	if l.Replicas <= 1 {
+		return true
+	}
+	if l.MaxElapsed > 0 && elapsed >= l.MaxElapsed {
+		return true
+	}
+
+	return int(Bucket(mac))%l.Replicas == l.Index
+}
+
+// Bucket returns the hash bucket, 0-255, of a MAC address.
+func Bucket(mac net.HardwareAddr) uint8 {
+	h := fnv.New32a()
+	_, _ = h.Write(mac)
+	s := h.Sum32()
+
+	return uint8(s ^ s>>8 ^ s>>16 ^ s>>24)
+}
+
+// Handler answers the DHCPv4 messages that Gate lets this replica answer with Next and ignores the others.
+type Handler struct {
+	Log  logr.Logger
+	Gate Gate
+	Next server.Handler
+}
+
+// Handle implements server.Handler.
+func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, p dhcp.Packet) {
+	if p.Pkt != nil && !h.Gate.Serve(p.Pkt.ClientHWAddr, time.Duration(p.Pkt.NumSeconds)*time.Second) {
+		h.Log.V(1).Info("ignoring DHCP packet, another replica answers this client", "mac", p.Pkt.ClientHWAddr.String(), "xid", p.Pkt.TransactionID.String())
+		return
+	}
+	h.Next.Handle(ctx, conn, p)
+}
+
+// Handler6 answers the DHCPv6 messages that Gate lets this replica answer with Next and ignores the others.
+type Handler6 struct {
+	Log  logr.Logger
+	Gate Gate
+	Next server.Handler6
+}
+
+// Handle implements server.Handler6.
+func (h *Handler6) Handle(ctx context.Context, conn *ipv6.PacketConn, p dhcp.Packet6) {
+	// Messages without a MAC address are left to Next, which ignores them.
+	if mac, err := p.MAC(); err == nil && p.Pkt != nil && !h.Gate.Serve(mac, p.Pkt.Options.ElapsedTime()) {
+		h.Log.V(1).Info("ignoring DHCPv6 packet, another replica answers this client", "mac", mac.String(), "xid", p.Pkt.TransactionID.String())
+		return
+	}
+	h.Next.Handle(ctx, conn, p)
+}
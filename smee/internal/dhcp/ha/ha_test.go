@@ -0,0 +1,102 @@
+package ha
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
+	"golang.org/x/net/ipv4"
+)
+
+func TestLoadBalance(t *testing.T) {
+	// Every client is answered by exactly one of the replicas.
+	replicas := []LoadBalance{{Replicas: 3, Index: 0}, {Replicas: 3, Index: 1}, {Replicas: 3, Index: 2}}
+	served := make([]int, len(replicas))
+	for i := range 3000 {
+		mac := net.HardwareAddr{0x52, 0x54, 0x00, byte(i >> 16), byte(i >> 8), byte(i)}
+		var n int
+		for j, r := range replicas {
+			if r.Serve(mac, 0) {
+				n++
+				served[j]++
+			}
+		}
+		if n != 1 {
+			t.Fatalf("client %v is answered by %d replicas, want 1", mac, n)
+		}
+	}
+	// The clients are split roughly evenly.
+	for j, n := range served {
+		if n < 800 || n > 1200 {
+			t.Errorf("replica %d answers %d of 3000 clients, want about 1000", j, n)
+		}
+	}
+}
+
+func TestLoadBalanceServe(t *testing.T) {
+	mac := net.HardwareAddr{0x52, 0x54, 0x00, 0x12, 0x34, 0x01}
+	own := int(Bucket(mac)) % 2
+	other := 1 - own
+	tests := map[string]struct {
+		lb      LoadBalance
+		elapsed time.Duration
+		want    bool
+	}{
+		"own client":                  {lb: LoadBalance{Replicas: 2, Index: own}, want: true},
+		"other client":                {lb: LoadBalance{Replicas: 2, Index: other}},
+		"other client, long elapsed":  {lb: LoadBalance{Replicas: 2, Index: other, MaxElapsed: 3 * time.Second}, elapsed: 4 * time.Second, want: true},
+		"other client, short elapsed": {lb: LoadBalance{Replicas: 2, Index: other, MaxElapsed: 3 * time.Second}, elapsed: time.Second},
+		"single replica":              {lb: LoadBalance{Replicas: 1}, want: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.lb.Serve(mac, tt.elapsed); got != tt.want {
+				t.Fatalf("Serve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type handler struct{ called bool }
+
+func (h *handler) Handle(context.Context, *ipv4.PacketConn, dhcp.Packet) { h.called = true }
+
+type gate bool
+
+func (g gate) Serve(net.HardwareAddr, time.Duration) bool { return bool(g) }
+
+func TestHandler(t *testing.T) {
+	pkt, err := dhcpv4.NewDiscovery(net.HardwareAddr{0x52, 0x54, 0x00, 0x12, 0x34, 0x01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, serve := range []bool{true, false} {
+		next := &handler{}
+		h := &Handler{Log: logr.Discard(), Gate: gate(serve), Next: next}
+		h.Handle(context.Background(), nil, dhcp.Packet{Pkt: pkt})
+		if next.called != serve {
+			t.Fatalf("Next called = %v, want %v", next.called, serve)
+		}
+	}
+}
+
+func TestLeaderServe(t *testing.T) {
+	l := &Leader{}
+	if l.Serve(nil, time.Minute) {
+		t.Fatal("Serve() = true before becoming the leader")
+	}
+	l.leader.Store(true)
+	if !l.Serve(nil, 0) || !l.IsLeader() {
+		t.Fatal("Serve() = false for the leader")
+	}
+}
+
+func TestLeaderRunWithoutConfig(t *testing.T) {
+	if err := (&Leader{}).Run(context.Background()); err == nil {
+		t.Fatal("Run() expected an error without a Kubernetes configuration")
+	}
+}
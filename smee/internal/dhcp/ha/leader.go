@@ -0,0 +1,106 @@
+package ha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	// DefaultLeaseDuration is how long a leader holds the Lease without renewing it, the longest time no replica answers DHCP after the leader fails.
+	DefaultLeaseDuration = 15 * time.Second
+	defaultRenewDeadline = 10 * time.Second
+	defaultRetryPeriod   = 2 * time.Second
+)
+
+// Leader is a Gate that lets only the replica that holds a Kubernetes Lease answer DHCP messages.
+type Leader struct {
+	Log logr.Logger
+	// Config is the configuration of the Kubernetes API server that holds the Lease.
+	Config *rest.Config
+	// Namespace and Name of the Lease.
+	Namespace string
+	Name      string
+	// Identity of this replica in the Lease. Defaults to the hostname with a random suffix.
+	Identity string
+
+	leader atomic.Bool
+}
+
+// Serve implements Gate. All clients are answered while this replica is the leader.
+func (l *Leader) Serve(net.HardwareAddr, time.Duration) bool {
+	return l.leader.Load()
+}
+
+// IsLeader reports whether this replica holds the Lease.
+func (l *Leader) IsLeader() bool {
+	return l.leader.Load()
+}
+
+// Run takes part in the leader election until ctx is done. A replica that loses the Lease stops answering and runs for it again.
+// The Lease is released when ctx is done, so that another replica takes over without waiting for it to expire.
+func (l *Leader) Run(ctx context.Context) error {
+	if l.Config == nil {
+		return errors.New("DHCP leader election requires a Kubernetes backend")
+	}
+	if l.Identity == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("unable to get the hostname for the DHCP leader election identity: %w", err)
+		}
+		l.Identity = host + "_" + string(uuid.NewUUID())
+	}
+	cs, err := kubernetes.NewForConfig(l.Config)
+	if err != nil {
+		return fmt.Errorf("unable to create the Kubernetes client for the DHCP leader election: %w", err)
+	}
+	lock, err := resourcelock.New(resourcelock.LeasesResourceLock, l.Namespace, l.Name, cs.CoreV1(), cs.CoordinationV1(), resourcelock.ResourceLockConfig{Identity: l.Identity})
+	if err != nil {
+		return fmt.Errorf("unable to create the DHCP leader election lock: %w", err)
+	}
+	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   DefaultLeaseDuration,
+		RenewDeadline:   defaultRenewDeadline,
+		RetryPeriod:     defaultRetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            l.Name,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) {
+				l.leader.Store(true)
+				l.Log.Info("became the DHCP leader, answering DHCP", "identity", l.Identity)
+			},
+			OnStoppedLeading: func() {
+				l.leader.Store(false)
+				l.Log.Info("no longer the DHCP leader, not answering DHCP", "identity", l.Identity)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != l.Identity {
+					l.Log.Info("another replica is the DHCP leader", "leader", identity)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("invalid DHCP leader election configuration: %w", err)
+	}
+
+	l.Log.Info("starting the DHCP leader election", "lease", l.Namespace+"/"+l.Name, "identity", l.Identity)
+	for ctx.Err() == nil {
+		// Run returns when ctx is done or the Lease is lost.
+		le.Run(ctx)
+	}
+
+	return nil
+}
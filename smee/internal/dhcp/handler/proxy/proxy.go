@@ -69,6 +69,10 @@ type Handler struct {
 	// AutoProxyEnabled is used to determine if the proxyDHCP handler should do any Backend calls or not.
 	// When enabled no Backend calls are made and responses are sent to all valid network boot clients.
 	AutoProxyEnabled bool
+
+	// Options are raw DHCP options sent to all clients. They are applied after all other options and
+	// are replaced by the options of a client's Hardware object.
+	Options []dhcpv4.Option
 }
 
 // Netboot holds the netboot configuration details used in running a DHCP server.
@@ -93,6 +97,10 @@ type Netboot struct {
 
 	// IPXEArchMapping will override the default architecture to binary mapping.
 	IPXEArchMapping map[iana.Arch]constant.IPXEBinary
+
+	// IPXEMenu is whether the iPXE boot menu is enabled.
+	// When true, clients whose Hardware doesn't allow netbooting are still sent netboot options so that they can load the menu.
+	IPXEMenu bool
 }
 
 // Handle implements a ProxyDHCP Redirection server.
@@ -229,7 +237,7 @@ func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, dp dhcp.Pac
 	// set bootfile header
 	// TODO(jacobweinstock): plum through the custom user class.
 	reply.BootFileName = i.Bootfile("", h.Netboot.IPXEScriptURL(dp.Pkt), h.Netboot.IPXEBinServerHTTP, h.Netboot.IPXEBinServerTFTP)
-	if hw.Netboot != nil && !hw.Netboot.AllowNetboot {
+	if hw.Netboot != nil && !hw.Netboot.AllowNetboot && !h.Netboot.IPXEMenu {
 		// if the netboot is not allowed, set the boot file name to "/<mac address>/netboot-not-allowed"
 		// this follows the same pattern and keeps the same user experience as the reservation handler.
 		reply.BootFileName = fmt.Sprintf("/%s/netboot-not-allowed", dp.Pkt.ClientHWAddr.String())
@@ -237,6 +245,15 @@ func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, dp dhcp.Pac
 		span.SetStatus(codes.Ok, "netboot not allowed")
 	}
 
+	// Raw options are applied last so that they can replace any option set above.
+	dhcp.WithOptions(h.Options...)(reply)
+	if hw.DHCP != nil {
+		dhcp.WithOptions(hw.DHCP.Options...)(reply)
+		for _, err := range hw.DHCP.InvalidOptions {
+			log.Info("skipping invalid DHCP option of Hardware", "error", err.Error())
+		}
+	}
+
 	log.Info(
 		"received DHCP packet",
 		"type", dp.Pkt.MessageType().String(),
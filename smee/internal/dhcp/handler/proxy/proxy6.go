@@ -0,0 +1,239 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+
+	"github.com/go-logr/logr"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/tinkerbell/tinkerbell/pkg/constant"
+	"github.com/tinkerbell/tinkerbell/pkg/data"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/ipv6"
+)
+
+// Handler6 holds the configuration details for running the proxyDHCPv6 server.
+// Like its DHCPv4 counterpart, it does not administer addresses. It only sends the boot file URL option
+// to netboot clients and leaves address assignment to another DHCPv6 server or SLAAC.
+type Handler6 struct {
+	// Backend is the backend to use for getting DHCP data.
+	Backend BackendReader
+
+	// ServerID is the DHCPv6 server identifier (option 2) used in all responses.
+	ServerID dhcpv6.DUID
+
+	// Log is used to log messages.
+	// `logr.Discard()` can be used if no logging is desired.
+	Log logr.Logger
+
+	// Netboot configuration
+	Netboot Netboot6
+
+	// AutoProxyEnabled is used to determine if the proxyDHCP handler should do any Backend calls or not.
+	// When enabled responses are sent to all valid network boot clients, even those without a Hardware object.
+	AutoProxyEnabled bool
+}
+
+// Netboot6 holds the netboot configuration details used in running a proxyDHCPv6 server.
+type Netboot6 struct {
+	// iPXE binary server IPv6:Port serving via TFTP.
+	IPXEBinServerTFTP netip.AddrPort
+
+	// IPXEBinServerHTTP is the URL to the IPXE binary server serving via HTTP(s).
+	IPXEBinServerHTTP *url.URL
+
+	// IPXEScriptURL is the URL to the IPXE script to use.
+	IPXEScriptURL func(net.HardwareAddr) *url.URL
+
+	// Enabled is whether to enable sending netboot DHCP options.
+	Enabled bool
+
+	// InjectMacAddrFormat is the format to use when injecting the mac address into the iPXE binary URL.
+	InjectMacAddrFormat constant.MACFormat
+
+	// IPXEArchMapping will override the default architecture to binary mapping.
+	IPXEArchMapping map[iana.Arch]constant.IPXEBinary
+
+	// IPXEMenu is whether the iPXE boot menu is enabled.
+	// When true, clients whose Hardware doesn't allow netbooting are still sent netboot options so that they can load the menu.
+	IPXEMenu bool
+}
+
+// Handle responds to DHCPv6 netboot clients with the boot file URL option.
+// Solicit messages are answered with an Advertise. Request messages are only answered when they are
+// addressed to this server, Information-request messages are always answered.
+func (h *Handler6) Handle(ctx context.Context, conn *ipv6.PacketConn, p dhcp.Packet6) {
+	if h.Log.GetSink() == nil {
+		h.Log = logr.Discard()
+	}
+	if p.Pkt == nil {
+		h.Log.Error(errors.New("incoming packet is nil"), "not able to respond when the incoming packet is nil")
+		return
+	}
+	if p.Peer == nil {
+		h.Log.Error(errors.New("peer is nil"), "not able to respond when the peer is nil")
+		return
+	}
+	if conn == nil {
+		h.Log.Error(errors.New("connection is nil"), "not able to respond when the connection is nil")
+		return
+	}
+
+	var ifName string
+	if p.Md != nil {
+		ifName = p.Md.IfName
+	}
+	log := h.Log.WithValues("xid", p.Pkt.TransactionID.String(), "interface", ifName, "type", p.Pkt.Type().String())
+	tracer := otel.Tracer(tracerName)
+	var span trace.Span
+	ctx, span = tracer.Start(
+		ctx,
+		fmt.Sprintf("DHCPv6 Packet Received: %v", p.Pkt.Type().String()),
+		trace.WithAttributes(attribute.String("DHCP.peer", p.Peer.String())),
+		trace.WithAttributes(attribute.String("DHCP.server.ifname", ifName)),
+	)
+
+	defer span.End()
+
+	if !h.Netboot.Enabled {
+		log.V(1).Info("Ignoring packet: netboot is not enabled")
+		span.SetStatus(codes.Ok, "Ignoring packet: netboot is not enabled")
+
+		return
+	}
+	switch p.Pkt.Type() {
+	case dhcpv6.MessageTypeSolicit, dhcpv6.MessageTypeInformationRequest:
+	case dhcpv6.MessageTypeRequest:
+		if sid := p.Pkt.Options.ServerID(); sid == nil || h.ServerID == nil || !sid.Equal(h.ServerID) {
+			log.V(1).Info("Ignoring packet: Request is for another server")
+			span.SetStatus(codes.Ok, "Ignoring packet: Request is for another server")
+
+			return
+		}
+	default:
+		log.V(1).Info("Ignoring packet: proxyDHCPv6 only responds to Solicit, Request or Information-request message types")
+		span.SetStatus(codes.Ok, "Ignoring packet: unsupported message type")
+
+		return
+	}
+
+	mac, err := p.MAC()
+	if err != nil {
+		log.V(1).Info("Ignoring packet", "error", err.Error())
+		span.SetStatus(codes.Ok, err.Error())
+
+		return
+	}
+	log = log.WithValues("mac", mac.String())
+	span.SetAttributes(attribute.String("DHCP.mac", mac.String()))
+
+	i := dhcp.NewInfo6(p.Pkt, mac, dhcp.WithMacAddrFormat(h.Netboot.InjectMacAddrFormat), dhcp.WithArchMappingOverride(h.Netboot.IPXEArchMapping))
+	if err := i.IsNetbootClient; err != nil {
+		log.V(1).Info("Ignoring packet: not from a netboot client", "error", err.Error())
+		span.SetStatus(codes.Ok, fmt.Sprintf("Ignoring packet: not from a netboot client: %s", err.Error()))
+
+		return
+	}
+
+	var ipxeScript *url.URL
+	if h.Netboot.IPXEScriptURL != nil {
+		ipxeScript = h.Netboot.IPXEScriptURL(mac)
+	}
+	var hw dhcp.Hardware
+	spec, err := h.Backend.FilterHardware(ctx, data.HardwareFilter{ByMACAddress: mac.String()})
+	switch {
+	case err != nil && !h.AutoProxyEnabled:
+		log.Info("Ignoring packet", "error", err.Error())
+		span.SetStatus(codes.Error, err.Error())
+		return
+	case err != nil:
+		log.Info("No hardware found, proceeding with defaults", "error", err.Error())
+	default:
+		hw, err = dhcp.ConvertByMac(ctx, mac, spec)
+		if err != nil && !h.AutoProxyEnabled {
+			log.Info("Ignoring packet", "error", err.Error())
+			span.SetStatus(codes.Error, err.Error())
+			return
+		}
+		if err != nil {
+			log.Info("Failed to convert hardware data, proceeding with defaults", "error", err.Error())
+			hw = dhcp.Hardware{}
+		}
+	}
+	if hw.Netboot != nil {
+		if !hw.Netboot.AllowNetboot && !h.Netboot.IPXEMenu {
+			log.V(1).Info("Ignoring packet: netboot not allowed")
+			span.SetStatus(codes.Ok, "netboot not allowed")
+
+			return
+		}
+		// If we have a Hardware object, check if there is a custom iPXE binary or script defined.
+		if hw.Netboot.IPXEBinary != "" {
+			i.IPXEBinary = hw.Netboot.IPXEBinary
+		}
+		if hw.Netboot.IPXEScriptURL != nil {
+			ipxeScript = hw.Netboot.IPXEScriptURL
+		}
+	}
+
+	bootFileURL := i.BootFileURL("", ipxeScript, h.Netboot.IPXEBinServerHTTP, h.Netboot.IPXEBinServerTFTP)
+	if bootFileURL == "" {
+		log.V(1).Info("Ignoring packet: no boot file URL was able to be determined")
+		span.SetStatus(codes.Ok, "Ignoring packet: no boot file URL was able to be determined")
+
+		return
+	}
+	mods := append([]dhcpv6.Modifier{dhcpv6.WithServerID(h.ServerID)}, dhcp.WithNetboot6(p.Pkt, bootFileURL)...)
+	var reply *dhcpv6.Message
+	if p.Pkt.Type() == dhcpv6.MessageTypeSolicit {
+		reply, err = dhcpv6.NewAdvertiseFromSolicit(p.Pkt, mods...)
+	} else {
+		reply, err = dhcpv6.NewReplyFromMessage(p.Pkt, mods...)
+	}
+	if err != nil {
+		log.Info("Ignoring packet", "error", err.Error())
+		span.SetStatus(codes.Error, err.Error())
+
+		return
+	}
+
+	log.Info(
+		"received DHCPv6 packet",
+		"clientType", i.ClientType.String(),
+		"userClass", i.UserClass.String(),
+	)
+
+	out, dst, err := p.Reply(reply)
+	if err != nil {
+		log.Info("error creating DHCPv6 relay reply", "error", err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return
+	}
+	cm := &ipv6.ControlMessage{}
+	if p.Md != nil {
+		cm.IfIndex = p.Md.IfIndex
+	}
+	log = log.WithValues(
+		"destination", dst.String(),
+		"bootFileURL", bootFileURL,
+		"replyType", reply.Type().String(),
+	)
+	if _, err := conn.WriteTo(out.ToBytes(), cm, dst); err != nil {
+		log.Error(err, "failed to send ProxyDHCPv6 response")
+		span.SetStatus(codes.Error, err.Error())
+
+		return
+	}
+	log.Info("Sent ProxyDHCPv6 response")
+	span.SetStatus(codes.Ok, "sent DHCPv6 response")
+}
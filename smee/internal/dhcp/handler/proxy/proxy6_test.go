@@ -0,0 +1,141 @@
+//go:build linux
+
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
+	"golang.org/x/net/ipv6"
+)
+
+func TestHandle6(t *testing.T) {
+	mac := net.HardwareAddr{0x52, 0x54, 0x00, 0x12, 0x34, 0x56}
+	serverID := &dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}}
+	otherServer := &dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{0, 0, 0, 0, 0, 1}}
+	tests := map[string]struct {
+		backend     *mockBackend
+		autoProxy   bool
+		msgType     dhcpv6.MessageType
+		mods        []dhcpv6.Modifier
+		wantType    dhcpv6.MessageType
+		wantBootURL string
+	}{
+		"solicit gets advertise": {
+			backend:     &mockBackend{allowNetboot: true},
+			msgType:     dhcpv6.MessageTypeSolicit,
+			mods:        []dhcpv6.Modifier{dhcpv6.WithArchType(iana.EFI_X86_64)},
+			wantType:    dhcpv6.MessageTypeAdvertise,
+			wantBootURL: "tftp://[2001:db8::1]:69/52:54:00:12:34:56/ipxe.efi",
+		},
+		"request for this server gets reply with hardware binary": {
+			backend:     &mockBackend{allowNetboot: true, iPXEBinary: "snp.efi"},
+			msgType:     dhcpv6.MessageTypeRequest,
+			mods:        []dhcpv6.Modifier{dhcpv6.WithArchType(iana.EFI_X86_64), dhcpv6.WithServerID(serverID)},
+			wantType:    dhcpv6.MessageTypeReply,
+			wantBootURL: "tftp://[2001:db8::1]:69/52:54:00:12:34:56/snp.efi",
+		},
+		"tinkerbell ipxe gets script": {
+			backend:     &mockBackend{allowNetboot: true},
+			msgType:     dhcpv6.MessageTypeSolicit,
+			mods:        []dhcpv6.Modifier{dhcpv6.WithArchType(iana.EFI_X86_64), dhcpv6.WithUserClass([]byte("Tinkerbell"))},
+			wantType:    dhcpv6.MessageTypeAdvertise,
+			wantBootURL: "http://[2001:db8::1]:7171/auto.ipxe",
+		},
+		"auto proxy without hardware": {
+			backend:     &mockBackend{err: errBackend},
+			autoProxy:   true,
+			msgType:     dhcpv6.MessageTypeSolicit,
+			mods:        []dhcpv6.Modifier{dhcpv6.WithArchType(iana.EFI_X86_64)},
+			wantType:    dhcpv6.MessageTypeAdvertise,
+			wantBootURL: "tftp://[2001:db8::1]:69/52:54:00:12:34:56/ipxe.efi",
+		},
+		"request for another server is ignored": {
+			backend: &mockBackend{allowNetboot: true},
+			msgType: dhcpv6.MessageTypeRequest,
+			mods:    []dhcpv6.Modifier{dhcpv6.WithArchType(iana.EFI_X86_64), dhcpv6.WithServerID(otherServer)},
+		},
+		"netboot not allowed": {
+			backend: &mockBackend{allowNetboot: false},
+			msgType: dhcpv6.MessageTypeSolicit,
+			mods:    []dhcpv6.Modifier{dhcpv6.WithArchType(iana.EFI_X86_64)},
+		},
+		"not a netboot client": {
+			backend: &mockBackend{allowNetboot: true},
+			msgType: dhcpv6.MessageTypeSolicit,
+		},
+		"backend error": {
+			backend: &mockBackend{err: errBackend},
+			msgType: dhcpv6.MessageTypeSolicit,
+			mods:    []dhcpv6.Modifier{dhcpv6.WithArchType(iana.EFI_X86_64)},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &Handler6{
+				Backend:          tt.backend,
+				ServerID:         serverID,
+				AutoProxyEnabled: tt.autoProxy,
+				Netboot: Netboot6{
+					Enabled:           true,
+					IPXEBinServerTFTP: netip.MustParseAddrPort("[2001:db8::1]:69"),
+					IPXEBinServerHTTP: &url.URL{Scheme: "http", Host: "[2001:db8::1]:7171", Path: "/ipxe"},
+					IPXEScriptURL: func(net.HardwareAddr) *url.URL {
+						return &url.URL{Scheme: "http", Host: "[2001:db8::1]:7171", Path: "/auto.ipxe"}
+					},
+				},
+			}
+			srv, err := net.ListenPacket("udp6", "[::1]:0")
+			if err != nil {
+				t.Skipf("unable to listen on ::1: %v", err)
+			}
+			defer srv.Close()
+			client, err := net.ListenPacket("udp6", "[::1]:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			req, err := dhcpv6.NewSolicit(mac, tt.mods...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.MessageType = tt.msgType
+			h.Handle(context.Background(), ipv6.NewPacketConn(srv), dhcp.Packet6{Peer: client.LocalAddr(), Pkt: req})
+
+			buf := make([]byte, 4096)
+			_ = client.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+			n, _, err := client.ReadFrom(buf)
+			if tt.wantBootURL == "" {
+				if err == nil {
+					t.Fatal("expected no response")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := dhcpv6.MessageFromBytes(buf[:n])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Type() != tt.wantType {
+				t.Fatalf("got message type %v, want %v", resp.Type(), tt.wantType)
+			}
+			if resp.Options.OneIANA() != nil {
+				t.Fatal("proxyDHCPv6 must not hand out addresses")
+			}
+			if diff := cmp.Diff(tt.wantBootURL, resp.Options.BootFileURL()); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
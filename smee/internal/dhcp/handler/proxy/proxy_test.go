@@ -30,6 +30,7 @@ type mockBackend struct {
 	allowNetboot bool
 	iPXEBinary   string
 	err          error
+	options      []tinkerbell.DHCPOption
 }
 
 func (m *mockBackend) FilterHardware(_ context.Context, opts data.HardwareFilter) (*tinkerbell.Hardware, error) {
@@ -41,7 +42,8 @@ func (m *mockBackend) FilterHardware(_ context.Context, opts data.HardwareFilter
 			Interfaces: []tinkerbell.Interface{
 				{
 					DHCP: &tinkerbell.DHCP{
-						MAC: opts.ByMACAddress,
+						MAC:     opts.ByMACAddress,
+						Options: m.options,
 					},
 					Netboot: &tinkerbell.Netboot{
 						AllowPXE: &m.allowNetboot,
@@ -274,6 +276,53 @@ func TestHandle(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		"valid netboot client request with raw options": {
+			handler: Handler{
+				Log: logr.Discard(),
+				Backend: &mockBackend{allowNetboot: true, options: []tinkerbell.DHCPOption{
+					{Code: 26, Type: "uint16", Value: "9000"},
+				}},
+				Netboot: Netboot{Enabled: true, IPXEBinServerTFTP: binServerTFTP, IPXEBinServerHTTP: binServerHTTP, IPXEScriptURL: ipxeScript},
+				IPAddr:  ip,
+				Options: []dhcpv4.Option{
+					dhcpv4.OptGeneric(dhcpv4.OptionInterfaceMTU, []byte{0x05, 0xdc}),
+					dhcpv4.OptGeneric(dhcpv4.GenericOptionCode(150), []byte{10, 0, 0, 1}),
+				},
+			},
+			pkt: &dhcpv4.DHCPv4{
+				OpCode:       dhcpv4.OpcodeBootRequest,
+				ClientHWAddr: []byte{1, 2, 3, 4, 5, 6},
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptMessageType(dhcpv4.MessageTypeRequest),
+					dhcpv4.OptClassIdentifier("PXEClient:Arch:00000:UNDI:002001"),
+					dhcpv4.OptClientArch(9), // EFI_X86_64
+					dhcpv4.OptGeneric(dhcpv4.OptionClientNetworkInterfaceIdentifier, []byte{1, 2, 3, 4}),
+					dhcpv4.OptGeneric(dhcpv4.OptionClientMachineIdentifier, []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}),
+				),
+			},
+			peer: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 68},
+			md:   &dhcp.Metadata{IfName: lo.Name, IfIndex: lo.Index},
+			want: &dhcpv4.DHCPv4{
+				OpCode:         dhcpv4.OpcodeBootReply,
+				ClientHWAddr:   []byte{1, 2, 3, 4, 5, 6},
+				ClientIPAddr:   []byte{0, 0, 0, 0},
+				YourIPAddr:     []byte{0, 0, 0, 0},
+				ServerIPAddr:   []byte{127, 0, 0, 1},
+				GatewayIPAddr:  []byte{0, 0, 0, 0},
+				ServerHostName: "127.0.0.1",
+				BootFileName:   "ipxe.efi",
+				Options: dhcpv4.OptionsFromList(
+					dhcpv4.OptMessageType(dhcpv4.MessageTypeAck),
+					dhcpv4.OptServerIdentifier(net.IP{127, 0, 0, 1}),
+					dhcpv4.OptClassIdentifier("PXEClient"),
+					dhcpv4.OptGeneric(dhcpv4.OptionClientMachineIdentifier, []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}),
+					dhcpv4.OptGeneric(dhcpv4.OptionVendorSpecificInformation, dhcpv4.Options{6: []byte{8}}.ToBytes()),
+					dhcpv4.OptGeneric(dhcpv4.OptionInterfaceMTU, []byte{0x23, 0x28}),
+					dhcpv4.OptGeneric(dhcpv4.GenericOptionCode(150), []byte{10, 0, 0, 1}),
+				),
+			},
+			wantErr: false,
+		},
 	}
 
 	for name, tt := range tests {
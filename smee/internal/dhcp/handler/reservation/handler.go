@@ -5,9 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/netip"
+	"slices"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
 	"github.com/tinkerbell/tinkerbell/pkg/data"
 	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
 	oteldhcp "github.com/tinkerbell/tinkerbell/smee/internal/dhcp/otel"
@@ -70,6 +74,12 @@ func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, p dhcp.Pack
 	switch mt := p.Pkt.MessageType(); mt {
 	case dhcpv4.MessageTypeDiscover:
 		d, n, err := h.readBackend(ctx, p.Pkt.ClientHWAddr)
+		if hardwareNotFound(err) {
+			d, n, err = h.readBackendByRelayAgentInfo(ctx, p.Peer, p.Pkt)
+		}
+		if hardwareNotFound(err) && h.Pools != nil {
+			d, n, err = h.readPool(ctx, p.Pkt, false)
+		}
 		if err != nil {
 			if hardwareNotFound(err) {
 				span.SetStatus(codes.Ok, "no reservation found")
@@ -87,10 +97,29 @@ func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, p dhcp.Pack
 			return
 		}
 		log.Info("received DHCP packet", "type", p.Pkt.MessageType().String())
+		h.withSubnetSettings(p.Pkt, d)
 		reply = h.updateMsg(ctx, p.Pkt, d, n, dhcpv4.MessageTypeOffer)
 		log = log.WithValues("type", dhcpv4.MessageTypeOffer.String())
 	case dhcpv4.MessageTypeRequest:
 		d, n, err := h.readBackend(ctx, p.Pkt.ClientHWAddr)
+		if hardwareNotFound(err) {
+			d, n, err = h.readBackendByRelayAgentInfo(ctx, p.Peer, p.Pkt)
+		}
+		if hardwareNotFound(err) && h.Pools != nil {
+			if sid := p.Pkt.ServerIdentifier(); sid != nil && !sid.Equal(h.IPAddr.AsSlice()) {
+				log.V(1).Info("ignoring DHCP request for another server", "serverIdentifier", sid.String())
+				span.SetStatus(codes.Ok, "request for another server")
+
+				return
+			}
+			d, n, err = h.readPool(ctx, p.Pkt, true)
+			if err == nil && !requestedMatches(p.Pkt, d.IPAddress) {
+				log.Info("requested address is not leased to this client", "type", p.Pkt.MessageType().String(), "requested", requestedAddr(p.Pkt).String())
+				reply = h.nak(p.Pkt)
+				log = log.WithValues("type", dhcpv4.MessageTypeNak.String())
+				break
+			}
+		}
 		if err != nil {
 			if hardwareNotFound(err) {
 				span.SetStatus(codes.Ok, "no reservation found")
@@ -108,14 +137,19 @@ func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, p dhcp.Pack
 			return
 		}
 		log.Info("received DHCP packet", "type", p.Pkt.MessageType().String())
+		h.withSubnetSettings(p.Pkt, d)
 		reply = h.updateMsg(ctx, p.Pkt, d, n, dhcpv4.MessageTypeAck)
 		log = log.WithValues("type", dhcpv4.MessageTypeAck.String())
 	case dhcpv4.MessageTypeRelease:
-		// Since the design of this DHCP server is that all IP addresses are
-		// Host reservations, when a client releases an address, the server
-		// doesn't have anything to do. This case is included for clarity of this
-		// design decision.
-		log.Info("received DHCP release packet, no response required, all IPs are host reservations", "type", p.Pkt.MessageType().String())
+		// Host reservations are never released, so when a client releases a reserved address
+		// the server doesn't have anything to do. Only dynamic leases from a pool are given back.
+		// No response is sent to a release.
+		if h.Pools != nil {
+			if err := h.Pools.Release(p.Pkt.ClientHWAddr, requestedAddr(p.Pkt)); err != nil {
+				log.Info("error releasing dynamic lease", "error", err)
+			}
+		}
+		log.Info("received DHCP release packet, no response required", "type", p.Pkt.MessageType().String())
 		span.SetStatus(codes.Ok, "received release, no response required")
 
 		return
@@ -150,6 +184,23 @@ func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, p dhcp.Pack
 	log.Info("sent DHCP response")
 	span.SetAttributes(h.encodeToAttributes(reply, "reply")...)
 	span.SetStatus(codes.Ok, "sent DHCP response")
+	yiaddr, _ := netip.AddrFromSlice(reply.YourIPAddr.To4())
+	h.NetbootStatus.DHCP(p.Pkt.ClientHWAddr, yiaddr, ifName, reply.MessageType().String(), h.firmwareDiscover(p.Pkt))
+}
+
+// firmwareDiscover reports whether pkt starts a netboot. It's a DHCP discover from the firmware of a netboot client,
+// not from iPXE, which sends its own discover after it's loaded.
+func (h *Handler) firmwareDiscover(pkt *dhcpv4.DHCPv4) bool {
+	if pkt.MessageType() != dhcpv4.MessageTypeDiscover || dhcp.IsNetbootClient(pkt) != nil {
+		return false
+	}
+
+	return !fromIPXE(dhcp.UserClass(pkt.Options.Get(dhcpv4.OptionUserClassInformation)), h.Netboot.UserClass)
+}
+
+// fromIPXE reports whether the user class (option 77) is one that iPXE sends.
+func fromIPXE(uc, customUC dhcp.UserClass) bool {
+	return uc == dhcp.IPXE || uc == dhcp.Tinkerbell || (customUC != "" && uc == customUC)
 }
 
 // replyDestination determines the destination address for the DHCP reply.
@@ -172,11 +223,16 @@ func replyDestination(directPeer net.Addr, giaddr net.IP) net.Addr {
 func (h *Handler) readBackend(ctx context.Context, mac net.HardwareAddr) (*dhcp.DHCP, *dhcp.Netboot, error) {
 	h.setDefaults()
 
+	return readBackend(ctx, h.Backend, mac)
+}
+
+// readBackend gets the DHCP and netboot data for mac from the backend.
+func readBackend(ctx context.Context, backend BackendReader, mac net.HardwareAddr) (*dhcp.DHCP, *dhcp.Netboot, error) {
 	tracer := otel.Tracer(tracerName)
 	ctx, span := tracer.Start(ctx, "Hardware data get")
 	defer span.End()
 
-	spec, err := h.Backend.FilterHardware(ctx, data.HardwareFilter{ByMACAddress: mac.String()})
+	spec, err := backend.FilterHardware(ctx, data.HardwareFilter{ByMACAddress: mac.String()})
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
 
@@ -196,6 +252,171 @@ func (h *Handler) readBackend(ctx context.Context, mac net.HardwareAddr) (*dhcp.
 	return hw.DHCP, hw.Netboot, nil
 }
 
+// readBackendByRelayAgentInfo gets the DHCP and netboot data from the backend for the Hardware connected to the switch port
+// in the relay agent information (option 82) of pkt. Hardware that matches both the circuit ID and remote ID is preferred
+// over Hardware that only matches the circuit ID.
+// Clients can add option 82 to their own requests, so it's only used in relayed requests from a trusted relay agent, see relayTrusted.
+func (h *Handler) readBackendByRelayAgentInfo(ctx context.Context, peer net.Addr, pkt *dhcpv4.DHCPv4) (*dhcp.DHCP, *dhcp.Netboot, error) {
+	ri := dhcp.RelayAgentInfo(pkt)
+	if ri == nil {
+		return nil, nil, notFoundError{msg: "no relay agent information"}
+	}
+	if !h.relayTrusted(peer, pkt) {
+		return nil, nil, notFoundError{msg: "relay agent information not from a trusted relay agent"}
+	}
+	backend := h.Backend
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "Hardware data get by relay agent information")
+	defer span.End()
+
+	keys := []string{ri.Key()}
+	if ri.RemoteID != "" {
+		keys = append(keys, (&tinkerbell.RelayAgentInfo{CircuitID: ri.CircuitID}).Key())
+	}
+	for _, key := range keys {
+		spec, err := backend.FilterHardware(ctx, data.HardwareFilter{ByRelayAgentInfo: key})
+		if hardwareNotFound(err) {
+			continue
+		}
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, nil, err
+		}
+		hw, err := dhcp.ConvertByRelayAgentInfo(ctx, key, spec)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, nil, fmt.Errorf("failed to convert hardware data: %w", err)
+		}
+		span.SetAttributes(attribute.String("DHCP.relayAgentInfo", key))
+		span.SetAttributes(hw.DHCP.EncodeToAttributes()...)
+		span.SetAttributes(hw.Netboot.EncodeToAttributes()...)
+		span.SetStatus(codes.Ok, "done reading from backend")
+
+		return hw.DHCP, hw.Netboot, nil
+	}
+	span.SetStatus(codes.Ok, "no hardware found")
+
+	return nil, nil, notFoundError{msg: fmt.Sprintf("no hardware found for relay agent information %q", ri.Key())}
+}
+
+// relayTrusted reports whether pkt was relayed, it has a giaddr, and was sent by a relay agent in h.TrustedRelays.
+func (h *Handler) relayTrusted(peer net.Addr, pkt *dhcpv4.DHCPv4) bool {
+	if pkt.GatewayIPAddr == nil || pkt.GatewayIPAddr.IsUnspecified() {
+		return false
+	}
+	upeer, ok := peer.(*net.UDPAddr)
+	if !ok {
+		return false
+	}
+	addr, ok := netip.AddrFromSlice(upeer.IP)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap()
+
+	return slices.ContainsFunc(h.TrustedRelays, func(p netip.Prefix) bool { return p.Contains(addr) })
+}
+
+// withSubnetSettings fills in the subnet mask, gateway, name servers and options of a reservation from the pool that serves
+// the client's network, for example the subnet of the relay agent. Values set on the Hardware are never replaced.
+// The pool is only used when the reserved address is in its subnet.
+func (h *Handler) withSubnetSettings(pkt *dhcpv4.DHCPv4, d *dhcp.DHCP) {
+	if h.Pools == nil {
+		return
+	}
+	giaddr, _ := netip.AddrFromSlice(pkt.GatewayIPAddr.To4())
+	p, err := h.Pools.Select(giaddr, h.IPAddr)
+	if err != nil || !p.Subnet.Contains(d.IPAddress) {
+		return
+	}
+	if len(d.SubnetMask) == 0 {
+		d.SubnetMask = net.CIDRMask(p.Subnet.Bits(), 32)
+	}
+	if !d.DefaultGateway.IsValid() {
+		d.DefaultGateway = p.Gateway
+	}
+	if len(d.NameServers) == 0 {
+		for _, ns := range p.NameServers {
+			d.NameServers = append(d.NameServers, ns.AsSlice())
+		}
+	}
+	d.Options = append(slices.Clone(p.Options), d.Options...)
+}
+
+// readPool gets a dynamic lease from the pool that serves the client's network.
+// The lease is only committed and persisted when commit is true, for DHCPREQUESTs. For DHCPDISCOVERs the address
+// is only held for the client for a short time.
+// Dynamic leases always allow netbooting so that unknown machines can boot into HookOS.
+func (h *Handler) readPool(ctx context.Context, pkt *dhcpv4.DHCPv4, commit bool) (*dhcp.DHCP, *dhcp.Netboot, error) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, "Dynamic lease get")
+	defer span.End()
+
+	giaddr, _ := netip.AddrFromSlice(pkt.GatewayIPAddr.To4())
+	p, err := h.Pools.Select(giaddr, h.IPAddr)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, err
+	}
+	get := h.Pools.Offer
+	if commit {
+		get = h.Pools.Allocate
+	}
+	l, err := get(ctx, p, pkt.ClientHWAddr, requestedAddr(pkt))
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, nil, err
+	}
+	d := &dhcp.DHCP{
+		MACAddress:     pkt.ClientHWAddr,
+		IPAddress:      l.IP,
+		SubnetMask:     net.CIDRMask(p.Subnet.Bits(), 32),
+		DefaultGateway: p.Gateway,
+		LeaseTime:      uint32(time.Until(l.Expires).Round(time.Second).Seconds()),
+	}
+	for _, ns := range p.NameServers {
+		d.NameServers = append(d.NameServers, ns.AsSlice())
+	}
+	span.SetAttributes(d.EncodeToAttributes()...)
+	span.SetStatus(codes.Ok, "done reading from pool")
+
+	return d, &dhcp.Netboot{AllowNetboot: true}, nil
+}
+
+// requestedAddr returns the address the client asked for, from option 50 or the ciaddr header.
+func requestedAddr(pkt *dhcpv4.DHCPv4) netip.Addr {
+	ip := pkt.RequestedIPAddress()
+	if ip == nil || ip.IsUnspecified() {
+		ip = pkt.ClientIPAddr
+	}
+	a, _ := netip.AddrFromSlice(ip.To4())
+
+	return a
+}
+
+// requestedMatches reports whether the address in a DHCP request is the one leased to the client.
+// Requests that do not name an address match.
+func requestedMatches(pkt *dhcpv4.DHCPv4, leased netip.Addr) bool {
+	r := requestedAddr(pkt)
+
+	return !r.IsValid() || r.IsUnspecified() || r == leased
+}
+
+// nak creates a DHCPNAK response.
+func (h *Handler) nak(pkt *dhcpv4.DHCPv4) *dhcpv4.DHCPv4 {
+	// See updateMsg for why the error is ignored.
+	reply, _ := dhcpv4.NewReplyFromRequest(pkt,
+		dhcpv4.WithMessageType(dhcpv4.MessageTypeNak),
+		dhcpv4.WithGeneric(dhcpv4.OptionServerIdentifier, h.IPAddr.AsSlice()),
+	)
+
+	return reply
+}
+
 // updateMsg handles updating DHCP packets with the data from the backend.
 func (h *Handler) updateMsg(ctx context.Context, pkt *dhcpv4.DHCPv4, d *dhcp.DHCP, n *dhcp.Netboot, msgType dhcpv4.MessageType) *dhcpv4.DHCPv4 {
 	h.setDefaults()
@@ -213,6 +434,16 @@ func (h *Handler) updateMsg(ctx context.Context, pkt *dhcpv4.DHCPv4, d *dhcp.DHC
 		d.TFTPServerName == "" && d.BootFileName == "" {
 		mods = append(mods, h.setNetworkBootOpts(ctx, pkt, n))
 	}
+	// Raw options are applied last so that they can replace any option set above.
+	mods = append(mods, dhcp.WithOptions(h.Options...), dhcp.WithOptions(d.Options...))
+	// Relay agents expect the relay agent information they added to be echoed back, RFC 3046 section 2.2.
+	// Raw options can't set option 82, so it's always the one from the request.
+	if ri := pkt.Options.Get(dhcpv4.OptionRelayAgentInformation); ri != nil {
+		mods = append(mods, dhcpv4.WithGeneric(dhcpv4.OptionRelayAgentInformation, ri))
+	}
+	for _, err := range d.InvalidOptions {
+		h.Log.Info("skipping invalid DHCP option of Hardware", "mac", pkt.ClientHWAddr.String(), "error", err.Error())
+	}
 	// We ignore the error here because:
 	// 1. it's only non-nil if the generation of a transaction id (XID) fails.
 	// 2. We always use the clients transaction id (XID) in responses. See dhcpv4.WithReply().
@@ -229,6 +460,15 @@ func (h *Handler) encodeToAttributes(d *dhcpv4.DHCPv4, namespace string) []attri
 	return a.Encode(d, namespace, oteldhcp.AllEncoders()...)
 }
 
+// notFoundError is returned when no Hardware matches a lookup that is not done by the backend.
+type notFoundError struct {
+	msg string
+}
+
+func (notFoundError) NotFound() bool { return true }
+
+func (e notFoundError) Error() string { return e.msg }
+
 // hardwareNotFound returns true if the error is from a hardware record not being found.
 func hardwareNotFound(err error) bool {
 	type hardwareNotFound interface {
@@ -0,0 +1,280 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/ipv6"
+)
+
+// Handle responds to DHCPv6 messages with the IPv6 addresses and options from host reservations.
+func (h *Handler6) Handle(ctx context.Context, conn *ipv6.PacketConn, p dhcp.Packet6) {
+	if h.Log.GetSink() == nil {
+		h.Log = logr.Discard()
+	}
+	if p.Pkt == nil {
+		h.Log.Error(errors.New("incoming packet is nil"), "not able to respond when the incoming packet is nil")
+		return
+	}
+	if p.Peer == nil {
+		h.Log.Error(errors.New("peer is nil"), "not able to respond when the peer is nil")
+		return
+	}
+	if conn == nil {
+		h.Log.Error(errors.New("connection is nil"), "not able to respond when the connection is nil")
+		return
+	}
+
+	var ifName string
+	if p.Md != nil {
+		ifName = p.Md.IfName
+	}
+	log := h.Log.WithValues("xid", p.Pkt.TransactionID.String(), "interface", ifName, "type", p.Pkt.Type().String())
+	tracer := otel.Tracer(tracerName)
+	var span trace.Span
+	ctx, span = tracer.Start(
+		ctx,
+		fmt.Sprintf("DHCPv6 Packet Received: %v", p.Pkt.Type().String()),
+		trace.WithAttributes(attribute.String("DHCP.peer", p.Peer.String())),
+		trace.WithAttributes(attribute.String("DHCP.server.ifname", ifName)),
+	)
+
+	defer span.End()
+
+	switch p.Pkt.Type() {
+	case dhcpv6.MessageTypeSolicit, dhcpv6.MessageTypeRequest, dhcpv6.MessageTypeRenew, dhcpv6.MessageTypeRebind,
+		dhcpv6.MessageTypeConfirm, dhcpv6.MessageTypeInformationRequest, dhcpv6.MessageTypeRelease, dhcpv6.MessageTypeDecline:
+	default:
+		log.Info("received unknown message type")
+		span.SetStatus(codes.Error, "received unknown message type")
+
+		return
+	}
+	// Messages that name a server must only be answered by that server.
+	if sid := p.Pkt.Options.ServerID(); sid != nil && h.ServerID != nil && !sid.Equal(h.ServerID) {
+		log.V(1).Info("ignoring DHCPv6 packet for another server", "serverID", sid.String())
+		span.SetStatus(codes.Ok, "packet for another server")
+
+		return
+	}
+
+	mac, err := p.MAC()
+	if err != nil {
+		log.Info("ignoring DHCPv6 packet", "error", err)
+		span.SetStatus(codes.Ok, err.Error())
+
+		return
+	}
+	log = log.WithValues("mac", mac.String())
+	span.SetAttributes(attribute.String("DHCP.mac", mac.String()))
+
+	d, n, err := readBackend(ctx, h.Backend, mac)
+	if err != nil {
+		if hardwareNotFound(err) {
+			span.SetStatus(codes.Ok, "no reservation found")
+			return
+		}
+		log.Info("error reading from backend", "error", err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return
+	}
+	if d.Disabled {
+		log.Info("DHCP is disabled for this MAC address, no response sent")
+		span.SetStatus(codes.Ok, "disabled DHCP response")
+
+		return
+	}
+	log.Info("received DHCPv6 packet")
+
+	reply, err := h.updateMsg(p.Pkt, mac, d, n)
+	if err != nil {
+		log.Info("error creating DHCPv6 response", "error", err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return
+	}
+	if bf := reply.Options.BootFileURL(); bf != "" {
+		log = log.WithValues("bootFileURL", bf)
+	}
+
+	out, dst, err := p.Reply(reply)
+	if err != nil {
+		log.Info("error creating DHCPv6 relay reply", "error", err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return
+	}
+	log = log.WithValues("replyType", reply.Type().String(), "ipAddresses", d.IPv6Addresses, "destination", dst.String())
+	cm := &ipv6.ControlMessage{}
+	if p.Md != nil {
+		cm.IfIndex = p.Md.IfIndex
+	}
+
+	if _, err := conn.WriteTo(out.ToBytes(), cm, dst); err != nil {
+		log.Error(err, "failed to send DHCPv6")
+		span.SetStatus(codes.Error, err.Error())
+
+		return
+	}
+
+	log.Info("sent DHCPv6 response")
+	span.SetStatus(codes.Ok, "sent DHCPv6 response")
+	var addr netip.Addr
+	if len(d.IPv6Addresses) > 0 {
+		addr = d.IPv6Addresses[0]
+	}
+	h.NetbootStatus.DHCP(mac, addr, ifName, reply.Type().String(), h.firmwareSolicit(p.Pkt, mac))
+}
+
+// firmwareSolicit reports whether msg starts a netboot. It's a DHCPv6 solicit from the firmware of a netboot client, not from iPXE.
+func (h *Handler6) firmwareSolicit(msg *dhcpv6.Message, mac net.HardwareAddr) bool {
+	if msg.Type() != dhcpv6.MessageTypeSolicit {
+		return false
+	}
+	i := dhcp.NewInfo6(msg, mac)
+
+	return i.IsNetbootClient == nil && !fromIPXE(i.UserClass, h.Netboot.UserClass)
+}
+
+// updateMsg creates the response to a DHCPv6 message with the data from the backend.
+func (h *Handler6) updateMsg(msg *dhcpv6.Message, mac net.HardwareAddr, d *dhcp.DHCP, n *dhcp.Netboot) (*dhcpv6.Message, error) {
+	mods := []dhcpv6.Modifier{dhcpv6.WithServerID(h.ServerID)}
+
+	switch msg.Type() {
+	case dhcpv6.MessageTypeRelease, dhcpv6.MessageTypeDecline:
+		// Since the design of this DHCP server is that all IP addresses are
+		// Host reservations, when a client releases or declines an address, the server
+		// doesn't have anything to do other than acknowledge the message.
+		mods = append(mods, dhcpv6.WithOption(&dhcpv6.OptStatusCode{StatusCode: iana.StatusSuccess, StatusMessage: "all addresses are host reservations"}))
+
+		return dhcpv6.NewReplyFromMessage(msg, mods...)
+	case dhcpv6.MessageTypeConfirm:
+		mods = append(mods, dhcpv6.WithOption(confirmStatus(msg, d)))
+
+		return dhcpv6.NewReplyFromMessage(msg, mods...)
+	}
+
+	mods = append(mods, setDHCPv6Opts(d)...)
+	mods = append(mods, h.setNetworkBootOpts(msg, mac, d, n)...)
+	if msg.Type() != dhcpv6.MessageTypeInformationRequest {
+		if ia := identityAssociation(msg, d); ia != nil {
+			mods = append(mods, dhcpv6.WithOption(ia))
+		}
+	}
+
+	if msg.Type() == dhcpv6.MessageTypeSolicit && msg.GetOneOption(dhcpv6.OptionRapidCommit) == nil {
+		return dhcpv6.NewAdvertiseFromSolicit(msg, mods...)
+	}
+
+	return dhcpv6.NewReplyFromMessage(msg, mods...)
+}
+
+// setDHCPv6Opts returns the DHCPv6 options, other than addresses and netboot options, for a response.
+// Only IPv6 name servers can be sent in a DHCPv6 response, IPv4 name servers are ignored.
+func setDHCPv6Opts(d *dhcp.DHCP) []dhcpv6.Modifier {
+	var mods []dhcpv6.Modifier
+	var dns []net.IP
+	for _, ns := range d.NameServers {
+		if ns.To4() == nil {
+			dns = append(dns, ns)
+		}
+	}
+	if len(dns) > 0 {
+		mods = append(mods, dhcpv6.WithDNS(dns...))
+	}
+	if len(d.DomainSearch) > 0 {
+		mods = append(mods, dhcpv6.WithDomainSearchList(d.DomainSearch...))
+	}
+
+	return mods
+}
+
+// setNetworkBootOpts returns the boot file URL option for netboot clients that are allowed to netboot.
+// If a BootFileName that is a URL is set on the Hardware it is used instead of the default netboot logic.
+func (h *Handler6) setNetworkBootOpts(msg *dhcpv6.Message, mac net.HardwareAddr, d *dhcp.DHCP, n *dhcp.Netboot) []dhcpv6.Modifier {
+	if !h.Netboot.Enabled || n == nil || (!n.AllowNetboot && !h.Netboot.IPXEMenu) {
+		return nil
+	}
+	if d.BootFileName != "" {
+		if u, err := url.Parse(d.BootFileName); err == nil && u.Scheme != "" {
+			return dhcp.WithNetboot6(msg, d.BootFileName)
+		}
+		return nil
+	}
+	binary := bootBinary(dhcp.Arch6(msg), n, h.Netboot.SecureBootShims)
+	i := dhcp.NewInfo6(msg, mac, dhcp.WithMacAddrFormat(h.Netboot.InjectMacAddrFormat), dhcp.WithIPXEBinary(binary), dhcp.WithArchMappingOverride(h.Netboot.IPXEArchMapping))
+	if i.IsNetbootClient != nil {
+		return nil
+	}
+	var ipxeScript *url.URL
+	// If the global IPXEScriptURL is set, use that.
+	if h.Netboot.IPXEScriptURL != nil {
+		ipxeScript = h.Netboot.IPXEScriptURL(mac)
+	}
+	// If the IPXE script URL is set on the hardware record, use that.
+	if n.IPXEScriptURL != nil {
+		ipxeScript = n.IPXEScriptURL
+	}
+
+	return dhcp.WithNetboot6(msg, i.BootFileURL(h.Netboot.UserClass, ipxeScript, h.Netboot.IPXEBinServerHTTP, h.Netboot.IPXEBinServerTFTP))
+}
+
+// identityAssociation returns the IA_NA option for the response to msg.
+// All reserved IPv6 addresses are returned in the IA_NA that the client asked for. Addresses the client
+// asked for that are not reserved are returned with zero lifetimes so that the client stops using them.
+// nil is returned if the client did not ask for an IA_NA.
+func identityAssociation(msg *dhcpv6.Message, d *dhcp.DHCP) *dhcpv6.OptIANA {
+	req := msg.Options.OneIANA()
+	if req == nil {
+		return nil
+	}
+	resp := &dhcpv6.OptIANA{IaId: req.IaId}
+	if len(d.IPv6Addresses) == 0 {
+		resp.Options.Add(&dhcpv6.OptStatusCode{StatusCode: iana.StatusNoAddrsAvail, StatusMessage: "no IPv6 address reserved"})
+
+		return resp
+	}
+
+	lt := time.Duration(d.LeaseTime) * time.Second
+	resp.T1 = lt / 2
+	resp.T2 = lt * 4 / 5
+	for _, a := range d.IPv6Addresses {
+		resp.Options.Add(&dhcpv6.OptIAAddress{IPv6Addr: a.AsSlice(), PreferredLifetime: lt, ValidLifetime: lt})
+	}
+	for _, a := range req.Options.Addresses() {
+		if !slices.ContainsFunc(d.IPv6Addresses, func(r netip.Addr) bool { return net.IP(r.AsSlice()).Equal(a.IPv6Addr) }) {
+			resp.Options.Add(&dhcpv6.OptIAAddress{IPv6Addr: a.IPv6Addr})
+		}
+	}
+
+	return resp
+}
+
+// confirmStatus returns the status of a Confirm message. The addresses in the message are
+// only confirmed if all of them are reserved for the client.
+func confirmStatus(msg *dhcpv6.Message, d *dhcp.DHCP) *dhcpv6.OptStatusCode {
+	for _, ia := range msg.Options.IANA() {
+		for _, a := range ia.Options.Addresses() {
+			if !slices.ContainsFunc(d.IPv6Addresses, func(r netip.Addr) bool { return net.IP(r.AsSlice()).Equal(a.IPv6Addr) }) {
+				return &dhcpv6.OptStatusCode{StatusCode: iana.StatusNotOnLink, StatusMessage: fmt.Sprintf("%v is not reserved", a.IPv6Addr)}
+			}
+		}
+	}
+
+	return &dhcpv6.OptStatusCode{StatusCode: iana.StatusSuccess, StatusMessage: "all addresses are on link"}
+}
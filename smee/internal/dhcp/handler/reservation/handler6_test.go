@@ -0,0 +1,203 @@
+//go:build linux
+
+package reservation
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
+	"golang.org/x/net/ipv6"
+)
+
+func TestHandle6(t *testing.T) {
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	serverID := &dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}}
+	tests := map[string]struct {
+		mods        []dhcpv6.Modifier
+		wantType    dhcpv6.MessageType
+		wantAddrs   []string
+		wantBootURL string
+		wantNoReply bool
+	}{
+		"solicit gets advertise with address and boot file url": {
+			mods:        []dhcpv6.Modifier{dhcpv6.WithArchType(iana.EFI_X86_64)},
+			wantType:    dhcpv6.MessageTypeAdvertise,
+			wantAddrs:   []string{"2001:db8::100"},
+			wantBootURL: "tftp://[2001:db8::1]:69/01:02:03:04:05:06/ipxe.efi",
+		},
+		"rapid commit solicit gets reply": {
+			mods:      []dhcpv6.Modifier{dhcpv6.WithRapidCommit},
+			wantType:  dhcpv6.MessageTypeReply,
+			wantAddrs: []string{"2001:db8::100"},
+		},
+		"packet for another server is ignored": {
+			mods:        []dhcpv6.Modifier{dhcpv6.WithServerID(&dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{0, 0, 0, 0, 0, 1}})},
+			wantNoReply: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &Handler6{
+				Backend:  &mockBackend{allowNetboot: true, ipv6Addresses: []string{"2001:db8::100"}},
+				ServerID: serverID,
+				Netboot: Netboot6{
+					Enabled:           true,
+					IPXEBinServerTFTP: netip.MustParseAddrPort("[2001:db8::1]:69"),
+				},
+			}
+			srv, err := net.ListenPacket("udp6", "[::1]:0")
+			if err != nil {
+				t.Skipf("unable to listen on ::1: %v", err)
+			}
+			defer srv.Close()
+			client, err := net.ListenPacket("udp6", "[::1]:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			req, err := dhcpv6.NewSolicit(mac, tt.mods...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h.Handle(context.Background(), ipv6.NewPacketConn(srv), dhcp.Packet6{Peer: client.LocalAddr(), Pkt: req})
+
+			buf := make([]byte, 4096)
+			_ = client.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+			n, _, err := client.ReadFrom(buf)
+			if tt.wantNoReply {
+				if err == nil {
+					t.Fatal("expected no response")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := dhcpv6.MessageFromBytes(buf[:n])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Type() != tt.wantType {
+				t.Fatalf("got message type %v, want %v", resp.Type(), tt.wantType)
+			}
+			if resp.TransactionID != req.TransactionID {
+				t.Fatalf("transaction ID mismatch: got %v, want %v", resp.TransactionID, req.TransactionID)
+			}
+			ia := resp.Options.OneIANA()
+			if ia == nil {
+				t.Fatal("expected an IA_NA option")
+			}
+			var got []string
+			for _, a := range ia.Options.Addresses() {
+				got = append(got, a.IPv6Addr.String())
+			}
+			if diff := cmp.Diff(tt.wantAddrs, got); diff != "" {
+				t.Fatal(diff)
+			}
+			if ia.T1 != 30*time.Second || ia.T2 != 48*time.Second {
+				t.Fatalf("unexpected T1/T2: %v/%v", ia.T1, ia.T2)
+			}
+			if diff := cmp.Diff(tt.wantBootURL, resp.Options.BootFileURL()); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestUpdateMsg6(t *testing.T) {
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	reserved := netip.MustParseAddr("2001:db8::100")
+	d := &dhcp.DHCP{MACAddress: mac, IPv6Addresses: []netip.Addr{reserved}, LeaseTime: 60}
+	withAddr := func(addr string) dhcpv6.Modifier {
+		return dhcpv6.WithIANA(dhcpv6.OptIAAddress{IPv6Addr: net.ParseIP(addr)})
+	}
+	tests := map[string]struct {
+		msgType    dhcpv6.MessageType
+		mods       []dhcpv6.Modifier
+		d          *dhcp.DHCP
+		wantStatus *iana.StatusCode
+		wantAddrs  map[string]time.Duration
+	}{
+		"renew with an unreserved address": {
+			msgType:   dhcpv6.MessageTypeRenew,
+			mods:      []dhcpv6.Modifier{withAddr("2001:db8::200")},
+			d:         d,
+			wantAddrs: map[string]time.Duration{"2001:db8::100": time.Minute, "2001:db8::200": 0},
+		},
+		"confirm with an unreserved address": {
+			msgType:    dhcpv6.MessageTypeConfirm,
+			mods:       []dhcpv6.Modifier{withAddr("2001:db8::200")},
+			d:          d,
+			wantStatus: func() *iana.StatusCode { s := iana.StatusNotOnLink; return &s }(),
+		},
+		"confirm with a reserved address": {
+			msgType:    dhcpv6.MessageTypeConfirm,
+			mods:       []dhcpv6.Modifier{withAddr("2001:db8::100")},
+			d:          d,
+			wantStatus: func() *iana.StatusCode { s := iana.StatusSuccess; return &s }(),
+		},
+		"release": {
+			msgType:    dhcpv6.MessageTypeRelease,
+			mods:       []dhcpv6.Modifier{withAddr("2001:db8::100")},
+			d:          d,
+			wantStatus: func() *iana.StatusCode { s := iana.StatusSuccess; return &s }(),
+		},
+		"request without reserved addresses": {
+			msgType: dhcpv6.MessageTypeRequest,
+			mods:    []dhcpv6.Modifier{withAddr("2001:db8::200")},
+			d:       &dhcp.DHCP{MACAddress: mac, LeaseTime: 60},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &Handler6{ServerID: &dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: mac}}
+			msg, err := dhcpv6.NewSolicit(mac, tt.mods...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg.MessageType = tt.msgType
+			got, err := h.updateMsg(msg, mac, tt.d, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Type() != dhcpv6.MessageTypeReply {
+				t.Fatalf("got message type %v, want Reply", got.Type())
+			}
+			if tt.wantStatus != nil {
+				sc, ok := got.GetOneOption(dhcpv6.OptionStatusCode).(*dhcpv6.OptStatusCode)
+				if !ok {
+					t.Fatal("expected a status code option")
+				}
+				if sc.StatusCode != *tt.wantStatus {
+					t.Fatalf("got status %v, want %v", sc.StatusCode, *tt.wantStatus)
+				}
+				return
+			}
+			ia := got.Options.OneIANA()
+			if ia == nil {
+				t.Fatal("expected an IA_NA option")
+			}
+			if tt.wantAddrs == nil {
+				if sc := ia.Options.Status(); sc == nil || sc.StatusCode != iana.StatusNoAddrsAvail {
+					t.Fatalf("expected NoAddrsAvail status, got %v", sc)
+				}
+				return
+			}
+			addrs := map[string]time.Duration{}
+			for _, a := range ia.Options.Addresses() {
+				addrs[a.IPv6Addr.String()] = a.ValidLifetime
+			}
+			if diff := cmp.Diff(tt.wantAddrs, addrs); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
@@ -50,6 +50,9 @@ type mockBackend struct {
 	classlessStaticRoutes dhcpv4.Routes
 	tftpServerName        string
 	bootFileName          string
+	ipv6Addresses         []string
+	// relayAgentInfo, when set, makes the Hardware only match by this relay agent information key.
+	relayAgentInfo *tinkerbell.RelayAgentInfo
 }
 
 type hwNotFoundError struct{}
@@ -57,13 +60,16 @@ type hwNotFoundError struct{}
 func (hwNotFoundError) NotFound() bool { return true }
 func (hwNotFoundError) Error() string  { return "not found" }
 
-func (m *mockBackend) FilterHardware(_ context.Context, _ data.HardwareFilter) (*tinkerbell.Hardware, error) {
+func (m *mockBackend) FilterHardware(_ context.Context, f data.HardwareFilter) (*tinkerbell.Hardware, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
 	if m.hardwareNotFound {
 		return nil, hwNotFoundError{}
 	}
+	if m.relayAgentInfo != nil && f.ByRelayAgentInfo != m.relayAgentInfo.Key() {
+		return nil, hwNotFoundError{}
+	}
 	hw := &tinkerbell.Hardware{
 		Spec: tinkerbell.HardwareSpec{
 			Interfaces: []tinkerbell.Interface{
@@ -76,6 +82,13 @@ func (m *mockBackend) FilterHardware(_ context.Context, _ data.HardwareFilter) (
 							Gateway: "192.168.1.1",
 							Family:  4,
 						},
+						IPv6: func() *tinkerbell.IPv6 {
+							if len(m.ipv6Addresses) == 0 {
+								return nil
+							}
+							return &tinkerbell.IPv6{Addresses: m.ipv6Addresses}
+						}(),
+						RelayAgentInfo: m.relayAgentInfo,
 						NameServers:    []string{"1.1.1.1"},
 						Hostname:       "test-host",
 						DomainName:     "mydomain.com",
@@ -1116,3 +1129,41 @@ func TestDHCPOnlyModeReflection(t *testing.T) {
 		})
 	}
 }
+
+func TestFirmwareDiscover(t *testing.T) {
+	pkt := func(mt dhcpv4.MessageType, uc string) *dhcpv4.DHCPv4 {
+		mods := []dhcpv4.Modifier{
+			dhcpv4.WithMessageType(mt),
+			dhcpv4.WithGeneric(dhcpv4.OptionClassIdentifier, []byte("PXEClient:Arch:00007:UNDI:003001")),
+			dhcpv4.WithGeneric(dhcpv4.OptionClientSystemArchitectureType, []byte{0x00, 0x07}),
+			dhcpv4.WithGeneric(dhcpv4.OptionClientNetworkInterfaceIdentifier, []byte{0x01, 0x03, 0x00}),
+		}
+		if uc != "" {
+			mods = append(mods, dhcpv4.WithGeneric(dhcpv4.OptionUserClassInformation, []byte(uc)))
+		}
+		p, err := dhcpv4.New(mods...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	tests := map[string]struct {
+		pkt  *dhcpv4.DHCPv4
+		want bool
+	}{
+		"firmware discover":    {pkt: pkt(dhcpv4.MessageTypeDiscover, ""), want: true},
+		"firmware request":     {pkt: pkt(dhcpv4.MessageTypeRequest, "")},
+		"iPXE discover":        {pkt: pkt(dhcpv4.MessageTypeDiscover, "iPXE")},
+		"tinkerbell":           {pkt: pkt(dhcpv4.MessageTypeDiscover, "Tinkerbell")},
+		"custom user class":    {pkt: pkt(dhcpv4.MessageTypeDiscover, "custom")},
+		"not a netboot client": {pkt: &dhcpv4.DHCPv4{Options: dhcpv4.OptionsFromList(dhcpv4.OptMessageType(dhcpv4.MessageTypeDiscover))}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{Netboot: Netboot{UserClass: "custom"}}
+			if got := h.firmwareDiscover(tt.pkt); got != tt.want {
+				t.Fatalf("firmwareDiscover() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/iana"
 	"github.com/tinkerbell/tinkerbell/pkg/otel"
 	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
 	dhcpotel "github.com/tinkerbell/tinkerbell/smee/internal/dhcp/otel"
@@ -91,8 +92,9 @@ func (h *Handler) setNetworkBootOpts(ctx context.Context, m *dhcpv4.DHCPv4, n *d
 		}
 		d.BootFileName = "/netboot-not-allowed"
 		d.ServerIPAddr = net.IPv4(0, 0, 0, 0)
-		if n.AllowNetboot {
-			i := dhcp.NewInfo(m, dhcp.WithMacAddrFormat(h.Netboot.InjectMacAddrFormat), dhcp.WithIPXEBinary(n.IPXEBinary), dhcp.WithArchMappingOverride(h.Netboot.IPXEArchMapping))
+		if n.AllowNetboot || h.Netboot.IPXEMenu {
+			binary := bootBinary(dhcp.Arch(m), n, h.Netboot.SecureBootShims)
+			i := dhcp.NewInfo(m, dhcp.WithMacAddrFormat(h.Netboot.InjectMacAddrFormat), dhcp.WithIPXEBinary(binary), dhcp.WithArchMappingOverride(h.Netboot.IPXEArchMapping))
 			if i.IPXEBinary == "" {
 				return
 			}
@@ -105,7 +107,7 @@ func (h *Handler) setNetworkBootOpts(ctx context.Context, m *dhcpv4.DHCPv4, n *d
 			if n.IPXEScriptURL != nil {
 				ipxeScript = n.IPXEScriptURL
 			}
-			d.BootFileName, d.ServerIPAddr = h.bootfileAndNextServer(ctx, m, h.Netboot.UserClass, h.Netboot.IPXEBinServerTFTP, h.Netboot.IPXEBinServerHTTP, ipxeScript, i, n.IPXEBinary)
+			d.BootFileName, d.ServerIPAddr = h.bootfileAndNextServer(ctx, m, h.Netboot.UserClass, h.Netboot.IPXEBinServerTFTP, h.Netboot.IPXEBinServerHTTP, ipxeScript, i, binary)
 			pxe := dhcpv4.Options{ // FYI, these are suboptions of option43. ref: https://datatracker.ietf.org/doc/html/rfc2132#section-8.4
 				// PXE Boot Server Discovery Control - bypass, just boot from filename.
 				6:  []byte{8},
@@ -118,6 +120,19 @@ func (h *Handler) setNetworkBootOpts(ctx context.Context, m *dhcpv4.DHCPv4, n *d
 	return withNetboot
 }
 
+// bootBinary returns the binary that overrides the default architecture to binary mapping for a client.
+// This is the signed shim of the client's architecture when the Hardware has Secure Boot enabled and
+// the iPXE binary of the Hardware otherwise. An empty string means the default mapping is used.
+func bootBinary(arch iana.Arch, n *dhcp.Netboot, shims map[iana.Arch]string) string {
+	if n.SecureBoot {
+		if shim, ok := shims[arch]; ok && shim != "" {
+			return shim
+		}
+	}
+
+	return n.IPXEBinary
+}
+
 // bootfileAndNextServer returns the bootfile (string) and next server (net.IP).
 // input arguments `tftp`, `ipxe` and `iscript` use non string types so as to attempt to be more clear about the expectation around what is wanted for these values.
 // It also helps us avoid having to validate a string in multiple ways.
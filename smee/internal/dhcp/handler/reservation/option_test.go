@@ -19,6 +19,7 @@ import (
 	dhcpotel "github.com/tinkerbell/tinkerbell/pkg/otel"
 	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
 	oteldhcp "github.com/tinkerbell/tinkerbell/smee/internal/dhcp/otel"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp/pool"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/propagation"
 )
@@ -351,6 +352,30 @@ func TestSetNetworkBootOpts(t *testing.T) {
 			},
 			want: &dhcpv4.DHCPv4{ServerIPAddr: net.IPv4(0, 0, 0, 0), BootFileName: "/netboot-not-allowed"},
 		},
+		"netboot not allowed, ipxe menu enabled": {
+			server: &Handler{Log: logr.Discard(), Netboot: Netboot{IPXEMenu: true, IPXEScriptURL: func(*dhcpv4.DHCPv4) *url.URL {
+				return &url.URL{Scheme: "http", Host: "localhost:8181", Path: "/01:02:03:04:05:06/auto.ipxe"}
+			}}},
+			args: args{
+				in0: context.Background(),
+				m: &dhcpv4.DHCPv4{
+					ClientHWAddr: net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+					Options: dhcpv4.OptionsFromList(
+						dhcpv4.OptUserClass(dhcp.Tinkerbell.String()),
+						dhcpv4.OptClassIdentifier("HTTPClient:xxxxx"),
+						dhcpv4.OptClientArch(iana.EFI_X86_64_HTTP),
+					),
+				},
+				n: &dhcp.Netboot{AllowNetboot: false},
+			},
+			want: &dhcpv4.DHCPv4{BootFileName: "http://localhost:8181/01:02:03:04:05:06/auto.ipxe", Options: dhcpv4.OptionsFromList(
+				dhcpv4.OptGeneric(dhcpv4.OptionVendorSpecificInformation, dhcpv4.Options{
+					6:  []byte{8},
+					69: oteldhcp.TraceparentFromContext(context.Background()),
+				}.ToBytes()),
+				dhcpv4.OptClassIdentifier("HTTPClient"),
+			)},
+		},
 		"netboot allowed": {
 			server: &Handler{Log: logr.Discard(), Netboot: Netboot{IPXEScriptURL: func(*dhcpv4.DHCPv4) *url.URL {
 				return &url.URL{Scheme: "http", Host: "localhost:8181", Path: "/01:02:03:04:05:06/auto.ipxe"}
@@ -402,6 +427,34 @@ func TestSetNetworkBootOpts(t *testing.T) {
 				dhcpv4.OptClassIdentifier("HTTPClient"),
 			)},
 		},
+		"netboot allowed, secure boot": {
+			server: &Handler{
+				Log: logr.Discard(),
+				Netboot: Netboot{
+					Enabled:           true,
+					IPXEBinServerHTTP: &url.URL{Scheme: "http", Host: "localhost:8181", Path: "/ipxe"},
+					SecureBootShims:   map[iana.Arch]string{iana.EFI_X86_64_HTTP: "secureboot/shimx64.efi"},
+				},
+			},
+			args: args{
+				in0: context.Background(),
+				m: &dhcpv4.DHCPv4{
+					ClientHWAddr: net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+					Options: dhcpv4.OptionsFromList(
+						dhcpv4.OptClassIdentifier("HTTPClient:xxxxx"),
+						dhcpv4.OptClientArch(iana.EFI_X86_64_HTTP),
+					),
+				},
+				n: &dhcp.Netboot{AllowNetboot: true, IPXEBinary: "snp-x86_64.efi", SecureBoot: true},
+			},
+			want: &dhcpv4.DHCPv4{BootFileName: "http://localhost:8181/ipxe/01:02:03:04:05:06/secureboot/shimx64.efi", Options: dhcpv4.OptionsFromList(
+				dhcpv4.OptGeneric(dhcpv4.OptionVendorSpecificInformation, dhcpv4.Options{
+					6:  []byte{8},
+					69: oteldhcp.TraceparentFromContext(context.Background()),
+				}.ToBytes()),
+				dhcpv4.OptClassIdentifier("HTTPClient"),
+			)},
+		},
 		"netboot not allowed, arch unknown": {
 			server: &Handler{Log: logr.Discard(), Netboot: Netboot{IPXEScriptURL: func(*dhcpv4.DHCPv4) *url.URL {
 				return &url.URL{Scheme: "http", Host: "localhost:8181", Path: "/01:02:03:04:05:06/auto.ipxe"}
@@ -430,6 +483,8 @@ func TestSetNetworkBootOpts(t *testing.T) {
 					IPXEScriptURL:     tt.server.Netboot.IPXEScriptURL,
 					Enabled:           tt.server.Netboot.Enabled,
 					UserClass:         tt.server.Netboot.UserClass,
+					IPXEMenu:          tt.server.Netboot.IPXEMenu,
+					SecureBootShims:   tt.server.Netboot.SecureBootShims,
 				},
 				IPAddr:  tt.server.IPAddr,
 				Backend: tt.server.Backend,
@@ -443,3 +498,79 @@ func TestSetNetworkBootOpts(t *testing.T) {
 		})
 	}
 }
+
+func TestBootBinary(t *testing.T) {
+	shims := map[iana.Arch]string{iana.EFI_X86_64: "secureboot/shimx64.efi", iana.EFI_ARM64: "secureboot/shimaa64.efi"}
+	tests := map[string]struct {
+		arch iana.Arch
+		n    *dhcp.Netboot
+		want string
+	}{
+		"default mapping":              {arch: iana.EFI_X86_64, n: &dhcp.Netboot{}, want: ""},
+		"hardware binary":              {arch: iana.EFI_X86_64, n: &dhcp.Netboot{IPXEBinary: "snp-x86_64.efi"}, want: "snp-x86_64.efi"},
+		"secure boot":                  {arch: iana.EFI_ARM64, n: &dhcp.Netboot{IPXEBinary: "snp-arm64.efi", SecureBoot: true}, want: "secureboot/shimaa64.efi"},
+		"secure boot, no shim":         {arch: iana.INTEL_X86PC, n: &dhcp.Netboot{SecureBoot: true}, want: ""},
+		"secure boot, hardware binary": {arch: iana.EFI_ARM32, n: &dhcp.Netboot{IPXEBinary: "snp-arm64.efi", SecureBoot: true}, want: "snp-arm64.efi"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := bootBinary(tt.arch, tt.n, shims); got != tt.want {
+				t.Errorf("bootBinary() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateMsgOptions(t *testing.T) {
+	mustOpt := func(code int, typ dhcp.OptionType, value string) dhcpv4.Option {
+		t.Helper()
+		o, err := dhcp.EncodeOption(code, typ, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return o
+	}
+	alloc, err := pool.NewAllocator([]pool.Pool{{
+		Subnet:  netip.MustParsePrefix("192.168.1.0/24"),
+		Options: []dhcpv4.Option{mustOpt(23, dhcp.OptionTypeUint8, "32"), mustOpt(119, dhcp.OptionTypeHex, "076578616d706c6500")},
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{
+		Log:     logr.Discard(),
+		IPAddr:  netip.MustParseAddr("192.168.1.2"),
+		Netboot: Netboot{Enabled: true},
+		Pools:   alloc,
+		Options: []dhcpv4.Option{mustOpt(26, dhcp.OptionTypeUint16, "1500"), mustOpt(23, dhcp.OptionTypeUint8, "64"), mustOpt(150, dhcp.OptionTypeIP, "10.0.0.1")},
+	}
+	req, err := dhcpv4.New(
+		dhcpv4.WithHwAddr(net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}),
+		dhcpv4.WithOption(dhcpv4.OptClientArch(iana.EFI_X86_64)),
+		dhcpv4.WithGeneric(dhcpv4.OptionClassIdentifier, []byte("PXEClient")),
+		dhcpv4.WithGeneric(dhcpv4.OptionClientNetworkInterfaceIdentifier, []byte{1, 2, 1}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &dhcp.DHCP{
+		IPAddress: netip.MustParseAddr("192.168.1.100"),
+		// The Hardware object replaces the global MTU and the netboot vendor options.
+		Options: []dhcpv4.Option{mustOpt(26, dhcp.OptionTypeUint16, "9000"), mustOpt(43, dhcp.OptionTypeHex, "01:02")},
+	}
+	h.withSubnetSettings(req, d)
+	reply := h.updateMsg(context.Background(), req, d, &dhcp.Netboot{AllowNetboot: true}, dhcpv4.MessageTypeOffer)
+
+	want := map[dhcpv4.OptionCode][]byte{
+		dhcpv4.OptionInterfaceMTU:              {0x23, 0x28},
+		dhcpv4.OptionDefaultIPTTL:              {32},
+		dhcpv4.OptionDNSDomainSearchList:       {7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0},
+		dhcpv4.GenericOptionCode(150):          {10, 0, 0, 1},
+		dhcpv4.OptionVendorSpecificInformation: {1, 2},
+	}
+	for code, v := range want {
+		if diff := cmp.Diff(reply.Options.Get(code), v); diff != "" {
+			t.Errorf("option %v: %s", code, diff)
+		}
+	}
+}
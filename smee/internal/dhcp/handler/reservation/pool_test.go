@@ -0,0 +1,119 @@
+//go:build linux
+
+package reservation
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp/pool"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/nettest"
+)
+
+func TestHandlePool(t *testing.T) {
+	mac := net.HardwareAddr{0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
+	alloc, err := pool.NewAllocator([]pool.Pool{{
+		Subnet:      netip.MustParsePrefix("192.168.2.0/24"),
+		Ranges:      []pool.Range{{Start: netip.MustParseAddr("192.168.2.100"), End: netip.MustParseAddr("192.168.2.110")}},
+		Gateway:     netip.MustParseAddr("192.168.2.1"),
+		NameServers: []netip.Addr{netip.MustParseAddr("8.8.8.8")},
+		LeaseTime:   10 * time.Minute,
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{
+		Backend: &mockBackend{hardwareNotFound: true},
+		IPAddr:  netip.MustParseAddr("127.0.0.1"),
+		Netboot: Netboot{Enabled: true, IPXEBinServerTFTP: netip.MustParseAddrPort("127.0.0.1:69")},
+		Pools:   alloc,
+	}
+
+	conn, err := nettest.NewLocalPacketListener("udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	con := ipv4.NewPacketConn(conn)
+	pc, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	peer := &net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: pc.LocalAddr().(*net.UDPAddr).Port}
+
+	send := func(mods ...dhcpv4.Modifier) *dhcpv4.DHCPv4 {
+		t.Helper()
+		mods = append([]dhcpv4.Modifier{
+			dhcpv4.WithHwAddr(mac),
+			dhcpv4.WithOption(dhcpv4.OptClientArch(iana.INTEL_X86PC)),
+			dhcpv4.WithGeneric(dhcpv4.OptionClassIdentifier, []byte("PXEClient")),
+			dhcpv4.WithGeneric(dhcpv4.OptionClientNetworkInterfaceIdentifier, []byte{1, 2, 1}),
+		}, mods...)
+		req, err := dhcpv4.New(mods...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Handle(context.Background(), con, dhcp.Packet{Peer: peer, Pkt: req})
+		resp, err := client(pc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	offer := send(dhcpv4.WithMessageType(dhcpv4.MessageTypeDiscover))
+	if offer.MessageType() != dhcpv4.MessageTypeOffer {
+		t.Fatalf("got %v, want offer", offer.MessageType())
+	}
+	if want := (net.IP{192, 168, 2, 100}); !offer.YourIPAddr.Equal(want) {
+		t.Fatalf("got yiaddr %v, want %v", offer.YourIPAddr, want)
+	}
+	if got := offer.Router(); len(got) != 1 || !got[0].Equal(net.IP{192, 168, 2, 1}) {
+		t.Fatalf("unexpected router %v", got)
+	}
+	if got := offer.DNS(); len(got) != 1 || !got[0].Equal(net.IP{8, 8, 8, 8}) {
+		t.Fatalf("unexpected dns %v", got)
+	}
+	if got := offer.IPAddressLeaseTime(0); got != 10*time.Minute {
+		t.Fatalf("got lease time %v, want %v", got, 10*time.Minute)
+	}
+	if offer.BootFileName == "" || offer.BootFileName == "/netboot-not-allowed" {
+		t.Fatalf("expected netboot options for a dynamic lease, got boot file %q", offer.BootFileName)
+	}
+	if l := alloc.Leases(); len(l) != 0 {
+		t.Fatalf("expected an offer not to commit a lease, got %v", l)
+	}
+
+	nak := send(dhcpv4.WithMessageType(dhcpv4.MessageTypeRequest), dhcpv4.WithOption(dhcpv4.OptRequestedIPAddress(net.IP{192, 168, 2, 105})))
+	if nak.MessageType() != dhcpv4.MessageTypeNak {
+		t.Fatalf("got %v, want nak for an address that is not leased to the client", nak.MessageType())
+	}
+
+	ack := send(dhcpv4.WithMessageType(dhcpv4.MessageTypeRequest), dhcpv4.WithOption(dhcpv4.OptRequestedIPAddress(offer.YourIPAddr)))
+	if ack.MessageType() != dhcpv4.MessageTypeAck {
+		t.Fatalf("got %v, want ack", ack.MessageType())
+	}
+	if !ack.YourIPAddr.Equal(offer.YourIPAddr) {
+		t.Fatalf("got yiaddr %v, want %v", ack.YourIPAddr, offer.YourIPAddr)
+	}
+	if l := alloc.Leases(); len(l) != 1 {
+		t.Fatalf("expected the request to commit a lease, got %v", l)
+	}
+
+	req, err := dhcpv4.New(dhcpv4.WithHwAddr(mac), dhcpv4.WithMessageType(dhcpv4.MessageTypeRelease), dhcpv4.WithClientIP(offer.YourIPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Handle(context.Background(), con, dhcp.Packet{Peer: peer, Pkt: req})
+	if l := alloc.Leases(); len(l) != 0 {
+		t.Fatalf("expected the lease to be released, got %v", l)
+	}
+}
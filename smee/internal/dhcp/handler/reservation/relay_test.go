@@ -0,0 +1,205 @@
+//go:build linux
+
+package reservation
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp/pool"
+)
+
+func TestReadBackendByRelayAgentInfo(t *testing.T) {
+	// The NIC was replaced, the new MAC is not in the Hardware object but the switch port is.
+	mac := net.HardwareAddr{0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
+	relay := &net.UDPAddr{IP: net.IP{10, 0, 0, 1}, Port: dhcpv4.ServerPort}
+	circuitAndRemote := []dhcpv4.Option{dhcpv4.OptGeneric(dhcpv4.AgentCircuitIDSubOption, []byte("Gi1/0/10")), dhcpv4.OptGeneric(dhcpv4.AgentRemoteIDSubOption, []byte("tor-1"))}
+	tests := map[string]struct {
+		hw      *tinkerbell.RelayAgentInfo
+		opts    []dhcpv4.Option
+		giaddr  net.IP
+		peer    net.Addr
+		trusted []netip.Prefix
+		wantIP  netip.Addr
+	}{
+		"circuit and remote id": {
+			hw:      &tinkerbell.RelayAgentInfo{CircuitID: "Gi1/0/10", RemoteID: "tor-1"},
+			opts:    circuitAndRemote,
+			giaddr:  relay.IP,
+			peer:    relay,
+			trusted: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/24")},
+			wantIP:  netip.MustParseAddr("192.168.1.100"),
+		},
+		"circuit id only in hardware": {
+			hw:      &tinkerbell.RelayAgentInfo{CircuitID: "Gi1/0/10"},
+			opts:    circuitAndRemote,
+			giaddr:  relay.IP,
+			peer:    relay,
+			trusted: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/24")},
+			wantIP:  netip.MustParseAddr("192.168.1.100"),
+		},
+		"different port": {
+			hw:      &tinkerbell.RelayAgentInfo{CircuitID: "Gi1/0/10"},
+			opts:    []dhcpv4.Option{dhcpv4.OptGeneric(dhcpv4.AgentCircuitIDSubOption, []byte("Gi1/0/11"))},
+			giaddr:  relay.IP,
+			peer:    relay,
+			trusted: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/24")},
+		},
+		"no option 82": {
+			hw:      &tinkerbell.RelayAgentInfo{CircuitID: "Gi1/0/10"},
+			giaddr:  relay.IP,
+			peer:    relay,
+			trusted: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/24")},
+		},
+		"not relayed": {
+			hw:      &tinkerbell.RelayAgentInfo{CircuitID: "Gi1/0/10", RemoteID: "tor-1"},
+			opts:    circuitAndRemote,
+			peer:    &net.UDPAddr{IP: net.IP{10, 0, 0, 50}, Port: dhcpv4.ClientPort},
+			trusted: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/24")},
+		},
+		"untrusted relay": {
+			hw:      &tinkerbell.RelayAgentInfo{CircuitID: "Gi1/0/10", RemoteID: "tor-1"},
+			opts:    circuitAndRemote,
+			giaddr:  relay.IP,
+			peer:    relay,
+			trusted: []netip.Prefix{netip.MustParsePrefix("10.0.1.0/24")},
+		},
+		"no trusted relays": {
+			hw:     &tinkerbell.RelayAgentInfo{CircuitID: "Gi1/0/10", RemoteID: "tor-1"},
+			opts:   circuitAndRemote,
+			giaddr: relay.IP,
+			peer:   relay,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{
+				Backend:       &mockBackend{allowNetboot: true, relayAgentInfo: tt.hw},
+				IPAddr:        netip.MustParseAddr("127.0.0.1"),
+				TrustedRelays: tt.trusted,
+			}
+			mods := []dhcpv4.Modifier{dhcpv4.WithHwAddr(mac), dhcpv4.WithMessageType(dhcpv4.MessageTypeDiscover)}
+			if tt.giaddr != nil {
+				mods = append(mods, dhcpv4.WithGatewayIP(tt.giaddr))
+			}
+			if tt.opts != nil {
+				mods = append(mods, dhcpv4.WithOption(dhcpv4.OptRelayAgentInfo(tt.opts...)))
+			}
+			req, err := dhcpv4.New(mods...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, _, err := h.readBackendByRelayAgentInfo(context.Background(), tt.peer, req)
+			if !tt.wantIP.IsValid() {
+				if !hardwareNotFound(err) {
+					t.Fatalf("expected a not found error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.IPAddress != tt.wantIP {
+				t.Fatalf("got address %v, want %v", d.IPAddress, tt.wantIP)
+			}
+		})
+	}
+}
+
+func TestUpdateMsgEchoesRelayAgentInfo(t *testing.T) {
+	ri := dhcpv4.OptRelayAgentInfo(dhcpv4.OptGeneric(dhcpv4.AgentCircuitIDSubOption, []byte("Gi1/0/10")), dhcpv4.OptGeneric(dhcpv4.AgentRemoteIDSubOption, []byte("tor-1")))
+	tests := map[string]struct {
+		opts []dhcpv4.Option
+		want []byte
+	}{
+		"relayed with option 82": {opts: []dhcpv4.Option{ri}, want: ri.Value.ToBytes()},
+		"without option 82":      {},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{IPAddr: netip.MustParseAddr("127.0.0.1")}
+			mods := []dhcpv4.Modifier{dhcpv4.WithHwAddr(net.HardwareAddr{0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}), dhcpv4.WithGatewayIP(net.IP{10, 0, 0, 1})}
+			for _, o := range tt.opts {
+				mods = append(mods, dhcpv4.WithOption(o))
+			}
+			req, err := dhcpv4.New(mods...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reply := h.updateMsg(context.Background(), req, &dhcp.DHCP{IPAddress: netip.MustParseAddr("192.168.1.100")}, &dhcp.Netboot{}, dhcpv4.MessageTypeOffer)
+			if diff := cmp.Diff(tt.want, reply.Options.Get(dhcpv4.OptionRelayAgentInformation)); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestWithSubnetSettings(t *testing.T) {
+	alloc, err := pool.NewAllocator([]pool.Pool{
+		{Subnet: netip.MustParsePrefix("10.0.0.0/24"), Gateway: netip.MustParseAddr("10.0.0.1"), NameServers: []netip.Addr{netip.MustParseAddr("10.0.0.53")}},
+		{Subnet: netip.MustParsePrefix("10.0.1.0/24"), Gateway: netip.MustParseAddr("10.0.1.1")},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{IPAddr: netip.MustParseAddr("192.168.2.5"), Pools: alloc}
+	tests := map[string]struct {
+		giaddr net.IP
+		in     *dhcp.DHCP
+		want   *dhcp.DHCP
+	}{
+		"fills missing settings from the relay subnet": {
+			giaddr: net.IP{10, 0, 0, 1},
+			in:     &dhcp.DHCP{IPAddress: netip.MustParseAddr("10.0.0.20")},
+			want: &dhcp.DHCP{
+				IPAddress:      netip.MustParseAddr("10.0.0.20"),
+				SubnetMask:     net.IPv4Mask(255, 255, 255, 0),
+				DefaultGateway: netip.MustParseAddr("10.0.0.1"),
+				NameServers:    []net.IP{{10, 0, 0, 53}},
+			},
+		},
+		"hardware values are kept": {
+			giaddr: net.IP{10, 0, 0, 1},
+			in: &dhcp.DHCP{
+				IPAddress:      netip.MustParseAddr("10.0.0.20"),
+				SubnetMask:     net.IPv4Mask(255, 255, 0, 0),
+				DefaultGateway: netip.MustParseAddr("10.0.0.254"),
+				NameServers:    []net.IP{{1, 1, 1, 1}},
+			},
+			want: &dhcp.DHCP{
+				IPAddress:      netip.MustParseAddr("10.0.0.20"),
+				SubnetMask:     net.IPv4Mask(255, 255, 0, 0),
+				DefaultGateway: netip.MustParseAddr("10.0.0.254"),
+				NameServers:    []net.IP{{1, 1, 1, 1}},
+			},
+		},
+		"address not in the relay subnet": {
+			giaddr: net.IP{10, 0, 1, 1},
+			in:     &dhcp.DHCP{IPAddress: netip.MustParseAddr("10.0.0.20")},
+			want:   &dhcp.DHCP{IPAddress: netip.MustParseAddr("10.0.0.20")},
+		},
+		"unknown relay": {
+			giaddr: net.IP{172, 16, 0, 1},
+			in:     &dhcp.DHCP{IPAddress: netip.MustParseAddr("10.0.0.20")},
+			want:   &dhcp.DHCP{IPAddress: netip.MustParseAddr("10.0.0.20")},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			pkt, err := dhcpv4.New(dhcpv4.WithGatewayIP(tt.giaddr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			h.withSubnetSettings(pkt, tt.in)
+			if diff := cmp.Diff(tt.in, tt.want, cmp.Comparer(func(x, y netip.Addr) bool { return x == y })); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
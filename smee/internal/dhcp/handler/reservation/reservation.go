@@ -1,18 +1,22 @@
-// Package reservation is the handler for responding to DHCPv4 messages with only host reservations.
+// Package reservation is the handler for responding to DHCPv4 and DHCPv6 messages with only host reservations.
 package reservation
 
 import (
 	"context"
+	"net"
 	"net/netip"
 	"net/url"
 
 	"github.com/go-logr/logr"
 	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
 	"github.com/insomniacslk/dhcp/iana"
 	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
 	"github.com/tinkerbell/tinkerbell/pkg/constant"
 	"github.com/tinkerbell/tinkerbell/pkg/data"
 	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp/pool"
+	"github.com/tinkerbell/tinkerbell/smee/internal/lifecycle"
 )
 
 // BackendReader is the interface for getting data from a backend.
@@ -45,6 +49,24 @@ type Handler struct {
 
 	// SyslogAddr is the address to send syslog messages to. DHCP Option 7.
 	SyslogAddr netip.Addr
+
+	// Pools hands out dynamic addresses to clients that do not have a Hardware object.
+	// Clients with a dynamic address are always allowed to netboot so that they can boot into HookOS for discovery.
+	// When nil, clients without a Hardware object are ignored.
+	Pools *pool.Allocator
+
+	// Options are raw DHCP options sent to all clients. They are applied after all other options and
+	// are replaced by the options of a client's subnet pool and Hardware object.
+	Options []dhcpv4.Option
+
+	// NetbootStatus records the DHCP responses sent to clients in the status of their Hardware.
+	// When nil, nothing is recorded.
+	NetbootStatus *lifecycle.Recorder
+
+	// TrustedRelays are the relay agents whose relay agent information (option 82) is used to match Hardware.
+	// Option 82 is only used in relayed requests, ones with a giaddr, sent from an address in one of these prefixes.
+	// When empty, Hardware is never matched by option 82.
+	TrustedRelays []netip.Prefix
 }
 
 // Netboot holds the netboot configuration details used in running a DHCP server.
@@ -69,4 +91,64 @@ type Netboot struct {
 
 	// IPXEArchMapping will override the default architecture to binary mapping.
 	IPXEArchMapping map[iana.Arch]constant.IPXEBinary
+
+	// IPXEMenu is whether the iPXE boot menu is enabled.
+	// When true, clients whose Hardware doesn't allow netbooting are still sent netboot options so that they can load the menu.
+	IPXEMenu bool
+
+	// SecureBootShims maps UEFI architectures to a signed shim, a path in Smee's boot files directory.
+	// Clients of these architectures whose Hardware has Secure Boot enabled are sent the shim instead of an iPXE binary.
+	SecureBootShims map[iana.Arch]string
+}
+
+// Handler6 holds the configuration details for running the DHCPv6 server.
+type Handler6 struct {
+	// Backend is the backend to use for getting DHCP data.
+	Backend BackendReader
+
+	// ServerID is the DHCPv6 server identifier (option 2) used in all responses.
+	ServerID dhcpv6.DUID
+
+	// Log is used to log messages.
+	// `logr.Discard()` can be used if no logging is desired.
+	Log logr.Logger
+
+	// Netboot configuration
+	Netboot Netboot6
+
+	// NetbootStatus records the DHCPv6 responses sent to clients in the status of their Hardware.
+	// When nil, nothing is recorded.
+	NetbootStatus *lifecycle.Recorder
+}
+
+// Netboot6 holds the netboot configuration details used in running a DHCPv6 server.
+type Netboot6 struct {
+	// iPXE binary server IPv6:Port serving via TFTP.
+	IPXEBinServerTFTP netip.AddrPort
+
+	// IPXEBinServerHTTP is the URL to the IPXE binary server serving via HTTP(s).
+	IPXEBinServerHTTP *url.URL
+
+	// IPXEScriptURL is the URL to the IPXE script to use.
+	IPXEScriptURL func(net.HardwareAddr) *url.URL
+
+	// Enabled is whether to enable sending netboot DHCP options.
+	Enabled bool
+
+	// UserClass (for network booting) allows a custom DHCPv6 option 15 to be used to break out of an iPXE loop.
+	UserClass dhcp.UserClass
+
+	// InjectMacAddrFormat is the format to use when injecting the mac address into the iPXE binary URL.
+	InjectMacAddrFormat constant.MACFormat
+
+	// IPXEArchMapping will override the default architecture to binary mapping.
+	IPXEArchMapping map[iana.Arch]constant.IPXEBinary
+
+	// IPXEMenu is whether the iPXE boot menu is enabled.
+	// When true, clients whose Hardware doesn't allow netbooting are still sent netboot options so that they can load the menu.
+	IPXEMenu bool
+
+	// SecureBootShims maps UEFI architectures to a signed shim, a path in Smee's boot files directory.
+	// Clients of these architectures whose Hardware has Secure Boot enabled are sent the shim instead of an iPXE binary.
+	SecureBootShims map[iana.Arch]string
 }
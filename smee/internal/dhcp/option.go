@@ -0,0 +1,130 @@
+package dhcp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+// OptionType is how the value of a raw DHCP option is encoded.
+type OptionType string
+
+const (
+	// OptionTypeIP is a single IPv4 address.
+	OptionTypeIP OptionType = "ip"
+	// OptionTypeIPList is IPv4 addresses separated by commas or spaces.
+	OptionTypeIPList OptionType = "ip-list"
+	// OptionTypeString is the value as is.
+	OptionTypeString OptionType = "string"
+	// OptionTypeUint8 is an unsigned 8 bit integer.
+	OptionTypeUint8 OptionType = "uint8"
+	// OptionTypeUint16 is an unsigned 16 bit integer in network byte order.
+	OptionTypeUint16 OptionType = "uint16"
+	// OptionTypeUint32 is an unsigned 32 bit integer in network byte order.
+	OptionTypeUint32 OptionType = "uint32"
+	// OptionTypeBool is true or false, encoded as a single byte.
+	OptionTypeBool OptionType = "bool"
+	// OptionTypeHex is raw bytes written as hex, optionally separated by colons.
+	OptionTypeHex OptionType = "hex"
+)
+
+// ReservedOptionCodes are the option codes that Smee must set itself, as they define the DHCP exchange
+// and the lease: lease time (51), option overload (52), message type (53), server identifier (54),
+// renewal and rebinding times (58, 59), client identifier (61) and relay agent information (82).
+var ReservedOptionCodes = []int{51, 52, 53, 54, 58, 59, 61, 82}
+
+// OptionTypes are all supported option types.
+var OptionTypes = []OptionType{OptionTypeIP, OptionTypeIPList, OptionTypeString, OptionTypeUint8, OptionTypeUint16, OptionTypeUint32, OptionTypeBool, OptionTypeHex}
+
+// EncodeOption returns the DHCPv4 option code with value encoded as typ.
+// Pad (0), End (255) and the ReservedOptionCodes can't be set.
+func EncodeOption(code int, typ OptionType, value string) (dhcpv4.Option, error) {
+	if code < 1 || code > 254 {
+		return dhcpv4.Option{}, fmt.Errorf("invalid DHCP option code %d, must be between 1 and 254", code)
+	}
+	if slices.Contains(ReservedOptionCodes, code) {
+		return dhcpv4.Option{}, fmt.Errorf("DHCP option %d is set by Smee and can't be set as a raw option, reserved codes are %v", code, ReservedOptionCodes)
+	}
+	b, err := encodeOptionValue(typ, value)
+	if err != nil {
+		return dhcpv4.Option{}, fmt.Errorf("invalid value for DHCP option %d: %w", code, err)
+	}
+	if len(b) > 255 {
+		return dhcpv4.Option{}, fmt.Errorf("value for DHCP option %d is %d bytes, must be at most 255", code, len(b))
+	}
+
+	return dhcpv4.OptGeneric(dhcpv4.GenericOptionCode(code), b), nil
+}
+
+func encodeOptionValue(typ OptionType, value string) ([]byte, error) {
+	switch typ {
+	case OptionTypeIP:
+		a, err := netip.ParseAddr(strings.TrimSpace(value))
+		if err != nil || !a.Is4() {
+			return nil, fmt.Errorf("%q is not an IPv4 address", value)
+		}
+		return a.AsSlice(), nil
+	case OptionTypeIPList:
+		var b []byte
+		for _, s := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+			a, err := netip.ParseAddr(s)
+			if err != nil || !a.Is4() {
+				return nil, fmt.Errorf("%q is not an IPv4 address", s)
+			}
+			b = append(b, a.AsSlice()...)
+		}
+		if len(b) == 0 {
+			return nil, fmt.Errorf("no IPv4 addresses in %q", value)
+		}
+		return b, nil
+	case OptionTypeString:
+		return []byte(value), nil
+	case OptionTypeUint8:
+		v, err := strconv.ParseUint(strings.TrimSpace(value), 0, 8)
+		if err != nil {
+			return nil, err
+		}
+		return []byte{byte(v)}, nil
+	case OptionTypeUint16:
+		v, err := strconv.ParseUint(strings.TrimSpace(value), 0, 16)
+		if err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.AppendUint16(nil, uint16(v)), nil
+	case OptionTypeUint32:
+		v, err := strconv.ParseUint(strings.TrimSpace(value), 0, 32)
+		if err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.AppendUint32(nil, uint32(v)), nil
+	case OptionTypeBool:
+		v, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, err
+		}
+		if v {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case OptionTypeHex:
+		s := strings.TrimPrefix(strings.ReplaceAll(strings.TrimSpace(value), ":", ""), "0x")
+		return hex.DecodeString(s)
+	default:
+		return nil, fmt.Errorf("unknown type %q, must be one of %v", typ, OptionTypes)
+	}
+}
+
+// WithOptions returns a modifier that sets opts in order, replacing any option with the same code.
+func WithOptions(opts ...dhcpv4.Option) dhcpv4.Modifier {
+	return func(d *dhcpv4.DHCPv4) {
+		for _, o := range opts {
+			d.UpdateOption(o)
+		}
+	}
+}
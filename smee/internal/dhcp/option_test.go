@@ -0,0 +1,74 @@
+package dhcp
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+func TestEncodeOption(t *testing.T) {
+	tests := map[string]struct {
+		code    int
+		typ     OptionType
+		value   string
+		want    []byte
+		wantErr bool
+	}{
+		"ip":                 {code: 150, typ: OptionTypeIP, value: "192.168.1.1", want: []byte{192, 168, 1, 1}},
+		"ip not ipv4":        {code: 150, typ: OptionTypeIP, value: "2001:db8::1", wantErr: true},
+		"ip-list commas":     {code: 42, typ: OptionTypeIPList, value: "10.0.0.1,10.0.0.2", want: []byte{10, 0, 0, 1, 10, 0, 0, 2}},
+		"ip-list spaces":     {code: 42, typ: OptionTypeIPList, value: "10.0.0.1 10.0.0.2", want: []byte{10, 0, 0, 1, 10, 0, 0, 2}},
+		"ip-list empty":      {code: 42, typ: OptionTypeIPList, value: " ", wantErr: true},
+		"string":             {code: 114, typ: OptionTypeString, value: "http://onie/installer", want: []byte("http://onie/installer")},
+		"uint8":              {code: 23, typ: OptionTypeUint8, value: "64", want: []byte{64}},
+		"uint8 overflow":     {code: 23, typ: OptionTypeUint8, value: "256", wantErr: true},
+		"uint16":             {code: 26, typ: OptionTypeUint16, value: "9000", want: []byte{0x23, 0x28}},
+		"uint32":             {code: 2, typ: OptionTypeUint32, value: "86400", want: []byte{0, 1, 0x51, 0x80}},
+		"uint32 hex literal": {code: 2, typ: OptionTypeUint32, value: "0x10", want: []byte{0, 0, 0, 0x10}},
+		"bool":               {code: 19, typ: OptionTypeBool, value: "true", want: []byte{1}},
+		"hex":                {code: 43, typ: OptionTypeHex, value: "01:04:c0:a8:01:01", want: []byte{1, 4, 192, 168, 1, 1}},
+		"hex prefix":         {code: 43, typ: OptionTypeHex, value: "0x0104", want: []byte{1, 4}},
+		"hex invalid":        {code: 43, typ: OptionTypeHex, value: "zz", wantErr: true},
+		"unknown type":       {code: 43, typ: "bytes", value: "1", wantErr: true},
+		"pad":                {code: 0, typ: OptionTypeUint8, value: "1", wantErr: true},
+		"end":                {code: 255, typ: OptionTypeUint8, value: "1", wantErr: true},
+		"message type":       {code: 53, typ: OptionTypeUint8, value: "2", wantErr: true},
+		"server identifier":  {code: 54, typ: OptionTypeIP, value: "192.168.1.1", wantErr: true},
+		"lease time":         {code: 51, typ: OptionTypeUint32, value: "86400", wantErr: true},
+		"relay agent info":   {code: 82, typ: OptionTypeHex, value: "0104", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := EncodeOption(tt.code, tt.typ, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EncodeOption() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Code.Code() != uint8(tt.code) {
+				t.Fatalf("got code %d, want %d", got.Code.Code(), tt.code)
+			}
+			if diff := cmp.Diff(got.Value.ToBytes(), tt.want); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestWithOptions(t *testing.T) {
+	pkt, err := dhcpv4.New(dhcpv4.WithGeneric(dhcpv4.OptionInterfaceMTU, []byte{0x05, 0xdc}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mtu, _ := EncodeOption(26, OptionTypeUint16, "9000")
+	ttl, _ := EncodeOption(23, OptionTypeUint8, "64")
+	WithOptions(mtu, ttl)(pkt)
+	if diff := cmp.Diff(pkt.Options.Get(dhcpv4.OptionInterfaceMTU), []byte{0x23, 0x28}); diff != "" {
+		t.Fatal(diff)
+	}
+	if diff := cmp.Diff(pkt.Options.Get(dhcpv4.OptionDefaultIPTTL), []byte{64}); diff != "" {
+		t.Fatal(diff)
+	}
+}
@@ -0,0 +1,322 @@
+// Package pool hands out dynamic IPv4 addresses to machines that do not have a Hardware object.
+// Leases are kept in memory and written to a Store so that they survive restarts.
+package pool
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+// ErrExhausted is returned when a pool has no free addresses.
+var ErrExhausted = errors.New("no free addresses in pool")
+
+// ErrNoPool is returned when no pool serves the network a client is on.
+var ErrNoPool = errors.New("no pool found for client network")
+
+// DefaultLeaseTime is used when a pool does not define a lease time.
+const DefaultLeaseTime = time.Hour
+
+// DefaultOfferHold is how long an offered address is held for a client when OfferHold is not set.
+const DefaultOfferHold = 30 * time.Second
+
+// Pool is a set of address ranges in a single subnet, along with the network options sent to clients.
+// A pool without ranges only provides the network options of its subnet.
+type Pool struct {
+	// Subnet is the network the pool serves. All ranges and the gateway must be in it.
+	Subnet netip.Prefix
+	// Ranges are the inclusive address ranges handed out to clients.
+	Ranges []Range
+	// Gateway is the default gateway. DHCP option 3.
+	Gateway netip.Addr
+	// NameServers are the DNS servers. DHCP option 6.
+	NameServers []netip.Addr
+	// LeaseTime is how long a lease is valid for. DHCP option 51.
+	LeaseTime time.Duration
+	// Options are raw DHCP options sent to clients in the subnet.
+	// They replace the global options and are replaced by the options of a Hardware object.
+	Options []dhcpv4.Option
+}
+
+// Range is an inclusive range of addresses.
+type Range struct {
+	Start netip.Addr
+	End   netip.Addr
+}
+
+// Lease is an address handed out to a client.
+type Lease struct {
+	MAC     string     `json:"mac"`
+	IP      netip.Addr `json:"ip"`
+	Expires time.Time  `json:"expires"`
+}
+
+// Validate checks that the pool is usable.
+func (p Pool) Validate() error {
+	if !p.Subnet.IsValid() || !p.Subnet.Addr().Is4() {
+		return fmt.Errorf("invalid subnet %q, must be an IPv4 CIDR", p.Subnet)
+	}
+	for _, r := range p.Ranges {
+		if !p.Subnet.Contains(r.Start) || !p.Subnet.Contains(r.End) {
+			return fmt.Errorf("range %v-%v is not in subnet %v", r.Start, r.End, p.Subnet)
+		}
+		if r.End.Less(r.Start) {
+			return fmt.Errorf("range %v-%v ends before it starts", r.Start, r.End)
+		}
+	}
+	if p.Gateway.IsValid() && !p.Subnet.Contains(p.Gateway) {
+		return fmt.Errorf("gateway %v is not in subnet %v", p.Gateway, p.Subnet)
+	}
+
+	return nil
+}
+
+// Contains reports whether addr is in one of the pool's ranges.
+func (p Pool) Contains(addr netip.Addr) bool {
+	for _, r := range p.Ranges {
+		if !addr.Less(r.Start) && !r.End.Less(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Allocator hands out leases from a set of pools.
+// It is safe for concurrent use.
+type Allocator struct {
+	// Reserved reports whether an address is already used by a Hardware object.
+	// Reserved addresses are never handed out. When nil no addresses are considered reserved.
+	// Reserved is called without holding the Allocator's lock, so it can be slow.
+	Reserved func(context.Context, netip.Addr) bool
+	// OfferHold is how long an offered address is held for a client before it can be offered to another one.
+	// Offers are kept in memory only. Defaults to DefaultOfferHold.
+	OfferHold time.Duration
+
+	pools []Pool
+	store Store
+	now   func() time.Time
+
+	mu     sync.Mutex
+	leases map[string]Lease
+	// offers are the addresses held for clients that haven't requested them yet, by MAC address.
+	offers map[string]Lease
+}
+
+// NewAllocator validates the pools and loads existing leases from the store.
+// A nil store keeps leases in memory only.
+func NewAllocator(pools []Pool, store Store) (*Allocator, error) {
+	for _, p := range pools {
+		if err := p.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if store == nil {
+		store = &MemoryStore{}
+	}
+	leases, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load leases: %w", err)
+	}
+	a := &Allocator{pools: pools, store: store, now: time.Now, leases: make(map[string]Lease, len(leases)), offers: map[string]Lease{}}
+	for _, l := range leases {
+		a.leases[l.MAC] = l
+	}
+
+	return a, nil
+}
+
+// Select returns the pool that serves the client.
+// Relayed requests use the pool whose subnet contains giaddr, directly connected clients use the pool whose subnet
+// contains the server address. A single configured pool is used when neither match.
+func (a *Allocator) Select(giaddr, server netip.Addr) (*Pool, error) {
+	if giaddr.IsValid() && !giaddr.IsUnspecified() {
+		for i := range a.pools {
+			if a.pools[i].Subnet.Contains(giaddr) {
+				return &a.pools[i], nil
+			}
+		}
+		return nil, fmt.Errorf("%w: relay %v", ErrNoPool, giaddr)
+	}
+	for i := range a.pools {
+		if a.pools[i].Subnet.Contains(server) {
+			return &a.pools[i], nil
+		}
+	}
+	if len(a.pools) == 1 {
+		return &a.pools[0], nil
+	}
+
+	return nil, ErrNoPool
+}
+
+// Offer returns the lease that Allocate would commit for mac, without committing or persisting it.
+// The address is held for mac for OfferHold so that it isn't offered to other clients in the meantime.
+// Offers are used to answer DHCPDISCOVERs, Allocate is used to answer DHCPREQUESTs.
+func (a *Allocator) Offer(ctx context.Context, p *Pool, mac net.HardwareAddr, requested netip.Addr) (Lease, error) {
+	return a.allocate(ctx, p, mac, requested, func(l Lease, now time.Time) error {
+		for k, o := range a.offers {
+			if !now.Before(o.Expires) {
+				delete(a.offers, k)
+			}
+		}
+		a.offers[l.MAC] = Lease{MAC: l.MAC, IP: l.IP, Expires: now.Add(cmp.Or(a.OfferHold, DefaultOfferHold))}
+
+		return nil
+	})
+}
+
+// Allocate returns a lease in p for mac.
+// An existing lease for mac is renewed. Otherwise the address offered to mac, requested, or the first free address
+// in the pool is used, in that order.
+// The returned lease is persisted before it is returned.
+func (a *Allocator) Allocate(ctx context.Context, p *Pool, mac net.HardwareAddr, requested netip.Addr) (Lease, error) {
+	return a.allocate(ctx, p, mac, requested, func(l Lease, _ time.Time) error {
+		prev := maps.Clone(a.leases)
+		// Expired leases of other clients for the same address are replaced.
+		for k, o := range a.leases {
+			if o.IP == l.IP {
+				delete(a.leases, k)
+			}
+		}
+		a.leases[l.MAC] = l
+		if err := a.store.Save(a.list()); err != nil {
+			a.leases = prev
+			return fmt.Errorf("failed to save lease: %w", err)
+		}
+		delete(a.offers, l.MAC)
+
+		return nil
+	})
+}
+
+// allocate picks an address in p for mac and calls keep with the lease, holding a.mu.
+// Candidates are checked against Reserved without holding a.mu, then checked again for other clients' leases and
+// offers before keep is called, as those can change while Reserved runs.
+func (a *Allocator) allocate(ctx context.Context, p *Pool, mac net.HardwareAddr, requested netip.Addr, keep func(Lease, time.Time) error) (Lease, error) {
+	key := mac.String()
+	lt := p.LeaseTime
+	if lt <= 0 {
+		lt = DefaultLeaseTime
+	}
+	reserved := map[netip.Addr]bool{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return Lease{}, err
+		}
+		a.mu.Lock()
+		ip, ok := a.pick(p, key, requested, a.now(), reserved)
+		a.mu.Unlock()
+		if !ok {
+			return Lease{}, fmt.Errorf("%w: %v", ErrExhausted, p.Subnet)
+		}
+		if a.reserved(ctx, ip) {
+			reserved[ip] = true
+			continue
+		}
+
+		a.mu.Lock()
+		now := a.now()
+		if !a.free(ip, key, now) {
+			// Another client got the address while Reserved ran.
+			a.mu.Unlock()
+			continue
+		}
+		l := Lease{MAC: key, IP: ip, Expires: now.Add(lt)}
+		err := keep(l, now)
+		a.mu.Unlock()
+		if err != nil {
+			return Lease{}, err
+		}
+
+		return l, nil
+	}
+}
+
+// Release removes the lease for mac if it is for ip, and any address offered to mac.
+func (a *Allocator) Release(mac net.HardwareAddr, ip netip.Addr) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.offers, mac.String())
+	l, ok := a.leases[mac.String()]
+	if !ok || l.IP != ip {
+		return nil
+	}
+	delete(a.leases, l.MAC)
+
+	return a.store.Save(a.list())
+}
+
+// Leases returns a copy of all leases, including expired ones that have not been reused yet.
+func (a *Allocator) Leases() []Lease {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.list()
+}
+
+// list returns the leases sorted by IP. The caller must hold a.mu.
+func (a *Allocator) list() []Lease {
+	out := make([]Lease, 0, len(a.leases))
+	for _, l := range a.leases {
+		out = append(out, l)
+	}
+	slices.SortFunc(out, func(x, y Lease) int { return x.IP.Compare(y.IP) })
+
+	return out
+}
+
+// pick returns the address to check for mac, skipping the reserved ones. The caller must hold a.mu.
+func (a *Allocator) pick(p *Pool, mac string, requested netip.Addr, now time.Time, reserved map[netip.Addr]bool) (netip.Addr, bool) {
+	usable := func(ip netip.Addr) bool {
+		return ip.IsValid() && p.Contains(ip) && !reserved[ip] && a.free(ip, mac, now)
+	}
+	if l, ok := a.leases[mac]; ok && usable(l.IP) {
+		return l.IP, true
+	}
+	if o, ok := a.offers[mac]; ok && usable(o.IP) {
+		return o.IP, true
+	}
+	if usable(requested) {
+		return requested, true
+	}
+	for _, r := range p.Ranges {
+		for ip := r.Start; ip.IsValid() && !r.End.Less(ip); ip = ip.Next() {
+			if !reserved[ip] && a.free(ip, mac, now) {
+				return ip, true
+			}
+		}
+	}
+
+	return netip.Addr{}, false
+}
+
+// free reports whether ip isn't leased or offered to a client other than mac. The caller must hold a.mu.
+func (a *Allocator) free(ip netip.Addr, mac string, now time.Time) bool {
+	for _, l := range a.leases {
+		if l.IP == ip && l.MAC != mac && now.Before(l.Expires) {
+			return false
+		}
+	}
+	for _, o := range a.offers {
+		if o.IP == ip && o.MAC != mac && now.Before(o.Expires) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (a *Allocator) reserved(ctx context.Context, ip netip.Addr) bool {
+	return a.Reserved != nil && a.Reserved(ctx, ip)
+}
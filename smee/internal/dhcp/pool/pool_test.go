@@ -0,0 +1,266 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func testPool() Pool {
+	return Pool{
+		Subnet:    netip.MustParsePrefix("192.168.2.0/24"),
+		Ranges:    []Range{{Start: netip.MustParseAddr("192.168.2.100"), End: netip.MustParseAddr("192.168.2.102")}},
+		Gateway:   netip.MustParseAddr("192.168.2.1"),
+		LeaseTime: time.Minute,
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		pool    func(p *Pool)
+		wantErr bool
+	}{
+		"valid":            {pool: func(*Pool) {}},
+		"ipv6 subnet":      {pool: func(p *Pool) { p.Subnet = netip.MustParsePrefix("2001:db8::/64") }, wantErr: true},
+		"no ranges":        {pool: func(p *Pool) { p.Ranges = nil }},
+		"range outside":    {pool: func(p *Pool) { p.Ranges[0].End = netip.MustParseAddr("192.168.3.1") }, wantErr: true},
+		"range backwards":  {pool: func(p *Pool) { p.Ranges[0].End = netip.MustParseAddr("192.168.2.99") }, wantErr: true},
+		"gateway outside":  {pool: func(p *Pool) { p.Gateway = netip.MustParseAddr("10.0.0.1") }, wantErr: true},
+		"no gateway is ok": {pool: func(p *Pool) { p.Gateway = netip.Addr{} }},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := testPool()
+			tt.pool(&p)
+			if err := p.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	other := Pool{
+		Subnet: netip.MustParsePrefix("10.0.0.0/24"),
+		Ranges: []Range{{Start: netip.MustParseAddr("10.0.0.10"), End: netip.MustParseAddr("10.0.0.20")}},
+	}
+	a, err := NewAllocator([]Pool{testPool(), other}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		giaddr  netip.Addr
+		server  netip.Addr
+		want    netip.Prefix
+		wantErr error
+	}{
+		"relayed":         {giaddr: netip.MustParseAddr("10.0.0.1"), server: netip.MustParseAddr("192.168.2.5"), want: other.Subnet},
+		"direct":          {giaddr: netip.IPv4Unspecified(), server: netip.MustParseAddr("192.168.2.5"), want: testPool().Subnet},
+		"unknown relay":   {giaddr: netip.MustParseAddr("172.16.0.1"), wantErr: ErrNoPool},
+		"unknown network": {server: netip.MustParseAddr("172.16.0.5"), wantErr: ErrNoPool},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := a.Select(tt.giaddr, tt.server)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Select() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && p.Subnet != tt.want {
+				t.Fatalf("Select() = %v, want %v", p.Subnet, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	ctx := context.Background()
+	mac1 := net.HardwareAddr{0, 0, 0, 0, 0, 1}
+	mac2 := net.HardwareAddr{0, 0, 0, 0, 0, 2}
+	mac3 := net.HardwareAddr{0, 0, 0, 0, 0, 3}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	a, err := NewAllocator([]Pool{testPool()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.now = func() time.Time { return now }
+	// .100 belongs to a Hardware object.
+	a.Reserved = func(_ context.Context, ip netip.Addr) bool { return ip == netip.MustParseAddr("192.168.2.100") }
+	p := &a.pools[0]
+
+	l1, err := a.Allocate(ctx, p, mac1, netip.Addr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := netip.MustParseAddr("192.168.2.101"); l1.IP != want {
+		t.Fatalf("got %v, want %v", l1.IP, want)
+	}
+	if want := now.Add(time.Minute); !l1.Expires.Equal(want) {
+		t.Fatalf("got expiry %v, want %v", l1.Expires, want)
+	}
+	// mac2 asks for mac1's address and gets the next free one.
+	l2, err := a.Allocate(ctx, p, mac2, l1.IP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := netip.MustParseAddr("192.168.2.102"); l2.IP != want {
+		t.Fatalf("got %v, want %v", l2.IP, want)
+	}
+	// mac1 renews and keeps its address, even when asking for another one.
+	if l, err := a.Allocate(ctx, p, mac1, l2.IP); err != nil || l.IP != l1.IP {
+		t.Fatalf("renew got %v, %v, want %v", l.IP, err, l1.IP)
+	}
+	// The pool is full.
+	if _, err := a.Allocate(ctx, p, mac3, netip.Addr{}); !errors.Is(err, ErrExhausted) {
+		t.Fatalf("got error %v, want %v", err, ErrExhausted)
+	}
+	// Expired leases are reused.
+	now = now.Add(2 * time.Minute)
+	l3, err := a.Allocate(ctx, p, mac3, netip.Addr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := netip.MustParseAddr("192.168.2.101"); l3.IP != want {
+		t.Fatalf("got %v, want %v", l3.IP, want)
+	}
+	// mac1's expired lease was replaced, so it gets the next free address.
+	if l, err := a.Allocate(ctx, p, mac1, netip.Addr{}); err != nil || l.IP != l2.IP {
+		t.Fatalf("got %v, %v, want %v", l.IP, err, l2.IP)
+	}
+	// Released addresses are free again.
+	if err := a.Release(mac3, l3.IP); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Leases()) != 1 {
+		t.Fatalf("expected 1 lease after release, got %d", len(a.Leases()))
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "leases", "leases.json")
+	mac := net.HardwareAddr{0, 0, 0, 0, 0, 1}
+
+	a, err := NewAllocator([]Pool{testPool()}, &FileStore{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := a.Allocate(ctx, &a.pools[0], mac, netip.MustParseAddr("192.168.2.102"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A new allocator, as after a restart, has the lease.
+	b, err := NewAllocator([]Pool{testPool()}, &FileStore{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]Lease{l}, b.Leases(), cmp.Comparer(func(x, y netip.Addr) bool { return x == y })); diff != "" {
+		t.Fatal(diff)
+	}
+	got, err := b.Allocate(ctx, &b.pools[0], mac, netip.Addr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.IP != l.IP {
+		t.Fatalf("got %v after restart, want %v", got.IP, l.IP)
+	}
+}
+
+// countingStore counts the times leases are saved.
+type countingStore struct {
+	MemoryStore
+	saves int
+}
+
+func (c *countingStore) Save(l []Lease) error {
+	c.saves++
+	return c.MemoryStore.Save(l)
+}
+
+func TestOffer(t *testing.T) {
+	ctx := context.Background()
+	mac1 := net.HardwareAddr{0, 0, 0, 0, 0, 1}
+	mac2 := net.HardwareAddr{0, 0, 0, 0, 0, 2}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store := &countingStore{}
+	a, err := NewAllocator([]Pool{testPool()}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.now = func() time.Time { return now }
+	a.OfferHold = 10 * time.Second
+	p := &a.pools[0]
+
+	o1, err := a.Offer(ctx, p, mac1, netip.Addr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := netip.MustParseAddr("192.168.2.100"); o1.IP != want {
+		t.Fatalf("got %v, want %v", o1.IP, want)
+	}
+	if want := now.Add(time.Minute); !o1.Expires.Equal(want) {
+		t.Fatalf("got expiry %v, want the lease time %v", o1.Expires, want)
+	}
+	if store.saves != 0 || len(a.Leases()) != 0 {
+		t.Fatalf("offer was committed: %d saves, leases %v", store.saves, a.Leases())
+	}
+	// The offered address is held for mac1, even when mac2 asks for it.
+	o2, err := a.Offer(ctx, p, mac2, o1.IP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := netip.MustParseAddr("192.168.2.101"); o2.IP != want {
+		t.Fatalf("got %v, want %v", o2.IP, want)
+	}
+	// mac1 requests without naming an address and gets the one offered to it.
+	l1, err := a.Allocate(ctx, p, mac1, netip.Addr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l1.IP != o1.IP || store.saves != 1 {
+		t.Fatalf("got %v with %d saves, want %v with 1 save", l1.IP, store.saves, o1.IP)
+	}
+	// After the hold, mac2's offered address can be given to another client.
+	now = now.Add(11 * time.Second)
+	l3, err := a.Allocate(ctx, p, net.HardwareAddr{0, 0, 0, 0, 0, 3}, o2.IP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l3.IP != o2.IP {
+		t.Fatalf("got %v, want the expired offer's address %v", l3.IP, o2.IP)
+	}
+}
+
+func TestAllocateReservedWithoutLock(t *testing.T) {
+	ctx := context.Background()
+	a, err := NewAllocator([]Pool{testPool()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var checked []netip.Addr
+	a.Reserved = func(_ context.Context, ip netip.Addr) bool {
+		// Leases takes the lock, so this deadlocks if Reserved is called with the lock held.
+		_ = a.Leases()
+		checked = append(checked, ip)
+		return ip != netip.MustParseAddr("192.168.2.102")
+	}
+	l, err := a.Allocate(ctx, &a.pools[0], net.HardwareAddr{0, 0, 0, 0, 0, 1}, netip.MustParseAddr("192.168.2.101"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := netip.MustParseAddr("192.168.2.102"); l.IP != want {
+		t.Fatalf("got %v, want %v", l.IP, want)
+	}
+	want := []netip.Addr{netip.MustParseAddr("192.168.2.101"), netip.MustParseAddr("192.168.2.100"), netip.MustParseAddr("192.168.2.102")}
+	if diff := cmp.Diff(want, checked, cmp.Comparer(func(x, y netip.Addr) bool { return x == y })); diff != "" {
+		t.Fatal(diff)
+	}
+}
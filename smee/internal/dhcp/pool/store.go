@@ -0,0 +1,105 @@
+package pool
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// Store persists leases.
+type Store interface {
+	// Load returns all stored leases.
+	Load() ([]Lease, error)
+	// Save replaces all stored leases.
+	Save([]Lease) error
+}
+
+// MemoryStore keeps leases in memory. Leases are lost on restart.
+type MemoryStore struct {
+	mu     sync.Mutex
+	leases []Lease
+}
+
+// Load returns all stored leases.
+func (m *MemoryStore) Load() ([]Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return slices.Clone(m.leases), nil
+}
+
+// Save replaces all stored leases.
+func (m *MemoryStore) Save(l []Lease) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.leases = slices.Clone(l)
+
+	return nil
+}
+
+// ReadOnlyStore loads the leases of Store and discards saves, so that leases handed out aren't persisted.
+type ReadOnlyStore struct {
+	Store
+}
+
+// Save discards the leases.
+func (ReadOnlyStore) Save([]Lease) error {
+	return nil
+}
+
+// FileStore keeps leases in a JSON file.
+// The file is replaced atomically on every save so that a crash never leaves a partial file behind.
+type FileStore struct {
+	Path string
+}
+
+// Load returns all leases in the file. A missing file has no leases.
+func (f *FileStore) Load() ([]Lease, error) {
+	b, err := os.ReadFile(f.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var leases []Lease
+	if err := json.Unmarshal(b, &leases); err != nil {
+		return nil, err
+	}
+
+	return leases, nil
+}
+
+// Save writes all leases to the file.
+func (f *FileStore) Save(l []Lease) error {
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(f.Path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(f.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.Path)
+}
@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"github.com/tinkerbell/tinkerbell/pkg/data"
+	"github.com/tinkerbell/tinkerbell/smee/internal/metric"
+)
+
+// DefaultMaxNotFound is the default number of lookups that found no Hardware that are remembered.
+const DefaultMaxNotFound = 4096
+
+// Backend is the interface for getting Hardware from a backend.
+type Backend interface {
+	FilterHardware(ctx context.Context, opts data.HardwareFilter) (*tinkerbell.Hardware, error)
+}
+
+// NotFoundCache is a Backend that remembers which Hardware lookups found nothing, so that the messages of machines
+// without Hardware, that a DHCP server doesn't answer, don't each cause a lookup in the backend.
+// A Hardware object created while a lookup for it is remembered is found once TTL has passed.
+type NotFoundCache struct {
+	Backend Backend
+	// TTL is how long a lookup that found nothing is remembered. Zero disables the cache.
+	TTL time.Duration
+	// MaxEntries is the number of lookups remembered. Defaults to DefaultMaxNotFound.
+	MaxEntries int
+
+	mu      sync.Mutex
+	entries map[string]notFound
+	// now is used in tests.
+	now func() time.Time
+}
+
+// notFound is a remembered lookup.
+type notFound struct {
+	err     error
+	expires time.Time
+}
+
+// FilterHardware implements Backend. A lookup that found nothing less than TTL ago returns the same error without a backend lookup.
+func (c *NotFoundCache) FilterHardware(ctx context.Context, opts data.HardwareFilter) (*tinkerbell.Hardware, error) {
+	// Label selectors are only used outside of DHCP and aren't part of the key.
+	if c.TTL <= 0 || len(opts.ByLabels) > 0 {
+		return c.Backend.FilterHardware(ctx, opts)
+	}
+	key := fmt.Sprintf("%+v", opts)
+	now := c.time()
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		metric.DHCPHardwareNotFoundCacheHits.Inc()
+		return nil, e.err
+	}
+
+	hw, err := c.Backend.FilterHardware(ctx, opts)
+	var nf interface{ NotFound() bool }
+	if err == nil || !errors.As(err, &nf) || !nf.NotFound() {
+		return hw, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store(key, notFound{err: err, expires: now.Add(c.TTL)}, now)
+
+	return hw, err
+}
+
+// store remembers a lookup. It isn't remembered when MaxEntries lookups that haven't expired are already remembered.
+func (c *NotFoundCache) store(key string, e notFound, now time.Time) {
+	if c.entries == nil {
+		c.entries = make(map[string]notFound)
+	}
+	limit := c.MaxEntries
+	if limit <= 0 {
+		limit = DefaultMaxNotFound
+	}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= limit {
+		for k, v := range c.entries {
+			if !now.Before(v.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= limit {
+			return
+		}
+	}
+	c.entries[key] = e
+}
+
+func (c *NotFoundCache) time() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"github.com/tinkerbell/tinkerbell/pkg/data"
+)
+
+type notFoundError struct{}
+
+func (notFoundError) NotFound() bool { return true }
+
+func (notFoundError) Error() string { return "hardware not found" }
+
+type fakeBackend struct {
+	calls int
+	hw    *tinkerbell.Hardware
+	err   error
+}
+
+func (f *fakeBackend) FilterHardware(context.Context, data.HardwareFilter) (*tinkerbell.Hardware, error) {
+	f.calls++
+	return f.hw, f.err
+}
+
+func TestNotFoundCache(t *testing.T) {
+	initMetrics()
+	now, advance := fixedClock()
+	b := &fakeBackend{err: notFoundError{}}
+	c := &NotFoundCache{Backend: b, TTL: 10 * time.Second, now: now}
+	opts := data.HardwareFilter{ByMACAddress: "52:54:00:12:34:01"}
+
+	for range 3 {
+		if _, err := c.FilterHardware(context.Background(), opts); !errors.Is(err, notFoundError{}) {
+			t.Fatalf("FilterHardware() error = %v, want not found", err)
+		}
+	}
+	if b.calls != 1 {
+		t.Fatalf("got %d backend lookups, want 1", b.calls)
+	}
+
+	// Other lookups aren't answered from the cache.
+	_, _ = c.FilterHardware(context.Background(), data.HardwareFilter{ByMACAddress: "52:54:00:12:34:02"})
+	if b.calls != 2 {
+		t.Fatalf("got %d backend lookups, want 2", b.calls)
+	}
+
+	// The Hardware is found once the lookup expired.
+	advance(10 * time.Second)
+	b.hw, b.err = &tinkerbell.Hardware{}, nil
+	if hw, err := c.FilterHardware(context.Background(), opts); err != nil || hw == nil {
+		t.Fatalf("FilterHardware() = %v, %v, want the Hardware", hw, err)
+	}
+}
+
+func TestNotFoundCacheOtherErrors(t *testing.T) {
+	b := &fakeBackend{err: errors.New("connection refused")}
+	c := &NotFoundCache{Backend: b, TTL: 10 * time.Second}
+	for range 2 {
+		_, _ = c.FilterHardware(context.Background(), data.HardwareFilter{ByMACAddress: "52:54:00:12:34:01"})
+	}
+	if b.calls != 2 {
+		t.Fatalf("got %d backend lookups, want 2, errors other than not found must not be cached", b.calls)
+	}
+}
+
+func TestNotFoundCacheMaxEntries(t *testing.T) {
+	initMetrics()
+	now, advance := fixedClock()
+	b := &fakeBackend{err: notFoundError{}}
+	c := &NotFoundCache{Backend: b, TTL: 10 * time.Second, MaxEntries: 1, now: now}
+
+	_, _ = c.FilterHardware(context.Background(), data.HardwareFilter{ByMACAddress: "52:54:00:12:34:01"})
+	_, _ = c.FilterHardware(context.Background(), data.HardwareFilter{ByMACAddress: "52:54:00:12:34:02"})
+	if len(c.entries) != 1 {
+		t.Fatalf("got %d cached lookups, want 1", len(c.entries))
+	}
+
+	// Expired lookups make room for new ones.
+	advance(10 * time.Second)
+	_, _ = c.FilterHardware(context.Background(), data.HardwareFilter{ByMACAddress: "52:54:00:12:34:02"})
+	_, _ = c.FilterHardware(context.Background(), data.HardwareFilter{ByMACAddress: "52:54:00:12:34:02"})
+	if b.calls != 3 {
+		t.Fatalf("got %d backend lookups, want 3", b.calls)
+	}
+}
@@ -0,0 +1,158 @@
+// Package ratelimit protects the DHCP server and its backend from floods of DHCP messages, like those of a misbehaving NIC
+// or of a rack of machines that power on at once.
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp/server"
+	"github.com/tinkerbell/tinkerbell/smee/internal/metric"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// ReasonClient is the reason of a message dropped because its client sent too many messages.
+	ReasonClient = "client"
+	// ReasonGlobal is the reason of a message dropped because all clients together sent too many messages.
+	ReasonGlobal = "global"
+
+	// DefaultMaxClients is the default number of clients whose rate is tracked.
+	DefaultMaxClients = 4096
+)
+
+// Limiter limits the rate of DHCP messages answered, per client and for all clients together.
+// Each limit is a token bucket that refills at the rate and holds at most its burst.
+type Limiter struct {
+	// PerClient is the number of messages per second answered for a single client. Zero disables the per-client limit.
+	PerClient rate.Limit
+	// PerClientBurst is the number of messages of a single client answered at once.
+	PerClientBurst int
+	// Global is the number of messages per second answered for all clients together. Zero disables the global limit.
+	Global rate.Limit
+	// GlobalBurst is the number of messages answered at once.
+	GlobalBurst int
+	// MaxClients is the number of clients whose rate is tracked. Clients that aren't tracked are only limited by the global limit.
+	// Defaults to DefaultMaxClients.
+	MaxClients int
+
+	mu      sync.Mutex
+	global  *rate.Limiter
+	clients map[string]*client
+	// now is used in tests.
+	now func() time.Time
+}
+
+// client is the rate limit of a single client.
+type client struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+// Allow reports whether a message from the client with the MAC address is answered and, when it isn't, why.
+func (l *Limiter) Allow(mac net.HardwareAddr) (bool, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.now != nil {
+		now = l.now()
+	}
+	if l.PerClient > 0 {
+		if c := l.client(mac.String(), now); c != nil && !c.limiter.AllowN(now, 1) {
+			return false, ReasonClient
+		}
+	}
+	if l.Global > 0 {
+		if l.global == nil {
+			l.global = rate.NewLimiter(l.Global, max(l.GlobalBurst, 1))
+		}
+		if !l.global.AllowN(now, 1) {
+			return false, ReasonGlobal
+		}
+	}
+
+	return true, ""
+}
+
+// client returns the rate limit of a client, creating it when needed.
+// Returns nil when MaxClients clients are already tracked.
+func (l *Limiter) client(key string, now time.Time) *client {
+	if c, ok := l.clients[key]; ok {
+		c.seen = now
+		return c
+	}
+	if l.clients == nil {
+		l.clients = make(map[string]*client)
+	}
+	limit := l.MaxClients
+	if limit <= 0 {
+		limit = DefaultMaxClients
+	}
+	if len(l.clients) >= limit {
+		l.prune(now)
+		if len(l.clients) >= limit {
+			return nil
+		}
+	}
+	c := &client{limiter: rate.NewLimiter(l.PerClient, max(l.PerClientBurst, 1)), seen: now}
+	l.clients[key] = c
+
+	return c
+}
+
+// prune forgets the clients whose bucket has refilled since their last message, as a new bucket is the same.
+func (l *Limiter) prune(now time.Time) {
+	refill := time.Duration(float64(max(l.PerClientBurst, 1)) / float64(l.PerClient) * float64(time.Second))
+	for key, c := range l.clients {
+		if now.Sub(c.seen) >= refill {
+			delete(l.clients, key)
+		}
+	}
+}
+
+// Handler answers the DHCPv4 messages allowed by Limiter with Next and drops the others.
+type Handler struct {
+	Log     logr.Logger
+	Limiter *Limiter
+	Next    server.Handler
+}
+
+// Handle implements server.Handler.
+func (h *Handler) Handle(ctx context.Context, conn *ipv4.PacketConn, p dhcp.Packet) {
+	if p.Pkt != nil {
+		if ok, reason := h.Limiter.Allow(p.Pkt.ClientHWAddr); !ok {
+			metric.DHCPRateLimited.With(prometheus.Labels{"reason": reason}).Inc()
+			h.Log.V(1).Info("dropping DHCP packet, rate limit exceeded", "mac", p.Pkt.ClientHWAddr.String(), "xid", p.Pkt.TransactionID.String(), "limit", reason)
+			return
+		}
+	}
+	h.Next.Handle(ctx, conn, p)
+}
+
+// Handler6 answers the DHCPv6 messages allowed by Limiter with Next and drops the others.
+type Handler6 struct {
+	Log     logr.Logger
+	Limiter *Limiter
+	Next    server.Handler6
+}
+
+// Handle implements server.Handler6.
+func (h *Handler6) Handle(ctx context.Context, conn *ipv6.PacketConn, p dhcp.Packet6) {
+	// Messages without a MAC address are left to Next, which ignores them.
+	if mac, err := p.MAC(); err == nil && p.Pkt != nil {
+		if ok, reason := h.Limiter.Allow(mac); !ok {
+			metric.DHCPRateLimited.With(prometheus.Labels{"reason": reason}).Inc()
+			h.Log.V(1).Info("dropping DHCPv6 packet, rate limit exceeded", "mac", mac.String(), "xid", p.Pkt.TransactionID.String(), "limit", reason)
+			return
+		}
+	}
+	h.Next.Handle(ctx, conn, p)
+}
@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
+	"github.com/tinkerbell/tinkerbell/smee/internal/metric"
+	"golang.org/x/net/ipv4"
+)
+
+var metricsOnce sync.Once
+
+func initMetrics() {
+	metricsOnce.Do(metric.Init)
+}
+
+// fixedClock returns a clock that is moved with its function.
+func fixedClock() (func() time.Time, func(time.Duration)) {
+	t := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	return func() time.Time { return t }, func(d time.Duration) { t = t.Add(d) }
+}
+
+func mac(i int) net.HardwareAddr {
+	return net.HardwareAddr{0x52, 0x54, 0x00, 0x12, byte(i >> 8), byte(i)}
+}
+
+func TestAllowPerClient(t *testing.T) {
+	now, advance := fixedClock()
+	l := &Limiter{PerClient: 1, PerClientBurst: 2, now: now}
+
+	for i := range 2 {
+		if ok, _ := l.Allow(mac(1)); !ok {
+			t.Fatalf("message %d within the burst was dropped", i)
+		}
+	}
+	if ok, reason := l.Allow(mac(1)); ok || reason != ReasonClient {
+		t.Fatalf("Allow() = %v, %q, want false, %q", ok, reason, ReasonClient)
+	}
+	// Other clients have their own limit.
+	if ok, _ := l.Allow(mac(2)); !ok {
+		t.Fatal("message of another client was dropped")
+	}
+	advance(time.Second)
+	if ok, _ := l.Allow(mac(1)); !ok {
+		t.Fatal("message was dropped after the bucket refilled")
+	}
+}
+
+func TestAllowGlobal(t *testing.T) {
+	now, advance := fixedClock()
+	l := &Limiter{Global: 10, GlobalBurst: 3, now: now}
+
+	for i := range 3 {
+		if ok, _ := l.Allow(mac(i)); !ok {
+			t.Fatalf("message %d within the burst was dropped", i)
+		}
+	}
+	if ok, reason := l.Allow(mac(3)); ok || reason != ReasonGlobal {
+		t.Fatalf("Allow() = %v, %q, want false, %q", ok, reason, ReasonGlobal)
+	}
+	advance(100 * time.Millisecond)
+	if ok, _ := l.Allow(mac(3)); !ok {
+		t.Fatal("message was dropped after the bucket refilled")
+	}
+}
+
+func TestAllowMaxClients(t *testing.T) {
+	now, advance := fixedClock()
+	l := &Limiter{PerClient: 1, PerClientBurst: 1, MaxClients: 2, now: now}
+
+	l.Allow(mac(1))
+	l.Allow(mac(2))
+	// A client that isn't tracked is only limited by the global limit, which is disabled.
+	for range 3 {
+		if ok, _ := l.Allow(mac(3)); !ok {
+			t.Fatal("message of an untracked client was dropped")
+		}
+	}
+	if len(l.clients) != 2 {
+		t.Fatalf("got %d tracked clients, want 2", len(l.clients))
+	}
+
+	// Clients whose bucket refilled are forgotten to make room.
+	advance(time.Second)
+	l.Allow(mac(3))
+	if ok, _ := l.Allow(mac(3)); ok {
+		t.Fatal("message of a tracked client over its limit was allowed")
+	}
+	if len(l.clients) != 1 {
+		t.Fatalf("got %d tracked clients, want 1", len(l.clients))
+	}
+}
+
+type handler struct{ calls int }
+
+func (h *handler) Handle(context.Context, *ipv4.PacketConn, dhcp.Packet) { h.calls++ }
+
+func TestHandler(t *testing.T) {
+	initMetrics()
+	now, _ := fixedClock()
+	pkt, err := dhcpv4.NewDiscovery(mac(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := &handler{}
+	h := &Handler{Log: logr.Discard(), Limiter: &Limiter{PerClient: 1, PerClientBurst: 2, now: now}, Next: next}
+	for range 5 {
+		h.Handle(context.Background(), nil, dhcp.Packet{Pkt: pkt})
+	}
+	if next.calls != 2 {
+		t.Fatalf("Next called %d times, want 2", next.calls)
+	}
+}
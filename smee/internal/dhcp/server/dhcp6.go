@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"net"
+
+	"github.com/go-logr/logr"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/dhcpv6/server6"
+	dp "github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
+	"golang.org/x/net/ipv6"
+)
+
+// Handler6 is a type that defines the handler function to be called every time a valid DHCPv6 message is received.
+type Handler6 interface {
+	Handle(ctx context.Context, conn *ipv6.PacketConn, d dp.Packet6)
+}
+
+// DHCPv6 represents a DHCPv6 server object.
+type DHCPv6 struct {
+	Conn     net.PacketConn
+	Handlers []Handler6
+	Logger   logr.Logger
+}
+
+// Serve serves requests.
+func (s *DHCPv6) Serve(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		_ = s.Close()
+	}()
+	s.Logger.V(1).Info("Server listening on", "addr", s.Conn.LocalAddr())
+
+	nConn := ipv6.NewPacketConn(s.Conn)
+	if err := nConn.SetControlMessage(ipv6.FlagInterface, true); err != nil {
+		s.Logger.Info("error setting control message", "err", err)
+		return err
+	}
+
+	defer func() {
+		_ = nConn.Close()
+	}()
+	for {
+		// DHCPv6 messages, including relay encapsulation, fit well within 4096 bytes.
+		rbuf := make([]byte, 4096)
+		n, cm, peer, err := nConn.ReadFrom(rbuf)
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+			}
+			s.Logger.Info("error reading from packet conn", "err", err)
+			return err
+		}
+
+		d, err := dhcpv6.FromBytes(rbuf[:n])
+		if err != nil {
+			s.Logger.Info("error parsing DHCPv6 request", "err", err)
+			continue
+		}
+		p := dp.Packet6{Peer: peer}
+		if relay, ok := d.(*dhcpv6.RelayMessage); ok {
+			p.Relay = relay
+		}
+		if p.Pkt, err = d.GetInnerMessage(); err != nil {
+			s.Logger.Info("error decapsulating DHCPv6 relay message", "err", err)
+			continue
+		}
+
+		md := &dp.Metadata{}
+		if cm != nil {
+			md.IfIndex = cm.IfIndex
+			if n, err := net.InterfaceByIndex(cm.IfIndex); err == nil {
+				md.IfName = n.Name
+			}
+		}
+		p.Md = md
+
+		for _, handler := range s.Handlers {
+			go handler.Handle(ctx, nConn, p)
+		}
+	}
+}
+
+// Close sends a termination request to the server, and closes the UDP listener.
+func (s *DHCPv6) Close() error {
+	return s.Conn.Close()
+}
+
+// NewServer6 initializes and returns a new DHCPv6 Server object.
+// When addr is the unspecified address on the DHCPv6 server port, the All_DHCP_Relay_Agents_and_Servers
+// and All_DHCP_Servers multicast groups are joined on ifname, or on the default multicast interface if ifname is empty.
+func NewServer6(ifname string, addr *net.UDPAddr, handler ...Handler6) (*DHCPv6, error) {
+	s := &DHCPv6{
+		Handlers: handler,
+		Logger:   logr.Discard(),
+	}
+
+	var iface *net.Interface
+	if ifname != "" {
+		var err error
+		if iface, err = net.InterfaceByName(ifname); err != nil {
+			return nil, err
+		}
+	}
+	conn, err := server6.NewIPv6UDPConn(ifname, addr)
+	if err != nil {
+		return nil, err
+	}
+	p := ipv6.NewPacketConn(conn)
+	groups := []net.IP{addr.IP}
+	if addr.IP == nil || addr.IP.IsUnspecified() {
+		groups = []net.IP{dhcpv6.AllDHCPRelayAgentsAndServers, dhcpv6.AllDHCPServers}
+	}
+	for _, g := range groups {
+		if !g.IsMulticast() {
+			continue
+		}
+		if err := p.JoinGroup(iface, &net.UDPAddr{IP: g, Port: addr.Port}); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	s.Conn = conn
+
+	return s, nil
+}
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io/fs"
 	"net"
 	"net/http"
+	"net/netip"
 	"path"
 	"path/filepath"
 	"regexp"
@@ -13,7 +15,9 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/tinkerbell/tinkerbell/smee/internal/bootfile"
 	binary "github.com/tinkerbell/tinkerbell/smee/internal/ipxe/binary/file"
+	"github.com/tinkerbell/tinkerbell/smee/internal/lifecycle"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -24,10 +28,16 @@ import (
 type Handler struct {
 	Log   logr.Logger
 	Patch []byte
+	// Prefix is removed from request paths before looking up files in BootFiles.
+	Prefix string
+	// BootFiles, when set, serves the requests for files that aren't embedded iPXE binaries.
+	BootFiles *bootfile.Tree
+	// NetbootStatus records the files served to machines in the status of their Hardware.
+	NetbootStatus *lifecycle.Recorder
 }
 
 // Handle handles GET and HEAD responses to HTTP requests.
-// Serves embedded iPXE binaries.
+// Serves embedded iPXE binaries and, when configured, user supplied boot files.
 func (h Handler) Handle(w http.ResponseWriter, req *http.Request) {
 	h.Log.V(1).Info("handling request", "method", req.Method, "path", req.URL.Path)
 	if req.Method != http.MethodGet && req.Method != http.MethodHead {
@@ -67,6 +77,19 @@ func (h Handler) Handle(w http.ResponseWriter, req *http.Request) {
 	defer span.End()
 
 	file, found := binary.Files[filename]
+	if !found && h.BootFiles != nil {
+		name := path.Join(path.Dir(strings.TrimPrefix(req.URL.Path, h.Prefix)), filename)
+		fi, err := h.BootFiles.Serve(w, req, name)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return
+		}
+		if req.Method == http.MethodGet {
+			recordBootFile(h.NetbootStatus, fi, optionalMac, lifecycle.AddrFromRemote(req.RemoteAddr), name, lifecycle.ProtocolHTTP)
+		}
+		span.SetStatus(codes.Ok, name)
+		return
+	}
 	if !found {
 		log.Info("requested file not found")
 		http.NotFound(w, req)
@@ -87,12 +110,22 @@ func (h Handler) Handle(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodGet:
 		log.Info("file served", "name", filename, "fileSize", len(file))
+		h.NetbootStatus.Binary(optionalMac, lifecycle.AddrFromRemote(req.RemoteAddr), filename, lifecycle.ProtocolHTTP)
 	case http.MethodHead:
 		log.Info("HEAD method requested", "fileSize", len(file))
 	}
 	span.SetStatus(codes.Ok, filename)
 }
 
+// recordBootFile records a boot file served to a machine. Generated files, like the GRUB configuration, are recorded as scripts.
+func recordBootFile(r *lifecycle.Recorder, fi fs.FileInfo, mac net.HardwareAddr, client netip.Addr, name, protocol string) {
+	if bootfile.Generated(fi) {
+		r.Script(mac, client, name, protocol)
+		return
+	}
+	r.Binary(mac, client, name, protocol)
+}
+
 // extractTraceparentFromFilename takes a context and filename and checks the filename for
 // a traceparent tacked onto the end of it. If there is a match, the traceparent is extracted
 // and a new SpanContext is constructed and added to the context.Context that is returned.
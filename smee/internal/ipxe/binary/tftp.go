@@ -14,7 +14,9 @@ import (
 
 	"github.com/go-logr/logr"
 	"github.com/pin/tftp/v3"
+	"github.com/tinkerbell/tinkerbell/smee/internal/bootfile"
 	binary "github.com/tinkerbell/tinkerbell/smee/internal/ipxe/binary/file"
+	"github.com/tinkerbell/tinkerbell/smee/internal/lifecycle"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -29,6 +31,10 @@ type TFTP struct {
 	Timeout              time.Duration
 	Patch                []byte
 	BlockSize            int
+	// BootFiles, when set, serves the requests for files that aren't embedded iPXE binaries.
+	BootFiles *bootfile.Tree
+	// NetbootStatus records the files served to machines in the status of their Hardware.
+	NetbootStatus *lifecycle.Recorder
 }
 
 // ListenAndServe will listen and serve iPXE binaries over TFTP.
@@ -101,6 +107,9 @@ func (h TFTP) HandleRead(filename string, rf io.ReaderFrom) error {
 	defer span.End()
 
 	content, ok := binary.Files[filepath.Base(shortfile)]
+	if !ok && h.BootFiles != nil {
+		return h.serveBootFile(ctx, path.Join(path.Dir(full), shortfile), optionalMac, client, rf, log, span)
+	}
 	if !ok {
 		err := fmt.Errorf("file [%v] unknown: %w", filepath.Base(shortfile), os.ErrNotExist)
 		log.Error(err, "file unknown")
@@ -125,10 +134,49 @@ func (h TFTP) HandleRead(filename string, rf io.ReaderFrom) error {
 	}
 	log.Info("file served", "bytesSent", b, "contentSize", len(content))
 	span.SetStatus(codes.Ok, filename)
+	h.NetbootStatus.Binary(optionalMac, clientAddr(client), filename, lifecycle.ProtocolTFTP)
 
 	return nil
 }
 
+// serveBootFile serves a file from the boot file tree.
+func (h TFTP) serveBootFile(ctx context.Context, name string, mac net.HardwareAddr, client net.UDPAddr, rf io.ReaderFrom, log logr.Logger, span trace.Span) error {
+	f, err := h.BootFiles.Open(ctx, name, clientAddr(client))
+	if err != nil {
+		log.Error(err, "boot file unknown")
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	// Let clients that ask for the transfer size (tsize) know it.
+	if ot, ok := rf.(tftp.OutgoingTransfer); ok {
+		ot.SetSize(fi.Size())
+	}
+	b, err := rf.ReadFrom(f)
+	if err != nil {
+		log.Error(err, "boot file serve failed", "b", b, "contentSize", fi.Size())
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	log.Info("boot file served", "name", name, "bytesSent", b, "contentSize", fi.Size())
+	span.SetStatus(codes.Ok, name)
+	recordBootFile(h.NetbootStatus, fi, mac, clientAddr(client), name, lifecycle.ProtocolTFTP)
+
+	return nil
+}
+
+// clientAddr returns the IP address of a TFTP client.
+func clientAddr(client net.UDPAddr) netip.Addr {
+	ip, _ := netip.AddrFromSlice(client.IP)
+
+	return ip.Unmap()
+}
+
 // HandleWrite handles TFTP PUT requests. It will always return an error. This library does not support PUT.
 func (h TFTP) HandleWrite(filename string, wt io.WriterTo) error {
 	err := fmt.Errorf("access_violation: %w", os.ErrPermission)
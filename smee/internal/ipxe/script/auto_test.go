@@ -22,6 +22,7 @@ func TestGenerateTemplate(t *testing.T) {
 				SyslogHost:        "1.2.3.4",
 				DownloadURL:       "http://location:8080/to/kernel/and/initrd",
 				Facility:          "onprem",
+				Consoles:          []string{"tty0", "ttyS1,115200"},
 				ExtraKernelParams: []string{"tink_worker_image=quay.io/tinkerbell/tink-worker:v0.8.0", "tinkerbell=packet"},
 				HWAddr:            "3c:ec:ef:4c:4f:54",
 				Retries:           10,
@@ -83,6 +84,7 @@ exit
 				SyslogHost:        "1.2.3.4",
 				DownloadURL:       "http://location:8080/to/kernel/and/initrd",
 				Facility:          "onprem",
+				Consoles:          []string{"tty0", "ttyS1,115200"},
 				ExtraKernelParams: []string{"tink_worker_image=quay.io/tinkerbell/tink-worker:v0.8.0", "tinkerbell=packet"},
 				HWAddr:            "3c:ec:ef:4c:4f:54",
 				VLANID:            "16",
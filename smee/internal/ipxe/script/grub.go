@@ -0,0 +1,132 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GRUBScript is the GRUB configuration that loads Hook on machines with UEFI Secure Boot enforced.
+// It boots the kernel and initrd of HookScript with the same kernel parameters.
+// Under Secure Boot the kernel must be signed by a key that the shim trusts.
+var GRUBScript = `set timeout=0
+{{- if .TraceID }}
+echo "Debug TraceID: {{ .TraceID }}"
+{{- end }}
+
+echo "Loading the Tinkerbell Hook kernel..."
+linux {{ .KernelPath }} {{- if ne .VLANID "" }} vlan_id={{ .VLANID }} {{- end }} facility={{ .Facility }} syslog_host={{ .SyslogHost }} grpc_authority={{ .TinkGRPCAuthority }} tinkerbell_tls={{ .TinkerbellTLS }} tinkerbell_insecure_tls={{ .TinkerbellInsecureTLS }} worker_id={{ .WorkerID }} hw_addr={{ .HWAddr }} modules=loop,squashfs,sd-mod,usb-storage intel_iommu=on iommu=pt {{- range .Consoles }} console={{ . }} {{- end }} {{- range .ExtraKernelParams}} {{.}} {{- end}}
+echo "Loading the Tinkerbell Hook initrd..."
+initrd {{ .InitrdPath }}
+boot
+
+echo "Failed to boot Hook"
+sleep 5
+exit
+`
+
+// grubExitScript is served to machines that aren't allowed to netboot. GRUB returns to the firmware, which tries the next boot option.
+const grubExitScript = `echo "The hardware data for this machine, or lack there of, does not allow it to netboot"
+exit
+`
+
+// grubMACPrefix prefixes the dash separated MAC address in the per machine configuration files that GRUB requests.
+const grubMACPrefix = "grub.cfg-01-"
+
+// GRUB holds the values used to generate the GRUB configuration that loads the Hook OS.
+type GRUB struct {
+	Hook
+	KernelPath string // example (http,192.168.2.50:7171)/osie/vmlinuz-x86_64
+	InitrdPath string // example (http,192.168.2.50:7171)/osie/initramfs-x86_64
+}
+
+// GRUBConfig generates the GRUB configuration of a machine. It's used to generate the boot files that GRUB requests.
+// name is grub.cfg, or grub.cfg-01-<mac> with a dash separated MAC address, optionally in a directory.
+// The machine is found by the MAC address in name, by mac or by the client IP address, in that order.
+// It returns an error wrapping fs.ErrNotExist for other files and unknown machines, so that GRUB tries the next file.
+func (h *Handler) GRUBConfig(ctx context.Context, name string, mac net.HardwareAddr, client netip.Addr) ([]byte, error) {
+	switch base := path.Base(name); {
+	case base == "grub.cfg":
+	case strings.HasPrefix(base, grubMACPrefix):
+		m, err := net.ParseMAC(strings.ReplaceAll(strings.TrimPrefix(base, grubMACPrefix), "-", ":"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", fs.ErrNotExist, err)
+		}
+		mac = m
+	default:
+		return nil, fs.ErrNotExist
+	}
+
+	hw, err := info{}, errors.New("no MAC or IP address")
+	if mac != nil {
+		hw, err = getByMac(ctx, mac, h.Backend)
+	}
+	// Hardware matched by relay agent information (option 82) has a different MAC address, it's found by its IP address instead.
+	if err != nil && client.IsValid() {
+		if byIP, ipErr := getByIP(ctx, client.Unmap().AsSlice(), h.Backend); ipErr == nil {
+			hw, err = byIP, nil
+		}
+	}
+	if err != nil {
+		h.Logger.Info("unable to find the hardware data for the GRUB configuration", "name", name, "mac", mac.String(), "client", client, "error", err)
+		return nil, fmt.Errorf("%w: %w", fs.ErrNotExist, err)
+	}
+	if !hw.AllowNetboot {
+		h.Logger.Info("the hardware data for this machine does not allow it to netboot", "mac", hw.MACAddress.String())
+		return []byte(grubExitScript), nil
+	}
+
+	span := trace.SpanFromContext(ctx)
+	cfg, err := h.grubConfig(span, hw)
+	if err != nil {
+		h.Logger.Error(err, "error with GRUB configuration", "mac", hw.MACAddress.String())
+		return nil, err
+	}
+	span.SetAttributes(attribute.String("grub-config", cfg))
+
+	return []byte(cfg), nil
+}
+
+// grubConfig renders GRUBScript with the values of the Hook script.
+func (h *Handler) grubConfig(span trace.Span, hw info) (string, error) {
+	g := GRUB{Hook: h.hookData(span, hw)}
+	kernel, initrd := g.KernelName, g.InitrdName
+	if kernel == "" {
+		kernel = "vmlinuz-" + g.Arch
+	}
+	if initrd == "" {
+		initrd = "initramfs-" + g.Arch
+	}
+	var err error
+	if g.KernelPath, err = grubPath(g.DownloadURL, kernel); err != nil {
+		return "", err
+	}
+	if g.InitrdPath, err = grubPath(g.DownloadURL, initrd); err != nil {
+		return "", err
+	}
+
+	return GenerateTemplate(g, GRUBScript)
+}
+
+// grubPath converts the URL of a file, in the directory base, to a GRUB path, for example (http,192.168.2.50:7171)/osie/vmlinuz-x86_64.
+// GRUB can only download files over HTTP.
+func grubPath(base, file string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid OSIE URL %q: %w", base, err)
+	}
+	if u.Scheme != "http" || u.Host == "" {
+		return "", fmt.Errorf("OSIE URL %q must be an http URL for GRUB", base)
+	}
+
+	return fmt.Sprintf("(http,%s)%s", u.Host, path.Join("/", u.Path, file)), nil
+}
@@ -0,0 +1,90 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+)
+
+func TestGRUBConfig(t *testing.T) {
+	want := `set timeout=0
+
+echo "Loading the Tinkerbell Hook kernel..."
+linux (http,127.1.1.1:7171)/osie/vmlinuz-x86_64 vlan_id=10 facility=onprem syslog_host=127.1.1.2 grpc_authority=127.1.1.3:42113 tinkerbell_tls=false tinkerbell_insecure_tls=false worker_id=00:01:02:03:04:05 hw_addr=00:01:02:03:04:05 modules=loop,squashfs,sd-mod,usb-storage intel_iommu=on iommu=pt console=tty0 console=ttyS1,115200 k=v
+echo "Loading the Tinkerbell Hook initrd..."
+initrd (http,127.1.1.1:7171)/osie/initramfs-x86_64
+boot
+
+echo "Failed to boot Hook"
+sleep 5
+exit
+`
+	allow, deny := true, false
+	hardware := func(allowPXE *bool) *tinkerbell.Hardware {
+		return &tinkerbell.Hardware{Spec: tinkerbell.HardwareSpec{
+			Metadata: &tinkerbell.HardwareMetadata{Facility: &tinkerbell.MetadataFacility{FacilityCode: "onprem"}},
+			Interfaces: []tinkerbell.Interface{{
+				DHCP:    &tinkerbell.DHCP{MAC: "00:01:02:03:04:05", VLANID: "10", IP: &tinkerbell.IP{Address: "192.168.2.5", Netmask: "255.255.255.0"}},
+				Netboot: &tinkerbell.Netboot{AllowPXE: allowPXE, SecureBoot: &allow},
+			}},
+		}}
+	}
+	tests := map[string]struct {
+		name     string
+		mac      net.HardwareAddr
+		client   netip.Addr
+		hw       *tinkerbell.Hardware
+		osieURL  string
+		want     string
+		wantErr  bool
+		notExist bool
+	}{
+		"mac in file name": {name: "secureboot/grub.cfg-01-00-01-02-03-04-05", hw: hardware(&allow), want: want},
+		"mac in path":      {name: "grub.cfg", mac: net.HardwareAddr{0, 1, 2, 3, 4, 5}, hw: hardware(&allow), want: want},
+		"client address":   {name: "secureboot/grub.cfg", client: netip.MustParseAddr("192.168.2.5"), hw: hardware(&allow), want: want},
+		"not allowed":      {name: "grub.cfg", mac: net.HardwareAddr{0, 1, 2, 3, 4, 5}, hw: hardware(&deny), want: grubExitScript},
+		"unknown machine":  {name: "grub.cfg", client: netip.MustParseAddr("192.168.2.6"), wantErr: true, notExist: true},
+		"no address":       {name: "grub.cfg", hw: hardware(&allow), wantErr: true, notExist: true},
+		"invalid mac":      {name: "grub.cfg-01-00-01", hw: hardware(&allow), wantErr: true, notExist: true},
+		"other file":       {name: "grub.cfg-C0A80205", hw: hardware(&allow), wantErr: true, notExist: true},
+		"https osie url":   {name: "grub.cfg", mac: net.HardwareAddr{0, 1, 2, 3, 4, 5}, hw: hardware(&allow), osieURL: "https://127.1.1.1/osie", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{
+				Logger:             logr.Discard(),
+				Backend:            &mockBackend{hw: tt.hw},
+				OSIEURL:            "http://127.1.1.1:7171/osie",
+				ExtraKernelParams:  []string{"k=v"},
+				PublicSyslogFQDN:   "127.1.1.2",
+				TinkServerGRPCAddr: "127.1.1.3:42113",
+			}
+			if tt.osieURL != "" {
+				h.OSIEURL = tt.osieURL
+			}
+			got, err := h.GRUBConfig(context.Background(), tt.name, tt.mac, tt.client)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("GRUBConfig() expected an error, got:\n%s", got)
+				}
+				if tt.notExist && !errors.Is(err, fs.ErrNotExist) {
+					t.Fatalf("GRUBConfig() error = %v, want %v", err, fs.ErrNotExist)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
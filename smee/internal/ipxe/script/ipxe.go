@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"path"
 
@@ -14,12 +15,17 @@ import (
 	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
 	"github.com/tinkerbell/tinkerbell/pkg/data"
 	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
+	"github.com/tinkerbell/tinkerbell/smee/internal/lifecycle"
 	"github.com/tinkerbell/tinkerbell/smee/internal/metric"
+	"github.com/tinkerbell/tinkerbell/smee/internal/osie"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// defaultConsoles are the consoles in the Hook script when none are set for the Hardware.
+var defaultConsoles = []string{"tty0", "ttyS1,115200"}
+
 // BackendReader is the interface for getting data from a backend.
 type BackendReader interface {
 	FilterHardware(ctx context.Context, opts data.HardwareFilter) (*tinkerbell.Hardware, error)
@@ -39,11 +45,18 @@ type Handler struct {
 	StaticIPXEEnabled     bool
 	KernelName            string // name of the kernel file
 	InitrdName            string // name of the initrd file
+	// Scripts lists IPXEScript templates. When nil, the built-in Hook script is always used.
+	Scripts IPXEScriptLister
+	// Menu configures the iPXE boot menu.
+	Menu MenuConfig
+	// OSIEProfiles are OSIE settings for Hardware that matches them by facility or labels.
+	OSIEProfiles []osie.Profile
+	// NetbootStatus records the scripts served to machines in the status of their Hardware.
+	NetbootStatus *lifecycle.Recorder
 }
 
 type info struct {
 	AllowNetboot  bool // If true, the client will be provided netboot options in the DHCP offer/ack.
-	Console       string
 	MACAddress    net.HardwareAddr
 	Arch          string
 	VLANID        string
@@ -52,6 +65,9 @@ type info struct {
 	IPXEScript    string
 	IPXEScriptURL *url.URL
 	OSIE          OSIE
+	TemplateRef   string               // Name of the IPXEScript to serve, in the namespace of Hardware.
+	Hardware      *tinkerbell.Hardware // The full Hardware object, available to IPXEScript templates.
+	Menu          []dhcp.IPXEMenuEntry // Custom entries for the iPXE boot menu.
 }
 
 // OSIE or OS Installation Environment is the data about where the OSIE parts are located.
@@ -62,6 +78,12 @@ type OSIE struct {
 	Kernel string
 	// Initrd is the name of the initrd file.
 	Initrd string
+	// Version is the HookOS version, a directory under the OSIE URL.
+	Version string
+	// KernelParams are extra kernel parameters.
+	KernelParams []string
+	// Consoles replace the default consoles, for example ttyS0,115200.
+	Consoles []string
 }
 
 // getByMac uses the BackendReader to get the (hardware) data and then
@@ -90,7 +112,6 @@ func getByMac(ctx context.Context, mac net.HardwareAddr, br BackendReader) (info
 
 	return info{
 		AllowNetboot:  n.AllowNetboot,
-		Console:       "",
 		MACAddress:    d.MACAddress,
 		Arch:          d.Arch,
 		VLANID:        d.VLANID,
@@ -99,6 +120,9 @@ func getByMac(ctx context.Context, mac net.HardwareAddr, br BackendReader) (info
 		IPXEScript:    n.IPXEScript,
 		IPXEScriptURL: n.IPXEScriptURL,
 		OSIE:          OSIE(n.OSIE),
+		TemplateRef:   n.IPXETemplateRef,
+		Hardware:      spec,
+		Menu:          n.IPXEMenu,
 	}, nil
 }
 
@@ -125,7 +149,6 @@ func getByIP(ctx context.Context, ip net.IP, br BackendReader) (info, error) {
 
 	return info{
 		AllowNetboot:  n.AllowNetboot,
-		Console:       "",
 		MACAddress:    d.MACAddress,
 		Arch:          d.Arch,
 		VLANID:        d.VLANID,
@@ -134,14 +157,18 @@ func getByIP(ctx context.Context, ip net.IP, br BackendReader) (info, error) {
 		IPXEScript:    n.IPXEScript,
 		IPXEScriptURL: n.IPXEScriptURL,
 		OSIE:          OSIE(n.OSIE),
+		TemplateRef:   n.IPXETemplateRef,
+		Hardware:      spec,
+		Menu:          n.IPXEMenu,
 	}, nil
 }
 
 // HandlerFunc returns a http.HandlerFunc that serves the ipxe script.
 // It is expected that the request path is /<mac address>/auto.ipxe.
+// When the boot menu is enabled, auto.ipxe is the menu and /<mac address>/hook.ipxe is the script booted from the menu.
 func (h *Handler) HandlerFunc() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if path.Base(r.URL.Path) != "auto.ipxe" {
+		if name := path.Base(r.URL.Path); name != "auto.ipxe" && (name != hookScriptName || !h.Menu.Enabled) {
 			h.Logger.Info("URL path not supported", "path", r.URL.Path)
 			w.WriteHeader(http.StatusNotFound)
 
@@ -163,22 +190,33 @@ func (h *Handler) HandlerFunc() http.HandlerFunc {
 		// 2. the network.interfaces[].netboot.allow_pxe value, in the tink server hardware record, equal to true
 		// This allows serving custom ipxe scripts, starting up into OSIE or other installation environments
 		// without a tink workflow present.
+		// When the boot menu is enabled, machines that aren't allowed to pxe are served the menu, which defaults to booting from disk,
+		// so that an operator on the console can still pick a boot target.
 
 		// Try to get the MAC address from the URL path, if not available get the source IP address.
 		if ha, err := getMAC(r.URL.Path); err == nil {
 			hw, err := getByMac(ctx, ha, h.Backend)
+			if err != nil {
+				// Hardware matched by relay agent information (option 82) has a different MAC than the one
+				// in the URL path, it is found by the address it was given instead.
+				if ip, ipErr := getIP(r.RemoteAddr); ipErr == nil {
+					if byIP, ipErr := getByIP(ctx, ip, h.Backend); ipErr == nil {
+						hw, err = byIP, nil
+					}
+				}
+			}
 			if err != nil && h.StaticIPXEEnabled {
 				h.Logger.Info("serving static ipxe script", "mac", ha.String(), "reasonForStaticScript", err)
 				h.serveStaticIPXEScript(w)
 				return
 			}
-			if err != nil || !hw.AllowNetboot {
+			if err != nil || !h.scriptAllowed(hw, path.Base(r.URL.Path)) {
 				w.WriteHeader(http.StatusNotFound)
 				h.Logger.Info("the hardware data for this machine, or lack there of, does not allow it to pxe", "client", ha, "error", err)
 
 				return
 			}
-			h.serveBootScript(ctx, w, path.Base(r.URL.Path), hw)
+			h.serveBootScript(ctx, w, path.Base(r.URL.Path), hw, lifecycle.AddrFromRemote(r.RemoteAddr))
 			return
 		}
 		if ip, err := getIP(r.RemoteAddr); err == nil {
@@ -188,13 +226,13 @@ func (h *Handler) HandlerFunc() http.HandlerFunc {
 				h.serveStaticIPXEScript(w)
 				return
 			}
-			if err != nil || !hw.AllowNetboot {
+			if err != nil || !h.scriptAllowed(hw, path.Base(r.URL.Path)) {
 				w.WriteHeader(http.StatusNotFound)
 				h.Logger.Info("the hardware data for this machine, or lack there of, does not allow it to pxe", "client", r.RemoteAddr, "error", err)
 
 				return
 			}
-			h.serveBootScript(ctx, w, path.Base(r.URL.Path), hw)
+			h.serveBootScript(ctx, w, path.Base(r.URL.Path), hw, lifecycle.AddrFromRemote(r.RemoteAddr))
 			return
 		}
 
@@ -204,6 +242,12 @@ func (h *Handler) HandlerFunc() http.HandlerFunc {
 	}
 }
 
+// scriptAllowed reports whether the script name can be served to hw.
+// Hardware that doesn't allow netbooting is only served the boot menu, which only offers to boot from the local disk.
+func (h *Handler) scriptAllowed(hw info, name string) bool {
+	return hw.AllowNetboot || (h.Menu.Enabled && name == "auto.ipxe")
+}
+
 func (h *Handler) serveStaticIPXEScript(w http.ResponseWriter) {
 	// Serve static iPXE script.
 	auto := Hook{
@@ -250,17 +294,33 @@ func getMAC(urlPath string) (net.HardwareAddr, error) {
 	return ha, nil
 }
 
-func (h *Handler) serveBootScript(ctx context.Context, w http.ResponseWriter, name string, hw info) {
+func (h *Handler) serveBootScript(ctx context.Context, w http.ResponseWriter, name string, hw info, client netip.Addr) {
 	span := trace.SpanFromContext(ctx)
 	span.SetAttributes(attribute.String("smee.script_name", name))
 	var script []byte
+	switch {
+	case name == "auto.ipxe" && h.Menu.Enabled:
+		name = "menu.ipxe"
+	case name == hookScriptName:
+		name = "auto.ipxe"
+	}
 	// check if the custom script should be used
-	if hw.IPXEScriptURL != nil || hw.IPXEScript != "" {
+	if name == "auto.ipxe" && (hw.IPXEScriptURL != nil || hw.IPXEScript != "") {
 		name = "custom.ipxe"
 	}
 	switch name {
 	case "auto.ipxe":
-		s, err := h.defaultScript(span, hw)
+		s, err := h.templateScript(ctx, span, hw)
+		if s == "" && err == nil {
+			s, err = h.defaultScript(span, hw)
+		}
+		if errors.Is(err, errScriptNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			h.Logger.Info("ipxe script referenced by the hardware not found", "script", name, "error", err.Error())
+			span.SetStatus(codes.Error, err.Error())
+
+			return
+		}
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			h.Logger.Error(err, "error with default ipxe script", "script", name)
@@ -279,6 +339,16 @@ func (h *Handler) serveBootScript(ctx context.Context, w http.ResponseWriter, na
 			return
 		}
 		script = []byte(cs)
+	case "menu.ipxe":
+		ms, err := h.menuScript(hw)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			h.Logger.Error(err, "error with ipxe menu script", "script", name)
+			span.SetStatus(codes.Error, err.Error())
+
+			return
+		}
+		script = []byte(ms)
 	default:
 		w.WriteHeader(http.StatusNotFound)
 		err := fmt.Errorf("boot script %q not found", name)
@@ -295,9 +365,15 @@ func (h *Handler) serveBootScript(ctx context.Context, w http.ResponseWriter, na
 
 		return
 	}
+	h.NetbootStatus.Script(hw.MACAddress, client, name, lifecycle.ProtocolHTTP)
 }
 
 func (h *Handler) defaultScript(span trace.Span, hw info) (string, error) {
+	return GenerateTemplate(h.hookData(span, hw), HookScript)
+}
+
+// hookData returns the values used to render the Hook script and IPXEScript templates.
+func (h *Handler) hookData(span trace.Span, hw info) Hook {
 	mac := hw.MACAddress
 	arch := hw.Arch
 	if arch == "" {
@@ -311,7 +387,6 @@ func (h *Handler) defaultScript(span trace.Span, hw info) (string, error) {
 
 	auto := Hook{
 		Arch:                  arch,
-		Console:               "",
 		DownloadURL:           h.OSIEURL,
 		ExtraKernelParams:     h.ExtraKernelParams,
 		Facility:              hw.Facility,
@@ -325,6 +400,13 @@ func (h *Handler) defaultScript(span trace.Span, hw info) (string, error) {
 		Retries:               h.IPXEScriptRetries,
 		RetryDelay:            h.IPXEScriptRetryDelay,
 	}
+	settings := h.osieSettings(hw)
+	auto.Consoles = settings.Consoles
+	if len(auto.Consoles) == 0 {
+		auto.Consoles = defaultConsoles
+	}
+	auto.ExtraKernelParams = append(auto.ExtraKernelParams[:len(auto.ExtraKernelParams):len(auto.ExtraKernelParams)], settings.KernelParams...)
+	auto.DownloadURL = settings.VersionURL(auto.DownloadURL)
 	if h.KernelName != "" {
 		auto.KernelName = h.KernelName + "-" + arch
 	}
@@ -344,7 +426,21 @@ func (h *Handler) defaultScript(span trace.Span, hw info) (string, error) {
 		auto.TraceID = span.SpanContext().TraceID().String()
 	}
 
-	return GenerateTemplate(auto, HookScript)
+	return auto
+}
+
+// osieSettings returns the OSIE settings of the Hardware, from the matching OSIE profiles and the Hardware itself.
+func (h *Handler) osieSettings(hw info) osie.Settings {
+	var lbls map[string]string
+	if hw.Hardware != nil {
+		lbls = hw.Hardware.Labels
+	}
+
+	return osie.Resolve(h.OSIEProfiles, hw.Facility, lbls, osie.Settings{
+		Version:      hw.OSIE.Version,
+		KernelParams: hw.OSIE.KernelParams,
+		Consoles:     hw.OSIE.Consoles,
+	})
 }
 
 // customScript returns the custom script or chain URL if defined in the hardware data otherwise an error.
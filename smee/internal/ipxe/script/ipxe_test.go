@@ -6,13 +6,25 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
 	"github.com/tinkerbell/tinkerbell/smee/internal/metric"
+	"github.com/tinkerbell/tinkerbell/smee/internal/osie"
 	"go.opentelemetry.io/otel/trace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
+var metricsOnce sync.Once
+
+// initMetrics initializes the metrics once, as they can only be registered once per test binary.
+func initMetrics() {
+	metricsOnce.Do(metric.Init)
+}
+
 func TestCustomScript(t *testing.T) {
 	tests := map[string]struct {
 		ipxeURL    string
@@ -262,6 +274,56 @@ func TestDefaultScriptCustomKernelInitrd(t *testing.T) {
 	}
 }
 
+func TestDefaultScriptOSIESettings(t *testing.T) {
+	lab, err := labels.Parse("rack=a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Handler{
+		OSIEURL:           "http://127.1.1.1",
+		ExtraKernelParams: []string{"global=1"},
+		OSIEProfiles: []osie.Profile{
+			{Facility: "lab", Settings: osie.Settings{Version: "v0.10.0", KernelParams: []string{"facility=1"}, Consoles: []string{"ttyS0,115200"}}},
+			{Selector: lab, Settings: osie.Settings{KernelParams: []string{"rack=1"}}},
+		},
+	}
+	tests := map[string]struct {
+		d    info
+		want []string
+	}{
+		"defaults": {
+			d:    info{Facility: "onprem"},
+			want: []string{"set download-url http://127.1.1.1\n", "initrd=${initrd} console=tty0 console=ttyS1,115200 global=1 &&"},
+		},
+		"profiles and hardware": {
+			d: info{
+				Facility: "lab",
+				Hardware: &tinkerbell.Hardware{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"rack": "a"}}},
+				OSIE:     OSIE{KernelParams: []string{"hw=1"}, Consoles: []string{"tty1"}},
+			},
+			want: []string{"set download-url http://127.1.1.1/v0.10.0\n", "initrd=${initrd} console=tty1 global=1 facility=1 rack=1 hw=1 &&"},
+		},
+		"hardware base URL": {
+			d:    info{Facility: "lab", OSIE: OSIE{BaseURL: &url.URL{Scheme: "http", Host: "10.0.0.1"}}},
+			want: []string{"set download-url http://10.0.0.1\n", "console=ttyS0,115200 global=1 facility=1 &&"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tt.d.MACAddress = net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+			got, err := h.defaultScript(trace.SpanFromContext(context.Background()), tt.d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("expected %q in script, got:\n%s", w, got)
+				}
+			}
+		})
+	}
+}
+
 func TestStaticScript(t *testing.T) {
 	want := `#!ipxe
 
@@ -324,7 +386,7 @@ echo Failed to boot
 imgfree
 exit
 `
-	metric.Init()
+	initMetrics()
 	h := &Handler{
 		OSIEURL:            "http://127.0.0.1",
 		ExtraKernelParams:  []string{"k=v", "k2=v2"},
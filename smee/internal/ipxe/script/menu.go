@@ -0,0 +1,175 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// MenuScript is the template for the iPXE boot menu.
+// Entries are run from labels prefixed with "entry-" so that they don't collide with the labels of the menu itself.
+var MenuScript = `#!ipxe
+
+:menu
+menu {{ .Title }}
+{{- range .Entries }}
+item {{ .Name }} {{ .Label }}
+{{- end }}
+choose{{ if .Timeout }} --timeout {{ .Timeout }}{{ end }} --default {{ .Default }} selected || goto menu
+goto entry-${selected}
+{{ range .Entries }}
+:entry-{{ .Name }}
+{{- if .URL }}
+chain --autofree {{ .URL }} || goto failed
+{{- else }}
+{{ .Script }}
+{{- end }}
+goto menu
+{{ end }}
+:failed
+echo Boot menu entry ${selected} failed
+sleep 5
+goto menu
+`
+
+// Names of the built-in menu entries.
+const (
+	// MenuEntryHook boots the script that is served to the Hardware when the menu is disabled, HookOS by default.
+	MenuEntryHook = "hook"
+	// MenuEntryRescue drops to the iPXE shell.
+	MenuEntryRescue = "rescue"
+	// MenuEntryDisk exits iPXE so that the firmware boots the next device, usually the local disk.
+	MenuEntryDisk = "disk"
+)
+
+// hookScriptName is the path, relative to auto.ipxe, that serves the script the Hardware boots when the menu is disabled.
+const hookScriptName = "hook.ipxe"
+
+var menuEntryName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// MenuConfig configures the iPXE boot menu.
+type MenuConfig struct {
+	// Enabled serves the menu as auto.ipxe, also to Hardware that doesn't allow netbooting.
+	// The menu of Hardware that doesn't allow netbooting only has the disk entry.
+	Enabled bool
+	// Timeout after which the default entry is booted. Zero waits for a selection.
+	Timeout time.Duration
+	// Default is the name of the entry booted after the timeout for Hardware that allows netbooting.
+	// Defaults to the hook entry. Hardware that doesn't allow netbooting always defaults to the disk entry.
+	Default string
+	// Entries are custom entries added to the menu of all Hardware.
+	Entries []MenuEntry
+}
+
+// MenuEntry is an entry in the iPXE boot menu.
+// URL, when set, is chain loaded. Otherwise Script is run.
+type MenuEntry struct {
+	Name   string
+	Label  string
+	URL    string
+	Script string
+}
+
+// Menu holds the values used to render the MenuScript.
+type Menu struct {
+	Title   string
+	Timeout int64 // milliseconds
+	Default string
+	Entries []MenuEntry
+}
+
+// menuScript renders the boot menu for hw.
+// Built-in entries come first, followed by the entries configured in Smee and then the entries of the Hardware.
+// An entry replaces any previous entry with the same name.
+// Hardware that doesn't allow netbooting only gets the built-in disk entry, so that the menu can't boot anything else.
+func (h *Handler) menuScript(hw info) (string, error) {
+	if !hw.AllowNetboot {
+		return GenerateTemplate(Menu{
+			Title:   "Tinkerbell boot menu for " + hw.MACAddress.String(),
+			Timeout: h.Menu.Timeout.Milliseconds(),
+			Default: MenuEntryDisk,
+			Entries: []MenuEntry{{Name: MenuEntryDisk, Label: "Boot from local disk", Script: "exit"}},
+		}, MenuScript)
+	}
+	hookLabel := "Boot HookOS"
+	if hw.IPXEScriptURL != nil || hw.IPXEScript != "" || hw.TemplateRef != "" {
+		hookLabel = "Boot the iPXE script of this machine"
+	}
+	entries := []MenuEntry{
+		{Name: MenuEntryHook, Label: hookLabel, Script: "chain --autofree " + hookScriptName + " || goto failed"},
+		{Name: MenuEntryRescue, Label: "Rescue: iPXE shell", Script: "shell"},
+		{Name: MenuEntryDisk, Label: "Boot from local disk", Script: "exit"},
+	}
+	custom := append([]MenuEntry{}, h.Menu.Entries...)
+	for _, e := range hw.Menu {
+		custom = append(custom, MenuEntry(e))
+	}
+	for _, e := range custom {
+		if err := e.validate(); err != nil {
+			h.Logger.Info("skipping invalid ipxe menu entry", "entry", e.Name, "error", err.Error(), "mac", hw.MACAddress.String())
+			continue
+		}
+		entries = setMenuEntry(entries, e)
+	}
+
+	m := Menu{
+		Title:   "Tinkerbell boot menu for " + hw.MACAddress.String(),
+		Timeout: h.Menu.Timeout.Milliseconds(),
+		Default: MenuEntryHook,
+		Entries: entries,
+	}
+	if h.Menu.Default != "" && hasMenuEntry(entries, h.Menu.Default) {
+		m.Default = h.Menu.Default
+	}
+
+	return GenerateTemplate(m, MenuScript)
+}
+
+func (e MenuEntry) validate() error {
+	if !menuEntryName.MatchString(e.Name) {
+		return fmt.Errorf("invalid name %q, must match %v", e.Name, menuEntryName)
+	}
+	if e.URL != "" {
+		u, err := url.Parse(e.URL)
+		if err != nil {
+			return err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid URL scheme: %v", u.Scheme)
+		}
+		return nil
+	}
+	if e.Script == "" {
+		return errors.New("one of URL or Script is required")
+	}
+
+	return nil
+}
+
+// setMenuEntry replaces the entry with the same name as e or appends e.
+// The label of e defaults to its name.
+func setMenuEntry(entries []MenuEntry, e MenuEntry) []MenuEntry {
+	if e.Label == "" {
+		e.Label = e.Name
+	}
+	for i := range entries {
+		if entries[i].Name == e.Name {
+			entries[i] = e
+			return entries
+		}
+	}
+
+	return append(entries, e)
+}
+
+func hasMenuEntry(entries []MenuEntry, name string) bool {
+	for _, e := range entries {
+		if e.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
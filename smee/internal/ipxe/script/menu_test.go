@@ -0,0 +1,205 @@
+package script
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"github.com/tinkerbell/tinkerbell/pkg/data"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
+)
+
+type mockBackend struct {
+	hw *tinkerbell.Hardware
+}
+
+func (m *mockBackend) FilterHardware(_ context.Context, _ data.HardwareFilter) (*tinkerbell.Hardware, error) {
+	return m.hw, nil
+}
+
+func TestMenuScript(t *testing.T) {
+	want := `#!ipxe
+
+:menu
+menu Tinkerbell boot menu for 00:01:02:03:04:05
+item hook Boot HookOS
+item rescue Rescue: iPXE shell
+item disk Reinstall from scratch
+item netbootxyz netboot.xyz
+item memtest memtest
+choose --timeout 5000 --default netbootxyz selected || goto menu
+goto entry-${selected}
+
+:entry-hook
+chain --autofree hook.ipxe || goto failed
+goto menu
+
+:entry-rescue
+shell
+goto menu
+
+:entry-disk
+chain --autofree http://example.com/reinstall.ipxe || goto failed
+goto menu
+
+:entry-netbootxyz
+chain --autofree https://boot.netboot.xyz || goto failed
+goto menu
+
+:entry-memtest
+kernel http://example.com/memtest
+boot
+goto menu
+
+:failed
+echo Boot menu entry ${selected} failed
+sleep 5
+goto menu
+`
+	h := &Handler{
+		Logger: logr.Discard(),
+		Menu: MenuConfig{
+			Enabled: true,
+			Timeout: 5 * time.Second,
+			Default: "netbootxyz",
+			Entries: []MenuEntry{
+				{Name: "netbootxyz", Label: "netboot.xyz", URL: "https://boot.netboot.xyz"},
+				{Name: "invalid name", URL: "https://boot.netboot.xyz"},
+				{Name: "invalid-url", URL: "ftp://example.com"},
+			},
+		},
+	}
+	hw := info{
+		AllowNetboot: true,
+		MACAddress:   net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		Menu: []dhcp.IPXEMenuEntry{
+			{Name: "disk", Label: "Reinstall from scratch", URL: "http://example.com/reinstall.ipxe"},
+			{Name: "memtest", Script: "kernel http://example.com/memtest\nboot"},
+			{Name: "empty"},
+		},
+	}
+	got, err := h.menuScript(hw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestMenuScriptDefault(t *testing.T) {
+	tests := map[string]struct {
+		allowNetboot bool
+		configured   string
+		want         string
+	}{
+		"netboot allowed":                    {allowNetboot: true, want: "--default hook"},
+		"netboot allowed, configured":        {allowNetboot: true, configured: "rescue", want: "--default rescue"},
+		"netboot allowed, unknown":           {allowNetboot: true, configured: "unknown", want: "--default hook"},
+		"netboot not allowed":                {want: "--default disk"},
+		"netboot not allowed, configuration": {configured: "hook", want: "--default disk"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{Logger: logr.Discard(), Menu: MenuConfig{Enabled: true, Default: tt.configured}}
+			got, err := h.menuScript(info{AllowNetboot: tt.allowNetboot, MACAddress: net.HardwareAddr{0, 1, 2, 3, 4, 5}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(got, "choose "+tt.want+" selected") {
+				t.Fatalf("expected %q in menu, got:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMenuScriptNetbootNotAllowed(t *testing.T) {
+	want := `#!ipxe
+
+:menu
+menu Tinkerbell boot menu for 00:01:02:03:04:05
+item disk Boot from local disk
+choose --default disk selected || goto menu
+goto entry-${selected}
+
+:entry-disk
+exit
+goto menu
+
+:failed
+echo Boot menu entry ${selected} failed
+sleep 5
+goto menu
+`
+	h := &Handler{
+		Logger: logr.Discard(),
+		Menu: MenuConfig{
+			Enabled: true,
+			Default: "netbootxyz",
+			Entries: []MenuEntry{{Name: "netbootxyz", Label: "netboot.xyz", URL: "https://boot.netboot.xyz"}},
+		},
+	}
+	hw := info{
+		MACAddress: net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		Menu:       []dhcp.IPXEMenuEntry{{Name: "disk", Label: "Reinstall from scratch", URL: "http://example.com/reinstall.ipxe"}},
+	}
+	got, err := h.menuScript(hw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestMenuHandlerFunc(t *testing.T) {
+	initMetrics()
+	tests := map[string]struct {
+		allowPXE   bool
+		menu       bool
+		path       string
+		wantStatus int
+		wantPrefix string
+	}{
+		"menu disabled":           {path: "/00:01:02:03:04:05/auto.ipxe", wantStatus: http.StatusNotFound},
+		"menu disabled, hook":     {path: "/00:01:02:03:04:05/hook.ipxe", wantStatus: http.StatusNotFound},
+		"menu enabled":            {menu: true, path: "/00:01:02:03:04:05/auto.ipxe", wantStatus: http.StatusOK, wantPrefix: "#!ipxe\n\n:menu\n"},
+		"menu enabled, hook":      {menu: true, path: "/00:01:02:03:04:05/hook.ipxe", wantStatus: http.StatusNotFound},
+		"menu enabled, not found": {menu: true, path: "/00:01:02:03:04:05/other.ipxe", wantStatus: http.StatusNotFound},
+		"allowed, menu enabled":   {allowPXE: true, menu: true, path: "/00:01:02:03:04:05/auto.ipxe", wantStatus: http.StatusOK, wantPrefix: "#!ipxe\n\n:menu\n"},
+		"allowed, hook":           {allowPXE: true, menu: true, path: "/00:01:02:03:04:05/hook.ipxe", wantStatus: http.StatusOK, wantPrefix: "#!ipxe\n\necho Loading the Tinkerbell Hook iPXE script..."},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			hw := &tinkerbell.Hardware{
+				Spec: tinkerbell.HardwareSpec{
+					Interfaces: []tinkerbell.Interface{{
+						DHCP:    &tinkerbell.DHCP{MAC: "00:01:02:03:04:05", IP: &tinkerbell.IP{Address: "192.168.2.5", Netmask: "255.255.255.0"}},
+						Netboot: &tinkerbell.Netboot{AllowPXE: &tt.allowPXE},
+					}},
+				},
+			}
+			h := &Handler{
+				Logger:  logr.Discard(),
+				Backend: &mockBackend{hw: hw},
+				OSIEURL: "http://127.0.0.1",
+				Menu:    MenuConfig{Enabled: tt.menu},
+			}
+			w := httptest.NewRecorder()
+			h.HandlerFunc()(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if !strings.HasPrefix(w.Body.String(), tt.wantPrefix) {
+				t.Fatalf("expected body to start with %q, got:\n%s", tt.wantPrefix, w.Body.String())
+			}
+		})
+	}
+}
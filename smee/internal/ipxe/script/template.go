@@ -0,0 +1,93 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// IPXEScriptLister is the interface for listing IPXEScript templates from a backend.
+type IPXEScriptLister interface {
+	ListIPXEScripts(ctx context.Context, namespace string) ([]tinkerbell.IPXEScript, error)
+}
+
+// errScriptNotFound is returned when the IPXEScript named by the TemplateRef of a Hardware doesn't exist.
+var errScriptNotFound = errors.New("ipxe script not found")
+
+// Template holds the values used to render an IPXEScript.
+// It has all the values of the built-in Hook script and the full Hardware object.
+type Template struct {
+	Hook
+	Hardware *tinkerbell.Hardware
+}
+
+// templateScript renders the IPXEScript selected for hw.
+// An empty script and no error are returned when no IPXEScript applies, in which case the built-in Hook script should be used.
+// An IPXEScript is selected by the TemplateRef of the Hardware or, when not set, by the facility of the Hardware.
+// A TemplateRef is never replaced by the built-in Hook script: errScriptNotFound is returned when the IPXEScript doesn't
+// exist, or IPXEScripts aren't available, and an error when IPXEScripts can't be listed.
+func (h *Handler) templateScript(ctx context.Context, span trace.Span, hw info) (string, error) {
+	if hw.Hardware == nil {
+		return "", nil
+	}
+	if h.Scripts == nil {
+		if hw.TemplateRef != "" {
+			return "", fmt.Errorf("%w: %s/%s, ipxe scripts are not available", errScriptNotFound, hw.Hardware.Namespace, hw.TemplateRef)
+		}
+		return "", nil
+	}
+	scripts, err := h.Scripts.ListIPXEScripts(ctx, hw.Hardware.Namespace)
+	if err != nil {
+		if hw.TemplateRef != "" {
+			return "", fmt.Errorf("failed to list ipxe scripts for %s/%s: %w", hw.Hardware.Namespace, hw.TemplateRef, err)
+		}
+		h.Logger.Error(err, "unable to list ipxe scripts, using the built-in Hook script", "hardware", hw.Hardware.Name)
+		return "", nil
+	}
+	s := selectScript(scripts, hw.TemplateRef, hw.Facility)
+	if s == nil {
+		if hw.TemplateRef != "" {
+			return "", fmt.Errorf("%w: %s/%s", errScriptNotFound, hw.Hardware.Namespace, hw.TemplateRef)
+		}
+		return "", nil
+	}
+	span.SetAttributes(attribute.String("smee.ipxe_script_template", s.Namespace+"/"+s.Name))
+	out, err := GenerateTemplate(Template{Hook: h.hookData(span, hw), Hardware: hw.Hardware}, s.Spec.Script)
+	if err != nil {
+		return "", fmt.Errorf("failed to render ipxe script %s/%s: %w", s.Namespace, s.Name, err)
+	}
+
+	return out, nil
+}
+
+// selectScript returns the script named ref or, when ref is empty, the first script by name that lists facility.
+func selectScript(scripts []tinkerbell.IPXEScript, ref, facility string) *tinkerbell.IPXEScript {
+	if ref != "" {
+		for i := range scripts {
+			if scripts[i].Name == ref {
+				return &scripts[i]
+			}
+		}
+		return nil
+	}
+	if facility == "" {
+		return nil
+	}
+	var found *tinkerbell.IPXEScript
+	for i := range scripts {
+		if !slices.Contains(scripts[i].Spec.Facilities, facility) {
+			continue
+		}
+		if found == nil || strings.Compare(scripts[i].Name, found.Name) < 0 {
+			found = &scripts[i]
+		}
+	}
+
+	return found
+}
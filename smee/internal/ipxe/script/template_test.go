@@ -0,0 +1,143 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"go.opentelemetry.io/otel/trace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type mockLister struct {
+	scripts []tinkerbell.IPXEScript
+	err     error
+}
+
+func (m *mockLister) ListIPXEScripts(_ context.Context, namespace string) ([]tinkerbell.IPXEScript, error) {
+	var out []tinkerbell.IPXEScript
+	for _, s := range m.scripts {
+		if s.Namespace == namespace {
+			out = append(out, s)
+		}
+	}
+	return out, m.err
+}
+
+func ipxeScript(name, script string, facilities ...string) tinkerbell.IPXEScript {
+	return tinkerbell.IPXEScript{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "tink"},
+		Spec:       tinkerbell.IPXEScriptSpec{Script: script, Facilities: facilities},
+	}
+}
+
+func TestSelectScript(t *testing.T) {
+	scripts := []tinkerbell.IPXEScript{
+		ipxeScript("rescue", "#!ipxe"),
+		ipxeScript("lab-b", "#!ipxe", "lab"),
+		ipxeScript("lab-a", "#!ipxe", "lab", "dc1"),
+	}
+	tests := map[string]struct {
+		ref      string
+		facility string
+		want     string
+	}{
+		"by ref":                    {ref: "rescue", facility: "lab", want: "rescue"},
+		"ref not found":             {ref: "missing", facility: "lab"},
+		"by facility first by name": {facility: "lab", want: "lab-a"},
+		"by facility":               {facility: "dc1", want: "lab-a"},
+		"unknown facility":          {facility: "dc2"},
+		"nothing selected":          {},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := selectScript(scripts, tt.ref, tt.facility)
+			var gotName string
+			if got != nil {
+				gotName = got.Name
+			}
+			if gotName != tt.want {
+				t.Fatalf("got %q, want %q", gotName, tt.want)
+			}
+		})
+	}
+}
+
+// errAny matches any error in the wantErr of test cases.
+var errAny = errors.New("any error")
+
+func TestTemplateScript(t *testing.T) {
+	hw := &tinkerbell.Hardware{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine1", Namespace: "tink"},
+		Spec: tinkerbell.HardwareSpec{
+			Metadata: &tinkerbell.HardwareMetadata{Instance: &tinkerbell.MetadataInstance{Hostname: "machine1.lab"}},
+		},
+	}
+	tests := map[string]struct {
+		lister  IPXEScriptLister
+		d       info
+		want    string
+		wantErr error
+	}{
+		"renders hook values and hardware": {
+			lister: &mockLister{scripts: []tinkerbell.IPXEScript{ipxeScript("custom", "#!ipxe\necho {{ .WorkerID }} {{ .Arch }} {{ .DownloadURL }} {{ .Hardware.Spec.Metadata.Instance.Hostname }}")}},
+			d:      info{MACAddress: net.HardwareAddr{0, 1, 2, 3, 4, 5}, TemplateRef: "custom", Hardware: hw},
+			want:   "#!ipxe\necho 00:01:02:03:04:05 x86_64 http://127.1.1.1 machine1.lab",
+		},
+		"selected by facility": {
+			lister: &mockLister{scripts: []tinkerbell.IPXEScript{ipxeScript("lab", "#!ipxe\necho {{ .Facility }}", "lab")}},
+			d:      info{MACAddress: net.HardwareAddr{0, 1, 2, 3, 4, 5}, Facility: "lab", Hardware: hw},
+			want:   "#!ipxe\necho lab",
+		},
+		"scripts in other namespaces are not used": {
+			lister: &mockLister{scripts: []tinkerbell.IPXEScript{{
+				ObjectMeta: metav1.ObjectMeta{Name: "custom", Namespace: "other"},
+				Spec:       tinkerbell.IPXEScriptSpec{Script: "#!ipxe"},
+			}}},
+			d:       info{TemplateRef: "custom", Hardware: hw},
+			wantErr: errScriptNotFound,
+		},
+		"missing ref": {
+			lister:  &mockLister{},
+			d:       info{TemplateRef: "custom", Hardware: hw},
+			wantErr: errScriptNotFound,
+		},
+		"list error": {
+			lister:  &mockLister{err: errors.New("boom")},
+			d:       info{TemplateRef: "custom", Hardware: hw},
+			wantErr: errAny,
+		},
+		"list error without ref falls back": {
+			lister: &mockLister{err: errors.New("boom")},
+			d:      info{Facility: "lab", Hardware: hw},
+		},
+		"no lister": {
+			d:       info{TemplateRef: "custom", Hardware: hw},
+			wantErr: errScriptNotFound,
+		},
+		"no lister without ref falls back": {
+			d: info{Facility: "lab", Hardware: hw},
+		},
+		"render error": {
+			lister:  &mockLister{scripts: []tinkerbell.IPXEScript{ipxeScript("custom", "#!ipxe\necho {{ .Hardware.Spec.Nope }}")}},
+			d:       info{TemplateRef: "custom", Hardware: hw},
+			wantErr: errAny,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{Logger: logr.Discard(), OSIEURL: "http://127.1.1.1", Scripts: tt.lister}
+			got, err := h.templateScript(context.Background(), trace.SpanFromContext(context.Background()), tt.d)
+			if (err != nil) != (tt.wantErr != nil) || (tt.wantErr != errAny && !errors.Is(err, tt.wantErr)) {
+				t.Fatalf("templateScript() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,472 @@
+package iso
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/tinkerbell/smee/internal/metric"
+)
+
+const (
+	cacheIndexFile = "index.json"
+	cacheBlobDir   = "blobs"
+
+	// defaultCacheFetchTimeout bounds the download of an ISO, so that a stalled upstream doesn't block caching it forever.
+	defaultCacheFetchTimeout = 30 * time.Minute
+	// defaultCacheRevalidate is how long a cached ISO is served before it's checked against the upstream again.
+	defaultCacheRevalidate = time.Hour
+)
+
+// defaultCacheClient is used when Cache.Client is nil.
+var defaultCacheClient = &http.Client{Timeout: defaultCacheFetchTimeout}
+
+// Cache is a content-addressed disk cache of upstream ISOs with a size limit.
+// ISOs are stored by the sha256 digest of their contents, so that upstream URLs that serve the same ISO share one copy.
+// The offsets of the magic string are recorded when an ISO is stored, so that the patch can be applied to any range that is read.
+// When the cache is over MaxBytes, the least recently used ISOs that aren't being read are evicted.
+// Only ISOs from Hosts are cached, as the URL of an ISO can come from the client.
+type Cache struct {
+	Logger logr.Logger
+	// Dir is the directory in which ISOs and the cache index are stored.
+	Dir string
+	// MaxBytes is the maximum total size of the cached ISOs. Zero means no limit.
+	MaxBytes int64
+	// Hosts are the hosts, with the port if the URLs have one, whose ISOs are cached.
+	// ISOs from other hosts are never cached. When empty, no ISOs are cached.
+	Hosts []string
+	// Client is the HTTP client used to download ISOs. Defaults to a client with a 30 minute timeout.
+	Client *http.Client
+	// FetchTimeout is the maximum time a download of an ISO can take. Defaults to 30 minutes.
+	FetchTimeout time.Duration
+	// Revalidate is how long a cached ISO is served before it's checked against the upstream again.
+	// The check runs in the background and uses the ETag or Last-Modified header of the upstream response.
+	// The cached ISO is served until the check finds a new one. Defaults to 1 hour.
+	Revalidate time.Duration
+
+	// magic is the string whose offsets are recorded. It is set by the Handler.
+	magic []byte
+
+	loadOnce sync.Once
+	mu       sync.Mutex
+	index    cacheIndex
+	size     int64
+	refs     map[string]int
+	inflight map[string]bool
+}
+
+// cacheIndex is persisted in the cache directory so that cached ISOs survive restarts.
+type cacheIndex struct {
+	// MagicString is the sha256 digest of the magic string the offsets were recorded for.
+	MagicString string `json:"magicString"`
+	// URLs maps an upstream URL to the ISO it served.
+	URLs map[string]*cachedURL `json:"urls"`
+	// ISOs maps a digest to a cached ISO.
+	ISOs map[string]*cachedISO `json:"isos"`
+}
+
+// cachedURL is an upstream URL and the validators of its response, used to check whether the ISO changed.
+type cachedURL struct {
+	Digest       string    `json:"digest"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	Checked      time.Time `json:"checked"`
+}
+
+type cachedISO struct {
+	Size     int64     `json:"size"`
+	Offsets  []int64   `json:"offsets"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+// cachedFile is an open cached ISO. Close must be called when done reading.
+type cachedFile struct {
+	*os.File
+	Size    int64
+	Offsets []int64
+	release func()
+}
+
+func (f *cachedFile) Close() error {
+	defer f.release()
+	return f.File.Close()
+}
+
+// Open returns the cached ISO for the upstream URL u.
+// When the ISO isn't cached, false is returned and the ISO is downloaded in the background.
+// ISOs from hosts that aren't in Hosts are never cached.
+// A cached ISO that's due to be revalidated is returned while it's checked in the background.
+func (c *Cache) Open(u string) (*cachedFile, bool) {
+	if !c.allowed(u) {
+		return nil, false
+	}
+	c.loadOnce.Do(c.load)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cu, ok := c.index.URLs[u]
+	if !ok {
+		c.startFetch(u, nil)
+		return nil, false
+	}
+	digest := cu.Digest
+	iso, ok := c.index.ISOs[digest]
+	if !ok {
+		delete(c.index.URLs, u)
+		c.startFetch(u, nil)
+		return nil, false
+	}
+	f, err := os.Open(c.blobPath(digest))
+	if err != nil {
+		c.Logger.Error(err, "unable to open cached iso, removing it from the cache", "digest", digest)
+		c.remove(digest)
+		c.save()
+		c.startFetch(u, nil)
+		return nil, false
+	}
+	c.refs[digest]++
+	iso.LastUsed = time.Now()
+	if time.Since(cu.Checked) >= cmp.Or(c.Revalidate, defaultCacheRevalidate) {
+		v := *cu
+		c.startFetch(u, &v)
+	}
+
+	return &cachedFile{
+		File:    f,
+		Size:    iso.Size,
+		Offsets: iso.Offsets,
+		release: func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.refs[digest]--
+			if c.refs[digest] == 0 {
+				delete(c.refs, digest)
+			}
+		},
+	}, true
+}
+
+// allowed reports whether ISOs from u can be cached.
+func (c *Cache) allowed(u string) bool {
+	pu, err := url.Parse(u)
+	return err == nil && slices.Contains(c.Hosts, pu.Host)
+}
+
+// startFetch downloads u in the background, unless a download is already in progress.
+// When cached is set, the download is conditional on the ISO having changed. c.mu must be held.
+func (c *Cache) startFetch(u string, cached *cachedURL) {
+	if c.inflight[u] {
+		return
+	}
+	c.inflight[u] = true
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cmp.Or(c.FetchTimeout, defaultCacheFetchTimeout))
+		defer cancel()
+		if err := c.fetch(ctx, u, cached); err != nil {
+			c.Logger.Error(err, "unable to cache iso", "url", u)
+		}
+		c.mu.Lock()
+		delete(c.inflight, u)
+		c.mu.Unlock()
+	}()
+}
+
+// fetch downloads u into the cache.
+// When cached is set, the request is conditional on its validators, and a 304 response only marks it as checked.
+func (c *Cache) fetch(ctx context.Context, u string, cached *cachedURL) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+	client := c.Client
+	if client == nil {
+		client = defaultCacheClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		c.checked(u, cached.Digest)
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %v", resp.Status)
+	}
+	if c.MaxBytes > 0 && resp.ContentLength > c.MaxBytes {
+		return fmt.Errorf("iso size %d is larger than the cache size limit %d", resp.ContentLength, c.MaxBytes)
+	}
+
+	if err := os.MkdirAll(filepath.Join(c.Dir, cacheBlobDir), 0o755); err != nil {
+		return fmt.Errorf("unable to create cache directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Join(c.Dir, cacheBlobDir), ".download-*")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	ow := &offsetWriter{magic: c.magic}
+	n, err := io.Copy(io.MultiWriter(tmp, h, ow), resp.Body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to download iso: %w", err)
+	}
+	if resp.ContentLength >= 0 && n != resp.ContentLength {
+		return fmt.Errorf("incomplete download: got %d bytes, want %d", n, resp.ContentLength)
+	}
+	if c.MaxBytes > 0 && n > c.MaxBytes {
+		return fmt.Errorf("iso size %d is larger than the cache size limit %d", n, c.MaxBytes)
+	}
+
+	cu := &cachedURL{Digest: digestOf(h), ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified"), Checked: time.Now()}
+
+	return c.add(u, tmp.Name(), cu, &cachedISO{Size: n, Offsets: ow.offsets, LastUsed: time.Now()})
+}
+
+// checked records that the ISO cached for u was revalidated, if u still points to digest.
+func (c *Cache) checked(u, digest string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cu, ok := c.index.URLs[u]; ok && cu.Digest == digest {
+		cu.Checked = time.Now()
+		c.save()
+	}
+}
+
+// add moves a downloaded ISO into the cache and evicts ISOs until the cache is within its size limit.
+// The ISO that u pointed to before is removed when no other URL points to it and it isn't being read.
+func (c *Cache) add(u, tmp string, cu *cachedURL, iso *cachedISO) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	digest := cu.Digest
+	if _, ok := c.index.ISOs[digest]; !ok {
+		if err := os.Rename(tmp, c.blobPath(digest)); err != nil {
+			return fmt.Errorf("unable to store iso: %w", err)
+		}
+		c.index.ISOs[digest] = iso
+		c.size += iso.Size
+	}
+	prev := c.index.URLs[u]
+	c.index.URLs[u] = cu
+	if prev != nil && prev.Digest != digest && c.refs[prev.Digest] == 0 && !c.referenced(prev.Digest) {
+		c.Logger.Info("upstream iso changed, removing the previous one from the cache", "url", u, "digest", prev.Digest)
+		c.remove(prev.Digest)
+	}
+	c.evict(digest)
+	c.save()
+	metric.ISOCacheSize.Set(float64(c.size))
+	c.Logger.Info("cached iso", "url", u, "digest", digest, "bytes", iso.Size, "magicStringOffsets", len(iso.Offsets))
+
+	return nil
+}
+
+// evict removes the least recently used ISOs that aren't being read, other than keep, until the cache is within MaxBytes.
+// c.mu must be held.
+func (c *Cache) evict(keep string) {
+	for c.MaxBytes > 0 && c.size > c.MaxBytes {
+		var oldest string
+		for d, iso := range c.index.ISOs {
+			if d == keep || c.refs[d] > 0 {
+				continue
+			}
+			if oldest == "" || iso.LastUsed.Before(c.index.ISOs[oldest].LastUsed) {
+				oldest = d
+			}
+		}
+		if oldest == "" {
+			return
+		}
+		c.Logger.Info("evicting cached iso", "digest", oldest, "bytes", c.index.ISOs[oldest].Size)
+		c.remove(oldest)
+		metric.ISOCacheEvictions.Inc()
+	}
+}
+
+// referenced reports whether a URL points to digest. c.mu must be held.
+func (c *Cache) referenced(digest string) bool {
+	for _, cu := range c.index.URLs {
+		if cu.Digest == digest {
+			return true
+		}
+	}
+
+	return false
+}
+
+// remove deletes a cached ISO and the URLs that point to it. c.mu must be held.
+func (c *Cache) remove(digest string) {
+	if iso, ok := c.index.ISOs[digest]; ok {
+		c.size -= iso.Size
+		delete(c.index.ISOs, digest)
+	}
+	for u, cu := range c.index.URLs {
+		if cu.Digest == digest {
+			delete(c.index.URLs, u)
+		}
+	}
+	if err := os.Remove(c.blobPath(digest)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		c.Logger.Error(err, "unable to remove cached iso", "digest", digest)
+	}
+}
+
+// load reads the cache index from disk.
+// ISOs that are missing or whose size doesn't match are dropped and files that aren't in the index are removed.
+// The whole index is dropped when it was written for a different magic string.
+func (c *Cache) load() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.refs = map[string]int{}
+	c.inflight = map[string]bool{}
+	magic := sha256.Sum256(c.magic)
+	want := hex.EncodeToString(magic[:])
+	if b, err := os.ReadFile(filepath.Join(c.Dir, cacheIndexFile)); err == nil {
+		if err := json.Unmarshal(b, &c.index); err != nil {
+			c.Logger.Error(err, "unable to read the iso cache index, starting with an empty cache")
+			c.index = cacheIndex{}
+		}
+	}
+	if c.index.MagicString != want || c.index.URLs == nil || c.index.ISOs == nil {
+		c.index = cacheIndex{MagicString: want, URLs: map[string]*cachedURL{}, ISOs: map[string]*cachedISO{}}
+	}
+	for d, iso := range c.index.ISOs {
+		fi, err := os.Stat(c.blobPath(d))
+		if err != nil || fi.Size() != iso.Size {
+			delete(c.index.ISOs, d)
+			continue
+		}
+		c.size += iso.Size
+	}
+	for u, cu := range c.index.URLs {
+		if cu == nil {
+			delete(c.index.URLs, u)
+			continue
+		}
+		if _, ok := c.index.ISOs[cu.Digest]; !ok {
+			delete(c.index.URLs, u)
+		}
+	}
+	entries, _ := os.ReadDir(filepath.Join(c.Dir, cacheBlobDir))
+	for _, e := range entries {
+		if _, ok := c.index.ISOs[e.Name()]; !ok {
+			_ = os.Remove(filepath.Join(c.Dir, cacheBlobDir, e.Name()))
+		}
+	}
+	c.evict("")
+	metric.ISOCacheSize.Set(float64(c.size))
+}
+
+// save writes the cache index to disk. c.mu must be held.
+func (c *Cache) save() {
+	b, err := json.Marshal(c.index)
+	if err != nil {
+		c.Logger.Error(err, "unable to encode the iso cache index")
+		return
+	}
+	tmp := filepath.Join(c.Dir, "."+cacheIndexFile)
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		c.Logger.Error(err, "unable to write the iso cache index")
+		return
+	}
+	if err := os.Rename(tmp, filepath.Join(c.Dir, cacheIndexFile)); err != nil {
+		c.Logger.Error(err, "unable to write the iso cache index")
+	}
+}
+
+func (c *Cache) blobPath(digest string) string {
+	return filepath.Join(c.Dir, cacheBlobDir, digest)
+}
+
+func digestOf(h hash.Hash) string {
+	return "sha256-" + hex.EncodeToString(h.Sum(nil))
+}
+
+// offsetWriter records the offsets of all occurrences of magic in the bytes written to it.
+// The last len(magic)-1 bytes are kept between writes so that occurrences that span writes are found.
+type offsetWriter struct {
+	magic   []byte
+	tail    []byte
+	written int64
+	offsets []int64
+}
+
+func (o *offsetWriter) Write(p []byte) (int, error) {
+	if len(o.magic) == 0 {
+		return len(p), nil
+	}
+	buf := make([]byte, 0, len(o.tail)+len(p))
+	buf = append(buf, o.tail...)
+	buf = append(buf, p...)
+	start := o.written - int64(len(o.tail))
+	for i := 0; ; {
+		j := bytes.Index(buf[i:], o.magic)
+		if j == -1 {
+			break
+		}
+		o.offsets = append(o.offsets, start+int64(i+j))
+		i += j + len(o.magic)
+	}
+	keep := min(len(buf), len(o.magic)-1)
+	o.tail = append([]byte{}, buf[len(buf)-keep:]...)
+	o.written += int64(len(p))
+
+	return len(p), nil
+}
+
+// patchedReaderAt overlays patch at each offset when reading from r.
+type patchedReaderAt struct {
+	r       io.ReaderAt
+	offsets []int64
+	patch   []byte
+}
+
+func (p *patchedReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	n, err := p.r.ReadAt(b, off)
+	end := off + int64(n)
+	for _, o := range p.offsets {
+		ps, pe := max(o, off), min(o+int64(len(p.patch)), end)
+		if ps < pe {
+			copy(b[ps-off:pe-off], p.patch[ps-o:pe-o])
+		}
+	}
+
+	return n, err
+}
+
+// replacement returns the bytes that replace the magic string: the patch padded with spaces to the length of the magic string.
+func replacement(patch string, size int) []byte {
+	b := bytes.Repeat([]byte{' '}, size)
+	copy(b, patch)
+
+	return b
+}
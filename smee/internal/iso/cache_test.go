@@ -0,0 +1,317 @@
+package iso
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/tinkerbell/smee/internal/metric"
+)
+
+func TestMain(m *testing.M) {
+	metric.Init()
+	os.Exit(m.Run())
+}
+
+func TestOffsetWriter(t *testing.T) {
+	magic := []byte("magic")
+	data := []byte("xxmagicxxxxmagicmagicxmag")
+	want := []int64{2, 11, 16}
+	for _, chunk := range []int{1, 2, 3, 5, 7, len(data)} {
+		t.Run(fmt.Sprintf("chunk %d", chunk), func(t *testing.T) {
+			ow := &offsetWriter{magic: magic}
+			for i := 0; i < len(data); i += chunk {
+				if _, err := ow.Write(data[i:min(i+chunk, len(data))]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if diff := cmp.Diff(want, ow.offsets); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestPatchedReaderAt(t *testing.T) {
+	data := "0123magic9012magic890"
+	p := &patchedReaderAt{r: strings.NewReader(data), offsets: []int64{4, 13}, patch: replacement("ab", 5)}
+	want := "0123ab   9012ab   890"
+	for off := 0; off < len(data); off++ {
+		for n := 1; off+n <= len(data); n++ {
+			b := make([]byte, n)
+			if _, err := p.ReadAt(b, int64(off)); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(b); got != want[off:off+n] {
+				t.Fatalf("ReadAt(%d, %d) = %q, want %q", off, n, got, want[off:off+n])
+			}
+		}
+	}
+}
+
+// countingServer serves testdata and counts the requests.
+type countingServer struct {
+	mu   sync.Mutex
+	hits int
+	h    http.Handler
+}
+
+func (c *countingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	c.hits++
+	c.mu.Unlock()
+	c.h.ServeHTTP(w, r)
+}
+
+func (c *countingServer) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits
+}
+
+func (c *Cache) cached(u string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.index.URLs[u]
+	return ok
+}
+
+func TestCachedPatching(t *testing.T) {
+	up := &countingServer{h: http.FileServer(http.Dir("./testdata"))}
+	hs := httptest.NewServer(up)
+	defer hs.Close()
+	u := hs.URL + "/output.iso"
+
+	h := &Handler{
+		Logger:  logr.Discard(),
+		Backend: &mockBackend{},
+		Patch: Patch{
+			KernelParams: KernelParams{
+				ExtraParams:        []string{"k1=1", "k2=2"},
+				Syslog:             "127.0.0.1:514",
+				TinkServerGRPCAddr: "127.0.0.1:42113",
+			},
+			MagicString:       magicString,
+			SourceISO:         u,
+			StaticIPAMEnabled: true,
+		},
+		Cache: &Cache{Logger: logr.Discard(), Dir: t.TempDir(), Hosts: []string{hs.Listener.Addr().String()}},
+	}
+	hf, err := h.HandlerFunc()
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(rangeHeader string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/iso/de:ed:be:ef:fe:ed/output.iso", nil)
+		if rangeHeader != "" {
+			r.Header.Set("Range", rangeHeader)
+		}
+		w := httptest.NewRecorder()
+		hf.ServeHTTP(w, r)
+		return w
+	}
+
+	// The first request is proxied and starts caching the ISO.
+	proxied := get("")
+	if proxied.Code != http.StatusOK {
+		t.Fatalf("got status code: %d, want: %d", proxied.Code, http.StatusOK)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !h.Cache.cached(u) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the iso to be cached")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	hits := up.count()
+
+	cached := get("")
+	if cached.Code != http.StatusOK {
+		t.Fatalf("got status code: %d, want: %d", cached.Code, http.StatusOK)
+	}
+	if !bytes.Equal(proxied.Body.Bytes(), cached.Body.Bytes()) {
+		t.Fatal("cached iso doesn't match the proxied iso")
+	}
+	if !bytes.Contains(cached.Body.Bytes(), []byte("facility=test")) || bytes.Contains(cached.Body.Bytes(), []byte(magicString)) {
+		t.Fatal("cached iso is not patched")
+	}
+
+	// Range requests that cut through the patch are patched too.
+	i := int64(bytes.Index(cached.Body.Bytes(), []byte("facility=test")))
+	partial := get(fmt.Sprintf("bytes=%d-%d", i+5, i+50))
+	if partial.Code != http.StatusPartialContent {
+		t.Fatalf("got status code: %d, want: %d", partial.Code, http.StatusPartialContent)
+	}
+	if diff := cmp.Diff(cached.Body.String()[i+5:i+51], partial.Body.String()); diff != "" {
+		t.Fatal(diff)
+	}
+
+	if got := up.count(); got != hits {
+		t.Fatalf("cached requests went upstream: %d requests, want %d", got, hits)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	isos := map[string]string{"/a.iso": "aaaa", "/b.iso": "bbbb", "/c.iso": "cccc", "/a-copy.iso": "aaaa"}
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, isos[r.URL.Path])
+	}))
+	defer hs.Close()
+
+	c := &Cache{Logger: logr.Discard(), Dir: t.TempDir(), MaxBytes: 8, Hosts: []string{hs.Listener.Addr().String()}}
+	c.loadOnce.Do(c.load)
+	for _, p := range []string{"/a.iso", "/a-copy.iso", "/b.iso"} {
+		if err := c.fetch(t.Context(), hs.URL+p, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a.iso and a-copy.iso share one copy.
+	if c.size != 8 {
+		t.Fatalf("got cache size %d, want 8", c.size)
+	}
+
+	// a.iso is in use, so b.iso is evicted even though a.iso was used less recently.
+	f, ok := c.Open(hs.URL + "/a.iso")
+	if !ok {
+		t.Fatal("a.iso is not cached")
+	}
+	c.index.ISOs[digestFor(t, c, hs.URL+"/a.iso")].LastUsed = time.Time{}
+	if err := c.fetch(t.Context(), hs.URL+"/c.iso", nil); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	for p, want := range map[string]bool{"/a.iso": true, "/a-copy.iso": true, "/b.iso": false, "/c.iso": true} {
+		if got := c.cached(hs.URL + p); got != want {
+			t.Errorf("%v cached = %v, want %v", p, got, want)
+		}
+	}
+
+	// The index is loaded from disk.
+	reloaded := &Cache{Logger: logr.Discard(), Dir: c.Dir, MaxBytes: 8}
+	reloaded.loadOnce.Do(reloaded.load)
+	if reloaded.size != 8 || !reloaded.cached(hs.URL+"/c.iso") {
+		t.Fatalf("reloaded cache: size %d, cached %v", reloaded.size, reloaded.index.URLs)
+	}
+
+	// An ISO larger than the cache isn't cached.
+	small := &Cache{Logger: logr.Discard(), Dir: t.TempDir(), MaxBytes: 2}
+	small.loadOnce.Do(small.load)
+	if err := small.fetch(t.Context(), hs.URL+"/a.iso", nil); err == nil {
+		t.Fatal("expected an error caching an iso larger than the cache")
+	}
+}
+
+func digestFor(t *testing.T, c *Cache, u string) string {
+	t.Helper()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cu, ok := c.index.URLs[u]
+	if !ok {
+		t.Fatalf("%v is not cached", u)
+	}
+	return cu.Digest
+}
+
+func TestCacheHosts(t *testing.T) {
+	up := &countingServer{h: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { io.WriteString(w, "iso") })}
+	hs := httptest.NewServer(up)
+	defer hs.Close()
+
+	c := &Cache{Logger: logr.Discard(), Dir: t.TempDir(), Hosts: []string{"upstream.example.com"}}
+	if _, ok := c.Open(hs.URL + "/a.iso"); ok {
+		t.Fatal("iso from a host that isn't allowed is cached")
+	}
+	// Give a download, if one was started, time to reach the server.
+	time.Sleep(100 * time.Millisecond)
+	if got := up.count(); got != 0 {
+		t.Fatalf("got %d upstream requests for a host that isn't allowed, want 0", got)
+	}
+}
+
+func TestCacheRevalidate(t *testing.T) {
+	var mu sync.Mutex
+	content, etag := "aaaa", `"a"`
+	var conditional int
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("If-None-Match") != "" {
+			conditional++
+		}
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		io.WriteString(w, content)
+	}))
+	defer hs.Close()
+	u := hs.URL + "/a.iso"
+
+	c := &Cache{Logger: logr.Discard(), Dir: t.TempDir(), Hosts: []string{hs.Listener.Addr().String()}, Revalidate: time.Nanosecond}
+	c.loadOnce.Do(c.load)
+	if err := c.fetch(t.Context(), u, nil); err != nil {
+		t.Fatal(err)
+	}
+	first := digestFor(t, c, u)
+	// wait waits for background downloads to finish.
+	wait := func() {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			c.mu.Lock()
+			n := len(c.inflight)
+			c.mu.Unlock()
+			if n == 0 {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("timed out waiting for the revalidation")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// The cached ISO is served and revalidated, it's unchanged.
+	f, ok := c.Open(u)
+	if !ok {
+		t.Fatal("iso is not cached")
+	}
+	f.Close()
+	wait()
+	mu.Lock()
+	if conditional != 1 {
+		t.Fatalf("got %d conditional requests, want 1", conditional)
+	}
+	content, etag = "bbbb", `"b"`
+	mu.Unlock()
+	if got := digestFor(t, c, u); got != first {
+		t.Fatalf("unchanged iso got a new digest %v, want %v", got, first)
+	}
+
+	// The upstream ISO changed, so it replaces the cached one.
+	f, ok = c.Open(u)
+	if !ok {
+		t.Fatal("iso is not cached")
+	}
+	f.Close()
+	wait()
+	if got := digestFor(t, c, u); got == first {
+		t.Fatal("changed iso wasn't downloaded again")
+	}
+	c.mu.Lock()
+	_, ok = c.index.ISOs[first]
+	c.mu.Unlock()
+	if ok {
+		t.Fatal("previous iso is still cached")
+	}
+}
@@ -0,0 +1,503 @@
+package iso
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	diskfs "github.com/diskfs/go-diskfs"
+	"github.com/diskfs/go-diskfs/disk"
+	"github.com/diskfs/go-diskfs/filesystem"
+	"github.com/diskfs/go-diskfs/filesystem/iso9660"
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	binary "github.com/tinkerbell/tinkerbell/smee/internal/ipxe/binary/file"
+	"github.com/tinkerbell/tinkerbell/smee/internal/lifecycle"
+	"github.com/tinkerbell/tinkerbell/smee/internal/metric"
+)
+
+const (
+	// GeneratedIPXE is the name of the generated ISO that chains the iPXE script of the machine.
+	GeneratedIPXE = "ipxe.iso"
+	// GeneratedOSIE is the name of the generated ISO that downloads the OSIE kernel and initrd and boots them.
+	// The kernel and initrd aren't on the ISO, iPXE downloads them from the OSIE URL.
+	GeneratedOSIE = "osie.iso"
+
+	generatedScriptName = "tinkerbell.ipxe"
+	generatedESPName    = "efiboot.img"
+	generatedLabel      = "TINKERBELL"
+	// generatedESPSize is the size of the EFI system partition, which holds the iPXE binaries and the script.
+	generatedESPSize = 4 << 20
+)
+
+// generatedPatch replaces the magic string in the embedded script of the iPXE binaries on generated ISOs.
+// It runs the generated script from the EFI system partition iPXE was loaded from.
+// When that fails, iPXE carries on with its usual DHCP boot.
+var generatedPatch = []byte("chain file:/" + generatedScriptName + " ||")
+
+// generatedScript is the iPXE script of generated ISOs. It configures the network interface with the MAC address of the machine,
+// statically when the Hardware has an IPv4 address and with DHCP otherwise, and then chains ScriptURL or downloads and boots the OSIE.
+// It exits when the chained script returns, so that iPXE carries on with the next boot device.
+var generatedScript = template.Must(template.New("generated").Parse(`#!ipxe
+
+echo Booting {{ .MAC }} from a Tinkerbell generated ISO...
+set idx:int32 0
+:find-interface
+iseq ${net${idx}/mac} {{ .MAC }} && goto found-interface ||
+iseq ${idx} 50 && goto interface-error ||
+inc idx && goto find-interface
+
+:found-interface
+set netif net${idx}
+{{- if .VLANID }}
+vcreate --tag {{ .VLANID }} ${netif} || goto network-error
+set netif ${netif}-{{ .VLANID }}
+{{- end }}
+{{- if .IP }}
+set ${netif}/ip {{ .IP }}
+set ${netif}/netmask {{ .Netmask }}
+{{- if .Gateway }}
+set ${netif}/gateway {{ .Gateway }}
+{{- end }}
+{{- if .DNS }}
+set ${netif}/dns {{ .DNS }}
+{{- end }}
+ifopen ${netif} || goto network-error
+{{- else }}
+dhcp ${netif} || goto network-error
+{{- end }}
+{{- if .ScriptURL }}
+
+chain --autofree {{ .ScriptURL }} || goto boot-error
+{{- else }}
+
+set arch ${buildarch}
+iseq ${arch} arm64 && set arch aarch64 ||
+set kernel {{ .Kernel }}
+set initrd {{ .Initrd }}
+kernel {{ .OSIEURL }}/${kernel} {{ .KernelParams }} modules=loop,squashfs,sd-mod,usb-storage intel_iommu=on iommu=pt initrd=${initrd} || goto boot-error
+initrd {{ .OSIEURL }}/${initrd} || goto boot-error
+boot || goto boot-error
+{{- end }}
+exit
+
+:interface-error
+echo No network interface with the MAC address {{ .MAC }} was found
+shell
+
+:network-error
+echo Failed to configure the network interface ${netif}
+shell
+
+:boot-error
+echo Failed to boot
+shell
+`))
+
+// Generator builds small bootable ISOs for machines, as an alternative to patching an upstream ISO, so that any OSIE can be
+// booted from virtual media. A generated ISO boots iPXE from an EFI system partition with a script for the machine,
+// so only UEFI machines boot it. The OSIE isn't on the ISO, the script downloads it over the network.
+// Generated ISOs are kept in Dir and reused while the script of the machine doesn't change, so that all the range requests
+// of a BMC read the same ISO. When Dir is over MaxBytes, the least recently used ISOs are removed.
+type Generator struct {
+	// Dir is the directory in which generated ISOs are kept. It is created if it doesn't exist.
+	Dir string
+	// MaxBytes is the maximum total size of the generated ISOs in Dir. Zero means no limit.
+	// ISOs that are removed while they're being read can still be read by the requests that opened them.
+	MaxBytes int64
+	// ScriptURL is the URL of the iPXE script chained by ipxe.iso, for example http://192.168.2.2:7080/ipxe/script/auto.ipxe.
+	ScriptURL *url.URL
+	// InjectMAC inserts the MAC address of the machine in ScriptURL, before the file name.
+	InjectMAC bool
+	// OSIEURL is the URL of the directory with the OSIE kernel and initrd downloaded by osie.iso.
+	OSIEURL string
+	// KernelName and InitrdName are the names of the OSIE kernel and initrd, without the architecture suffix.
+	// They default to vmlinuz and initramfs. The kernel and initrd of a Hardware take precedence.
+	KernelName string
+	InitrdName string
+
+	// mu guards locks and used.
+	mu sync.Mutex
+	// locks serializes the generation of the ISOs of a machine and name, by file name prefix.
+	locks map[string]*keyLock
+	// used is when each generated ISO, by path, was last opened. ISOs not opened since Smee started use their modification time.
+	used map[string]time.Time
+}
+
+// keyLock is a mutex shared by the requests for one key.
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+// serveGenerated serves a generated ISO and reports whether the request was for one.
+func (h *Handler) serveGenerated(w http.ResponseWriter, r *http.Request) bool {
+	name := path.Base(r.URL.Path)
+	if name != GeneratedIPXE && name != GeneratedOSIE {
+		return false
+	}
+	log := h.Logger.WithValues("method", r.Method, "inboundURI", r.RequestURI, "remoteAddr", r.RemoteAddr)
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return true
+	}
+	ha, err := getMAC(r.URL.Path)
+	if err != nil {
+		log.Info("unable to parse mac address in the URL path", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return true
+	}
+	m, err := h.getMachine(r.Context(), ha, h.Backend)
+	if err != nil {
+		log.Info("unable to get the hardware object", "error", err, "mac", ha.String())
+		if apierrors.IsNotFound(err) {
+			w.WriteHeader(http.StatusNotFound)
+			return true
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return true
+	}
+	script, err := h.generatedScript(name, ha, m)
+	if err != nil {
+		log.Error(err, "unable to create the iPXE script of the generated iso", "mac", ha.String())
+		w.WriteHeader(http.StatusInternalServerError)
+		return true
+	}
+	f, err := h.Generator.Open(ha, name, script)
+	if err != nil {
+		log.Error(err, "unable to generate iso", "mac", ha.String())
+		w.WriteHeader(http.StatusInternalServerError)
+		return true
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		log.Error(err, "unable to stat generated iso")
+		w.WriteHeader(http.StatusInternalServerError)
+		return true
+	}
+
+	cw := &countingWriter{ResponseWriter: w}
+	http.ServeContent(cw, r, name, fi.ModTime(), f)
+	metric.ISOBytesServed.With(prometheus.Labels{"source": "generated"}).Add(float64(cw.n))
+	if r.Method == http.MethodGet {
+		h.NetbootStatus.ISO(ha, lifecycle.AddrFromRemote(r.RemoteAddr), name)
+	}
+
+	return true
+}
+
+// generatedScript returns the iPXE script of the generated ISO name for the machine.
+func (h *Handler) generatedScript(name string, mac net.HardwareAddr, m machine) ([]byte, error) {
+	data := struct {
+		MAC          string
+		VLANID       string
+		IP           string
+		Netmask      string
+		Gateway      string
+		DNS          string
+		ScriptURL    string
+		OSIEURL      string
+		Kernel       string
+		Initrd       string
+		KernelParams string
+	}{MAC: mac.String()}
+	if d := m.hw.DHCP; d != nil {
+		data.VLANID = d.VLANID
+		if d.IPAddress.Is4() && d.SubnetMask != nil {
+			data.IP = d.IPAddress.String()
+			data.Netmask = net.IP(d.SubnetMask).String()
+			if d.DefaultGateway.Is4() {
+				data.Gateway = d.DefaultGateway.String()
+			}
+			for _, ns := range d.NameServers {
+				if ns.To4() != nil {
+					data.DNS = ns.String()
+					break
+				}
+			}
+		}
+	}
+	switch name {
+	case GeneratedIPXE:
+		if h.Generator.ScriptURL == nil {
+			return nil, fmt.Errorf("no iPXE script URL to chain from %s", name)
+		}
+		u := *h.Generator.ScriptURL
+		if h.Generator.InjectMAC {
+			u.Path = path.Join(path.Dir(u.Path), mac.String(), path.Base(u.Path))
+		}
+		data.ScriptURL = u.String()
+	default:
+		if h.Generator.OSIEURL == "" {
+			return nil, fmt.Errorf("no OSIE URL to load from %s", name)
+		}
+		data.OSIEURL = strings.TrimSuffix(m.osie.VersionURL(h.Generator.OSIEURL), "/")
+		if m.parts.BaseURL != nil && m.parts.BaseURL.String() != "" {
+			data.OSIEURL = strings.TrimSuffix(m.parts.BaseURL.String(), "/")
+		}
+		// ${arch} is set by the script from the architecture of the iPXE binary.
+		data.Kernel = cmp.Or(m.parts.Kernel, cmp.Or(h.Generator.KernelName, "vmlinuz")+"-${arch}")
+		data.Initrd = cmp.Or(m.parts.Initrd, cmp.Or(h.Generator.InitrdName, "initramfs")+"-${arch}")
+		data.KernelParams = h.constructPatch(consoles(m.facility, m.osie), mac.String(), m.hw.DHCP, m.osie.KernelParams)
+	}
+
+	b := &bytes.Buffer{}
+	if err := generatedScript.Execute(b, data); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// Open returns the generated ISO name of the machine with the iPXE script, generating it when it doesn't exist yet.
+// The ISOs previously generated for the machine and name are removed.
+// Only requests for the same machine and name wait for each other.
+func (g *Generator) Open(mac net.HardwareAddr, name string, script []byte) (*os.File, error) {
+	sum := sha256.Sum256(script)
+	prefix := fmt.Sprintf("%s-%s-", strings.ReplaceAll(mac.String(), ":", ""), strings.TrimSuffix(name, path.Ext(name)))
+	p := filepath.Join(g.Dir, prefix+hex.EncodeToString(sum[:8])+".iso")
+	if f, err := os.Open(p); err == nil {
+		g.touch(p)
+		return f, nil
+	}
+
+	unlock := g.lock(prefix)
+	defer unlock()
+	if f, err := os.Open(p); err == nil {
+		g.touch(p)
+		return f, nil
+	}
+	if err := os.MkdirAll(g.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	// Hidden names aren't matched when older ISOs are removed.
+	tmp := filepath.Join(g.Dir, "."+prefix+"tmp")
+	_ = os.Remove(tmp)
+	if err := buildISO(tmp, script); err != nil {
+		_ = os.Remove(tmp)
+		return nil, fmt.Errorf("failed to build iso: %w", err)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		_ = os.Remove(tmp)
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	// Requests that still have an older ISO open keep reading it.
+	old, _ := filepath.Glob(filepath.Join(g.Dir, prefix+"*.iso"))
+	for _, o := range old {
+		if o != p {
+			_ = os.Remove(o)
+			g.forget(o)
+		}
+	}
+	g.touch(p)
+	g.evict(p)
+
+	return f, nil
+}
+
+// lock locks the mutex of key and returns the function that unlocks it.
+func (g *Generator) lock(key string) func() {
+	g.mu.Lock()
+	if g.locks == nil {
+		g.locks = map[string]*keyLock{}
+	}
+	l, ok := g.locks[key]
+	if !ok {
+		l = &keyLock{}
+		g.locks[key] = l
+	}
+	l.refs++
+	g.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(g.locks, key)
+		}
+	}
+}
+
+// touch records that the ISO at p was opened.
+func (g *Generator) touch(p string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.used == nil {
+		g.used = map[string]time.Time{}
+	}
+	g.used[p] = time.Now()
+}
+
+// forget drops the ISO at p from the last use times.
+func (g *Generator) forget(p string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.used, p)
+}
+
+// evict removes the least recently used generated ISOs, other than keep, until Dir is within MaxBytes.
+func (g *Generator) evict(keep string) {
+	if g.MaxBytes <= 0 {
+		return
+	}
+	type generated struct {
+		path string
+		size int64
+		used time.Time
+	}
+	entries, err := os.ReadDir(g.Dir)
+	if err != nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var isos []generated
+	var total int64
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") || filepath.Ext(e.Name()) != ".iso" {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		p := filepath.Join(g.Dir, e.Name())
+		used, ok := g.used[p]
+		if !ok {
+			used = fi.ModTime()
+		}
+		isos = append(isos, generated{path: p, size: fi.Size(), used: used})
+		total += fi.Size()
+	}
+	slices.SortFunc(isos, func(a, b generated) int { return a.used.Compare(b.used) })
+	for _, iso := range isos {
+		if total <= g.MaxBytes {
+			return
+		}
+		if iso.path == keep {
+			continue
+		}
+		if err := os.Remove(iso.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		delete(g.used, iso.path)
+		total -= iso.size
+		metric.ISOGeneratedEvictions.Inc()
+	}
+}
+
+// buildISO writes a UEFI bootable ISO to dst. Its El Torito boot image is an EFI system partition with the x86-64 and ARM64
+// iPXE binaries, patched to run the script, and the script. The script is also at the root of the ISO, to ease troubleshooting.
+func buildISO(dst string, script []byte) error {
+	x86, err := binary.Patch(binary.IpxeEFI, generatedPatch)
+	if err != nil {
+		return err
+	}
+	arm, err := binary.Patch(binary.SNPARM64, generatedPatch)
+	if err != nil {
+		return err
+	}
+
+	ws, err := os.MkdirTemp(filepath.Dir(dst), ".generate-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(ws)
+	// The workspace is the root directory of the ISO.
+	if err := os.Chmod(ws, 0o755); err != nil {
+		return err
+	}
+
+	files := []struct {
+		name    string
+		content []byte
+	}{
+		{name: "/EFI/BOOT/BOOTX64.EFI", content: x86},
+		{name: "/EFI/BOOT/BOOTAA64.EFI", content: arm},
+		{name: "/" + generatedScriptName, content: script},
+	}
+	esp, err := diskfs.Create(filepath.Join(ws, generatedESPName), generatedESPSize, diskfs.SectorSizeDefault)
+	if err != nil {
+		return err
+	}
+	defer esp.Close()
+	espfs, err := esp.CreateFilesystem(disk.FilesystemSpec{Partition: 0, FSType: filesystem.TypeFat32, VolumeLabel: generatedLabel})
+	if err != nil {
+		return fmt.Errorf("failed to create the EFI system partition: %w", err)
+	}
+	if err := espfs.Mkdir("/EFI/BOOT"); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := writeFile(espfs, f.name, f.content); err != nil {
+			return fmt.Errorf("failed to write %s to the EFI system partition: %w", f.name, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(ws, generatedScriptName), script, 0o644); err != nil {
+		return err
+	}
+
+	d, err := diskfs.Create(dst, generatedESPSize+1<<20, diskfs.SectorSizeDefault)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	// ISOs only have logical block sizes of 2048, 4096 or 8192.
+	d.LogicalBlocksize = 2048
+	fs, err := d.CreateFilesystem(disk.FilesystemSpec{Partition: 0, FSType: filesystem.TypeISO9660, VolumeLabel: generatedLabel, WorkDir: ws})
+	if err != nil {
+		return err
+	}
+	iso, ok := fs.(*iso9660.FileSystem)
+	if !ok {
+		return fmt.Errorf("unexpected filesystem %T", fs)
+	}
+
+	return iso.Finalize(iso9660.FinalizeOptions{
+		RockRidge:        true,
+		VolumeIdentifier: generatedLabel,
+		ElTorito: &iso9660.ElTorito{
+			BootCatalog: "/boot.catalog",
+			Platform:    iso9660.EFI,
+			Entries: []*iso9660.ElToritoEntry{
+				{Platform: iso9660.EFI, Emulation: iso9660.NoEmulation, BootFile: "/" + generatedESPName},
+			},
+		},
+	})
+}
+
+func writeFile(fs filesystem.FileSystem, name string, content []byte) error {
+	f, err := fs.OpenFile(name, os.O_CREATE|os.O_RDWR)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
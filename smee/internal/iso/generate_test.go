@@ -0,0 +1,246 @@
+package iso
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	diskfs "github.com/diskfs/go-diskfs"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"github.com/tinkerbell/tinkerbell/pkg/data"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
+	"github.com/tinkerbell/tinkerbell/smee/internal/osie"
+)
+
+type generateBackend struct{}
+
+func (generateBackend) FilterHardware(_ context.Context, opts data.HardwareFilter) (*tinkerbell.Hardware, error) {
+	return &tinkerbell.Hardware{Spec: tinkerbell.HardwareSpec{Interfaces: []tinkerbell.Interface{{
+		DHCP: &tinkerbell.DHCP{
+			MAC:         opts.ByMACAddress,
+			VLANID:      "400",
+			IP:          &tinkerbell.IP{Address: "192.168.2.10", Netmask: "255.255.255.0", Gateway: "192.168.2.1", Family: 4},
+			NameServers: []string{"1.1.1.1"},
+		},
+		Netboot: &tinkerbell.Netboot{},
+	}}}}, nil
+}
+
+// readISOFile returns the content of a file in an ISO or FAT image.
+func readISOFile(t *testing.T, image, name string) []byte {
+	t.Helper()
+	d, err := diskfs.Open(image, diskfs.WithOpenMode(diskfs.ReadOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	fs, err := d.GetFilesystem(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := fs.OpenFile(name, os.O_RDONLY)
+	if err != nil {
+		t.Fatalf("failed to open %s in %s: %v", name, image, err)
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestGeneratedISO(t *testing.T) {
+	dir := t.TempDir()
+	h := &Handler{
+		Backend: generateBackend{},
+		Logger:  logr.Discard(),
+		Patch:   Patch{KernelParams: KernelParams{Syslog: "192.168.2.2", TinkServerGRPCAddr: "192.168.2.2:42113"}},
+		Generator: &Generator{
+			Dir:       filepath.Join(dir, "generated"),
+			ScriptURL: &url.URL{Scheme: "http", Host: "192.168.2.2:7080", Path: "/ipxe/script/auto.ipxe"},
+			InjectMAC: true,
+			OSIEURL:   "http://192.168.2.2:7171/osie",
+		},
+	}
+	hf, err := h.HandlerFunc()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]string{
+		GeneratedIPXE: {
+			"vcreate --tag 400 ${netif}",
+			"set ${netif}/ip 192.168.2.10",
+			"set ${netif}/gateway 192.168.2.1",
+			"set ${netif}/dns 1.1.1.1",
+			"chain --autofree http://192.168.2.2:7080/ipxe/script/52:54:00:12:34:01/auto.ipxe || goto boot-error\nexit\n",
+		},
+		GeneratedOSIE: {
+			"set kernel vmlinuz-${arch}\n",
+			"set initrd initramfs-${arch}\n",
+			"kernel http://192.168.2.2:7171/osie/${kernel} ",
+			"hw_addr=52:54:00:12:34:01",
+			"grpc_authority=192.168.2.2:42113",
+			"initrd http://192.168.2.2:7171/osie/${initrd}",
+			"boot || goto boot-error\nexit\n",
+		},
+	}
+	for name, want := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			hf(w, httptest.NewRequest(http.MethodGet, "/iso/52:54:00:12:34:01/"+name, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+			}
+			image := filepath.Join(dir, name)
+			if err := os.WriteFile(image, w.Body.Bytes(), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			script := readISOFile(t, image, "/"+generatedScriptName)
+			for _, s := range want {
+				if !bytes.Contains(script, []byte(s)) {
+					t.Errorf("script doesn't contain %q:\n%s", s, script)
+				}
+			}
+
+			esp := filepath.Join(dir, name+".img")
+			if err := os.WriteFile(esp, readISOFile(t, image, "/"+generatedESPName), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			// go-diskfs reads FAT files up to the end of their last sector.
+			if got := readISOFile(t, esp, "/"+generatedScriptName); !bytes.Equal(bytes.TrimRight(got, "\x00"), script) {
+				t.Errorf("the script of the EFI system partition differs from the one of the ISO:\n%s", got)
+			}
+			for _, bin := range []string{"/EFI/BOOT/BOOTX64.EFI", "/EFI/BOOT/BOOTAA64.EFI"} {
+				if !bytes.Contains(readISOFile(t, esp, bin), generatedPatch) {
+					t.Errorf("%s isn't patched to run the script", bin)
+				}
+			}
+
+			// Range requests read the same ISO.
+			r := httptest.NewRequest(http.MethodGet, "/iso/52:54:00:12:34:01/"+name, nil)
+			r.Header.Set("Range", "bytes=32768-34815")
+			w2 := httptest.NewRecorder()
+			hf(w2, r)
+			if w2.Code != http.StatusPartialContent || !bytes.Equal(w2.Body.Bytes(), w.Body.Bytes()[32768:34816]) {
+				t.Fatalf("range request returned status %d and different content", w2.Code)
+			}
+		})
+	}
+
+	isos, err := filepath.Glob(filepath.Join(dir, "generated", "*.iso"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(isos) != 2 {
+		t.Fatalf("got %d generated ISOs, want 2: %v", len(isos), isos)
+	}
+}
+
+func TestGeneratedOSIEScript(t *testing.T) {
+	mac := net.HardwareAddr{0x52, 0x54, 0x00, 0x12, 0x34, 0x01}
+	tests := map[string]struct {
+		generator *Generator
+		machine   machine
+		want      []string
+	}{
+		"defaults": {
+			generator: &Generator{OSIEURL: "http://192.168.2.2:7171/osie"},
+			want:      []string{"set kernel vmlinuz-${arch}\n", "set initrd initramfs-${arch}\n", "kernel http://192.168.2.2:7171/osie/${kernel} ", "initrd http://192.168.2.2:7171/osie/${initrd} "},
+		},
+		"configured names": {
+			generator: &Generator{OSIEURL: "http://192.168.2.2:7171/osie", KernelName: "kernel", InitrdName: "initrd"},
+			machine:   machine{osie: osie.Settings{Version: "v0.10.0"}},
+			want:      []string{"set kernel kernel-${arch}\n", "set initrd initrd-${arch}\n", "kernel http://192.168.2.2:7171/osie/v0.10.0/${kernel} "},
+		},
+		"hardware": {
+			generator: &Generator{OSIEURL: "http://192.168.2.2:7171/osie", KernelName: "kernel", InitrdName: "initrd"},
+			machine:   machine{parts: dhcp.OSIE{BaseURL: &url.URL{Scheme: "http", Host: "10.0.0.1", Path: "/custom/"}, Kernel: "vmlinuz-custom", Initrd: "initrd-custom"}},
+			want:      []string{"set kernel vmlinuz-custom\n", "set initrd initrd-custom\n", "kernel http://10.0.0.1/custom/${kernel} "},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{Generator: tt.generator}
+			script, err := h.generatedScript(GeneratedOSIE, mac, tt.machine)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.want {
+				if !bytes.Contains(script, []byte(s)) {
+					t.Errorf("script doesn't contain %q:\n%s", s, script)
+				}
+			}
+		})
+	}
+}
+
+func TestGeneratorReplacesOlderISOs(t *testing.T) {
+	g := &Generator{Dir: t.TempDir()}
+	mac := []byte{0x52, 0x54, 0x00, 0x12, 0x34, 0x01}
+	for _, script := range []string{"#!ipxe\necho one\n", "#!ipxe\necho two\n"} {
+		f, err := g.Open(mac, GeneratedIPXE, []byte(script))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	entries, err := os.ReadDir(g.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || !strings.HasPrefix(entries[0].Name(), "525400123401-ipxe-") {
+		t.Fatalf("unexpected generated ISOs: %v", entries)
+	}
+}
+
+func TestGeneratorEviction(t *testing.T) {
+	g := &Generator{Dir: t.TempDir()}
+	open := func(mac byte) int64 {
+		t.Helper()
+		f, err := g.Open([]byte{0x52, 0x54, 0x00, 0x12, 0x34, mac}, GeneratedIPXE, []byte("#!ipxe\necho test\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		fi, err := f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fi.Size()
+	}
+	size := open(1)
+	g.MaxBytes = 2 * size
+	open(2)
+	// 01 is used more recently than 02, so 02 is removed when 03 is generated.
+	open(1)
+	open(3)
+
+	entries, err := os.ReadDir(g.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name()[:len("525400123401")])
+	}
+	if diff := cmp.Diff([]string{"525400123401", "525400123403"}, got); diff != "" {
+		t.Fatalf("unexpected generated ISOs (-want +got):\n%s", diff)
+	}
+	if len(g.locks) != 0 {
+		t.Fatalf("locks weren't released: %v", g.locks)
+	}
+}
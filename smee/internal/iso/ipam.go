@@ -3,7 +3,6 @@ package iso
 import (
 	"fmt"
 	"net"
-	"net/netip"
 	"strings"
 
 	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
@@ -14,6 +13,7 @@ func parseIPAM(d *dhcp.DHCP) string {
 		return ""
 	}
 	// return format is ipam=<mac-address>:<vlan-id>:<ip-address>:<netmask>:<gateway>:<hostname>:<dns>:<search-domains>:<ntp>
+	// The fields are colon separated so IPv6 addresses are left out. IPv6 clients are configured via DHCPv6 or SLAAC.
 	ipam := make([]string, 9)
 	ipam[0] = func() string {
 		m := d.MACAddress.String()
@@ -27,7 +27,7 @@ func parseIPAM(d *dhcp.DHCP) string {
 		return ""
 	}()
 	ipam[2] = func() string {
-		if d.IPAddress.Compare(netip.Addr{}) != 0 {
+		if d.IPAddress.Is4() {
 			return d.IPAddress.String()
 		}
 		return ""
@@ -39,7 +39,7 @@ func parseIPAM(d *dhcp.DHCP) string {
 		return ""
 	}()
 	ipam[4] = func() string {
-		if d.DefaultGateway.Compare(netip.Addr{}) != 0 {
+		if d.DefaultGateway.Is4() {
 			return d.DefaultGateway.String()
 		}
 		return ""
@@ -48,7 +48,9 @@ func parseIPAM(d *dhcp.DHCP) string {
 	ipam[6] = func() string {
 		var nameservers []string
 		for _, e := range d.NameServers {
-			nameservers = append(nameservers, e.String())
+			if e.To4() != nil {
+				nameservers = append(nameservers, e.String())
+			}
 		}
 		if len(nameservers) > 0 {
 			return strings.Join(nameservers, ",")
@@ -66,7 +68,9 @@ func parseIPAM(d *dhcp.DHCP) string {
 	ipam[8] = func() string {
 		var ntp []string
 		for _, e := range d.NTPServers {
-			ntp = append(ntp, e.String())
+			if e.To4() != nil {
+				ntp = append(ntp, e.String())
+			}
 		}
 		if len(ntp) > 0 {
 			return strings.Join(ntp, ",")
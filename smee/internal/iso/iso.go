@@ -19,10 +19,14 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
 	"github.com/tinkerbell/tinkerbell/pkg/data"
 	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
 	"github.com/tinkerbell/tinkerbell/smee/internal/iso/internal"
+	"github.com/tinkerbell/tinkerbell/smee/internal/lifecycle"
+	"github.com/tinkerbell/tinkerbell/smee/internal/metric"
+	"github.com/tinkerbell/tinkerbell/smee/internal/osie"
 )
 
 const (
@@ -43,6 +47,15 @@ type Handler struct {
 	Backend BackendReader
 	Logger  logr.Logger
 	Patch   Patch
+	// Cache, when set, serves ISOs from a local cache. ISOs that aren't cached yet are proxied from upstream while they're cached.
+	Cache *Cache
+	// OSIEProfiles are OSIE settings for Hardware that matches them by facility or labels.
+	OSIEProfiles []osie.Profile
+	// NetbootStatus records the ISOs served to machines in the status of their Hardware.
+	NetbootStatus *lifecycle.Recorder
+	// Generator, when set, serves ISOs generated for the machine, instead of a patched upstream ISO,
+	// for the names GeneratedIPXE and GeneratedOSIE.
+	Generator *Generator
 }
 
 // Patch holds the data and configuration used for ISO patching.
@@ -100,7 +113,84 @@ func (h *Handler) HandlerFunc() (http.HandlerFunc, error) {
 
 	h.Patch.magicStrPadding = bytes.Repeat([]byte{' '}, len(h.Patch.MagicString))
 
-	return proxy.ServeHTTP, nil
+	serve := proxy.ServeHTTP
+	if h.Cache != nil {
+		h.Cache.magic = []byte(h.Patch.MagicString)
+		serve = func(w http.ResponseWriter, r *http.Request) {
+			if h.serveCached(w, r) {
+				return
+			}
+			proxy.ServeHTTP(w, r)
+		}
+	}
+	if h.Generator == nil {
+		return serve, nil
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.serveGenerated(w, r) {
+			return
+		}
+		serve(w, r)
+	}, nil
+}
+
+// serveCached serves the patched ISO from the cache and reports whether it did.
+// Requests that can't be served from the cache, including invalid requests, are left to the reverse proxy,
+// which also responds with the errors.
+func (h *Handler) serveCached(w http.ResponseWriter, r *http.Request) bool {
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) || filepath.Ext(r.URL.Path) != ".iso" {
+		return false
+	}
+	ha, err := getMAC(r.URL.Path)
+	if err != nil {
+		return false
+	}
+	m, err := h.getMachine(r.Context(), ha, h.Backend)
+	if err != nil {
+		return false
+	}
+	tu, err := targetURL(r.URL.Query().Get(queryParamSourceISO), isoFromHardware(m.hw), m.osie.VersionFileURL(h.Patch.SourceISO))
+	if err != nil {
+		return false
+	}
+	f, ok := h.Cache.Open(tu.String())
+	if !ok {
+		metric.ISOCacheRequests.With(prometheus.Labels{"result": "miss"}).Inc()
+		return false
+	}
+	defer f.Close()
+	metric.ISOCacheRequests.With(prometheus.Labels{"result": "hit"}).Inc()
+
+	fi, err := f.Stat()
+	if err != nil {
+		h.Logger.Error(err, "unable to stat cached iso")
+		w.WriteHeader(http.StatusInternalServerError)
+		return true
+	}
+	patch := replacement(h.constructPatch(consoles(m.facility, m.osie), ha.String(), m.hw.DHCP, m.osie.KernelParams), len(h.Patch.MagicString))
+	content := io.NewSectionReader(&patchedReaderAt{r: f, offsets: f.Offsets, patch: patch}, 0, f.Size)
+	cw := &countingWriter{ResponseWriter: w}
+	http.ServeContent(cw, r, path.Base(r.URL.Path), fi.ModTime(), content)
+	metric.ISOBytesServed.With(prometheus.Labels{"source": "cache"}).Add(float64(cw.n))
+	if r.Method == http.MethodGet {
+		h.NetbootStatus.ISO(ha, lifecycle.AddrFromRemote(r.RemoteAddr), path.Base(r.URL.Path))
+	}
+
+	return true
+}
+
+// countingWriter counts the bytes written to the response body.
+type countingWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(b)
+	c.n += int64(n)
+
+	return n, err
 }
 
 // targetURL returns a valid URL from the first non-empty source and an error, if any.
@@ -161,6 +251,9 @@ func (h *Handler) Copy(ctx context.Context, dst io.Writer, src io.Reader, buf []
 		buf = make([]byte, 32*1024)
 	}
 	var written int64
+	defer func() {
+		metric.ISOBytesServed.With(prometheus.Labels{"source": "upstream"}).Add(float64(written))
+	}()
 	for {
 		nr, rerr := src.Read(buf)
 		if rerr != nil && rerr != io.EOF && rerr != context.Canceled { //nolint: errorlint // going to defer to the stdlib on this one.
@@ -252,7 +345,7 @@ func (h *Handler) roundTripWithRedirectCount(req *http.Request, redirectCount in
 			}, nil
 		}
 
-		fac, hw, err := h.getFacility(req.Context(), ha, h.Backend)
+		m, err := h.getMachine(req.Context(), ha, h.Backend)
 		if err != nil {
 			log.Info("unable to get the hardware object", "error", err, "mac", ha.String())
 			if apierrors.IsNotFound(err) {
@@ -270,26 +363,14 @@ func (h *Handler) roundTripWithRedirectCount(req *http.Request, redirectCount in
 				Request:    req,
 			}, nil
 		}
-		// The hardware object doesn't contain a dedicated field for consoles right now and
-		// historically the facility is used as a way to define consoles on a per Hardware basis.
-		var consoles string
-		switch {
-		case fac != "" && strings.Contains(fac, "console="):
-			consoles = fmt.Sprintf("facility=%s", fac)
-		case fac != "":
-			consoles = fmt.Sprintf("facility=%s %s", fac, defaultConsoles)
-		default:
-			consoles = defaultConsoles
+		if req.Method == http.MethodGet {
+			h.NetbootStatus.ISO(ha, lifecycle.AddrFromRemote(req.RemoteAddr), path.Base(req.URL.Path))
 		}
 		// The patch is added to the request context so that it can be used in the Copy method.
-		req = req.WithContext(internal.WithPatch(req.Context(), []byte(h.constructPatch(consoles, ha.String(), hw.DHCP))))
+		req = req.WithContext(internal.WithPatch(req.Context(), []byte(h.constructPatch(consoles(m.facility, m.osie), ha.String(), m.hw.DHCP, m.osie.KernelParams))))
 
 		// Get the target URL (either from query parameter or default SourceISO)
-		fromHWObject := ""
-		if hw.Isoboot != nil && hw.Isoboot.SourceISO != nil {
-			fromHWObject = hw.Isoboot.SourceISO.String()
-		}
-		tu, err := targetURL(req.URL.Query().Get(queryParamSourceISO), fromHWObject, h.Patch.SourceISO)
+		tu, err := targetURL(req.URL.Query().Get(queryParamSourceISO), isoFromHardware(m.hw), m.osie.VersionFileURL(h.Patch.SourceISO))
 		if err != nil {
 			log.Info("unable to determine target URL", "error", err)
 			return &http.Response{
@@ -368,7 +449,36 @@ func (h *Handler) roundTripWithRedirectCount(req *http.Request, redirectCount in
 	return resp, nil
 }
 
-func (h *Handler) constructPatch(console, mac string, d *dhcp.DHCP) string {
+// consoles returns the facility and console kernel parameters.
+// Consoles set in the OSIE settings replace the default consoles.
+// Historically the facility is used as a way to define consoles on a per Hardware basis, which is still supported.
+func consoles(fac string, s osie.Settings) string {
+	if c := s.ConsoleParams(); len(c) > 0 {
+		if fac != "" {
+			c = append([]string{fmt.Sprintf("facility=%s", fac)}, c...)
+		}
+		return strings.Join(c, " ")
+	}
+	switch {
+	case fac != "" && strings.Contains(fac, "console="):
+		return fmt.Sprintf("facility=%s", fac)
+	case fac != "":
+		return fmt.Sprintf("facility=%s %s", fac, defaultConsoles)
+	default:
+		return defaultConsoles
+	}
+}
+
+// isoFromHardware returns the source ISO URL set on the hardware object, if any.
+func isoFromHardware(hw dhcp.Hardware) string {
+	if hw.Isoboot != nil && hw.Isoboot.SourceISO != nil {
+		return hw.Isoboot.SourceISO.String()
+	}
+
+	return ""
+}
+
+func (h *Handler) constructPatch(console, mac string, d *dhcp.DHCP, extraParams []string) string {
 	syslogHost := fmt.Sprintf("syslog_host=%s", h.Patch.KernelParams.Syslog)
 	grpcAuthority := fmt.Sprintf("grpc_authority=%s", h.Patch.KernelParams.TinkServerGRPCAddr)
 	tinkerbellTLS := fmt.Sprintf("tinkerbell_tls=%v", h.Patch.KernelParams.TinkServerTLS)
@@ -380,6 +490,7 @@ func (h *Handler) constructPatch(console, mac string, d *dhcp.DHCP) string {
 	}
 	all = append(all, hwAddr, syslogHost, grpcAuthority, tinkerbellTLS, workerID)
 	all = append(all, h.Patch.KernelParams.ExtraParams...)
+	all = append(all, extraParams...)
 	if h.Patch.StaticIPAMEnabled && parseIPAM(d) != "" {
 		all = append(all, parseIPAM(d))
 	}
@@ -397,21 +508,36 @@ func getMAC(urlPath string) (net.HardwareAddr, error) {
 	return hw, nil
 }
 
-func (h *Handler) getFacility(ctx context.Context, mac net.HardwareAddr, br BackendReader) (string, dhcp.Hardware, error) {
+// machine holds the Hardware data used to patch an ISO and to locate its source.
+type machine struct {
+	facility string
+	hw       dhcp.Hardware
+	osie     osie.Settings
+	// parts are the OSIE base URL, kernel and initrd of the Hardware.
+	parts dhcp.OSIE
+}
+
+func (h *Handler) getMachine(ctx context.Context, mac net.HardwareAddr, br BackendReader) (machine, error) {
 	if br == nil {
-		return "", dhcp.Hardware{}, errors.New("backend is nil")
+		return machine{}, errors.New("backend is nil")
 	}
 
 	spec, err := br.FilterHardware(ctx, data.HardwareFilter{ByMACAddress: mac.String()})
 	if err != nil {
-		return "", dhcp.Hardware{}, err
+		return machine{}, err
 	}
 	hw, err := dhcp.ConvertByMac(ctx, mac, spec)
 	if err != nil {
-		return "", dhcp.Hardware{}, fmt.Errorf("failed to convert hardware data: %w", err)
+		return machine{}, fmt.Errorf("failed to convert hardware data: %w", err)
 	}
-
-	return hw.Netboot.Facility, dhcp.Hardware{DHCP: hw.DHCP, Isoboot: hw.Isoboot}, nil
+	o := hw.Netboot.OSIE
+
+	return machine{
+		facility: hw.Netboot.Facility,
+		hw:       dhcp.Hardware{DHCP: hw.DHCP, Isoboot: hw.Isoboot},
+		osie:     osie.Resolve(h.OSIEProfiles, hw.Netboot.Facility, spec.Labels, osie.Settings{Version: o.Version, KernelParams: o.KernelParams, Consoles: o.Consoles}),
+		parts:    o,
+	}, nil
 }
 
 func randomPercentage(precision int64) float64 {
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -21,6 +22,7 @@ import (
 	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
 	"github.com/tinkerbell/tinkerbell/pkg/data"
 	"github.com/tinkerbell/tinkerbell/smee/internal/iso/internal"
+	"github.com/tinkerbell/tinkerbell/smee/internal/osie"
 )
 
 const magicString = `464vn90e7rbj08xbwdjejmdf4it17c5zfzjyfhthbh19eij201hjgit021bmpdb9ctrc87x2ymc8e7icu4ffi15x1hah9iyaiz38ckyap8hwx2vt5rm44ixv4hau8iw718q5yd019um5dt2xpqqa2rjtdypzr5v1gun8un110hhwp8cex7pqrh2ivh0ynpm4zkkwc8wcn367zyethzy7q8hzudyeyzx3cgmxqbkh825gcak7kxzjbgjajwizryv7ec1xm2h0hh7pz29qmvtgfjj1vphpgq1zcbiiehv52wrjy9yq473d9t1rvryy6929nk435hfx55du3ih05kn5tju3vijreru1p6knc988d4gfdz28eragvryq5x8aibe5trxd0t6t7jwxkde34v6pj1khmp50k6qqj3nzgcfzabtgqkmeqhdedbvwf3byfdma4nkv3rcxugaj2d0ru30pa2fqadjqrtjnv8bu52xzxv7irbhyvygygxu1nt5z4fh9w1vwbdcmagep26d298zknykf2e88kumt59ab7nq79d8amnhhvbexgh48e8qc61vq2e9qkihzt1twk1ijfgw70nwizai15iqyted2dt9gfmf2gg7amzufre79hwqkddc1cd935ywacnkrnak6r7xzcz7zbmq3kt04u2hg1iuupid8rt4nyrju51e6uejb2ruu36g9aibmz3hnmvazptu8x5tyxk820g2cdpxjdij766bt2n3djur7v623a2v44juyfgz80ekgfb9hkibpxh3zgknw8a34t4jifhf116x15cei9hwch0fye3xyq0acuym8uhitu5evc4rag3ui0fny3qg4kju7zkfyy8hwh537urd5uixkzwu5bdvafz4jmv7imypj543xg5em8jk8cgk7c4504xdd5e4e71ihaumt6u5u2t1w7um92fepzae8p0vq93wdrd1756npu1pziiur1payc7kmdwyxg3hj5n4phxbc29x0tcddamjrwt260b0w`
@@ -446,3 +448,52 @@ func TestGetTargetURL(t *testing.T) {
 		})
 	}
 }
+
+func TestConsoles(t *testing.T) {
+	tests := map[string]struct {
+		facility string
+		settings osie.Settings
+		want     string
+	}{
+		"defaults":                 {want: defaultConsoles},
+		"facility":                 {facility: "onprem", want: "facility=onprem " + defaultConsoles},
+		"consoles in facility":     {facility: "onprem console=ttyS0", want: "facility=onprem console=ttyS0"},
+		"settings":                 {settings: osie.Settings{Consoles: []string{"ttyS0,115200"}}, want: "console=ttyS0,115200"},
+		"settings and facility":    {facility: "onprem", settings: osie.Settings{Consoles: []string{"tty0", "ttyS0,115200"}}, want: "facility=onprem console=tty0 console=ttyS0,115200"},
+		"settings without console": {facility: "onprem", settings: osie.Settings{Version: "v1"}, want: "facility=onprem " + defaultConsoles},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, consoles(tt.facility, tt.settings)); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestMachineOSIESettings(t *testing.T) {
+	h := &Handler{
+		Logger: logr.Discard(),
+		Patch: Patch{
+			KernelParams: KernelParams{ExtraParams: []string{"k1=1"}, Syslog: "127.0.0.1:514", TinkServerGRPCAddr: "127.0.0.1:42113"},
+			SourceISO:    "http://example.com/hook/hook.iso",
+		},
+		OSIEProfiles: []osie.Profile{
+			{Facility: "test", Settings: osie.Settings{Version: "v0.10.0", KernelParams: []string{"k2=2"}, Consoles: []string{"ttyS0,115200"}}},
+			{Facility: "other", Settings: osie.Settings{KernelParams: []string{"k3=3"}}},
+		},
+	}
+	mac, _ := net.ParseMAC("de:ed:be:ef:fe:ed")
+	m, err := h.getMachine(context.Background(), mac, &mockBackend{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := h.constructPatch(consoles(m.facility, m.osie), mac.String(), m.hw.DHCP, m.osie.KernelParams)
+	want := "facility=test console=ttyS0,115200 vlan_id=400 hw_addr=de:ed:be:ef:fe:ed syslog_host=127.0.0.1:514 grpc_authority=127.0.0.1:42113 tinkerbell_tls=false worker_id=de:ed:be:ef:fe:ed k1=1 k2=2"
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatal(diff)
+	}
+	if got, want := m.osie.VersionFileURL(h.Patch.SourceISO), "http://example.com/hook/v0.10.0/hook.iso"; got != want {
+		t.Fatalf("got source iso %q, want %q", got, want)
+	}
+}
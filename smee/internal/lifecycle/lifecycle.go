@@ -0,0 +1,277 @@
+// Package lifecycle records the netboot progress of machines, the DHCP leases, iPXE binaries, scripts and ISOs
+// that Smee serves them, in the status of their Hardware. Writes are batched and throttled so that the many
+// requests of a netbooting machine, like the range requests of an ISO, don't cause a write storm.
+package lifecycle
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"github.com/tinkerbell/tinkerbell/pkg/data"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultInterval is the default minimum time between status updates of a Hardware.
+	DefaultInterval = 30 * time.Second
+	// DefaultMaxPending is the default maximum number of machines with events that aren't written yet.
+	DefaultMaxPending = 4096
+
+	// ProtocolHTTP and ProtocolTFTP are the protocols files are served with.
+	ProtocolHTTP = "http"
+	ProtocolTFTP = "tftp"
+)
+
+// Backend is the interface for reading and updating Hardware.
+type Backend interface {
+	FilterHardware(ctx context.Context, opts data.HardwareFilter) (*tinkerbell.Hardware, error)
+	UpdateHardware(ctx context.Context, hw *tinkerbell.Hardware, opts data.UpdateOptions) error
+}
+
+// Recorder records netboot events and writes them to the status of Hardware every Interval.
+// Events of machines that don't have Hardware are dropped. The methods of a nil Recorder do nothing.
+type Recorder struct {
+	Log logr.Logger
+	// Backend reads and updates Hardware. It must be set before Run is called.
+	Backend Backend
+	// Interval is the minimum time between status updates of a Hardware. Defaults to DefaultInterval.
+	Interval time.Duration
+	// MaxPending is the maximum number of machines with events that aren't written yet.
+	// Events of other machines are dropped until the next write. Defaults to DefaultMaxPending.
+	MaxPending int
+
+	mu      sync.Mutex
+	pending map[string]*events
+	now     func() time.Time
+}
+
+// events are the unwritten events of a machine, identified by its MAC address or, when unknown, its IP address.
+type events struct {
+	mac    net.HardwareAddr
+	ip     netip.Addr
+	status tinkerbell.NetbootStatus
+}
+
+// DHCP records a DHCP message sent to a machine. boot is true for the first DHCP discover of a netboot,
+// from the firmware of the machine, and increments the boot count.
+func (r *Recorder) DHCP(mac net.HardwareAddr, ip netip.Addr, iface, messageType string, boot bool) {
+	r.record(mac, ip, func(s *tinkerbell.NetbootStatus, now metav1.Time) {
+		s.DHCP = &tinkerbell.NetbootDHCP{Time: now, MessageType: messageType, MAC: mac.String(), IP: addrString(ip), Interface: iface}
+		if boot {
+			s.BootCount++
+		}
+	})
+}
+
+// Binary records an iPXE binary or boot file downloaded by a machine. mac is nil when unknown.
+func (r *Recorder) Binary(mac net.HardwareAddr, ip netip.Addr, name, protocol string) {
+	r.record(mac, ip, func(s *tinkerbell.NetbootStatus, now metav1.Time) {
+		s.Binary = &tinkerbell.NetbootFile{Time: now, Name: name, Protocol: protocol}
+	})
+}
+
+// Script records an iPXE script or GRUB configuration served to a machine. mac is nil when unknown.
+func (r *Recorder) Script(mac net.HardwareAddr, ip netip.Addr, name, protocol string) {
+	r.record(mac, ip, func(s *tinkerbell.NetbootStatus, now metav1.Time) {
+		s.Script = &tinkerbell.NetbootFile{Time: now, Name: name, Protocol: protocol}
+	})
+}
+
+// ISO records an ISO served to a machine.
+func (r *Recorder) ISO(mac net.HardwareAddr, ip netip.Addr, name string) {
+	r.record(mac, ip, func(s *tinkerbell.NetbootStatus, now metav1.Time) {
+		s.ISO = &tinkerbell.NetbootFile{Time: now, Name: name, Protocol: ProtocolHTTP}
+	})
+}
+
+func (r *Recorder) record(mac net.HardwareAddr, ip netip.Addr, update func(*tinkerbell.NetbootStatus, metav1.Time)) {
+	if r == nil {
+		return
+	}
+	ip = ip.Unmap()
+	key := mac.String()
+	if len(mac) == 0 {
+		if !ip.IsValid() {
+			return
+		}
+		key = ip.String()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending == nil {
+		r.pending = map[string]*events{}
+	}
+	e, ok := r.pending[key]
+	if !ok {
+		limit := r.MaxPending
+		if limit <= 0 {
+			limit = DefaultMaxPending
+		}
+		if len(r.pending) >= limit {
+			return
+		}
+		e = &events{mac: mac, ip: ip}
+		r.pending[key] = e
+	}
+	if ip.IsValid() {
+		e.ip = ip
+	}
+	update(&e.status, metav1.Time{Time: r.timeNow()})
+}
+
+// Run writes the recorded events every Interval until ctx is done.
+func (r *Recorder) Run(ctx context.Context) {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// Write the last events, even though ctx is done.
+			r.Flush(context.WithoutCancel(ctx))
+			return
+		case <-t.C:
+			r.Flush(ctx)
+		}
+	}
+}
+
+// Flush writes the recorded events to the status of Hardware.
+// Events of the same Hardware, recorded by MAC and by IP address, are written together.
+func (r *Recorder) Flush(ctx context.Context) {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = nil
+	r.mu.Unlock()
+
+	type update struct {
+		hw     *tinkerbell.Hardware
+		status tinkerbell.NetbootStatus
+	}
+	updates := map[string]*update{}
+	var order []string
+	for _, e := range pending {
+		hw := r.hardware(ctx, e)
+		if hw == nil {
+			continue
+		}
+		key := hw.Namespace + "/" + hw.Name
+		u, ok := updates[key]
+		if !ok {
+			u = &update{hw: hw}
+			updates[key] = u
+			order = append(order, key)
+		}
+		merge(&u.status, e.status)
+	}
+
+	for _, key := range order {
+		u := updates[key]
+		original := u.hw.DeepCopy()
+		if u.hw.Status.Netboot == nil {
+			u.hw.Status.Netboot = &tinkerbell.NetbootStatus{}
+		}
+		merge(u.hw.Status.Netboot, u.status)
+		if equality.Semantic.DeepEqual(original.Status, u.hw.Status) {
+			continue
+		}
+		if err := r.Backend.UpdateHardware(ctx, u.hw, data.UpdateOptions{StatusOnly: true, PatchFrom: original}); err != nil {
+			r.Log.Info("unable to update the netboot status of Hardware", "hardware", key, "error", err.Error())
+			continue
+		}
+		r.Log.V(1).Info("updated the netboot status of Hardware", "hardware", key, "lastStep", u.hw.Status.Netboot.LastStep)
+	}
+}
+
+// hardware returns the Hardware of the machine with the events, by its MAC address and then by its IP address.
+func (r *Recorder) hardware(ctx context.Context, e *events) *tinkerbell.Hardware {
+	if r.Backend == nil {
+		return nil
+	}
+	if len(e.mac) > 0 {
+		if hw, err := r.Backend.FilterHardware(ctx, data.HardwareFilter{ByMACAddress: e.mac.String()}); err == nil && hw != nil {
+			return hw
+		}
+	}
+	if e.ip.IsValid() {
+		if hw, err := r.Backend.FilterHardware(ctx, data.HardwareFilter{ByIPAddress: e.ip.String()}); err == nil && hw != nil {
+			return hw
+		}
+	}
+
+	return nil
+}
+
+// merge merges the steps in src into dst, keeping the most recent of each, and adds the boot counts.
+// LastStep of dst is set to the most recent step.
+func merge(dst *tinkerbell.NetbootStatus, src tinkerbell.NetbootStatus) {
+	dst.BootCount += src.BootCount
+	if src.DHCP != nil && (dst.DHCP == nil || !src.DHCP.Time.Before(&dst.DHCP.Time)) {
+		dst.DHCP = src.DHCP
+	}
+	dst.Binary = latest(dst.Binary, src.Binary)
+	dst.Script = latest(dst.Script, src.Script)
+	dst.ISO = latest(dst.ISO, src.ISO)
+
+	var last metav1.Time
+	step := func(s tinkerbell.NetbootStep, t *metav1.Time) {
+		if t != nil && !t.Before(&last) {
+			dst.LastStep, last = s, *t
+		}
+	}
+	if dst.DHCP != nil {
+		step(tinkerbell.NetbootStepDHCP, &dst.DHCP.Time)
+	}
+	for _, f := range []struct {
+		step tinkerbell.NetbootStep
+		file *tinkerbell.NetbootFile
+	}{
+		{tinkerbell.NetbootStepBinary, dst.Binary},
+		{tinkerbell.NetbootStepScript, dst.Script},
+		{tinkerbell.NetbootStepISO, dst.ISO},
+	} {
+		if f.file != nil {
+			step(f.step, &f.file.Time)
+		}
+	}
+}
+
+func latest(a, b *tinkerbell.NetbootFile) *tinkerbell.NetbootFile {
+	if b == nil || (a != nil && b.Time.Before(&a.Time)) {
+		return a
+	}
+	return b
+}
+
+func (r *Recorder) timeNow() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+// AddrFromRemote returns the IP address of an HTTP request's RemoteAddr, or the zero netip.Addr.
+func AddrFromRemote(remoteAddr string) netip.Addr {
+	ap, err := netip.ParseAddrPort(remoteAddr)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return ap.Addr().Unmap()
+}
+
+func addrString(ip netip.Addr) string {
+	if !ip.IsValid() {
+		return ""
+	}
+	return ip.String()
+}
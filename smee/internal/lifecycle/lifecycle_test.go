@@ -0,0 +1,144 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"github.com/tinkerbell/tinkerbell/pkg/data"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakeBackend struct {
+	hw      *tinkerbell.Hardware
+	updates []tinkerbell.HardwareStatus
+	err     error
+}
+
+func (f *fakeBackend) FilterHardware(_ context.Context, opts data.HardwareFilter) (*tinkerbell.Hardware, error) {
+	if opts.ByMACAddress == "52:54:00:12:34:01" || opts.ByIPAddress == "192.168.2.10" {
+		return f.hw.DeepCopy(), nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (f *fakeBackend) UpdateHardware(_ context.Context, hw *tinkerbell.Hardware, opts data.UpdateOptions) error {
+	if !opts.StatusOnly || opts.PatchFrom == nil {
+		return errors.New("expected a status patch")
+	}
+	if f.err != nil {
+		return f.err
+	}
+	f.updates = append(f.updates, hw.Status)
+	f.hw.Status = hw.Status
+	return nil
+}
+
+// clock returns increasing times, one second apart.
+func clock() func() time.Time {
+	t := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		t = t.Add(time.Second)
+		return t
+	}
+}
+
+func at(sec int) metav1.Time {
+	return metav1.Time{Time: time.Date(2026, 1, 1, 0, 0, sec, 0, time.UTC)}
+}
+
+func TestFlush(t *testing.T) {
+	mac := net.HardwareAddr{0x52, 0x54, 0x00, 0x12, 0x34, 0x01}
+	ip := netip.MustParseAddr("192.168.2.10")
+	b := &fakeBackend{hw: &tinkerbell.Hardware{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine1", Namespace: "tink"},
+		Status:     tinkerbell.HardwareStatus{Netboot: &tinkerbell.NetbootStatus{BootCount: 2}},
+	}}
+	r := &Recorder{Log: logr.Discard(), Backend: b, now: clock()}
+
+	r.DHCP(mac, ip, "eth0", "OFFER", true)
+	r.DHCP(mac, ip, "eth0", "ACK", false)
+	r.Binary(nil, ip, "ipxe.efi", ProtocolTFTP)
+	r.Script(mac, ip, "auto.ipxe", ProtocolHTTP)
+	r.Binary(nil, netip.MustParseAddr("192.168.2.99"), "ipxe.efi", ProtocolTFTP)
+	r.Flush(context.Background())
+
+	want := tinkerbell.HardwareStatus{Netboot: &tinkerbell.NetbootStatus{
+		LastStep:  tinkerbell.NetbootStepScript,
+		BootCount: 3,
+		DHCP:      &tinkerbell.NetbootDHCP{Time: at(2), MessageType: "ACK", MAC: mac.String(), IP: ip.String(), Interface: "eth0"},
+		Binary:    &tinkerbell.NetbootFile{Time: at(3), Name: "ipxe.efi", Protocol: ProtocolTFTP},
+		Script:    &tinkerbell.NetbootFile{Time: at(4), Name: "auto.ipxe", Protocol: ProtocolHTTP},
+	}}
+	if len(b.updates) != 1 {
+		t.Fatalf("got %d updates, want 1", len(b.updates))
+	}
+	if diff := cmp.Diff(want, b.updates[0]); diff != "" {
+		t.Fatal(diff)
+	}
+
+	// Nothing is written without new events.
+	r.Flush(context.Background())
+	if len(b.updates) != 1 {
+		t.Fatalf("got %d updates, want 1", len(b.updates))
+	}
+
+	r.ISO(mac, ip, "hook.iso")
+	r.Flush(context.Background())
+	if len(b.updates) != 2 {
+		t.Fatalf("got %d updates, want 2", len(b.updates))
+	}
+	got := b.updates[1].Netboot
+	if got.LastStep != tinkerbell.NetbootStepISO || got.ISO == nil || got.ISO.Name != "hook.iso" || got.BootCount != 3 {
+		t.Fatalf("unexpected netboot status: %+v", got)
+	}
+}
+
+func TestFlushError(t *testing.T) {
+	b := &fakeBackend{hw: &tinkerbell.Hardware{}, err: errors.New("read-only backend")}
+	r := &Recorder{Log: logr.Discard(), Backend: b}
+	r.Script(net.HardwareAddr{0x52, 0x54, 0x00, 0x12, 0x34, 0x01}, netip.Addr{}, "auto.ipxe", ProtocolHTTP)
+	r.Flush(context.Background())
+	if len(b.updates) != 0 {
+		t.Fatalf("got %d updates, want 0", len(b.updates))
+	}
+}
+
+func TestRecordMaxPending(t *testing.T) {
+	r := &Recorder{MaxPending: 2}
+	for i := range 4 {
+		r.Binary(nil, netip.AddrFrom4([4]byte{192, 168, 2, byte(i)}), "ipxe.efi", ProtocolTFTP)
+	}
+	r.Binary(nil, netip.Addr{}, "ipxe.efi", ProtocolTFTP)
+	if len(r.pending) != 2 {
+		t.Fatalf("got %d pending machines, want 2", len(r.pending))
+	}
+}
+
+func TestNilRecorder(_ *testing.T) {
+	var r *Recorder
+	r.DHCP(nil, netip.Addr{}, "", "OFFER", true)
+	r.Binary(nil, netip.Addr{}, "", ProtocolHTTP)
+	r.Script(nil, netip.Addr{}, "", ProtocolHTTP)
+	r.ISO(nil, netip.Addr{}, "")
+}
+
+func TestAddrFromRemote(t *testing.T) {
+	tests := map[string]string{
+		"192.168.2.10:1234":          "192.168.2.10",
+		"[::ffff:192.168.2.10]:1234": "192.168.2.10",
+		"[fe80::1]:1234":             "fe80::1",
+		"invalid":                    "invalid IP",
+	}
+	for in, want := range tests {
+		if got := AddrFromRemote(in).String(); got != want {
+			t.Errorf("AddrFromRemote(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
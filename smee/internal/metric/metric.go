@@ -13,7 +13,9 @@ var Registry = prometheus.NewRegistry()
 var factory = promauto.With(Registry)
 
 var (
-	DHCPTotal *prometheus.CounterVec
+	DHCPTotal                     *prometheus.CounterVec
+	DHCPRateLimited               *prometheus.CounterVec
+	DHCPHardwareNotFoundCacheHits prometheus.Counter
 
 	DiscoverDuration    prometheus.ObserverVec
 	HardwareDiscovers   *prometheus.CounterVec
@@ -22,6 +24,12 @@ var (
 	JobDuration    prometheus.ObserverVec
 	JobsTotal      *prometheus.CounterVec
 	JobsInProgress *prometheus.GaugeVec
+
+	ISOCacheRequests      *prometheus.CounterVec
+	ISOBytesServed        *prometheus.CounterVec
+	ISOCacheSize          prometheus.Gauge
+	ISOCacheEvictions     prometheus.Counter
+	ISOGeneratedEvictions prometheus.Counter
 )
 
 func Init() {
@@ -43,6 +51,16 @@ func Init() {
 	}
 	initCounterLabels(DHCPTotal, labelValues)
 
+	DHCPRateLimited = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "dhcp_rate_limited_total",
+		Help: "Number of DHCP packets dropped by the rate limits, by the limit exceeded.",
+	}, []string{"reason"})
+	DHCPHardwareNotFoundCacheHits = factory.NewCounter(prometheus.CounterOpts{
+		Name: "dhcp_hardware_not_found_cache_hits_total",
+		Help: "Number of DHCP Hardware lookups answered from the cache of lookups that found no Hardware.",
+	})
+	initCounterLabels(DHCPRateLimited, []prometheus.Labels{{"reason": "client"}, {"reason": "global"}})
+
 	labelValues = []prometheus.Labels{
 		{"from": "dhcp"},
 		{"from": "ip"},
@@ -100,6 +118,30 @@ func Init() {
 	initObserverLabels(JobDuration, labelValues)
 	initCounterLabels(JobsTotal, labelValues)
 	initGaugeLabels(JobsInProgress, labelValues)
+
+	ISOCacheRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "iso_cache_requests_total",
+		Help: "Number of ISO requests by whether the ISO was served from the cache.",
+	}, []string{"result"})
+	ISOBytesServed = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "iso_bytes_served_total",
+		Help: "Number of ISO bytes served, from the cache, proxied from upstream or generated.",
+	}, []string{"source"})
+	ISOCacheSize = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "iso_cache_size_bytes",
+		Help: "Total size of the cached ISOs.",
+	})
+	ISOCacheEvictions = factory.NewCounter(prometheus.CounterOpts{
+		Name: "iso_cache_evictions_total",
+		Help: "Number of ISOs evicted from the cache.",
+	})
+	ISOGeneratedEvictions = factory.NewCounter(prometheus.CounterOpts{
+		Name: "iso_generated_evictions_total",
+		Help: "Number of generated ISOs removed to stay within the size limit.",
+	})
+
+	initCounterLabels(ISOCacheRequests, []prometheus.Labels{{"result": "hit"}, {"result": "miss"}})
+	initCounterLabels(ISOBytesServed, []prometheus.Labels{{"source": "cache"}, {"source": "upstream"}, {"source": "generated"}})
 }
 
 func initCounterLabels(m *prometheus.CounterVec, l []prometheus.Labels) {
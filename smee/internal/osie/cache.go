@@ -0,0 +1,259 @@
+// Package osie serves OSIE (HookOS) artifacts, like the kernel and initrd, through a disk-backed cache
+// and resolves the OSIE settings, like the HookOS version and kernel parameters, of each machine.
+package osie
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/sync/singleflight"
+)
+
+// defaultFetchTimeout is used when Cache.FetchTimeout is zero.
+const defaultFetchTimeout = 10 * time.Minute
+
+// defaultClient is used when Cache.Client is nil.
+var defaultClient = &http.Client{Timeout: defaultFetchTimeout}
+
+// errNotFound is returned when an artifact doesn't exist upstream or isn't listed in the checksum file.
+var errNotFound = errors.New("artifact not found")
+
+// Cache is an http.Handler that serves OSIE artifacts from a directory.
+// Artifacts that aren't in the directory are fetched from Upstream on first request, verified and stored.
+// Concurrent requests for the same artifact wait for a single fetch.
+type Cache struct {
+	Logger logr.Logger
+	// Upstream is the URL where the OSIE artifacts are located.
+	Upstream *url.URL
+	// Prefix is removed from request paths. The rest of the path is the artifact name,
+	// optionally preceded by a HookOS version directory, for example <prefix>/v0.10.0/vmlinuz-x86_64.
+	Prefix string
+	// Dir is the directory in which artifacts are cached. It is created if it doesn't exist.
+	Dir string
+	// ChecksumFile is the name of a file, relative to Upstream, in sha256sum or sha512sum format that lists the checksums of the artifacts.
+	// When set, artifacts that aren't listed are not served and fetched artifacts whose checksum doesn't match are discarded.
+	ChecksumFile string
+	// Client is the HTTP client used to fetch artifacts. Defaults to a client with a timeout of 10 minutes.
+	Client *http.Client
+	// FetchTimeout is the longest fetching an artifact from upstream can take, so that a stalled upstream doesn't
+	// keep requests for the artifact waiting forever. Defaults to 10 minutes.
+	FetchTimeout time.Duration
+
+	group singleflight.Group
+}
+
+// ServeHTTP serves the artifact named by the request path.
+// Range requests are supported.
+func (c *Cache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	name, err := artifactName(strings.TrimPrefix(r.URL.Path, c.Prefix))
+	if err != nil {
+		c.Logger.Info("invalid osie artifact request", "path", r.URL.Path, "error", err.Error())
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	f, err := c.open(r.Context(), name)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, errNotFound) {
+			status = http.StatusNotFound
+		}
+		c.Logger.Info("unable to serve osie artifact", "artifact", name, "error", err.Error())
+		w.WriteHeader(status)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		c.Logger.Error(err, "unable to stat cached osie artifact", "artifact", name)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, name, fi.ModTime(), f)
+}
+
+// artifactName returns the artifact name, a file name optionally preceded by a version directory, from a request path.
+// Hidden names are rejected as they're used for in-progress downloads.
+func artifactName(p string) (string, error) {
+	name := strings.TrimPrefix(path.Clean("/"+p), "/")
+	elems := strings.Split(name, "/")
+	if len(elems) > 2 {
+		return "", fmt.Errorf("invalid artifact name: %q", name)
+	}
+	for _, e := range elems {
+		if e == "" || strings.HasPrefix(e, ".") {
+			return "", fmt.Errorf("invalid artifact name: %q", name)
+		}
+	}
+
+	return name, nil
+}
+
+// open returns the cached artifact, fetching it first when it isn't cached.
+func (c *Cache) open(ctx context.Context, name string) (*os.File, error) {
+	p := filepath.Join(c.Dir, filepath.FromSlash(name))
+	f, err := os.Open(p)
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	// The fetch is shared by all waiting requests so it must not be canceled by any single one of them,
+	// but each request stops waiting for it when it's canceled.
+	ch := c.group.DoChan(name, func() (any, error) {
+		if _, err := os.Stat(p); err == nil {
+			return nil, nil
+		}
+		fctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cmp.Or(c.FetchTimeout, defaultFetchTimeout))
+		defer cancel()
+		return nil, c.fetch(fctx, name)
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	return os.Open(p)
+}
+
+// fetch downloads the artifact from upstream into the cache directory.
+// The artifact is written to a temporary file that is renamed once complete and verified,
+// so that partial downloads are never served.
+// Versioned artifacts are verified with the checksum file in the version directory.
+func (c *Cache) fetch(ctx context.Context, name string) error {
+	dir, file := path.Split(name)
+	var want string
+	if c.ChecksumFile != "" {
+		sums, err := c.checksums(ctx, path.Join(dir, c.ChecksumFile))
+		if err != nil {
+			return err
+		}
+		s, ok := sums[file]
+		if !ok {
+			return fmt.Errorf("%w: %q is not listed in %v", errNotFound, name, c.ChecksumFile)
+		}
+		want = s
+	}
+
+	resp, err := c.get(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	d := filepath.Join(c.Dir, filepath.FromSlash(dir))
+	if err := os.MkdirAll(d, 0o755); err != nil {
+		return fmt.Errorf("unable to create cache directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(d, "."+file+".*")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	var h hash.Hash = sha256.New()
+	if len(want) == hex.EncodedLen(sha512.Size) {
+		h = sha512.New()
+	}
+	n, err := io.Copy(io.MultiWriter(tmp, h), resp.Body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to download %v: %w", name, err)
+	}
+	if resp.ContentLength >= 0 && n != resp.ContentLength {
+		return fmt.Errorf("incomplete download of %v: got %d bytes, want %d", name, n, resp.ContentLength)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); want != "" && !strings.EqualFold(got, want) {
+		return fmt.Errorf("checksum mismatch for %v: got %v, want %v", name, got, want)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(d, file)); err != nil {
+		return fmt.Errorf("unable to store %v: %w", name, err)
+	}
+	c.Logger.Info("cached osie artifact", "artifact", name, "bytes", n, "verified", want != "")
+
+	return nil
+}
+
+// checksums fetches and parses a checksum file.
+// Each line is a hex encoded checksum followed by the artifact name, as written by sha256sum and sha512sum.
+func (c *Cache) checksums(ctx context.Context, name string) (map[string]string, error) {
+	resp, err := c.get(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch checksum file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	sums := map[string]string{}
+	s := bufio.NewScanner(resp.Body)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		// sha256sum marks files read in binary mode with a leading "*".
+		sums[path.Base(strings.TrimPrefix(fields[1], "*"))] = fields[0]
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read checksum file: %w", err)
+	}
+
+	return sums, nil
+}
+
+// get requests name from upstream and returns the response when the status is 200 OK.
+func (c *Cache) get(ctx context.Context, name string) (*http.Response, error) {
+	if c.Upstream == nil || c.Upstream.String() == "" {
+		return nil, errors.New("no upstream URL configured")
+	}
+	u := c.Upstream.JoinPath(strings.Split(name, "/")...)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	client := c.Client
+	if client == nil {
+		client = defaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %v", errNotFound, u.Redacted())
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status from %v: %v", u.Redacted(), resp.Status)
+	}
+}
@@ -0,0 +1,235 @@
+package osie
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+)
+
+const kernel = "this is a kernel"
+
+func sum(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+// upstream serves files and counts the requests for each.
+type upstream struct {
+	files map[string]string
+	mu    sync.Mutex
+	hits  map[string]int
+}
+
+func (u *upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	u.hits[r.URL.Path]++
+	u.mu.Unlock()
+	f, ok := u.files[r.URL.Path]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	fmt.Fprint(w, f)
+}
+
+func newCache(t *testing.T, files map[string]string, checksumFile string) (*Cache, *upstream) {
+	t.Helper()
+	up := &upstream{files: files, hits: map[string]int{}}
+	srv := httptest.NewServer(up)
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL + "/hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Cache{Logger: logr.Discard(), Upstream: u, Prefix: "/osie/", Dir: filepath.Join(t.TempDir(), "cache"), ChecksumFile: checksumFile}, up
+}
+
+func TestCache(t *testing.T) {
+	tests := map[string]struct {
+		files        map[string]string
+		checksumFile string
+		path         string
+		wantStatus   int
+		wantBody     string
+		wantCached   bool
+	}{
+		"fetched and cached": {
+			files:      map[string]string{"/hook/vmlinuz-x86_64": kernel},
+			path:       "/osie/vmlinuz-x86_64",
+			wantStatus: http.StatusOK,
+			wantBody:   kernel,
+			wantCached: true,
+		},
+		"checksum verified": {
+			files: map[string]string{
+				"/hook/vmlinuz-x86_64": kernel,
+				"/hook/checksum.txt":   sum(kernel) + "  vmlinuz-x86_64\n" + sum("other") + " *out/initramfs-x86_64\n",
+			},
+			checksumFile: "checksum.txt",
+			path:         "/osie/vmlinuz-x86_64",
+			wantStatus:   http.StatusOK,
+			wantBody:     kernel,
+			wantCached:   true,
+		},
+		"checksum mismatch": {
+			files: map[string]string{
+				"/hook/initramfs-x86_64": "corrupted",
+				"/hook/checksum.txt":     sum(kernel) + "  vmlinuz-x86_64\n" + sum("initrd") + " *out/initramfs-x86_64\n",
+			},
+			checksumFile: "checksum.txt",
+			path:         "/osie/initramfs-x86_64",
+			wantStatus:   http.StatusBadGateway,
+		},
+		"not in checksum file": {
+			files: map[string]string{
+				"/hook/vmlinuz-aarch64": kernel,
+				"/hook/checksum.txt":    sum(kernel) + "  vmlinuz-x86_64\n",
+			},
+			checksumFile: "checksum.txt",
+			path:         "/osie/vmlinuz-aarch64",
+			wantStatus:   http.StatusNotFound,
+		},
+		"checksum file not found": {
+			files:        map[string]string{"/hook/vmlinuz-x86_64": kernel},
+			checksumFile: "checksum.txt",
+			path:         "/osie/vmlinuz-x86_64",
+			wantStatus:   http.StatusNotFound,
+		},
+		"not found upstream": {
+			files:      map[string]string{},
+			path:       "/osie/vmlinuz-x86_64",
+			wantStatus: http.StatusNotFound,
+		},
+		"versioned": {
+			files: map[string]string{
+				"/hook/v0.10.0/vmlinuz-x86_64": kernel,
+				"/hook/v0.10.0/checksum.txt":   sum(kernel) + "  vmlinuz-x86_64\n",
+			},
+			checksumFile: "checksum.txt",
+			path:         "/osie/v0.10.0/vmlinuz-x86_64",
+			wantStatus:   http.StatusOK,
+			wantBody:     kernel,
+			wantCached:   true,
+		},
+		"nested too deep": {
+			files:      map[string]string{"/hook/a/b/vmlinuz-x86_64": kernel},
+			path:       "/osie/a/b/vmlinuz-x86_64",
+			wantStatus: http.StatusNotFound,
+		},
+		"hidden file": {
+			files:      map[string]string{"/hook/.vmlinuz-x86_64": kernel},
+			path:       "/osie/.vmlinuz-x86_64",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, _ := newCache(t, tt.files, tt.checksumFile)
+			w := httptest.NewRecorder()
+			c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" {
+				if diff := cmp.Diff(tt.wantBody, w.Body.String()); diff != "" {
+					t.Fatal(diff)
+				}
+			}
+			_, err := os.Stat(filepath.Join(c.Dir, filepath.FromSlash(strings.TrimPrefix(tt.path, c.Prefix))))
+			if cached := err == nil; cached != tt.wantCached {
+				t.Fatalf("cached = %v, want %v", cached, tt.wantCached)
+			}
+		})
+	}
+}
+
+func TestCacheFetchesOnce(t *testing.T) {
+	c, up := newCache(t, map[string]string{"/hook/vmlinuz-x86_64": kernel}, "")
+
+	var wg sync.WaitGroup
+	var ok atomic.Int32
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/osie/vmlinuz-x86_64", nil))
+			if w.Code == http.StatusOK && w.Body.String() == kernel {
+				ok.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if ok.Load() != 10 {
+		t.Fatalf("got %d successful responses, want 10", ok.Load())
+	}
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/osie/vmlinuz-x86_64", nil))
+	if hits := up.hits["/hook/vmlinuz-x86_64"]; hits != 1 {
+		t.Fatalf("got %d upstream requests, want 1", hits)
+	}
+}
+
+func TestCacheRange(t *testing.T) {
+	c, _ := newCache(t, map[string]string{"/hook/vmlinuz-x86_64": kernel}, "")
+	r := httptest.NewRequest(http.MethodGet, "/osie/vmlinuz-x86_64", nil)
+	r.Header.Set("Range", "bytes=5-6")
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, r)
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusPartialContent)
+	}
+	if diff := cmp.Diff("is", w.Body.String()); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestCacheStalledUpstream(t *testing.T) {
+	stalled := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-stalled:
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(stalled) })
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("fetch times out", func(t *testing.T) {
+		c := &Cache{Logger: logr.Discard(), Upstream: u, Prefix: "/osie/", Dir: t.TempDir(), FetchTimeout: 100 * time.Millisecond}
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/osie/vmlinuz-x86_64", nil))
+		if w.Code != http.StatusBadGateway {
+			t.Fatalf("got status %d, want %d", w.Code, http.StatusBadGateway)
+		}
+	})
+	t.Run("canceled request stops waiting", func(t *testing.T) {
+		c := &Cache{Logger: logr.Discard(), Upstream: u, Prefix: "/osie/", Dir: t.TempDir(), FetchTimeout: time.Minute}
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, httptest.NewRequestWithContext(ctx, http.MethodGet, "/osie/vmlinuz-x86_64", nil))
+		if w.Code != http.StatusBadGateway {
+			t.Fatalf("got status %d, want %d", w.Code, http.StatusBadGateway)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package osie
+
+import (
+	"net/url"
+	"path"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Settings are the OSIE settings of a single machine.
+type Settings struct {
+	// Version is the HookOS version, a directory under the OSIE URL.
+	Version string
+	// KernelParams are extra kernel parameters.
+	KernelParams []string
+	// Consoles replace the default consoles, for example ttyS0,115200.
+	Consoles []string
+}
+
+// Profile holds the OSIE settings for all Hardware that matches it.
+type Profile struct {
+	// Facility matches Hardware with this facility code. Empty matches Hardware in any facility.
+	Facility string
+	// Selector matches Hardware by its labels. Nil matches Hardware with any labels.
+	Selector labels.Selector
+	Settings
+}
+
+// Matches reports whether Hardware with the facility code and labels matches the profile.
+func (p Profile) Matches(facility string, lbls map[string]string) bool {
+	if p.Facility != "" && p.Facility != facility {
+		return false
+	}
+
+	return p.Selector == nil || p.Selector.Matches(labels.Set(lbls))
+}
+
+// Resolve returns the settings of a machine.
+// The settings of all matching profiles are applied in order, followed by the settings of the Hardware itself.
+// Kernel parameters accumulate, while Version and Consoles are replaced by the last one that is set.
+func Resolve(profiles []Profile, facility string, lbls map[string]string, hw Settings) Settings {
+	var s Settings
+	for _, p := range profiles {
+		if p.Matches(facility, lbls) {
+			s = s.merge(p.Settings)
+		}
+	}
+
+	return s.merge(hw)
+}
+
+func (s Settings) merge(o Settings) Settings {
+	if o.Version != "" {
+		s.Version = o.Version
+	}
+	if len(o.Consoles) > 0 {
+		s.Consoles = o.Consoles
+	}
+	s.KernelParams = append(s.KernelParams[:len(s.KernelParams):len(s.KernelParams)], o.KernelParams...)
+
+	return s
+}
+
+// ConsoleParams returns the console kernel parameters, or nil when no consoles are set.
+func (s Settings) ConsoleParams() []string {
+	var params []string
+	for _, c := range s.Consoles {
+		params = append(params, "console="+c)
+	}
+
+	return params
+}
+
+// VersionURL returns the URL of the HookOS version under base.
+// When no version is set, base is returned unchanged.
+func (s Settings) VersionURL(base string) string {
+	if s.Version == "" || base == "" {
+		return base
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return base
+	}
+
+	return u.JoinPath(s.Version).String()
+}
+
+// VersionFileURL returns the URL of a file, like an ISO, in the HookOS version directory next to it.
+// For example, http://example.com/hook.iso becomes http://example.com/<version>/hook.iso.
+// When no version is set, fileURL is returned unchanged.
+func (s Settings) VersionFileURL(fileURL string) string {
+	if s.Version == "" || fileURL == "" {
+		return fileURL
+	}
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return fileURL
+	}
+	dir, file := path.Split(u.Path)
+	u.Path = path.Join(dir, s.Version, file)
+	u.RawPath = ""
+
+	return u.String()
+}
@@ -0,0 +1,71 @@
+package osie
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestResolve(t *testing.T) {
+	rackA, err := labels.Parse("rack=a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	profiles := []Profile{
+		{Facility: "lab", Settings: Settings{Version: "v1", KernelParams: []string{"a=1"}, Consoles: []string{"ttyS0,115200"}}},
+		{Selector: rackA, Settings: Settings{Version: "v2", KernelParams: []string{"b=2"}}},
+		{Facility: "lab", Selector: rackA, Settings: Settings{KernelParams: []string{"c=3"}}},
+	}
+	tests := map[string]struct {
+		facility string
+		labels   map[string]string
+		hw       Settings
+		want     Settings
+	}{
+		"no match": {
+			facility: "onprem",
+			hw:       Settings{KernelParams: []string{"hw=1"}},
+			want:     Settings{KernelParams: []string{"hw=1"}},
+		},
+		"facility": {
+			facility: "lab",
+			want:     Settings{Version: "v1", KernelParams: []string{"a=1"}, Consoles: []string{"ttyS0,115200"}},
+		},
+		"labels": {
+			labels: map[string]string{"rack": "a"},
+			want:   Settings{Version: "v2", KernelParams: []string{"b=2"}},
+		},
+		"all profiles and hardware": {
+			facility: "lab",
+			labels:   map[string]string{"rack": "a"},
+			hw:       Settings{Version: "v3", KernelParams: []string{"hw=1"}, Consoles: []string{"tty0"}},
+			want:     Settings{Version: "v3", KernelParams: []string{"a=1", "b=2", "c=3", "hw=1"}, Consoles: []string{"tty0"}},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := Resolve(profiles, tt.facility, tt.labels, tt.hw)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+	// Resolving must not modify the profiles.
+	if diff := cmp.Diff([]string{"a=1"}, profiles[0].KernelParams); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestVersionURL(t *testing.T) {
+	s := Settings{Version: "v0.10.0"}
+	if got, want := s.VersionURL("http://example.com/hook"), "http://example.com/hook/v0.10.0"; got != want {
+		t.Errorf("VersionURL() = %q, want %q", got, want)
+	}
+	if got, want := s.VersionFileURL("http://example.com/hook/hook.iso?a=b"), "http://example.com/hook/v0.10.0/hook.iso?a=b"; got != want {
+		t.Errorf("VersionFileURL() = %q, want %q", got, want)
+	}
+	if got, want := (Settings{}).VersionFileURL("http://example.com/hook.iso"), "http://example.com/hook.iso"; got != want {
+		t.Errorf("VersionFileURL() = %q, want %q", got, want)
+	}
+}
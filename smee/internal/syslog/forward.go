@@ -0,0 +1,283 @@
+package syslog
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	// forwardQueueSize is the number of messages queued per forwarder. Messages are dropped when the queue is full.
+	forwardQueueSize = 4096
+	// forwardBatchSize is the maximum number of messages sent to a forwarder at once.
+	forwardBatchSize = 256
+	forwardTimeout   = 10 * time.Second
+)
+
+// Forwarder sends messages to an upstream log sink.
+type Forwarder interface {
+	Forward(ctx context.Context, entries []Entry) error
+}
+
+// NewForwarder returns a Forwarder for an upstream URL. Supported schemes are:
+//   - udp, tcp and tls for a syslog server, for example tls://logs.example.com:6514.
+//   - otlp and otlp+insecure for an OpenTelemetry logs endpoint using gRPC, with and without TLS.
+//
+// When the port is omitted the default port of the scheme is used: 514, 6514 or 4317.
+func NewForwarder(u *url.URL) (Forwarder, error) {
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("no host in syslog forward URL: %q", u.Redacted())
+	}
+	hostPort := func(port string) string {
+		if u.Port() != "" {
+			port = u.Port()
+		}
+		return net.JoinHostPort(u.Hostname(), port)
+	}
+	switch u.Scheme {
+	case "udp", "tcp":
+		return &SyslogForwarder{Network: u.Scheme, Addr: hostPort("514")}, nil
+	case "tls":
+		return &SyslogForwarder{Network: u.Scheme, Addr: hostPort("6514"), TLSConfig: &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}}, nil
+	case "otlp":
+		return &OTLPForwarder{Endpoint: hostPort("4317")}, nil
+	case "otlp+insecure":
+		return &OTLPForwarder{Endpoint: hostPort("4317"), Insecure: true}, nil
+	default:
+		return nil, fmt.Errorf("unsupported syslog forward URL scheme: %q, must be one of [udp, tcp, tls, otlp, otlp+insecure]", u.Scheme)
+	}
+}
+
+// queue decouples a Forwarder from the receiver, so that a slow or unavailable upstream doesn't delay receiving messages.
+type queue struct {
+	name      string
+	forwarder Forwarder
+	entries   chan Entry
+	log       logr.Logger
+}
+
+func newQueue(name string, f Forwarder, log logr.Logger) *queue {
+	return &queue{name: name, forwarder: f, entries: make(chan Entry, forwardQueueSize), log: log}
+}
+
+// add queues a message, dropping it when the queue is full.
+func (q *queue) add(e Entry) {
+	select {
+	case q.entries <- e:
+	default:
+		q.log.V(1).Info("syslog forward queue is full, dropping message", "forwarder", q.name)
+	}
+}
+
+// run sends queued messages in batches until ctx is done.
+// A batch that can't be sent is dropped, upstreams are expected to be available most of the time.
+func (q *queue) run(ctx context.Context) {
+	batch := make([]Entry, 0, forwardBatchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-q.entries:
+			batch = append(batch[:0], e)
+		}
+	fill:
+		for len(batch) < forwardBatchSize {
+			select {
+			case e := <-q.entries:
+				batch = append(batch, e)
+			default:
+				break fill
+			}
+		}
+		fctx, cancel := context.WithTimeout(ctx, forwardTimeout)
+		if err := q.forwarder.Forward(fctx, batch); err != nil {
+			q.log.Info("unable to forward syslog messages", "forwarder", q.name, "messages", len(batch), "error", err.Error())
+		}
+		cancel()
+	}
+}
+
+// SyslogForwarder forwards messages to a syslog server in RFC 5424 format.
+// Messages sent over tcp and tls are framed with octet counting, as described in RFC 6587.
+// It is not safe for concurrent use.
+type SyslogForwarder struct {
+	// Network is udp, tcp or tls.
+	Network string
+	// Addr is the host:port of the syslog server.
+	Addr string
+	// TLSConfig is used when Network is tls.
+	TLSConfig *tls.Config
+
+	conn net.Conn
+}
+
+// Forward sends the messages, connecting to the server first when not connected.
+func (f *SyslogForwarder) Forward(ctx context.Context, entries []Entry) error {
+	if f.conn == nil {
+		var err error
+		if f.conn, err = f.dial(ctx); err != nil {
+			return err
+		}
+	}
+	if d, ok := ctx.Deadline(); ok {
+		_ = f.conn.SetWriteDeadline(d)
+	}
+	for _, e := range entries {
+		msg := formatRFC5424(e)
+		if f.Network != "udp" {
+			msg = strconv.Itoa(len(msg)) + " " + msg
+		}
+		if _, err := f.conn.Write([]byte(msg)); err != nil {
+			f.conn.Close()
+			f.conn = nil
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *SyslogForwarder) dial(ctx context.Context) (net.Conn, error) {
+	switch f.Network {
+	case "udp", "tcp":
+		var d net.Dialer
+		return d.DialContext(ctx, f.Network, f.Addr)
+	case "tls":
+		d := tls.Dialer{Config: f.TLSConfig}
+		return d.DialContext(ctx, "tcp", f.Addr)
+	default:
+		return nil, fmt.Errorf("unsupported network: %q", f.Network)
+	}
+}
+
+// formatRFC5424 formats a message as RFC 5424 syslog message.
+// The hostname is the name of the Hardware when known, the source IP address is added as origin structured data.
+func formatRFC5424(e Entry) string {
+	hostname := e.Hostname
+	if _, name, ok := strings.Cut(e.Hardware, "/"); ok {
+		hostname = name
+	}
+	if hostname == "" {
+		hostname = e.Host
+	}
+	nilValue := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %s %s [origin ip=\"%s\"] %s",
+		e.Priority, e.Time.UTC().Format(time.RFC3339Nano), nilValue(hostname), nilValue(e.App), nilValue(e.ProcID), nilValue(e.MsgID), e.Host, e.Message)
+}
+
+// OTLPForwarder forwards messages to an OpenTelemetry logs endpoint using gRPC.
+// It is not safe for concurrent use.
+type OTLPForwarder struct {
+	// Endpoint is the host:port of the OTLP gRPC endpoint.
+	Endpoint string
+	// Insecure disables TLS.
+	Insecure bool
+
+	client collogspb.LogsServiceClient
+}
+
+// Forward exports the messages as OpenTelemetry log records.
+func (f *OTLPForwarder) Forward(ctx context.Context, entries []Entry) error {
+	if f.client == nil {
+		creds := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+		if f.Insecure {
+			creds = insecure.NewCredentials()
+		}
+		conn, err := grpc.NewClient(f.Endpoint, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return err
+		}
+		f.client = collogspb.NewLogsServiceClient(conn)
+	}
+	resp, err := f.client.Export(ctx, otlpRequest(entries))
+	if err != nil {
+		return err
+	}
+	if ps := resp.GetPartialSuccess(); ps != nil && ps.GetRejectedLogRecords() > 0 {
+		return errors.New(ps.GetErrorMessage())
+	}
+
+	return nil
+}
+
+func otlpRequest(entries []Entry) *collogspb.ExportLogsServiceRequest {
+	str := func(k, v string) *commonpb.KeyValue {
+		return &commonpb.KeyValue{Key: k, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}}
+	}
+	records := make([]*logspb.LogRecord, 0, len(entries))
+	for _, e := range entries {
+		attrs := []*commonpb.KeyValue{str("client.address", e.Host)}
+		for _, kv := range [][2]string{
+			{"host.name", e.Hostname},
+			{"syslog.facility", e.Facility},
+			{"syslog.app_name", e.App},
+			{"syslog.procid", e.ProcID},
+			{"syslog.msgid", e.MsgID},
+			{"tinkerbell.mac", e.MAC},
+			{"tinkerbell.hardware", e.Hardware},
+		} {
+			if kv[1] != "" {
+				attrs = append(attrs, str(kv[0], kv[1]))
+			}
+		}
+		records = append(records, &logspb.LogRecord{
+			TimeUnixNano:         uint64(e.Time.UnixNano()),     //nolint:gosec // Times before 1970 are not expected.
+			ObservedTimeUnixNano: uint64(time.Now().UnixNano()), //nolint:gosec // Times before 1970 are not expected.
+			SeverityNumber:       otlpSeverity(e),
+			SeverityText:         e.Severity,
+			Body:                 &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: e.Message}},
+			Attributes:           attrs,
+		})
+	}
+
+	return &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource:  &resourcepb.Resource{Attributes: []*commonpb.KeyValue{str("service.name", "smee")}},
+			ScopeLogs: []*logspb.ScopeLogs{{Scope: &commonpb.InstrumentationScope{Name: "smee/syslog"}, LogRecords: records}},
+		}},
+	}
+}
+
+// otlpSeverity maps a syslog severity to an OpenTelemetry severity number.
+func otlpSeverity(e Entry) logspb.SeverityNumber {
+	if e.Severity == "" {
+		return logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED
+	}
+	switch severity(e.Priority % 8) {
+	case EMERG:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL
+	case ALERT, CRIT:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR2
+	case ERR:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+	case WARNING:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	case NOTICE:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO2
+	case INFO:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	default:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG
+	}
+}
@@ -0,0 +1,173 @@
+package syslog
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+func TestNewForwarder(t *testing.T) {
+	tests := map[string]struct {
+		url     string
+		want    Forwarder
+		wantErr bool
+	}{
+		"udp default port":  {url: "udp://192.168.2.1", want: &SyslogForwarder{Network: "udp", Addr: "192.168.2.1:514"}},
+		"tcp":               {url: "tcp://logs.example.com:1514", want: &SyslogForwarder{Network: "tcp", Addr: "logs.example.com:1514"}},
+		"otlp":              {url: "otlp://collector", want: &OTLPForwarder{Endpoint: "collector:4317"}},
+		"otlp insecure":     {url: "otlp+insecure://collector:4318", want: &OTLPForwarder{Endpoint: "collector:4318", Insecure: true}},
+		"unsupported":       {url: "http://collector", wantErr: true},
+		"no host":           {url: "udp:///", wantErr: true},
+		"tls default port":  {url: "tls://logs.example.com"},
+		"ipv6 default port": {url: "udp://[fe80::1]", want: &SyslogForwarder{Network: "udp", Addr: "[fe80::1]:514"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := NewForwarder(u)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewForwarder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want == nil {
+				return
+			}
+			if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(SyslogForwarder{}, OTLPForwarder{})); diff != "" {
+				t.Errorf("unexpected forwarder (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFormatRFC5424(t *testing.T) {
+	ts := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		entry Entry
+		want  string
+	}{
+		"hardware name as hostname": {
+			entry: Entry{Time: ts, Host: "192.168.2.10", Hardware: "tinkerbell/machine1", Hostname: "localhost", Priority: 30, App: "dhcpcd", ProcID: "42", Message: "lease acquired"},
+			want:  `<30>1 2025-01-01T12:00:00Z machine1 dhcpcd 42 - [origin ip="192.168.2.10"] lease acquired`,
+		},
+		"reported hostname": {
+			entry: Entry{Time: ts, Host: "192.168.2.10", Hostname: "hook", Priority: 13, Message: "hello"},
+			want:  `<13>1 2025-01-01T12:00:00Z hook - - - [origin ip="192.168.2.10"] hello`,
+		},
+		"source address as hostname": {
+			entry: Entry{Time: ts, Host: "192.168.2.10", Priority: 13, Message: "hello"},
+			want:  `<13>1 2025-01-01T12:00:00Z 192.168.2.10 - - - [origin ip="192.168.2.10"] hello`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := formatRFC5424(tt.entry); got != tt.want {
+				t.Errorf("formatRFC5424() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyslogForwarderTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var msgs []string
+		for range 2 {
+			n, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			size, err := strconv.Atoi(strings.TrimSpace(n))
+			if err != nil {
+				return
+			}
+			buf := make([]byte, size)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			msgs = append(msgs, string(buf))
+		}
+		received <- msgs
+	}()
+
+	f := &SyslogForwarder{Network: "tcp", Addr: ln.Addr().String()}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	entries := []Entry{
+		{Host: "192.168.2.10", Priority: 13, Message: "one"},
+		{Host: "192.168.2.10", Priority: 13, Message: "two"},
+	}
+	if err := f.Forward(ctx, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-received:
+		want := []string{formatRFC5424(entries[0]), formatRFC5424(entries[1])}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("unexpected messages (-want +got):\n%s", diff)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for messages")
+	}
+}
+
+func TestOTLPRequest(t *testing.T) {
+	e := Entry{Time: time.Unix(10, 0), Host: "192.168.2.10", MAC: "de:ad:be:ef:00:01", Priority: 11, Severity: "ERR", App: "tink-agent", Message: "failed"}
+	req := otlpRequest([]Entry{e})
+
+	records := req.GetResourceLogs()[0].GetScopeLogs()[0].GetLogRecords()
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	r := records[0]
+	if r.GetSeverityNumber() != logspb.SeverityNumber_SEVERITY_NUMBER_ERROR {
+		t.Errorf("expected severity ERROR, got %v", r.GetSeverityNumber())
+	}
+	if r.GetBody().GetStringValue() != "failed" {
+		t.Errorf("expected body %q, got %q", "failed", r.GetBody().GetStringValue())
+	}
+	if r.GetTimeUnixNano() != uint64(10*time.Second) {
+		t.Errorf("expected time %d, got %d", uint64(10*time.Second), r.GetTimeUnixNano())
+	}
+	var keys []string
+	for _, kv := range r.GetAttributes() {
+		keys = append(keys, kv.GetKey())
+	}
+	if diff := cmp.Diff([]string{"client.address", "syslog.app_name", "tinkerbell.mac"}, keys); diff != "" {
+		t.Errorf("unexpected attributes (-want +got):\n%s", diff)
+	}
+}
+
+func TestQueueDropsWhenFull(t *testing.T) {
+	q := newQueue("test", nil, logr.Discard())
+	for range forwardQueueSize + 10 {
+		q.add(Entry{})
+	}
+	if len(q.entries) != forwardQueueSize {
+		t.Errorf("expected %d queued entries, got %d", forwardQueueSize, len(q.entries))
+	}
+}
@@ -117,6 +117,27 @@ func (m *message) String() string {
 	return strings.Join(fields, " ")
 }
 
+// entry returns the message as an Entry.
+// Messages that couldn't be parsed are returned as is, with the default priority of user.notice from RFC 3164.
+func (m *message) entry(parsed bool) Entry {
+	e := Entry{Time: m.time, Host: m.host.String()}
+	if !parsed {
+		e.Priority = int(user)*8 + int(NOTICE)
+		e.Message = string(m.buf[:m.size])
+		return e
+	}
+	e.Priority = int(m.priority)
+	e.Facility = m.Facility().String()
+	e.Severity = m.Severity().String()
+	e.Hostname = string(m.hostname)
+	e.App = string(m.app)
+	e.ProcID = string(m.procid)
+	e.MsgID = string(m.msgid)
+	e.Message = msgCleanup.Replace(string(m.msg))
+
+	return e
+}
+
 func (m *message) Timestamp() time.Time {
 	return m.time
 }
@@ -2,10 +2,12 @@ package syslog
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
+	"net/netip"
 	"strings"
 	"sync"
 	"time"
@@ -22,21 +24,89 @@ type Receiver struct {
 	parse chan *message
 	done  chan struct{}
 	err   error
+	ctx   context.Context
 
 	Logger logr.Logger
+	// Store, when set, keeps received messages.
+	Store *Store
+	// resolver adds the MAC address and Hardware of the source IP address to messages.
+	resolver *resolver
+	// queues forward received messages.
+	queues []*queue
+	// tcpAddr and tlsAddr are the addresses on which messages are also received over TCP and TLS.
+	tcpAddr   string
+	tlsAddr   string
+	tlsConfig *tls.Config
+	// listeners accept the TCP and TLS connections.
+	listeners []net.Listener
+	// streams tracks the goroutines that read TCP and TLS connections, so that parse is closed once they stopped sending messages.
+	streams sync.WaitGroup
+	// streamSlots limits the number of connections read at the same time, each holds a slot until it's closed.
+	streamSlots chan struct{}
+	// hostStreams counts the connections read from each source IP address, guarded by hostMu.
+	// A source can only have maxHostStreams connections, so that one host can't take all the slots.
+	hostMu         sync.Mutex
+	hostStreams    map[netip.Addr]int
+	maxHostStreams int
+	// firstTimeout, idleTimeout and handshakeTimeout close connections that don't send their first message,
+	// send a message or complete the TLS handshake in time.
+	firstTimeout     time.Duration
+	idleTimeout      time.Duration
+	handshakeTimeout time.Duration
 }
 
-func StartReceiver(ctx context.Context, logger logr.Logger, laddr string, parsers int) error {
+// ReceiverOption configures optional behavior of a Receiver.
+type ReceiverOption func(*Receiver)
+
+// WithStore keeps received messages in the store.
+func WithStore(s *Store) ReceiverOption {
+	return func(r *Receiver) {
+		r.Store = s
+	}
+}
+
+// WithBackend resolves the MAC address and Hardware of the source IP address of messages with the backend.
+func WithBackend(br BackendReader) ReceiverOption {
+	return func(r *Receiver) {
+		r.resolver = &resolver{backend: br}
+	}
+}
+
+// WithForwarders forwards received messages. The names identify the forwarders in logs.
+func WithForwarders(fs map[string]Forwarder) ReceiverOption {
+	return func(r *Receiver) {
+		for name, f := range fs {
+			r.queues = append(r.queues, newQueue(name, f, r.Logger))
+		}
+	}
+}
+
+// WithTCP also receives messages over TCP on laddr, framed with octet counting or newlines as described in RFC 6587.
+func WithTCP(laddr string) ReceiverOption {
+	return func(r *Receiver) {
+		r.tcpAddr = laddr
+	}
+}
+
+// WithTLS also receives messages over TLS on laddr, as described in RFC 5425, framed like messages received over TCP.
+func WithTLS(laddr string, cfg *tls.Config) ReceiverOption {
+	return func(r *Receiver) {
+		r.tlsAddr = laddr
+		r.tlsConfig = cfg
+	}
+}
+
+func StartReceiver(ctx context.Context, logger logr.Logger, laddr string, parsers int, opts ...ReceiverOption) error {
 	if parsers < 1 {
 		parsers = 1
 	}
 
-	addr, err := net.ResolveUDPAddr("udp4", laddr)
+	addr, err := net.ResolveUDPAddr("udp", laddr)
 	if err != nil {
 		return fmt.Errorf("resolve syslog udp listen address: %w", err)
 	}
 
-	c, err := net.ListenUDP("udp4", addr)
+	c, err := net.ListenUDP("udp", addr)
 	if err != nil {
 		return fmt.Errorf("listen on syslog udp address: %w", err)
 	}
@@ -45,12 +115,30 @@ func StartReceiver(ctx context.Context, logger logr.Logger, laddr string, parser
 		c:      c,
 		parse:  make(chan *message, parsers),
 		done:   make(chan struct{}),
+		ctx:    ctx,
 		Logger: logger,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if err := s.listen(); err != nil {
+		c.Close()
+		for _, l := range s.listeners {
+			l.Close()
+		}
+		return err
+	}
+	for _, q := range s.queues {
+		go q.run(ctx)
+	}
 
 	for i := 0; i < parsers; i++ {
 		go s.runParser()
 	}
+	for _, l := range s.listeners {
+		s.streams.Add(1)
+		go s.serveStream(ctx, l)
+	}
 	go s.run(ctx)
 
 	return nil
@@ -66,6 +154,10 @@ func (r *Receiver) Err() error {
 
 func (r *Receiver) cleanup() {
 	r.c.Close()
+	for _, l := range r.listeners {
+		l.Close()
+	}
+	r.streams.Wait()
 
 	close(r.parse)
 	close(r.done)
@@ -119,6 +211,21 @@ func (r *Receiver) run(ctx context.Context) {
 	}
 }
 
+// keep stores and forwards a message.
+func (r *Receiver) keep(e Entry) {
+	ctx := r.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	e.MAC, e.Hardware = r.resolver.resolve(ctx, e.Host)
+	if r.Store != nil {
+		r.Store.Add(e)
+	}
+	for _, q := range r.queues {
+		q.add(e)
+	}
+}
+
 func parse(m *message) map[string]interface{} {
 	structured := make(map[string]interface{})
 	if m.Facility().String() != "" {
@@ -158,7 +265,8 @@ func parse(m *message) map[string]interface{} {
 
 func (r *Receiver) runParser() {
 	for m := range r.parse {
-		if m.parse() {
+		parsed := m.parse()
+		if parsed {
 			structured := parse(m)
 			sl := r.Logger.WithValues("logEntry", structured)
 			if m.Severity() == DEBUG {
@@ -169,6 +277,9 @@ func (r *Receiver) runParser() {
 		} else {
 			r.Logger.V(1).Info("syslog message received", "logEntry", m)
 		}
+		if r.Store != nil || len(r.queues) > 0 {
+			r.keep(m.entry(parsed))
+		}
 		m.reset()
 		syslogMessagePool.Put(m)
 	}
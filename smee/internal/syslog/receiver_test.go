@@ -817,3 +817,53 @@ func TestReceiver_runParser_DEBUG_severity(t *testing.T) {
 		t.Error("runParser() did not complete processing DEBUG message within timeout")
 	}
 }
+
+type recordingForwarder struct {
+	entries chan Entry
+}
+
+func (f *recordingForwarder) Forward(_ context.Context, entries []Entry) error {
+	for _, e := range entries {
+		f.entries <- e
+	}
+	return nil
+}
+
+func TestReceiverStoreAndForward(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := conn.LocalAddr().String()
+	conn.Close()
+
+	store := &Store{}
+	fwd := &recordingForwarder{entries: make(chan Entry, 1)}
+	if err := StartReceiver(ctx, logr.Discard(), addr, 1, WithStore(store), WithForwarders(map[string]Forwarder{"test": fwd})); err != nil {
+		t.Fatalf("StartReceiver() error = %v", err)
+	}
+
+	clientConn, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatalf("Failed to create client connection: %v", err)
+	}
+	defer clientConn.Close()
+	if _, err := clientConn.Write([]byte("<30>dhcpcd[42]: lease acquired")); err != nil {
+		t.Fatalf("Failed to send test message: %v", err)
+	}
+
+	select {
+	case e := <-fwd.entries:
+		if e.Host != "127.0.0.1" || e.App != "dhcpcd" || e.Message != "lease acquired" {
+			t.Errorf("unexpected forwarded entry: %+v", e)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for forwarded message")
+	}
+	if got := store.Entries("127.0.0.1"); len(got) != 1 || got[0].Severity != "INFO" {
+		t.Errorf("unexpected stored entries: %+v", got)
+	}
+}
@@ -0,0 +1,73 @@
+package syslog
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"github.com/tinkerbell/tinkerbell/pkg/data"
+)
+
+// resolveTTL is how long the Hardware of a source IP address is remembered, including when none was found.
+const resolveTTL = time.Minute
+
+// BackendReader is the interface for getting data from a backend.
+type BackendReader interface {
+	FilterHardware(ctx context.Context, opts data.HardwareFilter) (*tinkerbell.Hardware, error)
+}
+
+type resolved struct {
+	mac      string
+	hardware string
+	expires  time.Time
+}
+
+// resolver looks up the Hardware of source IP addresses.
+// Lookups are cached so that a chatty host doesn't cause a backend request per message.
+type resolver struct {
+	backend BackendReader
+	mu      sync.Mutex
+	cache   map[string]resolved
+}
+
+// resolve returns the MAC address of the interface with the IP address and the namespace/name of its Hardware.
+// Empty strings are returned when no Hardware has the IP address.
+func (r *resolver) resolve(ctx context.Context, ip string) (mac, hardware string) {
+	if r == nil || r.backend == nil {
+		return "", ""
+	}
+	r.mu.Lock()
+	if c, ok := r.cache[ip]; ok && time.Now().Before(c.expires) {
+		r.mu.Unlock()
+		return c.mac, c.hardware
+	}
+	r.mu.Unlock()
+
+	c := resolved{expires: time.Now().Add(resolveTTL)}
+	if hw, err := r.backend.FilterHardware(ctx, data.HardwareFilter{ByIPAddress: ip}); err == nil && hw != nil {
+		c.hardware = hw.Namespace + "/" + hw.Name
+		for _, iface := range hw.Spec.Interfaces {
+			if iface.DHCP != nil && iface.DHCP.IP != nil && iface.DHCP.IP.Address == ip {
+				c.mac = strings.ToLower(iface.DHCP.MAC)
+				break
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cache == nil {
+		r.cache = map[string]resolved{}
+	}
+	// Drop expired lookups so that the cache doesn't grow with every host ever seen.
+	for k, v := range r.cache {
+		if time.Now().After(v.expires) {
+			delete(r.cache, k)
+		}
+	}
+	r.cache[ip] = c
+
+	return c.mac, c.hardware
+}
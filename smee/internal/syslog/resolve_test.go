@@ -0,0 +1,57 @@
+package syslog
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"github.com/tinkerbell/tinkerbell/pkg/data"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakeBackend struct {
+	hw    *tinkerbell.Hardware
+	calls int
+}
+
+func (f *fakeBackend) FilterHardware(_ context.Context, opts data.HardwareFilter) (*tinkerbell.Hardware, error) {
+	f.calls++
+	if f.hw == nil || opts.ByIPAddress != "192.168.2.10" {
+		return nil, errors.New("not found")
+	}
+	return f.hw, nil
+}
+
+func TestResolve(t *testing.T) {
+	be := &fakeBackend{hw: &tinkerbell.Hardware{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine1", Namespace: "tinkerbell"},
+		Spec: tinkerbell.HardwareSpec{Interfaces: []tinkerbell.Interface{
+			{DHCP: &tinkerbell.DHCP{MAC: "DE:AD:BE:EF:00:02", IP: &tinkerbell.IP{Address: "192.168.2.11"}}},
+			{DHCP: &tinkerbell.DHCP{MAC: "DE:AD:BE:EF:00:01", IP: &tinkerbell.IP{Address: "192.168.2.10"}}},
+		}},
+	}}
+	r := &resolver{backend: be}
+
+	for range 2 {
+		mac, hw := r.resolve(context.Background(), "192.168.2.10")
+		if mac != "de:ad:be:ef:00:01" || hw != "tinkerbell/machine1" {
+			t.Errorf("resolve() = %q, %q, want %q, %q", mac, hw, "de:ad:be:ef:00:01", "tinkerbell/machine1")
+		}
+	}
+	for range 2 {
+		if mac, hw := r.resolve(context.Background(), "192.168.2.99"); mac != "" || hw != "" {
+			t.Errorf("resolve() = %q, %q, want empty", mac, hw)
+		}
+	}
+	if be.calls != 2 {
+		t.Errorf("expected 2 backend calls, got %d", be.calls)
+	}
+}
+
+func TestResolveNoBackend(t *testing.T) {
+	var r *resolver
+	if mac, hw := r.resolve(context.Background(), "192.168.2.10"); mac != "" || hw != "" {
+		t.Errorf("resolve() = %q, %q, want empty", mac, hw)
+	}
+}
@@ -0,0 +1,228 @@
+package syslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultMaxEntries = 200
+	defaultMaxHosts   = 64
+
+	// maxStoredMessageSize is the size at which stored messages are truncated. Together with the header field limits
+	// of RFC 5424 it bounds a stored entry to about 2 KiB, so the default limits keep at most about 250 MiB.
+	// Forwarders receive messages whole.
+	maxStoredMessageSize = 1024
+)
+
+// Entry is a syslog message received from a host.
+type Entry struct {
+	// Time is the timestamp of the message, or the time it was received when the message has none.
+	Time time.Time `json:"time"`
+	// Host is the source IP address of the message.
+	Host string `json:"host"`
+	// MAC is the MAC address of the Hardware interface with the source IP address, when known.
+	MAC string `json:"mac,omitempty"`
+	// Hardware is the namespace/name of the Hardware with the source IP address, when known.
+	Hardware string `json:"hardware,omitempty"`
+	// Priority is the syslog priority, the facility times 8 plus the severity.
+	Priority int    `json:"priority"`
+	Facility string `json:"facility,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	// Hostname is the hostname reported in the message.
+	Hostname string `json:"hostname,omitempty"`
+	App      string `json:"app,omitempty"`
+	ProcID   string `json:"procid,omitempty"`
+	MsgID    string `json:"msgid,omitempty"`
+	Message  string `json:"msg"`
+}
+
+// String returns the entry as a single line of text.
+func (e Entry) String() string {
+	app := e.App
+	if e.ProcID != "" {
+		app += "[" + e.ProcID + "]"
+	}
+
+	return fmt.Sprintf("%s %s %s %s: %s", e.Time.Format(time.RFC3339), e.Host, e.Severity, app, e.Message)
+}
+
+// HostSummary describes the stored messages of a host.
+type HostSummary struct {
+	Host     string    `json:"host"`
+	MAC      string    `json:"mac,omitempty"`
+	Hardware string    `json:"hardware,omitempty"`
+	LastSeen time.Time `json:"lastSeen"`
+	Entries  int       `json:"entries"`
+}
+
+// Store keeps the most recent messages of each host in memory.
+// The zero value is ready to use.
+type Store struct {
+	mu         sync.RWMutex
+	maxEntries int
+	maxHosts   int
+	hosts      map[string]*ring
+}
+
+// ring is a fixed size ring buffer of the messages of a single host.
+type ring struct {
+	entries  []Entry
+	next     int
+	full     bool
+	mac      string
+	hardware string
+	lastSeen time.Time
+}
+
+// SetLimits sets the number of messages kept per host and the number of hosts for which messages are kept.
+// Values less than 1 use the defaults of 200 messages and 64 hosts. Limits apply to hosts added afterwards.
+func (s *Store) SetLimits(maxEntries, maxHosts int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxEntries = maxEntries
+	s.maxHosts = maxHosts
+}
+
+// Add stores a message. When the host's buffer is full the oldest message is dropped.
+// When a message arrives from a new host and the store is full, the host that was heard from least recently is dropped.
+// Messages longer than 1 KiB and header fields longer than allowed by RFC 5424 are truncated.
+func (s *Store) Add(e Entry) {
+	if len(e.Message) > maxStoredMessageSize {
+		e.Message = truncateUTF8(e.Message, maxStoredMessageSize) + "..."
+	}
+	e.Hostname = truncateUTF8(e.Hostname, 255)
+	e.App = truncateUTF8(e.App, 48)
+	e.ProcID = truncateUTF8(e.ProcID, 128)
+	e.MsgID = truncateUTF8(e.MsgID, 32)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hosts == nil {
+		s.hosts = map[string]*ring{}
+	}
+	r, ok := s.hosts[e.Host]
+	if !ok {
+		maxHosts := s.maxHosts
+		if maxHosts < 1 {
+			maxHosts = defaultMaxHosts
+		}
+		for len(s.hosts) >= maxHosts {
+			s.evictOldest()
+		}
+		maxEntries := s.maxEntries
+		if maxEntries < 1 {
+			maxEntries = defaultMaxEntries
+		}
+		r = &ring{entries: make([]Entry, maxEntries)}
+		s.hosts[e.Host] = r
+	}
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+	r.full = r.full || r.next == 0
+	if e.MAC != "" {
+		r.mac = e.MAC
+		r.hardware = e.Hardware
+	}
+	r.lastSeen = time.Now()
+}
+
+// truncateUTF8 returns the first n bytes of s, without splitting a UTF-8 encoded character.
+// Strings aren't copied by slicing, so the result is cloned to not keep the rest of s in memory.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return strings.Clone(s[:n])
+}
+
+func (s *Store) evictOldest() {
+	var oldest string
+	var t time.Time
+	for h, r := range s.hosts {
+		if oldest == "" || r.lastSeen.Before(t) {
+			oldest, t = h, r.lastSeen
+		}
+	}
+	delete(s.hosts, oldest)
+}
+
+// Hosts returns a summary of each host with stored messages, sorted by host.
+func (s *Store) Hosts() []HostSummary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	hosts := make([]HostSummary, 0, len(s.hosts))
+	for h, r := range s.hosts {
+		n := r.next
+		if r.full {
+			n = len(r.entries)
+		}
+		hosts = append(hosts, HostSummary{Host: h, MAC: r.mac, Hardware: r.hardware, LastSeen: r.lastSeen, Entries: n})
+	}
+	slices.SortFunc(hosts, func(a, b HostSummary) int { return strings.Compare(a.Host, b.Host) })
+
+	return hosts
+}
+
+// Entries returns the stored messages of the host with the IP or MAC address, oldest first.
+func (s *Store) Entries(key string) []Entry {
+	key = strings.ToLower(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var entries []Entry
+	for h, r := range s.hosts {
+		if h != key && (r.mac == "" || r.mac != key) {
+			continue
+		}
+		if r.full {
+			entries = append(entries, r.entries[r.next:]...)
+		}
+		entries = append(entries, r.entries[:r.next]...)
+	}
+	// A machine that changed IP address has messages under more than one host.
+	slices.SortStableFunc(entries, func(a, b Entry) int { return a.Time.Compare(b.Time) })
+
+	return entries
+}
+
+// ServeHTTP serves the stored messages.
+// A request for the prefix itself, for example /syslog/, returns the hosts with stored messages.
+// A request for /syslog/<ip or mac> returns the messages of that host as JSON,
+// or as lines of text with the query parameter format=text.
+func (s *Store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var v any
+	key := path.Base(r.URL.Path)
+	if strings.HasSuffix(r.URL.Path, "/") {
+		v = s.Hosts()
+	} else {
+		entries := s.Entries(key)
+		if len(entries) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Query().Get("format") == "text" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			for _, e := range entries {
+				fmt.Fprintln(w, e.String())
+			}
+			return
+		}
+		v = entries
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,116 @@
+package syslog
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStoreRing(t *testing.T) {
+	s := &Store{}
+	s.SetLimits(3, 0)
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		s.Add(Entry{Time: start.Add(time.Duration(i) * time.Second), Host: "192.168.2.10", Message: string(rune('a' + i))})
+	}
+
+	var got []string
+	for _, e := range s.Entries("192.168.2.10") {
+		got = append(got, e.Message)
+	}
+	if diff := cmp.Diff([]string{"c", "d", "e"}, got); diff != "" {
+		t.Errorf("unexpected entries (-want +got):\n%s", diff)
+	}
+	if n := s.Hosts()[0].Entries; n != 3 {
+		t.Errorf("expected 3 entries in host summary, got %d", n)
+	}
+}
+
+func TestStoreEvictsLeastRecentHost(t *testing.T) {
+	s := &Store{}
+	s.SetLimits(0, 2)
+	s.Add(Entry{Host: "192.168.2.10", Message: "one"})
+	s.Add(Entry{Host: "192.168.2.11", Message: "two"})
+	s.Add(Entry{Host: "192.168.2.10", Message: "three"})
+	s.Add(Entry{Host: "192.168.2.12", Message: "four"})
+
+	var got []string
+	for _, h := range s.Hosts() {
+		got = append(got, h.Host)
+	}
+	if diff := cmp.Diff([]string{"192.168.2.10", "192.168.2.12"}, got); diff != "" {
+		t.Errorf("unexpected hosts (-want +got):\n%s", diff)
+	}
+}
+
+func TestStoreTruncates(t *testing.T) {
+	s := &Store{}
+	s.Add(Entry{
+		Host:    "192.168.2.10",
+		App:     strings.Repeat("a", 100),
+		Message: strings.Repeat("x", maxStoredMessageSize-1) + "é" + strings.Repeat("y", 10000),
+	})
+
+	got := s.Entries("192.168.2.10")[0]
+	if want := strings.Repeat("x", maxStoredMessageSize-1) + "..."; got.Message != want {
+		t.Errorf("expected the message to be truncated before the split character, got %d bytes ending in %q", len(got.Message), got.Message[len(got.Message)-5:])
+	}
+	if len(got.App) != 48 {
+		t.Errorf("expected the app to be truncated to 48 bytes, got %d", len(got.App))
+	}
+}
+
+func TestStoreEntriesByMAC(t *testing.T) {
+	s := &Store{}
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.Add(Entry{Time: start.Add(time.Second), Host: "192.168.2.10", MAC: "de:ad:be:ef:00:01", Message: "second"})
+	s.Add(Entry{Time: start, Host: "192.168.2.20", MAC: "de:ad:be:ef:00:01", Message: "first"})
+	s.Add(Entry{Time: start, Host: "192.168.2.30", Message: "other"})
+
+	var got []string
+	for _, e := range s.Entries("DE:AD:BE:EF:00:01") {
+		got = append(got, e.Message)
+	}
+	if diff := cmp.Diff([]string{"first", "second"}, got); diff != "" {
+		t.Errorf("unexpected entries (-want +got):\n%s", diff)
+	}
+}
+
+func TestStoreServeHTTP(t *testing.T) {
+	s := &Store{}
+	s.Add(Entry{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Host: "192.168.2.10", Severity: "INFO", App: "kernel", Message: "booting"})
+
+	tests := map[string]struct {
+		method   string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		"hosts":          {method: http.MethodGet, path: "/syslog/", wantCode: http.StatusOK, wantBody: `"host":"192.168.2.10"`},
+		"entries":        {method: http.MethodGet, path: "/syslog/192.168.2.10", wantCode: http.StatusOK, wantBody: `"msg":"booting"`},
+		"entries text":   {method: http.MethodGet, path: "/syslog/192.168.2.10?format=text", wantCode: http.StatusOK, wantBody: "2025-01-01T00:00:00Z 192.168.2.10 INFO kernel: booting\n"},
+		"unknown host":   {method: http.MethodGet, path: "/syslog/192.168.2.99", wantCode: http.StatusNotFound},
+		"wrong method":   {method: http.MethodPost, path: "/syslog/", wantCode: http.StatusMethodNotAllowed},
+		"hosts are JSON": {method: http.MethodGet, path: "/syslog/", wantCode: http.StatusOK, wantBody: `"entries":1`},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("expected body to contain %q, got %q", tt.wantBody, w.Body.String())
+			}
+			if w.Code == http.StatusOK && !strings.Contains(tt.path, "format=text") && !json.Valid(w.Body.Bytes()) {
+				t.Errorf("expected valid JSON, got %q", w.Body.String())
+			}
+		})
+	}
+}
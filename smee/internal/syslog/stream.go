@@ -0,0 +1,267 @@
+package syslog
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"time"
+)
+
+const (
+	// maxFrameLengthDigits limits the length of the MSG-LEN of octet counted messages.
+	// Messages longer than the message buffer are truncated, so only the length needs to fit in an int.
+	maxFrameLengthDigits = 9
+	// maxStreams is the number of TCP and TLS connections that are read at the same time.
+	// Further connections wait in the backlog of the listener until a connection is closed.
+	maxStreams = 1024
+	// maxHostStreams is the number of connections read at the same time from one source IP address.
+	// Further connections from the source are closed.
+	maxHostStreams = 16
+	// streamFirstTimeout closes connections that don't send a complete message soon after they're opened,
+	// so that connections that never send anything don't hold a slot for streamIdleTimeout.
+	streamFirstTimeout = 10 * time.Second
+	// streamIdleTimeout closes connections that don't send a complete message in time.
+	// Senders keep their connection open between messages and reconnect when it's closed, so it's generous.
+	streamIdleTimeout = 10 * time.Minute
+	// tlsHandshakeTimeout closes TLS connections that don't complete the handshake in time.
+	tlsHandshakeTimeout = 10 * time.Second
+)
+
+// listen creates the TCP and TLS listeners.
+func (r *Receiver) listen() error {
+	if r.streamSlots == nil {
+		r.streamSlots = make(chan struct{}, maxStreams)
+	}
+	if r.maxHostStreams == 0 {
+		r.maxHostStreams = maxHostStreams
+	}
+	if r.firstTimeout == 0 {
+		r.firstTimeout = streamFirstTimeout
+	}
+	if r.idleTimeout == 0 {
+		r.idleTimeout = streamIdleTimeout
+	}
+	if r.handshakeTimeout == 0 {
+		r.handshakeTimeout = tlsHandshakeTimeout
+	}
+	if r.tcpAddr != "" {
+		l, err := net.Listen("tcp", r.tcpAddr)
+		if err != nil {
+			return fmt.Errorf("listen on syslog tcp address: %w", err)
+		}
+		r.listeners = append(r.listeners, l)
+	}
+	if r.tlsAddr != "" {
+		if r.tlsConfig == nil || (len(r.tlsConfig.Certificates) == 0 && r.tlsConfig.GetCertificate == nil) {
+			return errors.New("a TLS certificate is required to receive syslog messages over TLS")
+		}
+		l, err := tls.Listen("tcp", r.tlsAddr, r.tlsConfig)
+		if err != nil {
+			return fmt.Errorf("listen on syslog tls address: %w", err)
+		}
+		r.listeners = append(r.listeners, l)
+	}
+
+	return nil
+}
+
+// serveStream reads the messages of the connections accepted by l until l is closed.
+// Connections are only accepted while fewer than the capacity of streamSlots are read.
+// Connections from a source that already has maxHostStreams connections are closed.
+func (r *Receiver) serveStream(ctx context.Context, l net.Listener) {
+	defer r.streams.Done()
+	for {
+		select {
+		case r.streamSlots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		c, err := l.Accept()
+		if err != nil {
+			<-r.streamSlots
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			if ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+				r.Logger.Error(err, "error accepting syslog connection", "addr", l.Addr().String())
+			}
+			return
+		}
+		host := remoteAddr(c)
+		if !r.acquireHost(host) {
+			r.Logger.V(1).Info("too many syslog connections from the source, closing the connection", "remoteAddr", c.RemoteAddr().String(), "max", r.maxHostStreams)
+			c.Close()
+			<-r.streamSlots
+			continue
+		}
+		r.streams.Add(1)
+		go r.readStream(ctx, c, host)
+	}
+}
+
+// acquireHost counts a connection from host and reports whether it's within maxHostStreams.
+func (r *Receiver) acquireHost(host netip.Addr) bool {
+	r.hostMu.Lock()
+	defer r.hostMu.Unlock()
+	if r.hostStreams == nil {
+		r.hostStreams = map[netip.Addr]int{}
+	}
+	if r.hostStreams[host] >= r.maxHostStreams {
+		return false
+	}
+	r.hostStreams[host]++
+
+	return true
+}
+
+// releaseHost stops counting a connection from host.
+func (r *Receiver) releaseHost(host netip.Addr) {
+	r.hostMu.Lock()
+	defer r.hostMu.Unlock()
+	if r.hostStreams[host]--; r.hostStreams[host] <= 0 {
+		delete(r.hostStreams, host)
+	}
+}
+
+// remoteAddr returns the IP address of the remote end of c, or the zero Addr when it isn't a TCP connection.
+func remoteAddr(c net.Conn) netip.Addr {
+	if a, ok := c.RemoteAddr().(*net.TCPAddr); ok {
+		return a.AddrPort().Addr().Unmap()
+	}
+
+	return netip.Addr{}
+}
+
+// readStream sends the messages read from the connection to the parsers, until the connection or the context is closed
+// or no complete message is received within the first message or idle timeout.
+func (r *Receiver) readStream(ctx context.Context, c net.Conn, remote netip.Addr) {
+	defer r.streams.Done()
+	defer func() { <-r.streamSlots }()
+	defer r.releaseHost(remote)
+	defer c.Close()
+	stop := context.AfterFunc(ctx, func() { c.Close() })
+	defer stop()
+
+	if tc, ok := c.(*tls.Conn); ok {
+		hctx, cancel := context.WithTimeout(ctx, r.handshakeTimeout)
+		err := tc.HandshakeContext(hctx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil {
+				r.Logger.V(1).Info("error in syslog tls handshake", "error", err, "remoteAddr", c.RemoteAddr().String())
+			}
+			return
+		}
+	}
+
+	var host net.IP
+	if a, ok := c.RemoteAddr().(*net.TCPAddr); ok {
+		host = a.IP
+	}
+	br := bufio.NewReader(c)
+	timeout := r.firstTimeout
+	for {
+		msg, ok := syslogMessagePool.Get().(*message)
+		if !ok {
+			r.Logger.Error(errors.New("error type asserting pool item into message"), "error type asserting pool item into message")
+			return
+		}
+		if err := c.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			syslogMessagePool.Put(msg)
+			return
+		}
+		n, err := readFrame(br, msg.buf[:])
+		if err != nil {
+			syslogMessagePool.Put(msg)
+			if ctx.Err() == nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				r.Logger.V(1).Info("error reading syslog connection", "error", err, "remoteAddr", c.RemoteAddr().String())
+			}
+			return
+		}
+		if n == 0 {
+			syslogMessagePool.Put(msg)
+			continue
+		}
+		timeout = r.idleTimeout
+		msg.time = time.Now().UTC()
+		msg.host = host
+		msg.size = n
+		select {
+		case <-ctx.Done():
+			syslogMessagePool.Put(msg)
+			return
+		case r.parse <- msg:
+		}
+	}
+}
+
+// readFrame reads a message from a stream into buf and returns its length. Messages are framed as described in RFC 6587,
+// either with octet counting, "<length> <message>", or by a trailing newline. The framing is detected for each message,
+// as syslog messages start with "<" and octet counted messages with a digit.
+// Messages longer than buf are truncated.
+func readFrame(br *bufio.Reader, buf []byte) (int, error) {
+	b, err := br.Peek(1)
+	if err != nil {
+		return 0, err
+	}
+	if b[0] < '1' || b[0] > '9' {
+		return readLine(br, buf)
+	}
+
+	length := 0
+	for i := 0; ; i++ {
+		c, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if c == ' ' {
+			break
+		}
+		if c < '0' || c > '9' || i == maxFrameLengthDigits {
+			return 0, errors.New("invalid octet counting frame length")
+		}
+		length = length*10 + int(c-'0')
+	}
+	n, err := io.ReadFull(br, buf[:min(length, len(buf))])
+	if err != nil {
+		return 0, err
+	}
+	if length > n {
+		if _, err := br.Discard(length - n); err != nil {
+			return 0, err
+		}
+	}
+
+	return n, nil
+}
+
+// readLine reads a newline terminated message into buf and returns its length, without the newline.
+// The last message of a stream may end without a newline.
+func readLine(br *bufio.Reader, buf []byte) (int, error) {
+	n := 0
+	for {
+		line, err := br.ReadSlice('\n')
+		n += copy(buf[n:], line)
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) && n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		break
+	}
+	if n > 0 && buf[n-1] == '\n' {
+		n--
+	}
+
+	return n, nil
+}
@@ -0,0 +1,395 @@
+package syslog
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadFrame(t *testing.T) {
+	long := strings.Repeat("a", 20)
+	tests := map[string]struct {
+		stream  string
+		want    []string
+		wantErr bool
+	}{
+		"octet counting":        {stream: "5 <30>a11 <30>b c d e", want: []string{"<30>a", "<30>b c d e"}},
+		"newlines":              {stream: "<30>a\n<30>b\r\n\n", want: []string{"<30>a", "<30>b\r", ""}},
+		"mixed":                 {stream: "<30>a\n5 <30>b<30>c", want: []string{"<30>a", "<30>b", "<30>c"}},
+		"truncated octet count": {stream: "20 " + long + "5 <30>b", want: []string{long[:16], "<30>b"}},
+		"truncated newline":     {stream: long + "\n<30>b\n", want: []string{long[:16], "<30>b"}},
+		"invalid length":        {stream: "5x <30>a", wantErr: true},
+		"length too long":       {stream: "1234567890 <30>a", wantErr: true},
+		"short octet count":     {stream: "10 <30>a", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			// A small reader buffer exercises lines longer than the buffer.
+			br := bufio.NewReaderSize(strings.NewReader(tt.stream), 16)
+			buf := make([]byte, 16)
+			var got []string
+			for {
+				n, err := readFrame(br, buf)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					if !tt.wantErr {
+						t.Fatalf("readFrame() error = %v", err)
+					}
+					return
+				}
+				got = append(got, string(buf[:n]))
+			}
+			if tt.wantErr {
+				t.Fatalf("readFrame() expected an error, got %q", got)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func selfSignedCert(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	return l.Addr().String()
+}
+
+func TestReceiverStreams(t *testing.T) {
+	cert := selfSignedCert(t)
+	tests := map[string]struct {
+		opt  func(addr string) ReceiverOption
+		dial func(addr string) (net.Conn, error)
+	}{
+		"tcp": {
+			opt:  WithTCP,
+			dial: func(addr string) (net.Conn, error) { return net.Dial("tcp", addr) },
+		},
+		"tls": {
+			opt: func(addr string) ReceiverOption {
+				return WithTLS(addr, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+			},
+			dial: func(addr string) (net.Conn, error) {
+				return tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true, MinVersion: tls.VersionTLS12}) //nolint:gosec // The test certificate is self-signed.
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			addr := freeAddr(t)
+			store := &Store{}
+			fwd := &recordingForwarder{entries: make(chan Entry, 3)}
+			if err := StartReceiver(ctx, logr.Discard(), freeAddr(t), 1, WithStore(store), WithForwarders(map[string]Forwarder{"test": fwd}), tt.opt(addr)); err != nil {
+				t.Fatalf("StartReceiver() error = %v", err)
+			}
+
+			c, err := tt.dial(addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			// A message longer than a UDP datagram is received whole.
+			long := strings.Repeat("x", 10000)
+			msgs := []string{"<30>dhcpcd[42]: lease acquired", "<30>tink-agent: " + long}
+			if _, err := fmt.Fprintf(c, "%d %s%s\n", len(msgs[0]), msgs[0], msgs[1]); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []Entry
+			for len(got) < 2 {
+				select {
+				case e := <-fwd.entries:
+					got = append(got, e)
+				case <-ctx.Done():
+					t.Fatalf("timed out waiting for messages, got %+v", got)
+				}
+			}
+			if got[0].Host != "127.0.0.1" || got[0].App != "dhcpcd" || got[0].Message != "lease acquired" {
+				t.Errorf("unexpected entry: %+v", got[0])
+			}
+			if got[1].App != "tink-agent" || got[1].Message != long {
+				t.Errorf("unexpected entry of the long message, app %q, message length %d", got[1].App, len(got[1].Message))
+			}
+		})
+	}
+}
+
+func TestReceiverTLSWithoutCertificate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := StartReceiver(ctx, logr.Discard(), freeAddr(t), 1, WithTLS(freeAddr(t), &tls.Config{MinVersion: tls.VersionTLS12})); err == nil {
+		t.Fatal("StartReceiver() expected an error without a TLS certificate")
+	}
+}
+
+func TestReceiverStreamsStop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	addr := freeAddr(t)
+	r := &Receiver{parse: make(chan *message), Logger: logr.Discard()}
+	WithTCP(addr)(r)
+	if err := r.listen(); err != nil {
+		t.Fatal(err)
+	}
+	r.streams.Add(1)
+	go r.serveStream(ctx, r.listeners[0])
+
+	// The connection blocks on the parse channel, which is never read.
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Write([]byte("<30>a\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	r.listeners[0].Close()
+	done := make(chan struct{})
+	go func() {
+		r.streams.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("TCP connections weren't closed when the context was done")
+	}
+}
+
+func TestReceiverStreamsLimit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addr := freeAddr(t)
+	r := &Receiver{parse: make(chan *message, 2), Logger: logr.Discard(), streamSlots: make(chan struct{}, 1)}
+	WithTCP(addr)(r)
+	if err := r.listen(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.listeners[0].Close()
+	r.streams.Add(1)
+	go r.serveStream(ctx, r.listeners[0])
+
+	receive := func() string {
+		t.Helper()
+		select {
+		case msg := <-r.parse:
+			defer syslogMessagePool.Put(msg)
+			return string(msg.buf[:msg.size])
+		case <-time.After(200 * time.Millisecond):
+			return ""
+		}
+	}
+	first, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	if _, err := first.Write([]byte("<30>first\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(); got != "<30>first" {
+		t.Fatalf("got message %q from the first connection", got)
+	}
+
+	// The second connection isn't read until the first one is closed.
+	second, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	if _, err := second.Write([]byte("<30>second\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(); got != "" {
+		t.Fatalf("got message %q beyond the connection limit", got)
+	}
+	first.Close()
+	if got := receive(); got != "<30>second" {
+		t.Fatalf("got message %q from the second connection once the first one was closed", got)
+	}
+}
+
+func TestReceiverStreamsTimeout(t *testing.T) {
+	cert := selfSignedCert(t)
+	tests := map[string]struct {
+		opt              func(addr string) ReceiverOption
+		firstTimeout     time.Duration
+		idleTimeout      time.Duration
+		handshakeTimeout time.Duration
+		write            string
+	}{
+		"no message": {
+			opt:              WithTCP,
+			firstTimeout:     50 * time.Millisecond,
+			idleTimeout:      time.Hour,
+			handshakeTimeout: time.Hour,
+		},
+		"partial message": {
+			opt:              WithTCP,
+			firstTimeout:     50 * time.Millisecond,
+			idleTimeout:      time.Hour,
+			handshakeTimeout: time.Hour,
+			write:            "100 <30>partial",
+		},
+		"idle": {
+			opt:              WithTCP,
+			firstTimeout:     time.Hour,
+			idleTimeout:      50 * time.Millisecond,
+			handshakeTimeout: time.Hour,
+			write:            "<30>first\n",
+		},
+		"tls handshake": {
+			opt: func(addr string) ReceiverOption {
+				return WithTLS(addr, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+			},
+			firstTimeout:     time.Hour,
+			idleTimeout:      time.Hour,
+			handshakeTimeout: 50 * time.Millisecond,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			addr := freeAddr(t)
+			r := &Receiver{parse: make(chan *message, 1), Logger: logr.Discard(), firstTimeout: tt.firstTimeout, idleTimeout: tt.idleTimeout, handshakeTimeout: tt.handshakeTimeout}
+			tt.opt(addr)(r)
+			if err := r.listen(); err != nil {
+				t.Fatal(err)
+			}
+			defer r.listeners[0].Close()
+			r.streams.Add(1)
+			go r.serveStream(ctx, r.listeners[0])
+
+			c, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			if _, err := c.Write([]byte(tt.write)); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+				t.Fatal(err)
+			}
+			// The server closes the connection, the read fails with EOF, or a reset, instead of timing out.
+			_, err = c.Read(make([]byte, 1))
+			var ne net.Error
+			if err == nil || (errors.As(err, &ne) && ne.Timeout()) {
+				t.Fatalf("the connection wasn't closed, read error = %v", err)
+			}
+		})
+	}
+}
+
+func TestReceiverStreamsHostLimit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addr := freeAddr(t)
+	r := &Receiver{parse: make(chan *message, 2), Logger: logr.Discard(), maxHostStreams: 1}
+	WithTCP(addr)(r)
+	if err := r.listen(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.listeners[0].Close()
+	r.streams.Add(1)
+	go r.serveStream(ctx, r.listeners[0])
+
+	first, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	// The second connection from the same source is closed.
+	second, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	if err := second.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	_, err = second.Read(make([]byte, 1))
+	var ne net.Error
+	if err == nil || (errors.As(err, &ne) && ne.Timeout()) {
+		t.Fatalf("the connection beyond the source's limit wasn't closed, read error = %v", err)
+	}
+
+	// Once the first connection is closed, the source can connect again.
+	first.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		r.hostMu.Lock()
+		n := len(r.hostStreams)
+		r.hostMu.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the closed connection is still counted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	third, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer third.Close()
+	if _, err := third.Write([]byte("<30>third\n")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-r.parse:
+		defer syslogMessagePool.Put(msg)
+		if got := string(msg.buf[:msg.size]); got != "<30>third" {
+			t.Fatalf("got message %q, want %q", got, "<30>third")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message from the source once its first connection was closed")
+	}
+}
@@ -0,0 +1,49 @@
+package smee
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	httpserver "github.com/tinkerbell/tinkerbell/pkg/http/server"
+)
+
+func TestOSIECacheHandlerRoute(t *testing.T) {
+	var requested []string
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		if r.URL.Path != "/hook/vmlinuz-x86_64" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = io.WriteString(w, "kernel")
+	}))
+	defer up.Close()
+	u, err := url.Parse(up.URL + "/hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewConfig(Config{}, netip.MustParseAddr("192.168.2.2"))
+	c.OSIECache.Enabled = true
+	c.OSIECache.Dir = filepath.Join(t.TempDir(), "cache")
+	c.IPXE.HTTPScriptServer.OSIEURL = u
+	h := c.OSIECacheHandler(logr.Discard())
+	if h == nil {
+		t.Fatal("OSIECacheHandler() returned nil with the cache enabled")
+	}
+	routes := &httpserver.Routes{}
+	routes.Register(OSIECacheURI, h, "smee OSIE cache handler")
+	mux, _ := routes.Muxes(logr.Discard(), 0, false)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/osie/vmlinuz-x86_64", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "kernel" {
+		t.Fatalf("got status %d and body %q, want %d and %q, upstream requests: %v", w.Code, w.Body.String(), http.StatusOK, "kernel", requested)
+	}
+}
@@ -0,0 +1,188 @@
+package smee
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp/server"
+	"golang.org/x/net/ipv4"
+)
+
+// simulateTimeout is how long Simulate waits for the reply to a DHCP message once the handler has returned.
+// The reply is sent on the loopback interface before the handler returns, so this only needs to cover scheduling delays.
+const simulateTimeout = time.Second
+
+// SimulateRequest describes the machine whose netboot Simulate simulates.
+type SimulateRequest struct {
+	// MAC is the MAC address of the machine.
+	MAC net.HardwareAddr
+	// Arch is the client system architecture, DHCP option 93.
+	Arch iana.Arch
+	// UserClass is the user class, DHCP option 77. The firmware of most machines doesn't send one, a stock iPXE sends "iPXE"
+	// and Smee's iPXE binary sends "Tinkerbell".
+	UserClass string
+	// VendorClass is the vendor class identifier, DHCP option 60.
+	// Defaults to the identifier of a PXE client of the architecture, like "PXEClient:Arch:00007:UNDI:003001".
+	VendorClass string
+}
+
+// Simulation is what Smee sends a machine while it netboots.
+type Simulation struct {
+	// DHCP are the DHCP exchanges, in order.
+	DHCP []SimulatedDHCP
+	// ScriptURL is the URL of the iPXE script requested. It's nil when the machine isn't told to download a script from Smee.
+	ScriptURL *url.URL
+	// ScriptStatus is the HTTP status of the iPXE script request.
+	ScriptStatus int
+	// Script is the iPXE script served.
+	Script string
+}
+
+// SimulatedDHCP is a DHCP exchange of a simulated netboot.
+type SimulatedDHCP struct {
+	// Request is the DHCP discover sent by the machine.
+	Request *dhcpv4.DHCPv4
+	// Reply is the reply Smee sends. It's nil when Smee doesn't answer.
+	Reply *dhcpv4.DHCPv4
+}
+
+// Simulate runs the netboot of a machine through the DHCP handler and the iPXE script handler of the DHCP mode, against
+// the backend, without sending or receiving anything on the network. The DHCP discover of the machine is answered
+// and, when the reply loads iPXE, the discover of Smee's iPXE binary is answered too. The iPXE script of the last reply is then requested.
+// Nothing is recorded in the status of the Hardware and no dynamic lease is written to the lease file. InitMetrics must have been called.
+func (c *Config) Simulate(ctx context.Context, log logr.Logger, req SimulateRequest) (*Simulation, error) {
+	if len(req.MAC) == 0 {
+		return nil, errors.New("a MAC address is required")
+	}
+	sc := *c
+	sc.NetbootStatus.Enabled = false
+	// Dynamic leases of the simulation are kept in memory, existing leases are still offered.
+	sc.leaseFileReadOnly = true
+	dh, err := sc.dhcpHandler(log, sc.Backend)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the DHCP handler: %w", err)
+	}
+
+	s := &Simulation{}
+	userClass := req.UserClass
+	for {
+		d, err := simulateDHCP(ctx, dh, req, userClass)
+		if err != nil {
+			return nil, err
+		}
+		s.DHCP = append(s.DHCP, d)
+		// The firmware, or a stock iPXE, loads Smee's iPXE binary, which sends its own discover with the Tinkerbell user class.
+		if d.Reply == nil || d.Reply.BootFileName == "" || dhcp.UserClass(userClass) == dhcp.Tinkerbell {
+			break
+		}
+		userClass = string(dhcp.Tinkerbell)
+	}
+
+	last := s.DHCP[len(s.DHCP)-1].Reply
+	if last == nil || !c.IPXE.HTTPScriptServer.Enabled {
+		return s, nil
+	}
+	// Scripts of other servers, like the iPXE script URL of a Hardware, aren't requested.
+	u, err := url.Parse(last.BootFileName)
+	if err != nil || c.DHCP.IPXEHTTPScript.URL == nil || u.Host != c.DHCP.IPXEHTTPScript.URL.Host || !strings.HasPrefix(u.Path, IPXEScriptURI) {
+		return s, nil
+	}
+	s.ScriptURL = u
+	r := httptest.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if ip, ok := netip.AddrFromSlice(last.YourIPAddr.To4()); ok && !ip.IsUnspecified() {
+		r.RemoteAddr = netip.AddrPortFrom(ip, 80).String()
+	}
+	w := httptest.NewRecorder()
+	sc.scriptHandler(log).HandlerFunc()(w, r)
+	s.ScriptStatus = w.Code
+	s.Script = w.Body.String()
+
+	return s, nil
+}
+
+// simulateDHCP sends a DHCP discover of the machine to h and returns the reply.
+// The handler replies on a loopback connection, so that the reply is the same as the one it sends on the network.
+func simulateDHCP(ctx context.Context, h server.Handler, req SimulateRequest, userClass string) (SimulatedDHCP, error) {
+	vendorClass := req.VendorClass
+	if vendorClass == "" {
+		vendorClass = fmt.Sprintf("%s:Arch:%05d:UNDI:003001", dhcp.PXEClient, uint16(req.Arch))
+	}
+	mods := []dhcpv4.Modifier{
+		dhcpv4.WithOption(dhcpv4.OptClassIdentifier(vendorClass)),
+		dhcpv4.WithOption(dhcpv4.OptClientArch(req.Arch)),
+		// UNDI 3.1, as sent by PXE clients.
+		dhcpv4.WithOption(dhcpv4.OptGeneric(dhcpv4.OptionClientNetworkInterfaceIdentifier, []byte{1, 3, 1})),
+	}
+	if userClass != "" {
+		mods = append(mods, dhcpv4.WithUserClass(userClass, false))
+	}
+	pkt, err := dhcpv4.NewDiscovery(req.MAC, mods...)
+	if err != nil {
+		return SimulatedDHCP{}, fmt.Errorf("failed to create the DHCP discover: %w", err)
+	}
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		return SimulatedDHCP{}, err
+	}
+	defer conn.Close()
+	client, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		return SimulatedDHCP{}, err
+	}
+	defer client.Close()
+
+	h.Handle(ctx, ipv4.NewPacketConn(conn), dhcp.Packet{Peer: client.LocalAddr(), Pkt: pkt, Md: &dhcp.Metadata{IfName: "simulated"}})
+
+	if err := client.SetReadDeadline(time.Now().Add(simulateTimeout)); err != nil {
+		return SimulatedDHCP{}, err
+	}
+	buf := make([]byte, 4096)
+	n, _, err := client.ReadFrom(buf)
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return SimulatedDHCP{Request: pkt}, nil
+	}
+	if err != nil {
+		return SimulatedDHCP{}, fmt.Errorf("failed to read the DHCP reply: %w", err)
+	}
+	reply, err := dhcpv4.FromBytes(buf[:n])
+	if err != nil {
+		return SimulatedDHCP{}, fmt.Errorf("failed to parse the DHCP reply: %w", err)
+	}
+
+	return SimulatedDHCP{Request: pkt, Reply: reply}, nil
+}
+
+// String formats the simulation for people.
+func (s *Simulation) String() string {
+	b := &strings.Builder{}
+	for _, d := range s.DHCP {
+		uc := string(d.Request.Options.Get(dhcpv4.OptionUserClassInformation))
+		fmt.Fprintf(b, "DHCP discover from %s, user class %q, vendor class %q\n", d.Request.ClientHWAddr, uc, d.Request.ClassIdentifier())
+		if d.Reply == nil {
+			b.WriteString("no reply\n\n")
+			continue
+		}
+		fmt.Fprintf(b, "%s\n", d.Reply.Summary())
+	}
+	if s.ScriptURL == nil {
+		b.WriteString("no iPXE script from Smee\n")
+		return b.String()
+	}
+	fmt.Fprintf(b, "iPXE script %s: %d %s\n%s\n", s.ScriptURL, s.ScriptStatus, http.StatusText(s.ScriptStatus), s.Script)
+
+	return b.String()
+}
@@ -0,0 +1,146 @@
+package smee
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"github.com/tinkerbell/tinkerbell/pkg/data"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp/pool"
+	"github.com/tinkerbell/tinkerbell/smee/internal/metric"
+)
+
+// initMetrics registers the metrics, which can only be done once.
+var initMetrics = sync.OnceFunc(metric.Init)
+
+type notFoundError struct{}
+
+func (notFoundError) NotFound() bool { return true }
+
+func (notFoundError) Error() string { return "not found" }
+
+type simulateBackend struct{}
+
+func (simulateBackend) FilterHardware(_ context.Context, f data.HardwareFilter) (*tinkerbell.Hardware, error) {
+	if f.ByMACAddress != "52:54:00:12:34:01" && f.ByIPAddress != "192.168.2.10" {
+		return nil, notFoundError{}
+	}
+	allow := true
+	return &tinkerbell.Hardware{Spec: tinkerbell.HardwareSpec{Interfaces: []tinkerbell.Interface{{
+		DHCP: &tinkerbell.DHCP{
+			MAC: "52:54:00:12:34:01",
+			IP:  &tinkerbell.IP{Address: "192.168.2.10", Netmask: "255.255.255.0", Gateway: "192.168.2.1", Family: 4},
+		},
+		Netboot: &tinkerbell.Netboot{AllowPXE: &allow},
+	}}}}, nil
+}
+
+func TestSimulate(t *testing.T) {
+	initMetrics()
+	c := NewConfig(Config{}, netip.Addr{})
+	c.Backend = simulateBackend{}
+	c.DHCP.IPForPacket = netip.MustParseAddr("192.168.2.2")
+	c.DHCP.TFTPIP = netip.MustParseAddr("192.168.2.2")
+	c.DHCP.IPXEHTTPBinaryURL.Host = "192.168.2.2:7080"
+	c.DHCP.IPXEHTTPScript.URL.Host = "192.168.2.2:7080"
+
+	s, err := c.Simulate(context.Background(), logr.Discard(), SimulateRequest{
+		MAC:  net.HardwareAddr{0x52, 0x54, 0x00, 0x12, 0x34, 0x01},
+		Arch: iana.EFI_X86_64,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The firmware is sent iPXE and iPXE is sent the script.
+	if len(s.DHCP) != 2 {
+		t.Fatalf("got %d DHCP exchanges, want 2:\n%s", len(s.DHCP), s)
+	}
+	for i, d := range s.DHCP {
+		if d.Reply == nil || d.Reply.MessageType() != dhcpv4.MessageTypeOffer || !d.Reply.YourIPAddr.Equal(net.IPv4(192, 168, 2, 10)) {
+			t.Fatalf("unexpected reply to DHCP exchange %d:\n%s", i, s)
+		}
+	}
+	if got := s.DHCP[0].Reply.BootFileName; got != "ipxe.efi" {
+		t.Errorf("firmware boot file name = %q, want ipxe.efi", got)
+	}
+	if s.ScriptURL == nil || s.ScriptURL.String() != "http://192.168.2.2:7080/ipxe/script/52:54:00:12:34:01/auto.ipxe" {
+		t.Fatalf("unexpected iPXE script URL: %v\n%s", s.ScriptURL, s)
+	}
+	if s.ScriptStatus != 200 || !strings.HasPrefix(s.Script, "#!ipxe") {
+		t.Fatalf("unexpected iPXE script, status %d:\n%s", s.ScriptStatus, s.Script)
+	}
+
+	// Machines without Hardware aren't answered.
+	s, err = c.Simulate(context.Background(), logr.Discard(), SimulateRequest{MAC: net.HardwareAddr{0x52, 0x54, 0x00, 0x12, 0x34, 0x02}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.DHCP) != 1 || s.DHCP[0].Reply != nil || s.ScriptURL != nil {
+		t.Fatalf("unexpected simulation of a machine without Hardware:\n%s", s)
+	}
+}
+
+func TestSimulateLeaseFile(t *testing.T) {
+	initMetrics()
+	c := NewConfig(Config{}, netip.Addr{})
+	c.Backend = simulateBackend{}
+	c.DHCP.IPForPacket = netip.MustParseAddr("192.168.2.2")
+	c.DHCP.TFTPIP = netip.MustParseAddr("192.168.2.2")
+	c.DHCP.Pools = []DHCPPool{{
+		Subnet:    netip.MustParsePrefix("192.168.2.0/24"),
+		Ranges:    []DHCPPoolRange{{Start: netip.MustParseAddr("192.168.2.100"), End: netip.MustParseAddr("192.168.2.110")}},
+		LeaseTime: time.Hour,
+	}}
+	c.DHCP.LeaseFile = filepath.Join(t.TempDir(), "leases.json")
+	leased := pool.Lease{MAC: "52:54:00:12:34:03", IP: netip.MustParseAddr("192.168.2.105"), Expires: time.Now().Add(time.Hour)}
+	if err := (&pool.FileStore{Path: c.DHCP.LeaseFile}).Save([]pool.Lease{leased}); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(c.DHCP.LeaseFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for mac, want := range map[string]string{"52:54:00:12:34:02": "", "52:54:00:12:34:03": "192.168.2.105"} {
+		hw, _ := net.ParseMAC(mac)
+		s, err := c.Simulate(context.Background(), logr.Discard(), SimulateRequest{MAC: hw, Arch: iana.EFI_X86_64})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := s.DHCP[0].Reply
+		if r == nil || r.MessageType() != dhcpv4.MessageTypeOffer {
+			t.Fatalf("unexpected reply to %s:\n%s", mac, s)
+		}
+		// Existing leases are offered.
+		if want != "" && !r.YourIPAddr.Equal(net.ParseIP(want)) {
+			t.Errorf("offered %v to %s, want %s", r.YourIPAddr, mac, want)
+		}
+	}
+
+	after, err := os.ReadFile(c.DHCP.LeaseFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(before), string(after)); diff != "" {
+		t.Errorf("lease file changed by the simulation:\n%s", diff)
+	}
+}
+
+func TestSimulateWithoutMAC(t *testing.T) {
+	_, err := NewConfig(Config{}, netip.Addr{}).Simulate(context.Background(), logr.Discard(), SimulateRequest{})
+	if err == nil {
+		t.Fatal("Simulate() expected an error without a MAC address")
+	}
+}
@@ -9,9 +9,14 @@ import (
 	"net/http"
 	"net/netip"
 	"net/url"
+	"os"
 	"path"
+	"path/filepath"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"dario.cat/mergo"
@@ -23,15 +28,25 @@ import (
 	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
 	"github.com/tinkerbell/tinkerbell/pkg/constant"
 	"github.com/tinkerbell/tinkerbell/pkg/data"
+	"github.com/tinkerbell/tinkerbell/smee/internal/bootfile"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp/ha"
 	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp/handler/proxy"
 	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp/handler/reservation"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp/pool"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp/ratelimit"
 	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp/server"
 	"github.com/tinkerbell/tinkerbell/smee/internal/ipxe/binary"
 	"github.com/tinkerbell/tinkerbell/smee/internal/ipxe/script"
 	"github.com/tinkerbell/tinkerbell/smee/internal/iso"
+	"github.com/tinkerbell/tinkerbell/smee/internal/lifecycle"
 	"github.com/tinkerbell/tinkerbell/smee/internal/metric"
+	"github.com/tinkerbell/tinkerbell/smee/internal/osie"
 	"github.com/tinkerbell/tinkerbell/smee/internal/syslog"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
 )
 
 // MetricsRegistry returns the Prometheus registry that contains all Smee metrics.
@@ -49,6 +64,10 @@ const (
 	DHCPModeProxy       DHCPMode = "proxy"
 	DHCPModeReservation DHCPMode = "reservation"
 	DHCPModeAutoProxy   DHCPMode = "auto-proxy"
+	// DHCPHAModeLeaderElection lets only the replica that holds a Kubernetes Lease answer DHCP.
+	DHCPHAModeLeaderElection DHCPHAMode = "leader-election"
+	// DHCPHAModeLoadBalance splits DHCP clients between the replicas by a hash of their MAC address.
+	DHCPHAModeLoadBalance DHCPHAMode = "load-balance"
 	// isoMagicString comes from the HookOS repo and is used to patch the HookOS ISO image.
 	// ref: https://github.com/tinkerbell/hook/blob/main/linuxkit-templates/hook.template.yaml
 	isoMagicString = `464vn90e7rbj08xbwdjejmdf4it17c5zfzjyfhthbh19eij201hjgit021bmpdb9ctrc87x2ymc8e7icu4ffi15x1hah9iyaiz38ckyap8hwx2vt5rm44ixv4hau8iw718q5yd019um5dt2xpqqa2rjtdypzr5v1gun8un110hhwp8cex7pqrh2ivh0ynpm4zkkwc8wcn367zyethzy7q8hzudyeyzx3cgmxqbkh825gcak7kxzjbgjajwizryv7ec1xm2h0hh7pz29qmvtgfjj1vphpgq1zcbiiehv52wrjy9yq473d9t1rvryy6929nk435hfx55du3ih05kn5tju3vijreru1p6knc988d4gfdz28eragvryq5x8aibe5trxd0t6t7jwxkde34v6pj1khmp50k6qqj3nzgcfzabtgqkmeqhdedbvwf3byfdma4nkv3rcxugaj2d0ru30pa2fqadjqrtjnv8bu52xzxv7irbhyvygygxu1nt5z4fh9w1vwbdcmagep26d298zknykf2e88kumt59ab7nq79d8amnhhvbexgh48e8qc61vq2e9qkihzt1twk1ijfgw70nwizai15iqyted2dt9gfmf2gg7amzufre79hwqkddc1cd935ywacnkrnak6r7xzcz7zbmq3kt04u2hg1iuupid8rt4nyrju51e6uejb2ruu36g9aibmz3hnmvazptu8x5tyxk820g2cdpxjdij766bt2n3djur7v623a2v44juyfgz80ekgfb9hkibpxh3zgknw8a34t4jifhf116x15cei9hwch0fye3xyq0acuym8uhitu5evc4rag3ui0fny3qg4kju7zkfyy8hwh537urd5uixkzwu5bdvafz4jmv7imypj543xg5em8jk8cgk7c4504xdd5e4e71ihaumt6u5u2t1w7um92fepzae8p0vq93wdrd1756npu1pziiur1payc7kmdwyxg3hj5n4phxbc29x0tcddamjrwt260b0w`
@@ -59,12 +78,30 @@ const (
 	DefaultTFFTPSinglePort = true
 	DefaultTFFTPTimeout    = 10 * time.Second
 	DefaultDHCPPort        = 67
+	DefaultDHCPv6Port      = 547
 	DefaultSyslogPort      = 514
+	DefaultSyslogTLSPort   = 6514
 	DefaultTinkServerPort  = 42113
+	// DefaultDHCPHALeaseName is the name of the Kubernetes Lease that elects the replica that answers DHCP.
+	DefaultDHCPHALeaseName = "smee-dhcp.tinkerbell.org"
+	// DefaultDHCPHAMaxElapsed is how long a client tries to get a lease before every replica answers it in load-balance mode.
+	DefaultDHCPHAMaxElapsed = 3 * time.Second
+	// DefaultDHCPRateLimitPerClient and DefaultDHCPRateLimitPerClientBurst allow a few netboots in a row, each of
+	// which is a DHCP exchange of the firmware, of iPXE and of the OS, with their retries.
+	DefaultDHCPRateLimitPerClient      = 2
+	DefaultDHCPRateLimitPerClientBurst = 20
+	// DefaultDHCPRateLimitGlobal and DefaultDHCPRateLimitGlobalBurst allow hundreds of machines to netboot at once.
+	DefaultDHCPRateLimitGlobal      = 500
+	DefaultDHCPRateLimitGlobalBurst = 1000
+	// DefaultDHCPNotFoundTTL is how long a Hardware lookup that found nothing is remembered.
+	DefaultDHCPNotFoundTTL = 10 * time.Second
 
 	IPXEBinaryURI = "/ipxe/binary/"
 	IPXEScriptURI = "/ipxe/script/"
 	ISOURI        = "/iso/"
+	OSIECacheURI  = "/osie/"
+	SyslogURI     = "/syslog/"
+	BootFilesURI  = "/boot/"
 )
 
 type DHCPMode string
@@ -87,16 +124,51 @@ func (d *DHCPMode) Type() string {
 	return "dhcp-mode"
 }
 
+// DHCPHAMode is how DHCP clients are shared between Smee replicas. The empty mode means every replica answers every client.
+type DHCPHAMode string
+
+func (d DHCPHAMode) String() string {
+	return string(d)
+}
+
+func (d *DHCPHAMode) Set(s string) error {
+	switch strings.ToLower(s) {
+	case "", string(DHCPHAModeLeaderElection), string(DHCPHAModeLoadBalance):
+		*d = DHCPHAMode(strings.ToLower(s))
+		return nil
+	default:
+		return fmt.Errorf("invalid DHCP HA mode: %q, must be one of [%s, %s] or empty", s, DHCPHAModeLeaderElection, DHCPHAModeLoadBalance)
+	}
+}
+
+func (d *DHCPHAMode) Type() string {
+	return "dhcp-ha-mode"
+}
+
 // Config is the configuration for the Smee service.
 type Config struct {
 	// Backend is the backend to use for getting data.
 	Backend BackendReader
+	// BootFiles is the configuration for serving user supplied boot files over TFTP and HTTP.
+	BootFiles BootFiles
 	// DHCP is the configuration for the DHCP service.
 	DHCP DHCP
+	// DHCPv6 is the configuration for the DHCPv6 service.
+	DHCPv6 DHCPv6
 	// IPXE is the configuration for the iPXE service.
 	IPXE IPXE
 	// ISO is the configuration for the ISO service.
 	ISO ISO
+	// NetbootStatus is the configuration for recording the netboot progress of machines in the status of their Hardware.
+	NetbootStatus NetbootStatus
+	// OSIECache is the configuration for serving OSIE artifacts through a disk-backed cache.
+	OSIECache OSIECache
+	// SecureBoot is the configuration for netbooting Hardware with UEFI Secure Boot enforced.
+	SecureBoot SecureBoot
+
+	// OSIEProfiles are OSIE settings, like the HookOS version, for Hardware that matches them by facility or labels.
+	// They're applied in order, before the OSIE settings of the Hardware itself, in both the iPXE script and the ISO.
+	OSIEProfiles []OSIEProfile
 	// OTEL is the configuration for OpenTelemetry.
 	OTEL OTEL
 	// Syslog is the configuration for the syslog service.
@@ -107,6 +179,23 @@ type Config struct {
 	TinkServer TinkServer
 	// TLS is the configuration for TLS.
 	TLS TLS
+
+	// syslogStore keeps received syslog messages when Syslog.Store is enabled.
+	syslogStore *syslog.Store
+	// netbootStatus records the netboot progress of machines when NetbootStatus is enabled.
+	netbootStatus *lifecycle.Recorder
+	// dhcpState is whether this replica answers DHCP, for readiness checks.
+	dhcpState *dhcpState
+	// leaseFileReadOnly loads the leases of DHCP.LeaseFile without writing new ones, for simulations.
+	leaseFileReadOnly bool
+}
+
+// dhcpState is whether this replica answers DHCP.
+type dhcpState struct {
+	// listening is the number of DHCP and DHCPv6 servers that are listening.
+	listening atomic.Int32
+	// leader is set in leader-election mode.
+	leader atomic.Pointer[ha.Leader]
 }
 
 type Syslog struct {
@@ -116,8 +205,39 @@ type Syslog struct {
 	BindPort uint16
 	// Enabled is a flag to enable or disable the syslog server.
 	Enabled bool
+	// TCPEnabled also receives messages over TCP on BindAddr and TCPBindPort, framed as described in RFC 6587.
+	TCPEnabled bool
+	// TCPBindPort is the local port to which to bind the TCP syslog server.
+	TCPBindPort uint16
+	// TLSEnabled also receives messages over TLS on BindAddr and TLSBindPort, as described in RFC 5425.
+	// The TLS certificates of Smee are used.
+	TLSEnabled bool
+	// TLSBindPort is the local port to which to bind the TLS syslog server.
+	TLSBindPort uint16
+	// Store keeps the most recent messages of each machine in memory.
+	Store SyslogStore
+	// ForwardURLs are upstream log sinks to which received messages are forwarded.
+	// Supported schemes are udp, tcp and tls for syslog servers and otlp and otlp+insecure for OpenTelemetry logs endpoints.
+	ForwardURLs []string
 }
 
+// SyslogStore is the configuration for keeping received syslog messages in memory.
+type SyslogStore struct {
+	// Enabled keeps received messages, indexed by source IP and MAC address, so they can be viewed in the UI.
+	Enabled bool
+	// MaxEntries is the number of messages kept per machine.
+	MaxEntries int
+	// MaxHosts is the number of machines for which messages are kept.
+	// Kept messages use at most about 2 KiB each, so the store uses at most about MaxEntries * MaxHosts * 2 KiB.
+	MaxHosts int
+	// HTTPEnabled serves the stored messages at SyslogURI. SyslogHandler doesn't authenticate requests,
+	// callers must wrap it with authentication.
+	HTTPEnabled bool
+}
+
+// SyslogEntry is a syslog message received from a machine.
+type SyslogEntry = syslog.Entry
+
 type TFTP struct {
 	// BindAddr is the local address to which to bind the TFTP server.
 	BindAddr netip.Addr
@@ -155,6 +275,28 @@ type IPXEHTTPScriptServer struct {
 	ExtraKernelArgs []string
 	KernelName      string
 	InitrdName      string
+	// Menu configures the iPXE boot menu.
+	Menu IPXEMenu
+}
+
+// IPXEMenu configures the iPXE boot menu that is served as auto.ipxe when enabled.
+type IPXEMenu struct {
+	// Enabled serves the boot menu instead of the boot script. Hardware that doesn't allow netbooting is served a menu
+	// with only the local disk entry.
+	Enabled bool
+	// Timeout after which the default entry is booted. Zero waits for a selection.
+	Timeout time.Duration
+	// Default is the name of the entry booted after the timeout, for Hardware that allows netbooting.
+	Default string
+	// Entries are custom menu entries added to the menu of all Hardware.
+	Entries []IPXEMenuEntry
+}
+
+// IPXEMenuEntry is a custom boot menu entry that chain loads a URL.
+type IPXEMenuEntry struct {
+	Name  string
+	Label string
+	URL   string
 }
 
 type DHCP struct {
@@ -182,6 +324,106 @@ type DHCP struct {
 	IPXEHTTPBinaryURL *url.URL
 	// IPXEHTTPScript is the URL to the iPXE script to use.
 	IPXEHTTPScript IPXEHTTPScript
+	// Pools are dynamic address pools for machines without a Hardware object.
+	// They are only used in reservation mode. Machines with a dynamic address are allowed to netboot into HookOS.
+	Pools []DHCPPool
+	// LeaseFile is the file dynamic pool leases are persisted to.
+	// When empty, leases are only kept in memory and are lost on restart.
+	LeaseFile string
+	// Options are raw DHCP options sent to all clients.
+	// They are replaced by the options of a client's pool and Hardware object.
+	Options []DHCPOption
+	// TrustedRelays are the relay agents whose relay agent information (option 82) is used to match Hardware in reservation mode.
+	// When empty, Hardware is never matched by option 82.
+	TrustedRelays []netip.Prefix
+	// HA is the configuration for running the DHCP and DHCPv6 servers in more than one replica.
+	HA DHCPHA
+	// RateLimit is the configuration for protecting the DHCP and DHCPv6 servers and the backend from floods of DHCP messages.
+	RateLimit DHCPRateLimit
+}
+
+// DHCPRateLimit limits the DHCP and DHCPv6 messages answered. Messages over a limit are dropped.
+type DHCPRateLimit struct {
+	// PerClient is the number of messages per second answered for a single MAC address. Zero disables the per-client limit.
+	PerClient float64
+	// PerClientBurst is the number of messages of a single MAC address answered at once.
+	PerClientBurst int
+	// Global is the number of messages per second answered for all clients together. Zero disables the global limit.
+	Global float64
+	// GlobalBurst is the number of messages answered at once.
+	GlobalBurst int
+	// NotFoundTTL is how long a Hardware lookup that found nothing is remembered, so that the messages of machines
+	// without Hardware don't each cause a backend lookup. Zero disables it.
+	NotFoundTTL time.Duration
+}
+
+// DHCPHA is the configuration for running the DHCP and DHCPv6 servers in more than one replica.
+// Without a mode every replica answers every client.
+type DHCPHA struct {
+	// Mode is how clients are shared between the replicas.
+	Mode DHCPHAMode
+	// LeaseName is the name of the Kubernetes Lease that elects the replica that answers DHCP, in leader-election mode.
+	LeaseName string
+	// LeaseNamespace is the namespace of the Lease.
+	LeaseNamespace string
+	// KubeConfig is the configuration of the Kubernetes API server that holds the Lease.
+	KubeConfig *rest.Config
+	// Replicas is the number of replicas in load-balance mode.
+	Replicas int
+	// ReplicaIndex is the index of this replica, from 0 to Replicas-1, in load-balance mode.
+	// When negative, it's the ordinal at the end of the hostname, like the hostname of a StatefulSet Pod.
+	ReplicaIndex int
+	// MaxElapsed is how long a client tries to get a lease before every replica answers it, in load-balance mode.
+	// This keeps clients of a replica that is down from waiting for it. Zero disables it.
+	MaxElapsed time.Duration
+}
+
+// DHCPOption is a raw DHCP option.
+type DHCPOption struct {
+	// Code is the DHCP option code, 1-254.
+	Code int
+	// Type is how Value is encoded, one of ip, ip-list, string, uint8, uint16, uint32, bool or hex.
+	Type string
+	// Value is the option value.
+	Value string
+}
+
+// DHCPPool is a range of addresses, in a single subnet, handed out to machines without a Hardware object.
+// A pool without ranges only provides the settings of its subnet.
+type DHCPPool struct {
+	// Subnet is the IPv4 network the pool serves.
+	// Relayed requests are matched to a pool by their giaddr, other requests by the DHCP IPForPacket.
+	Subnet netip.Prefix
+	// Ranges are the inclusive address ranges to hand out.
+	Ranges []DHCPPoolRange
+	// Gateway is the default gateway, DHCP option 3.
+	Gateway netip.Addr
+	// NameServers are the DNS servers, DHCP option 6.
+	NameServers []netip.Addr
+	// LeaseTime is how long a dynamic lease is valid for, DHCP option 51.
+	LeaseTime time.Duration
+	// Options are raw DHCP options sent to clients in the subnet.
+	Options []DHCPOption
+}
+
+// DHCPPoolRange is an inclusive range of addresses.
+type DHCPPoolRange struct {
+	Start netip.Addr
+	End   netip.Addr
+}
+
+// DHCPv6 is the configuration for the DHCPv6 service.
+// The DHCPv6 server uses the mode, bind interface, TFTP port and iPXE settings of the DHCP configuration.
+type DHCPv6 struct {
+	// Enabled configures whether the DHCPv6 server is enabled.
+	Enabled bool
+	// BindAddr is the local address to which to bind the DHCPv6 server and listen for DHCPv6 packets.
+	// When unspecified, the All_DHCP_Relay_Agents_and_Servers and All_DHCP_Servers multicast groups are joined.
+	BindAddr netip.Addr
+	BindPort uint16
+	// IPForPacket is the IPv6 address of Smee. It is used in netboot URLs sent to DHCPv6 clients,
+	// in place of the IPv4 address of the DHCP configuration, and to create the DHCPv6 server identifier.
+	IPForPacket netip.Addr
 }
 
 type IPXEHTTPBinary struct {
@@ -211,6 +453,92 @@ type ISO struct {
 	UpstreamURL       *url.URL
 	PatchMagicString  string
 	StaticIPAMEnabled bool
+	// Cache configures the local cache of upstream ISOs.
+	Cache ISOCache
+	// Generate configures the bootable ISOs that Smee builds for machines.
+	Generate ISOGenerate
+}
+
+// ISOCache is the configuration for the local, content-addressed cache of upstream ISOs.
+// Patching is applied when cached ISOs are read, so one cached ISO serves all Hardware.
+type ISOCache struct {
+	Enabled bool
+	// Dir is the directory in which ISOs are cached.
+	Dir string
+	// MaxBytes is the maximum total size of the cached ISOs. The least recently used ISOs are evicted when it is exceeded. Zero means no limit.
+	MaxBytes int64
+	// Hosts are the upstream hosts, in addition to the host of ISO.UpstreamURL, whose ISOs are cached.
+	// ISOs from other hosts, for example from a sourceISO query parameter, are proxied without being cached.
+	Hosts []string
+}
+
+// ISOGenerate is the configuration for the small bootable ISOs that Smee builds for machines, as an alternative to patching an upstream ISO.
+// They boot UEFI machines into iPXE with a script for the machine that chains its iPXE script (ipxe.iso) or downloads
+// the OSIE kernel and initrd and boots them (osie.iso).
+type ISOGenerate struct {
+	Enabled bool
+	// Dir is the directory in which generated ISOs are kept, so that all the range requests of a BMC read the same ISO.
+	Dir string
+	// MaxBytes is the maximum total size of the generated ISOs. The least recently used ISOs are removed when it is exceeded. Zero means no limit.
+	MaxBytes int64
+}
+
+// OSIECache is the configuration for serving the OSIE (HookOS) kernel and initrd from Smee.
+// Artifacts are fetched from IPXE.HTTPScriptServer.OSIEURL on first request and cached on disk.
+type OSIECache struct {
+	// Enabled serves OSIE artifacts from the cache and points the iPXE script at Smee instead of the OSIE URL.
+	Enabled bool
+	// Dir is the directory in which artifacts are cached.
+	Dir string
+	// ChecksumFile is the name of a sha256sum or sha512sum formatted file, relative to the OSIE URL, used to verify artifacts.
+	ChecksumFile string
+}
+
+// BootFiles is the configuration for serving a tree of user supplied boot files, like Raspberry Pi firmware,
+// shim and GRUB or vendor NBPs. Files are served over TFTP and the iPXE binary HTTP server when they aren't
+// embedded iPXE binaries, and at BootFilesURI.
+type BootFiles struct {
+	// Dir is the directory with the boot files. Boot files are not served when it's empty.
+	Dir string
+	// OCIRef is an OCI artifact, for example ghcr.io/example/bootfiles:v1, that is extracted into Dir when Smee starts.
+	OCIRef string
+	// PathTemplates map a requested file to a path in Dir. They're tried in order and the first file that exists is served.
+	// Templates can use .MAC, .IP and .Path, for example machines/{{ .MAC | replace ":" "-" }}/{{ .Path }}.
+	// Defaults to {{ .Path }}.
+	PathTemplates []string
+}
+
+// SecureBoot is the configuration for netbooting Hardware with UEFI Secure Boot enforced.
+// UEFI clients of Hardware with Secure Boot enabled are sent a signed shim from the boot files directory instead of iPXE.
+// The shim loads GRUB from the same directory and GRUB loads the OSIE with a per machine grub.cfg generated by Smee.
+type SecureBoot struct {
+	// ShimX86_64 is the path of the signed shim for x86_64 UEFI clients in the boot files directory.
+	ShimX86_64 string
+	// ShimARM64 is the path of the signed shim for arm64 UEFI clients in the boot files directory.
+	ShimARM64 string
+}
+
+// NetbootStatus is the configuration for recording the netboot progress of machines, the DHCP responses, iPXE binaries,
+// scripts and ISOs that they're served, in the status of their Hardware. It requires a backend that can update Hardware.
+type NetbootStatus struct {
+	// Enabled records the netboot progress of machines.
+	Enabled bool
+	// Interval is the minimum time between status updates of a Hardware. Events in between are batched.
+	Interval time.Duration
+}
+
+// OSIEProfile holds OSIE settings for Hardware that matches it.
+type OSIEProfile struct {
+	// Facility matches Hardware with this facility code. Empty matches Hardware in any facility.
+	Facility string
+	// Selector matches Hardware by its labels. Nil matches Hardware with any labels.
+	Selector labels.Selector
+	// Version is the HookOS version, a directory under the OSIE URL and next to the source ISO.
+	Version string
+	// KernelParams are extra kernel parameters, appended to ExtraKernelArgs.
+	KernelParams []string
+	// Consoles replace the default consoles, for example ttyS0,115200.
+	Consoles []string
 }
 
 type TinkServer struct {
@@ -246,6 +574,24 @@ func NewConfig(c Config, publicIP netip.Addr) *Config {
 				InjectMacAddress: true,
 			},
 			TFTPPort: DefaultTFFTPPort,
+			HA: DHCPHA{
+				LeaseName:    DefaultDHCPHALeaseName,
+				Replicas:     1,
+				ReplicaIndex: -1,
+				MaxElapsed:   DefaultDHCPHAMaxElapsed,
+			},
+			RateLimit: DHCPRateLimit{
+				PerClient:      DefaultDHCPRateLimitPerClient,
+				PerClientBurst: DefaultDHCPRateLimitPerClientBurst,
+				Global:         DefaultDHCPRateLimitGlobal,
+				GlobalBurst:    DefaultDHCPRateLimitGlobalBurst,
+				NotFoundTTL:    DefaultDHCPNotFoundTTL,
+			},
+		},
+		DHCPv6: DHCPv6{
+			Enabled:  false,
+			BindAddr: netip.IPv6Unspecified(),
+			BindPort: DefaultDHCPv6Port,
 		},
 		IPXE: IPXE{
 			EmbeddedScriptPatch: "",
@@ -259,26 +605,57 @@ func NewConfig(c Config, publicIP netip.Addr) *Config {
 				OSIEURL:         &url.URL{},
 				TrustedProxies:  []string{},
 				ExtraKernelArgs: []string{},
+				Menu: IPXEMenu{
+					Timeout: 10 * time.Second,
+					Entries: []IPXEMenuEntry{},
+				},
 			},
 			IPXEBinary: IPXEHTTPBinary{
 				InjectMacAddrFormat: constant.MacAddrFormatColon,
 				IPXEArchMapping:     map[iana.Arch]constant.IPXEBinary{},
 			},
 		},
+		OSIECache: OSIECache{
+			Dir: filepath.Join(os.TempDir(), "tinkerbell-osie-cache"),
+		},
+		SecureBoot: SecureBoot{
+			ShimX86_64: "secureboot/shimx64.efi",
+			ShimARM64:  "secureboot/shimaa64.efi",
+		},
 		ISO: ISO{
+			Cache: ISOCache{
+				Dir:      filepath.Join(os.TempDir(), "tinkerbell-iso-cache"),
+				MaxBytes: 20 << 30,
+			},
+			Generate: ISOGenerate{
+				Dir:      filepath.Join(os.TempDir(), "tinkerbell-iso-generated"),
+				MaxBytes: 1 << 30,
+			},
 			Enabled:           false,
 			UpstreamURL:       &url.URL{},
 			PatchMagicString:  "",
 			StaticIPAMEnabled: false,
 		},
+		NetbootStatus: NetbootStatus{
+			Enabled:  true,
+			Interval: lifecycle.DefaultInterval,
+		},
 		OTEL: OTEL{
 			Endpoint:         "",
 			InsecureEndpoint: false,
 		},
 		Syslog: Syslog{
-			BindAddr: publicIP,
-			BindPort: DefaultSyslogPort,
-			Enabled:  true,
+			BindAddr:    publicIP,
+			BindPort:    DefaultSyslogPort,
+			Enabled:     true,
+			TCPBindPort: DefaultSyslogPort,
+			TLSBindPort: DefaultSyslogTLSPort,
+			Store: SyslogStore{
+				Enabled:    true,
+				MaxEntries: 200,
+				MaxHosts:   64,
+			},
+			ForwardURLs: []string{},
 		},
 		TFTP: TFTP{
 			BindAddr:   publicIP,
@@ -294,6 +671,9 @@ func NewConfig(c Config, publicIP netip.Addr) *Config {
 	if err := mergo.Merge(defaults, &c, mergo.WithTransformers(&c)); err != nil {
 		panic(fmt.Sprintf("failed to merge config: %v", err))
 	}
+	defaults.syslogStore = &syslog.Store{}
+	defaults.netbootStatus = &lifecycle.Recorder{}
+	defaults.dhcpState = &dhcpState{}
 
 	return defaults
 }
@@ -313,7 +693,39 @@ func (c *Config) BinaryHandler(log logr.Logger) http.Handler {
 	if !c.IPXE.HTTPBinaryServer.Enabled {
 		return nil
 	}
-	return http.HandlerFunc(binary.Handler{Log: log, Patch: []byte(c.IPXE.EmbeddedScriptPatch)}.Handle)
+	// An invalid boot files configuration is reported by BootFilesHandler and Start.
+	bf, _ := c.bootFiles(log)
+	return http.HandlerFunc(binary.Handler{Log: log, Patch: []byte(c.IPXE.EmbeddedScriptPatch), Prefix: IPXEBinaryURI, BootFiles: bf, NetbootStatus: c.netbootRecorder()}.Handle)
+}
+
+// BootFilesHandler returns an http.Handler that serves user supplied boot files.
+// Returns nil, nil if no boot files directory is configured.
+func (c *Config) BootFilesHandler(log logr.Logger) (http.Handler, error) {
+	bf, err := c.bootFiles(log)
+	if err != nil || bf == nil {
+		return nil, err
+	}
+	return bf, nil
+}
+
+// bootFiles returns the tree of user supplied boot files, or nil when no boot files directory is configured.
+func (c *Config) bootFiles(log logr.Logger) (*bootfile.Tree, error) {
+	if c.BootFiles.Dir == "" {
+		return nil, nil
+	}
+	templates, err := bootfile.ParsePathTemplates(c.BootFiles.PathTemplates)
+	if err != nil {
+		return nil, err
+	}
+	return &bootfile.Tree{
+		Log:           log,
+		Root:          c.BootFiles.Dir,
+		Prefix:        BootFilesURI,
+		PathTemplates: templates,
+		Backend:       c.Backend,
+		// GRUB, loaded by the Secure Boot shim, requests its configuration next to itself.
+		Generate: c.scriptHandler(log).GRUBConfig,
+	}, nil
 }
 
 // ScriptHandler returns an http.Handler that serves iPXE scripts.
@@ -322,10 +734,15 @@ func (c *Config) ScriptHandler(log logr.Logger) http.Handler {
 	if !c.IPXE.HTTPScriptServer.Enabled {
 		return nil
 	}
-	jh := script.Handler{
+	return c.scriptHandler(log).HandlerFunc()
+}
+
+// scriptHandler returns the handler that generates iPXE scripts and GRUB configurations.
+func (c *Config) scriptHandler(log logr.Logger) *script.Handler {
+	jh := &script.Handler{
 		Logger:                log,
 		Backend:               c.Backend,
-		OSIEURL:               c.IPXE.HTTPScriptServer.OSIEURL.String(),
+		OSIEURL:               c.osieURL(),
 		ExtraKernelParams:     c.IPXE.HTTPScriptServer.ExtraKernelArgs,
 		PublicSyslogFQDN:      c.DHCP.SyslogIP.String(),
 		TinkServerTLS:         c.TinkServer.UseTLS,
@@ -333,14 +750,99 @@ func (c *Config) ScriptHandler(log logr.Logger) http.Handler {
 		TinkServerGRPCAddr:    c.TinkServer.AddrPort,
 		IPXEScriptRetries:     c.IPXE.HTTPScriptServer.Retries,
 		IPXEScriptRetryDelay:  c.IPXE.HTTPScriptServer.RetryDelay,
-		StaticIPXEEnabled:     (c.DHCP.Mode == DHCPModeAutoProxy),
+		StaticIPXEEnabled:     c.DHCP.Mode == DHCPModeAutoProxy || (c.DHCP.Mode == DHCPModeReservation && len(c.DHCP.Pools) > 0),
 		KernelName:            c.IPXE.HTTPScriptServer.KernelName,
 		InitrdName:            c.IPXE.HTTPScriptServer.InitrdName,
+		OSIEProfiles:          c.osieProfiles(),
+		NetbootStatus:         c.netbootRecorder(),
+	}
+	if m := c.IPXE.HTTPScriptServer.Menu; m.Enabled {
+		jh.Menu = script.MenuConfig{Enabled: true, Timeout: m.Timeout, Default: m.Default}
+		for _, e := range m.Entries {
+			jh.Menu.Entries = append(jh.Menu.Entries, script.MenuEntry{Name: e.Name, Label: e.Label, URL: e.URL})
+		}
+	}
+	// IPXEScript templates are only available with backends that can list them, like the Kubernetes backend.
+	if l, ok := c.Backend.(script.IPXEScriptLister); ok {
+		jh.Scripts = l
+	}
+	return jh
+}
+
+// osieURL returns the URL from which the iPXE script downloads the OSIE kernel and initrd.
+// When the OSIE cache is enabled this is Smee itself, on the same host as the iPXE script.
+func (c *Config) osieURL() string {
+	if c.OSIECache.Enabled && c.DHCP.IPXEHTTPScript.URL != nil {
+		u := url.URL{
+			Scheme: c.DHCP.IPXEHTTPScript.URL.Scheme,
+			Host:   c.DHCP.IPXEHTTPScript.URL.Host,
+			Path:   strings.TrimSuffix(OSIECacheURI, "/"),
+		}
+		return u.String()
+	}
+	return c.IPXE.HTTPScriptServer.OSIEURL.String()
+}
+
+// osieProfiles converts the OSIE profiles for the iPXE script and ISO handlers.
+func (c *Config) osieProfiles() []osie.Profile {
+	var profiles []osie.Profile
+	for _, p := range c.OSIEProfiles {
+		profiles = append(profiles, osie.Profile{
+			Facility: p.Facility,
+			Selector: p.Selector,
+			Settings: osie.Settings{Version: p.Version, KernelParams: p.KernelParams, Consoles: p.Consoles},
+		})
+	}
+
+	return profiles
+}
+
+// SyslogHandler returns an http.Handler that serves the stored syslog messages.
+// Returns nil if the syslog server, the store or serving it over HTTP is disabled.
+func (c *Config) SyslogHandler() http.Handler {
+	if !c.Syslog.Enabled || !c.Syslog.Store.Enabled || !c.Syslog.Store.HTTPEnabled || c.syslogStore == nil {
+		return nil
 	}
-	return jh.HandlerFunc()
+	return c.syslogStore
 }
 
-// ISOHandler returns an http.Handler that serves patched ISO images.
+// SyslogEntries returns the stored syslog messages of the machine with the IP or MAC address, oldest first.
+// Returns nil if the syslog server or the store is disabled.
+func (c *Config) SyslogEntries(key string) []SyslogEntry {
+	if !c.Syslog.Enabled || !c.Syslog.Store.Enabled || c.syslogStore == nil {
+		return nil
+	}
+	return c.syslogStore.Entries(key)
+}
+
+// netbootRecorder returns the recorder of the netboot progress of machines.
+// Returns nil if recording is disabled or the backend can't update Hardware.
+func (c *Config) netbootRecorder() *lifecycle.Recorder {
+	if !c.NetbootStatus.Enabled || c.netbootStatus == nil {
+		return nil
+	}
+	if _, ok := c.Backend.(lifecycle.Backend); !ok {
+		return nil
+	}
+	return c.netbootStatus
+}
+
+// OSIECacheHandler returns an http.Handler that serves OSIE artifacts through a disk-backed cache.
+// Returns nil if the OSIE cache is disabled.
+func (c *Config) OSIECacheHandler(log logr.Logger) http.Handler {
+	if !c.OSIECache.Enabled {
+		return nil
+	}
+	return &osie.Cache{
+		Logger:       log,
+		Upstream:     c.IPXE.HTTPScriptServer.OSIEURL,
+		Prefix:       OSIECacheURI,
+		Dir:          c.OSIECache.Dir,
+		ChecksumFile: c.OSIECache.ChecksumFile,
+	}
+}
+
+// ISOHandler returns an http.Handler that serves patched, and optionally generated, ISO images.
 // Returns nil, nil if the ISO server is disabled.
 func (c *Config) ISOHandler(log logr.Logger) (http.Handler, error) {
 	if !c.ISO.Enabled {
@@ -365,6 +867,31 @@ func (c *Config) ISOHandler(log logr.Logger) (http.Handler, error) {
 			SourceISO:         c.ISO.UpstreamURL.String(),
 			StaticIPAMEnabled: c.ISO.StaticIPAMEnabled,
 		},
+		OSIEProfiles:  c.osieProfiles(),
+		NetbootStatus: c.netbootRecorder(),
+	}
+	if c.ISO.Cache.Enabled {
+		hosts := slices.Clone(c.ISO.Cache.Hosts)
+		if c.ISO.UpstreamURL != nil && c.ISO.UpstreamURL.Host != "" {
+			hosts = append(hosts, c.ISO.UpstreamURL.Host)
+		}
+		ih.Cache = &iso.Cache{
+			Logger:   log,
+			Dir:      c.ISO.Cache.Dir,
+			MaxBytes: c.ISO.Cache.MaxBytes,
+			Hosts:    hosts,
+		}
+	}
+	if c.ISO.Generate.Enabled {
+		ih.Generator = &iso.Generator{
+			Dir:        c.ISO.Generate.Dir,
+			MaxBytes:   c.ISO.Generate.MaxBytes,
+			ScriptURL:  c.DHCP.IPXEHTTPScript.URL,
+			InjectMAC:  c.DHCP.IPXEHTTPScript.InjectMacAddress,
+			OSIEURL:    c.osieURL(),
+			KernelName: c.IPXE.HTTPScriptServer.KernelName,
+			InitrdName: c.IPXE.HTTPScriptServer.InitrdName,
+		}
 	}
 	h, err := ih.HandlerFunc()
 	if err != nil {
@@ -380,19 +907,69 @@ func (c *Config) Start(ctx context.Context, log logr.Logger) error {
 		return errors.New("no backend provided")
 	}
 	if c.noServicesEnabled() {
-		return errors.New("all Smee services are disabled (DHCP, TFTP, syslog, iPXE binary, iPXE script, ISO)")
+		return errors.New("all Smee services are disabled (DHCP, DHCPv6, TFTP, syslog, iPXE binary, iPXE script, ISO, OSIE cache)")
 	}
 
 	g, ctx := errgroup.WithContext(ctx)
+	bootFiles, err := c.bootFiles(log)
+	if err != nil {
+		return err
+	}
+	if c.BootFiles.OCIRef != "" {
+		if c.BootFiles.Dir == "" {
+			return errors.New("a boot files directory is required to extract the boot files OCI artifact into")
+		}
+		// Files that are already in the directory are served while the artifact is pulled.
+		// Pulled files only replace them once the whole artifact is extracted and verified.
+		go func() {
+			log.Info("pulling boot files", "ref", c.BootFiles.OCIRef, "dir", c.BootFiles.Dir)
+			digest, err := bootfile.Pull(ctx, c.BootFiles.OCIRef, c.BootFiles.Dir)
+			if err != nil {
+				log.Error(err, "unable to pull boot files", "ref", c.BootFiles.OCIRef)
+				return
+			}
+			log.Info("boot files pulled", "ref", c.BootFiles.OCIRef, "digest", digest)
+		}()
+	}
+
 	// syslog
 	if c.Syslog.Enabled {
 		addr := netip.AddrPortFrom(c.Syslog.BindAddr, c.Syslog.BindPort)
 		if !addr.IsValid() {
 			return fmt.Errorf("invalid syslog bind address: IP: %v, Port: %v", addr.Addr(), addr.Port())
 		}
-		log.Info("starting syslog server", "bindAddr", addr)
+		opts := []syslog.ReceiverOption{syslog.WithBackend(c.Backend)}
+		if c.Syslog.Store.Enabled && c.syslogStore != nil {
+			c.syslogStore.SetLimits(c.Syslog.Store.MaxEntries, c.Syslog.Store.MaxHosts)
+			opts = append(opts, syslog.WithStore(c.syslogStore))
+		}
+		if len(c.Syslog.ForwardURLs) > 0 {
+			fs := map[string]syslog.Forwarder{}
+			for _, raw := range c.Syslog.ForwardURLs {
+				u, err := url.Parse(raw)
+				if err != nil {
+					return fmt.Errorf("invalid syslog forward URL: %w", err)
+				}
+				f, err := syslog.NewForwarder(u)
+				if err != nil {
+					return err
+				}
+				fs[u.Redacted()] = f
+			}
+			opts = append(opts, syslog.WithForwarders(fs))
+		}
+		if c.Syslog.TCPEnabled {
+			opts = append(opts, syslog.WithTCP(netip.AddrPortFrom(c.Syslog.BindAddr, c.Syslog.TCPBindPort).String()))
+		}
+		if c.Syslog.TLSEnabled {
+			if len(c.TLS.Certs) == 0 {
+				return errors.New("receiving syslog messages over TLS requires a TLS certificate")
+			}
+			opts = append(opts, syslog.WithTLS(netip.AddrPortFrom(c.Syslog.BindAddr, c.Syslog.TLSBindPort).String(), &tls.Config{Certificates: c.TLS.Certs, MinVersion: tls.VersionTLS12}))
+		}
+		log.Info("starting syslog server", "bindAddr", addr, "tcp", c.Syslog.TCPEnabled, "tls", c.Syslog.TLSEnabled, "store", c.Syslog.Store.Enabled, "forwarders", len(c.Syslog.ForwardURLs))
 		g.Go(func() error {
-			if err := syslog.StartReceiver(ctx, log, addr.String(), 1); err != nil {
+			if err := syslog.StartReceiver(ctx, log, addr.String(), 1, opts...); err != nil {
 				log.Error(err, "syslog server failure")
 				return err
 			}
@@ -402,6 +979,18 @@ func (c *Config) Start(ctx context.Context, log logr.Logger) error {
 		})
 	}
 
+	// netboot status
+	if r := c.netbootRecorder(); r != nil {
+		r.Log = log
+		r.Backend = c.Backend.(lifecycle.Backend)
+		r.Interval = c.NetbootStatus.Interval
+		log.Info("recording the netboot status of Hardware", "interval", r.Interval)
+		g.Go(func() error {
+			r.Run(ctx)
+			return nil
+		})
+	}
+
 	// tftp
 	if c.TFTP.Enabled {
 		addrPort := netip.AddrPortFrom(c.TFTP.BindAddr, c.TFTP.BindPort)
@@ -415,6 +1004,8 @@ func (c *Config) Start(ctx context.Context, log logr.Logger) error {
 			Timeout:              c.TFTP.Timeout,
 			Patch:                []byte(c.IPXE.EmbeddedScriptPatch),
 			BlockSize:            c.TFTP.BlockSize,
+			BootFiles:            bootFiles,
+			NetbootStatus:        c.netbootRecorder(),
 		}
 
 		log.Info("starting tftp server", "bindAddr", addrPort.String())
@@ -423,12 +1014,33 @@ func (c *Config) Start(ctx context.Context, log logr.Logger) error {
 		})
 	}
 
+	// dhcp storm protection
+	dhcpBackend := c.dhcpBackend()
+	limiter := c.dhcpLimiter()
+
+	// dhcp high availability
+	gate, err := c.dhcpGate(log)
+	if err != nil {
+		return err
+	}
+	if l, ok := gate.(*ha.Leader); ok {
+		g.Go(func() error {
+			return l.Run(ctx)
+		})
+	}
+
 	// dhcp serving
 	if c.DHCP.Enabled {
-		dh, err := c.dhcpHandler(log)
+		dh, err := c.dhcpHandler(log, dhcpBackend)
 		if err != nil {
 			return fmt.Errorf("failed to create dhcp listener: %w", err)
 		}
+		if limiter != nil {
+			dh = &ratelimit.Handler{Log: log, Limiter: limiter, Next: dh}
+		}
+		if gate != nil {
+			dh = &ha.Handler{Log: log, Gate: gate, Next: dh}
+		}
 		dhcpAddrPort := netip.AddrPortFrom(c.DHCP.BindAddr, c.DHCP.BindPort)
 		if !dhcpAddrPort.IsValid() {
 			return fmt.Errorf("invalid DHCP bind address: IP: %v, Port: %v", dhcpAddrPort.Addr(), dhcpAddrPort.Port())
@@ -440,12 +1052,44 @@ func (c *Config) Start(ctx context.Context, log logr.Logger) error {
 				return err
 			}
 			defer conn.Close()
+			c.dhcpState.listening.Add(1)
+			defer c.dhcpState.listening.Add(-1)
 			ds := &server.DHCP{Logger: log, Conn: conn, Handlers: []server.Handler{dh}}
 
 			return ds.Serve(ctx)
 		})
 	}
 
+	// dhcpv6 serving
+	if c.DHCPv6.Enabled {
+		dh, err := c.dhcpv6Handler(log, dhcpBackend)
+		if err != nil {
+			return fmt.Errorf("failed to create dhcpv6 listener: %w", err)
+		}
+		if limiter != nil {
+			dh = &ratelimit.Handler6{Log: log, Limiter: limiter, Next: dh}
+		}
+		if gate != nil {
+			dh = &ha.Handler6{Log: log, Gate: gate, Next: dh}
+		}
+		dhcpAddrPort := netip.AddrPortFrom(c.DHCPv6.BindAddr, c.DHCPv6.BindPort)
+		if !dhcpAddrPort.IsValid() || !dhcpAddrPort.Addr().Is6() {
+			return fmt.Errorf("invalid DHCPv6 bind address: IP: %v, Port: %v", dhcpAddrPort.Addr(), dhcpAddrPort.Port())
+		}
+		log.Info("starting dhcpv6 server", "bindAddr", dhcpAddrPort)
+		g.Go(func() error {
+			ds, err := server.NewServer6(c.DHCP.BindInterface, net.UDPAddrFromAddrPort(dhcpAddrPort), dh)
+			if err != nil {
+				return err
+			}
+			ds.Logger = log
+			c.dhcpState.listening.Add(1)
+			defer c.dhcpState.listening.Add(-1)
+
+			return ds.Serve(ctx)
+		})
+	}
+
 	if err := g.Wait(); err != nil && !errors.Is(err, context.Canceled) {
 		return fmt.Errorf("failed running all Smee services: %w", err)
 	}
@@ -453,7 +1097,7 @@ func (c *Config) Start(ctx context.Context, log logr.Logger) error {
 	return nil
 }
 
-func (c *Config) dhcpHandler(log logr.Logger) (server.Handler, error) {
+func (c *Config) dhcpHandler(log logr.Logger, backend BackendReader) (server.Handler, error) {
 	// 1. create the handler
 	// 2. create the backend
 	// 3. add the backend to the handler
@@ -484,10 +1128,19 @@ func (c *Config) dhcpHandler(log logr.Logger) (server.Handler, error) {
 		}
 	}
 
+	opts, err := dhcpOptions(c.DHCP.Options)
+	if err != nil {
+		return nil, err
+	}
+
 	switch c.DHCP.Mode {
 	case DHCPModeReservation:
+		pools, err := c.dhcpPools()
+		if err != nil {
+			return nil, err
+		}
 		dh := &reservation.Handler{
-			Backend: c.Backend,
+			Backend: backend,
 			IPAddr:  c.DHCP.IPForPacket,
 			Log:     log,
 			Netboot: reservation.Netboot{
@@ -497,14 +1150,20 @@ func (c *Config) dhcpHandler(log logr.Logger) (server.Handler, error) {
 				Enabled:             c.DHCP.EnableNetbootOptions,
 				InjectMacAddrFormat: c.IPXE.IPXEBinary.InjectMacAddrFormat,
 				IPXEArchMapping:     c.IPXE.IPXEBinary.IPXEArchMapping,
+				IPXEMenu:            c.IPXE.HTTPScriptServer.Menu.Enabled,
+				SecureBootShims:     c.secureBootShims(),
 			},
-			OTELEnabled: true,
-			SyslogAddr:  c.DHCP.SyslogIP,
+			OTELEnabled:   true,
+			SyslogAddr:    c.DHCP.SyslogIP,
+			Pools:         pools,
+			Options:       opts,
+			NetbootStatus: c.netbootRecorder(),
+			TrustedRelays: c.DHCP.TrustedRelays,
 		}
 		return dh, nil
 	case DHCPModeProxy:
 		dh := &proxy.Handler{
-			Backend: c.Backend,
+			Backend: backend,
 			IPAddr:  c.DHCP.IPForPacket,
 			Log:     log,
 			Netboot: proxy.Netboot{
@@ -514,14 +1173,16 @@ func (c *Config) dhcpHandler(log logr.Logger) (server.Handler, error) {
 				Enabled:             c.DHCP.EnableNetbootOptions,
 				InjectMacAddrFormat: c.IPXE.IPXEBinary.InjectMacAddrFormat,
 				IPXEArchMapping:     c.IPXE.IPXEBinary.IPXEArchMapping,
+				IPXEMenu:            c.IPXE.HTTPScriptServer.Menu.Enabled,
 			},
 			OTELEnabled:      true,
 			AutoProxyEnabled: false,
+			Options:          opts,
 		}
 		return dh, nil
 	case DHCPModeAutoProxy:
 		dh := &proxy.Handler{
-			Backend: c.Backend,
+			Backend: backend,
 			IPAddr:  c.DHCP.IPForPacket,
 			Log:     log,
 			Netboot: proxy.Netboot{
@@ -531,9 +1192,11 @@ func (c *Config) dhcpHandler(log logr.Logger) (server.Handler, error) {
 				Enabled:             c.DHCP.EnableNetbootOptions,
 				InjectMacAddrFormat: c.IPXE.IPXEBinary.InjectMacAddrFormat,
 				IPXEArchMapping:     c.IPXE.IPXEBinary.IPXEArchMapping,
+				IPXEMenu:            c.IPXE.HTTPScriptServer.Menu.Enabled,
 			},
 			OTELEnabled:      true,
 			AutoProxyEnabled: true,
+			Options:          opts,
 		}
 		return dh, nil
 	}
@@ -541,6 +1204,250 @@ func (c *Config) dhcpHandler(log logr.Logger) (server.Handler, error) {
 	return nil, errors.New("invalid dhcp mode")
 }
 
+// dhcpBackend returns the backend of the DHCP and DHCPv6 handlers, which remembers the lookups that found no Hardware.
+func (c *Config) dhcpBackend() BackendReader {
+	if c.DHCP.RateLimit.NotFoundTTL <= 0 {
+		return c.Backend
+	}
+
+	return &ratelimit.NotFoundCache{Backend: c.Backend, TTL: c.DHCP.RateLimit.NotFoundTTL}
+}
+
+// dhcpLimiter returns the rate limits shared by the DHCP and DHCPv6 servers.
+// Returns nil when there are no limits.
+func (c *Config) dhcpLimiter() *ratelimit.Limiter {
+	rl := c.DHCP.RateLimit
+	if rl.PerClient <= 0 && rl.Global <= 0 {
+		return nil
+	}
+
+	return &ratelimit.Limiter{
+		PerClient:      rate.Limit(rl.PerClient),
+		PerClientBurst: rl.PerClientBurst,
+		Global:         rate.Limit(rl.Global),
+		GlobalBurst:    rl.GlobalBurst,
+	}
+}
+
+// dhcpGate returns the gate that decides which clients this replica answers over DHCP and DHCPv6.
+// Returns nil when every replica answers every client.
+func (c *Config) dhcpGate(log logr.Logger) (ha.Gate, error) {
+	if !c.DHCP.Enabled && !c.DHCPv6.Enabled {
+		return nil, nil
+	}
+	hc := c.DHCP.HA
+	switch hc.Mode {
+	case DHCPHAModeLeaderElection:
+		if hc.KubeConfig == nil {
+			return nil, errors.New("DHCP leader election requires the Kubernetes backend")
+		}
+		l := &ha.Leader{Log: log.WithName("dhcp-leader-election"), Config: hc.KubeConfig, Namespace: hc.LeaseNamespace, Name: hc.LeaseName}
+		c.dhcpState.leader.Store(l)
+		return l, nil
+	case DHCPHAModeLoadBalance:
+		// Replicas don't share their dynamic leases, so they would hand out the same addresses.
+		if len(c.DHCP.Pools) > 0 {
+			return nil, errors.New("DHCP pools are not supported in the load-balance DHCP HA mode")
+		}
+		index := hc.ReplicaIndex
+		if index < 0 {
+			host, err := os.Hostname()
+			if err != nil {
+				return nil, fmt.Errorf("unable to get the hostname for the DHCP replica index: %w", err)
+			}
+			if index, err = hostnameOrdinal(host); err != nil {
+				return nil, err
+			}
+		}
+		if hc.Replicas < 1 || index >= hc.Replicas {
+			return nil, fmt.Errorf("invalid DHCP replica index %d for %d replicas", index, hc.Replicas)
+		}
+		log.Info("answering the DHCP clients of this replica", "replica", index, "replicas", hc.Replicas, "maxElapsed", hc.MaxElapsed)
+		return ha.LoadBalance{Replicas: hc.Replicas, Index: index, MaxElapsed: hc.MaxElapsed}, nil
+	}
+
+	return nil, nil
+}
+
+// hostnameOrdinal returns the ordinal at the end of a hostname, for example 1 for smee-1.
+func hostnameOrdinal(host string) (int, error) {
+	i := strings.LastIndex(host, "-")
+	n, err := strconv.Atoi(host[i+1:])
+	if i < 0 || err != nil || n < 0 {
+		return 0, fmt.Errorf("hostname %q doesn't end with a replica index, set the DHCP replica index", host)
+	}
+
+	return n, nil
+}
+
+// DHCPReady returns nil when this replica answers DHCP: the DHCP or DHCPv6 server is listening
+// and, in leader-election mode, this replica is the leader.
+func (c *Config) DHCPReady() error {
+	if !c.DHCP.Enabled && !c.DHCPv6.Enabled {
+		return errors.New("DHCP is disabled")
+	}
+	if c.dhcpState == nil || c.dhcpState.listening.Load() == 0 {
+		return errors.New("DHCP server is not listening")
+	}
+	if l := c.dhcpState.leader.Load(); l != nil && !l.IsLeader() {
+		return errors.New("another replica is the DHCP leader")
+	}
+
+	return nil
+}
+
+// secureBootShims maps UEFI architectures to the signed shims handed out to Hardware with Secure Boot enabled.
+// The shims are served from the boot files directory, so none are handed out when it isn't configured.
+func (c *Config) secureBootShims() map[iana.Arch]string {
+	if c.BootFiles.Dir == "" {
+		return nil
+	}
+	shims := map[iana.Arch]string{}
+	for _, a := range []iana.Arch{iana.EFI_BC, iana.EFI_X86_64, iana.EFI_X86_64_HTTP} {
+		shims[a] = c.SecureBoot.ShimX86_64
+	}
+	for _, a := range []iana.Arch{iana.EFI_ARM64, iana.EFI_ARM64_HTTP} {
+		shims[a] = c.SecureBoot.ShimARM64
+	}
+
+	return shims
+}
+
+// dhcpPools creates the dynamic address allocator for reservation mode.
+// nil is returned when no pools are configured.
+func (c *Config) dhcpPools() (*pool.Allocator, error) {
+	if len(c.DHCP.Pools) == 0 {
+		return nil, nil
+	}
+	pools := make([]pool.Pool, 0, len(c.DHCP.Pools))
+	for _, p := range c.DHCP.Pools {
+		rs := make([]pool.Range, 0, len(p.Ranges))
+		for _, r := range p.Ranges {
+			rs = append(rs, pool.Range{Start: r.Start, End: r.End})
+		}
+		opts, err := dhcpOptions(p.Options)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DHCP pool %v: %w", p.Subnet, err)
+		}
+		pools = append(pools, pool.Pool{Subnet: p.Subnet, Ranges: rs, Gateway: p.Gateway, NameServers: p.NameServers, LeaseTime: p.LeaseTime, Options: opts})
+	}
+	var store pool.Store
+	if c.DHCP.LeaseFile != "" {
+		store = &pool.FileStore{Path: c.DHCP.LeaseFile}
+		if c.leaseFileReadOnly {
+			store = pool.ReadOnlyStore{Store: store}
+		}
+	}
+	a, err := pool.NewAllocator(pools, store)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DHCP pools: %w", err)
+	}
+	// Addresses that belong to a Hardware object are never handed out dynamically.
+	// Backend errors other than not found are treated as the address being in use.
+	a.Reserved = func(ctx context.Context, ip netip.Addr) bool {
+		_, err := c.Backend.FilterHardware(ctx, data.HardwareFilter{ByIPAddress: ip.String()})
+		if err == nil {
+			return true
+		}
+		var nf interface{ NotFound() bool }
+		return !errors.As(err, &nf) || !nf.NotFound()
+	}
+
+	return a, nil
+}
+
+// dhcpOptions encodes raw DHCP options.
+func dhcpOptions(opts []DHCPOption) ([]dhcpv4.Option, error) {
+	out := make([]dhcpv4.Option, 0, len(opts))
+	for _, o := range opts {
+		opt, err := dhcp.EncodeOption(o.Code, dhcp.OptionType(o.Type), o.Value)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, opt)
+	}
+
+	return out, nil
+}
+
+func (c *Config) dhcpv6Handler(log logr.Logger, backend BackendReader) (server.Handler6, error) {
+	ip := c.DHCPv6.IPForPacket
+	if !ip.Is6() || ip.Is4In6() || ip.IsUnspecified() {
+		return nil, fmt.Errorf("invalid DHCPv6 IP for packet, must be an IPv6 address: %v", ip)
+	}
+	if c.DHCP.IPXEHTTPScript.URL == nil || c.DHCP.IPXEHTTPBinaryURL == nil {
+		return nil, errors.New("http ipxe script and binary urls are required")
+	}
+	tftpIP := netip.AddrPortFrom(ip, c.DHCP.TFTPPort)
+	httpBinaryURL := withIPv6Host(*c.DHCP.IPXEHTTPBinaryURL, ip)
+	httpScriptURL := withIPv6Host(*c.DHCP.IPXEHTTPScript.URL, ip)
+	ipxeScript := func(net.HardwareAddr) *url.URL {
+		return &httpScriptURL
+	}
+	if c.DHCP.IPXEHTTPScript.InjectMacAddress {
+		ipxeScript = func(mac net.HardwareAddr) *url.URL {
+			u := httpScriptURL
+			p := path.Base(u.Path)
+			u.Path = path.Join(path.Dir(u.Path), mac.String(), p)
+			return &u
+		}
+	}
+	serverID := dhcp.ServerDUID(ip)
+
+	switch c.DHCP.Mode {
+	case DHCPModeReservation:
+		return &reservation.Handler6{
+			Backend:  backend,
+			ServerID: serverID,
+			Log:      log,
+			Netboot: reservation.Netboot6{
+				IPXEBinServerTFTP:   tftpIP,
+				IPXEBinServerHTTP:   &httpBinaryURL,
+				IPXEScriptURL:       ipxeScript,
+				Enabled:             c.DHCP.EnableNetbootOptions,
+				InjectMacAddrFormat: c.IPXE.IPXEBinary.InjectMacAddrFormat,
+				IPXEArchMapping:     c.IPXE.IPXEBinary.IPXEArchMapping,
+				IPXEMenu:            c.IPXE.HTTPScriptServer.Menu.Enabled,
+				SecureBootShims:     c.secureBootShims(),
+			},
+			NetbootStatus: c.netbootRecorder(),
+		}, nil
+	case DHCPModeProxy, DHCPModeAutoProxy:
+		return &proxy.Handler6{
+			Backend:  backend,
+			ServerID: serverID,
+			Log:      log,
+			Netboot: proxy.Netboot6{
+				IPXEBinServerTFTP:   tftpIP,
+				IPXEBinServerHTTP:   &httpBinaryURL,
+				IPXEScriptURL:       ipxeScript,
+				Enabled:             c.DHCP.EnableNetbootOptions,
+				InjectMacAddrFormat: c.IPXE.IPXEBinary.InjectMacAddrFormat,
+				IPXEArchMapping:     c.IPXE.IPXEBinary.IPXEArchMapping,
+				IPXEMenu:            c.IPXE.HTTPScriptServer.Menu.Enabled,
+			},
+			AutoProxyEnabled: c.DHCP.Mode == DHCPModeAutoProxy,
+		}, nil
+	}
+
+	return nil, errors.New("invalid dhcp mode")
+}
+
+// withIPv6Host returns u with its host replaced by ip when the host is an IPv4 address.
+// DNS names are kept as they can resolve to IPv6 addresses. The port is always kept.
+func withIPv6Host(u url.URL, ip netip.Addr) url.URL {
+	if a, err := netip.ParseAddr(u.Hostname()); err != nil || !a.Is4() {
+		return u
+	}
+	if port := u.Port(); port != "" {
+		u.Host = net.JoinHostPort(ip.String(), port)
+	} else {
+		u.Host = "[" + ip.String() + "]"
+	}
+
+	return u
+}
+
 // Transformer for merging the netip.IPPort and logr.Logger structs.
 func (c *Config) Transformer(typ reflect.Type) func(dst, src reflect.Value) error {
 	var zeroUint16 uint16
@@ -613,5 +1520,5 @@ func (c *Config) Transformer(typ reflect.Type) func(dst, src reflect.Value) erro
 }
 
 func (c *Config) noServicesEnabled() bool {
-	return !c.DHCP.Enabled && !c.TFTP.Enabled && !c.Syslog.Enabled && !c.ISO.Enabled && !c.IPXE.HTTPBinaryServer.Enabled && !c.IPXE.HTTPScriptServer.Enabled
+	return !c.DHCP.Enabled && !c.DHCPv6.Enabled && !c.TFTP.Enabled && !c.Syslog.Enabled && !c.ISO.Enabled && !c.OSIECache.Enabled && !c.IPXE.HTTPBinaryServer.Enabled && !c.IPXE.HTTPScriptServer.Enabled
 }
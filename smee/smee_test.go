@@ -0,0 +1,153 @@
+package smee
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/tinkerbell/smee/internal/dhcp/ha"
+)
+
+func TestHostnameOrdinal(t *testing.T) {
+	tests := map[string]struct {
+		host    string
+		want    int
+		wantErr bool
+	}{
+		"statefulset pod":    {host: "smee-1", want: 1},
+		"first pod":          {host: "tinkerbell-0", want: 0},
+		"dashes in the name": {host: "my-smee-12", want: 12},
+		"double dash":        {host: "smee--1", want: 1},
+		"deployment pod":     {host: "tinkerbell-7d9f8b6c5-x2x4z", wantErr: true},
+		"no dash":            {host: "smee", wantErr: true},
+		"trailing dash":      {host: "smee-", wantErr: true},
+		"not a number":       {host: "smee-one", wantErr: true},
+		"fqdn":               {host: "smee-1.example.com", wantErr: true},
+		"empty":              {host: "", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := hostnameOrdinal(tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("hostnameOrdinal(%q) error = %v, wantErr %v", tt.host, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("hostnameOrdinal(%q) = %d, want %d", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDHCPReady(t *testing.T) {
+	tests := map[string]struct {
+		config  func(c *Config)
+		wantErr bool
+	}{
+		"listening": {
+			config: func(c *Config) { c.dhcpState.listening.Add(1) },
+		},
+		"only dhcpv6 listening": {
+			config: func(c *Config) {
+				c.DHCP.Enabled = false
+				c.DHCPv6.Enabled = true
+				c.dhcpState.listening.Add(1)
+			},
+		},
+		"not listening": {
+			config:  func(*Config) {},
+			wantErr: true,
+		},
+		"disabled": {
+			config: func(c *Config) {
+				c.DHCP.Enabled = false
+				c.DHCPv6.Enabled = false
+				c.dhcpState.listening.Add(1)
+			},
+			wantErr: true,
+		},
+		"no state": {
+			config:  func(c *Config) { c.dhcpState = nil },
+			wantErr: true,
+		},
+		"not the leader": {
+			config: func(c *Config) {
+				c.dhcpState.listening.Add(1)
+				c.dhcpState.leader.Store(&ha.Leader{})
+			},
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := NewConfig(Config{}, netip.MustParseAddr("192.168.2.2"))
+			c.DHCP.Enabled = true
+			tt.config(c)
+			if err := c.DHCPReady(); (err != nil) != tt.wantErr {
+				t.Fatalf("DHCPReady() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDHCPGateLoadBalance(t *testing.T) {
+	tests := map[string]struct {
+		config  func(c *Config)
+		want    ha.Gate
+		wantErr bool
+	}{
+		"replica index": {
+			config: func(c *Config) {
+				c.DHCP.HA.Replicas = 3
+				c.DHCP.HA.ReplicaIndex = 2
+			},
+			want: ha.LoadBalance{Replicas: 3, Index: 2, MaxElapsed: DefaultDHCPHAMaxElapsed},
+		},
+		"index out of range": {
+			config: func(c *Config) {
+				c.DHCP.HA.Replicas = 2
+				c.DHCP.HA.ReplicaIndex = 2
+			},
+			wantErr: true,
+		},
+		"no replicas": {
+			config: func(c *Config) {
+				c.DHCP.HA.Replicas = 0
+				c.DHCP.HA.ReplicaIndex = 0
+			},
+			wantErr: true,
+		},
+		"pools": {
+			config: func(c *Config) {
+				c.DHCP.HA.Replicas = 2
+				c.DHCP.HA.ReplicaIndex = 0
+				c.DHCP.Pools = []DHCPPool{{Subnet: netip.MustParsePrefix("192.168.2.0/24")}}
+			},
+			wantErr: true,
+		},
+		"max elapsed": {
+			config: func(c *Config) {
+				c.DHCP.HA.Replicas = 2
+				c.DHCP.HA.ReplicaIndex = 1
+				c.DHCP.HA.MaxElapsed = time.Second
+			},
+			want: ha.LoadBalance{Replicas: 2, Index: 1, MaxElapsed: time.Second},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := NewConfig(Config{}, netip.MustParseAddr("192.168.2.2"))
+			c.DHCP.Enabled = true
+			c.DHCP.HA.Mode = DHCPHAModeLoadBalance
+			tt.config(c)
+			got, err := c.dhcpGate(logr.Discard())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("dhcpGate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
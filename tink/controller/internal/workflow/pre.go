@@ -11,6 +11,7 @@ import (
 	"github.com/tinkerbell/tinkerbell/api/v1alpha1/bmc"
 	v1alpha1 "github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
 	"github.com/tinkerbell/tinkerbell/pkg/journal"
+	"github.com/tinkerbell/tinkerbell/tink/internal/render"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -246,7 +247,7 @@ func templateActions(actions []bmc.Action, hw *v1alpha1.Hardware) ([]bmc.Action,
 // templateString executes a Go template string with the provided data.
 func templateString(tmplStr string, data templateData) (string, error) {
 	// Use Sprig hermetic functions for template operations (includes replace, etc.)
-	tmpl, err := template.New("action").Funcs(sprig.HermeticTxtFuncMap()).Funcs(templateFuncs).Parse(tmplStr)
+	tmpl, err := template.New("action").Funcs(sprig.HermeticTxtFuncMap()).Funcs(render.Funcs).Parse(tmplStr)
 	if err != nil {
 		return "", err
 	}
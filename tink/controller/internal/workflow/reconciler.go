@@ -2,7 +2,6 @@ package workflow
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -11,6 +10,7 @@ import (
 	"github.com/go-logr/logr"
 	v1alpha1 "github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
 	"github.com/tinkerbell/tinkerbell/pkg/journal"
+	"github.com/tinkerbell/tinkerbell/tink/internal/render"
 	"k8s.io/apimachinery/pkg/api/equality"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -22,21 +22,6 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-const (
-	// templateDataReferences is the key used to access the Hardware references in the template data.
-	// This is lowercase as it is new and follows the all lowercase convention used when referencing
-	// fields in the reference object.
-	templateDataReferences = "references"
-	// templateDataHardware is the key used to access the Hardware data in the template data.
-	templateDataHardware = "hardware"
-	// templateDataHardwareLegacy is the key used to access the Hardware data in the template data.
-	// This is Title cased as it was the original convention used in the template data and is
-	// used for backwards compatibility.
-	//
-	// Deprecated: use templateDataHardware instead. This key will be removed in a future release.
-	templateDataHardwareLegacy = "Hardware"
-)
-
 type dynamicClient interface {
 	DynamicRead(ctx context.Context, gvr schema.GroupVersionResource, name, namespace string) (map[string]interface{}, error)
 }
@@ -301,23 +286,21 @@ func (r *Reconciler) processWorkflow(ctx context.Context, logger logr.Logger, st
 		)
 	}
 
-	data := make(map[string]interface{})
-	for key, val := range stored.Spec.HardwareMap {
-		data[key] = val
+	params, err := render.ResolveParameters(tpl.Spec.Parameters, stored.Spec)
+	if err != nil {
+		journal.Log(ctx, "invalid template parameters", "error", err)
+		stored.Status.TemplateRendering = v1alpha1.TemplateRenderingFailed
+		stored.Status.SetConditionIfDifferent(v1alpha1.WorkflowCondition{
+			Type:    v1alpha1.TemplateParametersValid,
+			Status:  metav1.ConditionFalse,
+			Reason:  "Error",
+			Message: fmt.Sprintf("invalid template parameters: %v", err),
+			Time:    &metav1.Time{Time: metav1.Now().UTC()},
+		})
+		return fmt.Errorf("invalid template parameters: template=%v; error: %w", tpl.Name, err)
 	}
-	contract := toTemplateHardwareData(hardware)
-	data[templateDataHardware] = func() interface{} {
-		// structToMap is used so that fields are accessible in Templates by their json struct tag names instead of
-		// their Go struct field names and their case.
-		// for example, {{ hardware.spec.metadata.instance.id }} instead of {{ hardware.Spec.Metadata.Instance.ID }}.
-		v, err := structToMap(hardware)
-		if err != nil {
-			logger.V(1).Info("error converting hardware to map for use in template data", "error", err)
-			return map[string]interface{}{}
-		}
-		return v
-	}()
-	data[templateDataHardwareLegacy] = contract
+
+	data := render.Data(logger, tpl, stored.Spec, hardware, params)
 	references := make(map[string]interface{})
 	var refErr error
 	for refName, rf := range hardware.Spec.References {
@@ -354,9 +337,13 @@ func (r *Reconciler) processWorkflow(ctx context.Context, logger logr.Logger, st
 			logger.V(1).Info("error getting reference", "referenceName", rf.Name, "namespace", rf.Namespace, "gvr", gvr, "error", err, "refNil", v == nil)
 		}
 	}
-	data[templateDataReferences] = references
+	data[render.KeyReferences] = references
 
-	tinkWf, err := renderTemplateHardware(stored.Name, pointerToValue(tpl.Spec.Data), data)
+	var tplData string
+	if tpl.Spec.Data != nil {
+		tplData = *tpl.Spec.Data
+	}
+	tinkWf, err := render.Template(stored.Name, tplData, data)
 	if err != nil {
 		journal.Log(ctx, "error rendering template")
 		stored.Status.TemplateRendering = v1alpha1.TemplateRenderingFailed
@@ -372,7 +359,7 @@ func (r *Reconciler) processWorkflow(ctx context.Context, logger logr.Logger, st
 	}
 
 	// populate Task and Action data
-	stored.Status = *YAMLToStatus(tinkWf)
+	stored.Status = *render.YAMLToStatus(tinkWf)
 	stored.Status.TemplateRendering = v1alpha1.TemplateRenderingSuccessful
 	stored.Status.SetCondition(v1alpha1.WorkflowCondition{
 		Type:    v1alpha1.TemplateRenderedSuccess,
@@ -381,6 +368,15 @@ func (r *Reconciler) processWorkflow(ctx context.Context, logger logr.Logger, st
 		Message: "template rendered successfully",
 		Time:    &metav1.Time{Time: metav1.Now().UTC()},
 	})
+	if len(tpl.Spec.Parameters) > 0 {
+		stored.Status.SetCondition(v1alpha1.WorkflowCondition{
+			Type:    v1alpha1.TemplateParametersValid,
+			Status:  metav1.ConditionTrue,
+			Reason:  "Complete",
+			Message: "template parameters validated successfully",
+			Time:    &metav1.Time{Time: metav1.Now().UTC()},
+		})
+	}
 
 	return nil
 }
@@ -401,63 +397,6 @@ func (r *Reconciler) processNewWorkflow(ctx context.Context, logger logr.Logger,
 	return reconcile.Result{}, nil
 }
 
-// structToMap converts a struct to a map[string]interface{}.
-func structToMap(item interface{}) (map[string]interface{}, error) {
-	result := make(map[string]interface{})
-
-	// Marshal the struct to JSON.
-	jsonBytes, err := json.Marshal(item)
-	if err != nil {
-		return nil, err
-	}
-
-	// Unmarshal the JSON to a map[string]interface{}.
-	if err = json.Unmarshal(jsonBytes, &result); err != nil {
-		return nil, err
-	}
-
-	return result, nil
-}
-
-// templateHardwareData defines the data exposed for a Hardware instance to a Template.
-type templateHardwareData struct {
-	Disks      []string
-	Interfaces []v1alpha1.Interface
-	UserData   string
-	Metadata   v1alpha1.HardwareMetadata
-	VendorData string
-}
-
-// toTemplateHardwareData converts a Hardware instance of templateHardwareData for use in template
-// rendering.
-func toTemplateHardwareData(hardware v1alpha1.Hardware) templateHardwareData {
-	var contract templateHardwareData
-	for _, disk := range hardware.Spec.Disks {
-		contract.Disks = append(contract.Disks, disk.Device)
-	}
-	if len(hardware.Spec.Interfaces) > 0 {
-		contract.Interfaces = hardware.Spec.Interfaces
-	}
-	if hardware.Spec.UserData != nil {
-		contract.UserData = pointerToValue(hardware.Spec.UserData)
-	}
-	if hardware.Spec.Metadata != nil {
-		contract.Metadata = *hardware.Spec.Metadata
-	}
-	if hardware.Spec.VendorData != nil {
-		contract.VendorData = pointerToValue(hardware.Spec.VendorData)
-	}
-	return contract
-}
-
-func pointerToValue[V any](ptr *V) V {
-	if ptr == nil {
-		var zero V
-		return zero
-	}
-	return *ptr
-}
-
 // firstAction returns the first Action of the first Task in the Workflow.
 func firstAction(w *v1alpha1.Workflow) *v1alpha1.Action {
 	if len(w.Status.Tasks) > 0 {
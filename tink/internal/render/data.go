@@ -0,0 +1,106 @@
+package render
+
+import (
+	"encoding/json"
+
+	"github.com/go-logr/logr"
+	v1alpha1 "github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+)
+
+const (
+	// KeyReferences is the key used to access the Hardware references in the template data.
+	// This is lowercase as it is new and follows the all lowercase convention used when referencing
+	// fields in the reference object.
+	KeyReferences = "references"
+	// templateDataHardware is the key used to access the Hardware data in the template data.
+	templateDataHardware = "hardware"
+	// templateDataHardwareLegacy is the key used to access the Hardware data in the template data.
+	// This is Title cased as it was the original convention used in the template data and is
+	// used for backwards compatibility.
+	//
+	// Deprecated: use templateDataHardware instead. This key will be removed in a future release.
+	templateDataHardwareLegacy = "Hardware"
+)
+
+// Data returns the data used to render a Template for a Workflow with spec and hardware.
+// It includes the HardwareMap values, the resolved parameters and the Hardware. References are not included, they are added with the KeyReferences key.
+func Data(logger logr.Logger, tpl *v1alpha1.Template, spec v1alpha1.WorkflowSpec, hardware v1alpha1.Hardware, params map[string]interface{}) map[string]interface{} {
+	data := make(map[string]interface{})
+	for key, val := range spec.HardwareMap {
+		data[key] = val
+	}
+	if len(tpl.Spec.Parameters) > 0 {
+		data[templateDataParameters] = params
+	}
+	data[templateDataHardware] = func() interface{} {
+		// structToMap is used so that fields are accessible in Templates by their json struct tag names instead of
+		// their Go struct field names and their case.
+		// for example, {{ hardware.spec.metadata.instance.id }} instead of {{ hardware.Spec.Metadata.Instance.ID }}.
+		v, err := structToMap(hardware)
+		if err != nil {
+			logger.V(1).Info("error converting hardware to map for use in template data", "error", err)
+			return map[string]interface{}{}
+		}
+		return v
+	}()
+	data[templateDataHardwareLegacy] = toTemplateHardwareData(hardware)
+
+	return data
+}
+
+// structToMap converts a struct to a map[string]interface{}.
+func structToMap(item interface{}) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+
+	// Marshal the struct to JSON.
+	jsonBytes, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the JSON to a map[string]interface{}.
+	if err = json.Unmarshal(jsonBytes, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// templateHardwareData defines the data exposed for a Hardware instance to a Template.
+type templateHardwareData struct {
+	Disks      []string
+	Interfaces []v1alpha1.Interface
+	UserData   string
+	Metadata   v1alpha1.HardwareMetadata
+	VendorData string
+}
+
+// toTemplateHardwareData converts a Hardware instance of templateHardwareData for use in template
+// rendering.
+func toTemplateHardwareData(hardware v1alpha1.Hardware) templateHardwareData {
+	var contract templateHardwareData
+	for _, disk := range hardware.Spec.Disks {
+		contract.Disks = append(contract.Disks, disk.Device)
+	}
+	if len(hardware.Spec.Interfaces) > 0 {
+		contract.Interfaces = hardware.Spec.Interfaces
+	}
+	if hardware.Spec.UserData != nil {
+		contract.UserData = pointerToValue(hardware.Spec.UserData)
+	}
+	if hardware.Spec.Metadata != nil {
+		contract.Metadata = *hardware.Spec.Metadata
+	}
+	if hardware.Spec.VendorData != nil {
+		contract.VendorData = pointerToValue(hardware.Spec.VendorData)
+	}
+	return contract
+}
+
+func pointerToValue[V any](ptr *V) V {
+	if ptr == nil {
+		var zero V
+		return zero
+	}
+	return *ptr
+}
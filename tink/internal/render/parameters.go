@@ -0,0 +1,195 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	v1alpha1 "github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+)
+
+// templateDataParameters is the key used to access the resolved Template parameters in the template data.
+const templateDataParameters = "parameters"
+
+// parameterErrors is a list of all the problems found while resolving Template parameters.
+type parameterErrors []string
+
+func (p parameterErrors) Error() string {
+	return strings.Join(p, "; ")
+}
+
+// ResolveParameters validates and defaults the parameters declared by a Template against the values supplied in a Workflow spec.
+// For each declared parameter the value is taken from, in order, spec.Parameters, spec.HardwareMap (converted from a string), and
+// the parameter's Default. All problems are collected and returned together as a parameterErrors.
+func ResolveParameters(declared []v1alpha1.TemplateParameter, spec v1alpha1.WorkflowSpec) (map[string]interface{}, error) {
+	resolved := make(map[string]interface{}, len(declared))
+	var errs parameterErrors
+	for _, p := range declared {
+		typ := p.Type
+		if typ == "" {
+			typ = v1alpha1.TemplateParameterString
+		}
+
+		var (
+			v   interface{}
+			err error
+			set bool
+		)
+		if pv, ok := spec.Parameters[p.Name]; ok && len(pv.Raw) > 0 {
+			v, err = decodeParameter(typ, pv.Raw)
+			set = true
+		} else if s, ok := spec.HardwareMap[p.Name]; ok {
+			v, err = convertParameter(typ, s)
+			set = true
+		} else if p.Default != nil && len(p.Default.Raw) > 0 {
+			v, err = decodeParameter(typ, p.Default.Raw)
+			if err != nil {
+				err = fmt.Errorf("default: %w", err)
+			}
+			set = true
+		}
+
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Sprintf("parameter %q: %v", p.Name, err))
+		case set:
+			resolved[p.Name] = v
+		case p.Required:
+			errs = append(errs, fmt.Sprintf("missing required parameter %q", p.Name))
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return resolved, nil
+}
+
+// decodeParameter decodes a raw JSON value and checks that it is of type typ.
+func decodeParameter(typ v1alpha1.TemplateParameterType, raw []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid value: %w", err)
+	}
+
+	switch typ {
+	case v1alpha1.TemplateParameterString:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case v1alpha1.TemplateParameterInteger:
+		if n, ok := v.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				return i, nil
+			}
+			// Allow whole numbers written in float notation, for example 1e3.
+			// float64(math.MaxInt64) rounds up to 2^63, which doesn't fit in an int64, so the upper bound is exclusive.
+			if f, err := n.Float64(); err == nil && f == math.Trunc(f) && f >= math.MinInt64 && f < -math.MinInt64 {
+				return int64(f), nil
+			}
+		}
+	case v1alpha1.TemplateParameterNumber:
+		if n, ok := v.(json.Number); ok {
+			if f, err := n.Float64(); err == nil {
+				return f, nil
+			}
+		}
+	case v1alpha1.TemplateParameterBoolean:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case v1alpha1.TemplateParameterArray:
+		if a, ok := v.([]interface{}); ok {
+			return normalizeNumbers(a), nil
+		}
+	case v1alpha1.TemplateParameterObject:
+		if m, ok := v.(map[string]interface{}); ok {
+			return normalizeNumbers(m), nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown type %q", typ)
+	}
+
+	return nil, fmt.Errorf("expected %s, got %s", typ, jsonType(v))
+}
+
+// convertParameter converts a HardwareMap string value to type typ.
+func convertParameter(typ v1alpha1.TemplateParameterType, s string) (interface{}, error) {
+	switch typ {
+	case v1alpha1.TemplateParameterString:
+		return s, nil
+	case v1alpha1.TemplateParameterInteger:
+		i, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected integer, got %q", s)
+		}
+		return i, nil
+	case v1alpha1.TemplateParameterNumber:
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected number, got %q", s)
+		}
+		return f, nil
+	case v1alpha1.TemplateParameterBoolean:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("expected boolean, got %q", s)
+		}
+		return b, nil
+	case v1alpha1.TemplateParameterArray, v1alpha1.TemplateParameterObject:
+		return decodeParameter(typ, []byte(s))
+	default:
+		return nil, fmt.Errorf("unknown type %q", typ)
+	}
+}
+
+// normalizeNumbers replaces json.Number values in nested arrays and objects with int64 or float64
+// so that they behave as expected in Templates.
+func normalizeNumbers(v interface{}) interface{} {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+	case []interface{}:
+		for i := range t {
+			t[i] = normalizeNumbers(t[i])
+		}
+		return t
+	case map[string]interface{}:
+		for k := range t {
+			t[k] = normalizeNumbers(t[k])
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// jsonType returns the JSON type name of a decoded value for use in error messages.
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
@@ -0,0 +1,183 @@
+package render
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1alpha1 "github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+)
+
+func raw(s string) *v1alpha1.ParameterValue {
+	return &v1alpha1.ParameterValue{Raw: []byte(s)}
+}
+
+func TestResolveParameters(t *testing.T) {
+	tests := map[string]struct {
+		declared []v1alpha1.TemplateParameter
+		spec     v1alpha1.WorkflowSpec
+		want     map[string]interface{}
+		wantErr  []string
+	}{
+		"no parameters declared": {
+			spec: v1alpha1.WorkflowSpec{HardwareMap: map[string]string{"device_1": "00:00:00:00:00:01"}},
+			want: map[string]interface{}{},
+		},
+		"typed values from spec.parameters": {
+			declared: []v1alpha1.TemplateParameter{
+				{Name: "disk"},
+				{Name: "count", Type: v1alpha1.TemplateParameterInteger},
+				{Name: "ratio", Type: v1alpha1.TemplateParameterNumber},
+				{Name: "wipe", Type: v1alpha1.TemplateParameterBoolean},
+				{Name: "pkgs", Type: v1alpha1.TemplateParameterArray},
+				{Name: "labels", Type: v1alpha1.TemplateParameterObject},
+			},
+			spec: v1alpha1.WorkflowSpec{
+				Parameters: map[string]v1alpha1.ParameterValue{
+					"disk":   *raw(`"/dev/sda"`),
+					"count":  *raw(`3`),
+					"ratio":  *raw(`0.5`),
+					"wipe":   *raw(`true`),
+					"pkgs":   *raw(`["a", 1]`),
+					"labels": *raw(`{"rack": "r1", "slot": 7}`),
+				},
+			},
+			want: map[string]interface{}{
+				"disk":   "/dev/sda",
+				"count":  int64(3),
+				"ratio":  0.5,
+				"wipe":   true,
+				"pkgs":   []interface{}{"a", int64(1)},
+				"labels": map[string]interface{}{"rack": "r1", "slot": int64(7)},
+			},
+		},
+		"values converted from hardwareMap": {
+			declared: []v1alpha1.TemplateParameter{
+				{Name: "worker_id", Required: true},
+				{Name: "count", Type: v1alpha1.TemplateParameterInteger},
+				{Name: "wipe", Type: v1alpha1.TemplateParameterBoolean},
+			},
+			spec: v1alpha1.WorkflowSpec{
+				HardwareMap: map[string]string{"worker_id": "abc", "count": "2", "wipe": "false"},
+			},
+			want: map[string]interface{}{"worker_id": "abc", "count": int64(2), "wipe": false},
+		},
+		"spec.parameters takes precedence over hardwareMap": {
+			declared: []v1alpha1.TemplateParameter{{Name: "disk"}},
+			spec: v1alpha1.WorkflowSpec{
+				HardwareMap: map[string]string{"disk": "/dev/sda"},
+				Parameters:  map[string]v1alpha1.ParameterValue{"disk": *raw(`"/dev/nvme0n1"`)},
+			},
+			want: map[string]interface{}{"disk": "/dev/nvme0n1"},
+		},
+		"defaults applied": {
+			declared: []v1alpha1.TemplateParameter{
+				{Name: "disk", Required: true, Default: raw(`"/dev/sda"`)},
+				{Name: "timeout", Type: v1alpha1.TemplateParameterInteger, Default: raw(`600`)},
+				{Name: "optional"},
+			},
+			want: map[string]interface{}{"disk": "/dev/sda", "timeout": int64(600)},
+		},
+		"integers in float notation": {
+			declared: []v1alpha1.TemplateParameter{
+				{Name: "count", Type: v1alpha1.TemplateParameterInteger},
+				{Name: "min", Type: v1alpha1.TemplateParameterInteger},
+			},
+			spec: v1alpha1.WorkflowSpec{
+				Parameters: map[string]v1alpha1.ParameterValue{"count": *raw(`1e3`), "min": *raw(`-9.223372036854775808e18`)},
+			},
+			want: map[string]interface{}{"count": int64(1000), "min": int64(math.MinInt64)},
+		},
+		"integer out of range": {
+			declared: []v1alpha1.TemplateParameter{{Name: "count", Type: v1alpha1.TemplateParameterInteger}},
+			spec: v1alpha1.WorkflowSpec{
+				Parameters: map[string]v1alpha1.ParameterValue{"count": *raw(`9223372036854775808`)},
+			},
+			wantErr: []string{`parameter "count": expected integer, got number`},
+		},
+		"all errors reported": {
+			declared: []v1alpha1.TemplateParameter{
+				{Name: "disk", Required: true},
+				{Name: "count", Type: v1alpha1.TemplateParameterInteger},
+				{Name: "wipe", Type: v1alpha1.TemplateParameterBoolean},
+				{Name: "timeout", Type: v1alpha1.TemplateParameterInteger, Default: raw(`"ten"`)},
+			},
+			spec: v1alpha1.WorkflowSpec{
+				HardwareMap: map[string]string{"wipe": "maybe"},
+				Parameters:  map[string]v1alpha1.ParameterValue{"count": *raw(`1.5`)},
+			},
+			wantErr: []string{
+				`missing required parameter "disk"`,
+				`parameter "count": expected integer, got number`,
+				`parameter "wipe": expected boolean, got "maybe"`,
+				`parameter "timeout": default: expected integer, got string`,
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ResolveParameters(tt.declared, tt.spec)
+			if len(tt.wantErr) > 0 {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				if diff := cmp.Diff(strings.Join(tt.wantErr, "; "), err.Error()); diff != "" {
+					t.Fatalf("unexpected error (-want +got):\n%s", diff)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("unexpected parameters (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRenderTemplateWithParameters(t *testing.T) {
+	tmpl := `version: "0.1"
+name: params
+global_timeout: {{ .parameters.timeout }}
+tasks:
+  - name: "install"
+    worker: "{{ .parameters.worker_id }}"
+    actions:
+      - name: "wipe"
+        image: quay.io/tinkerbell/actions/wipe:latest
+        timeout: {{ .parameters.timeout }}
+        environment:
+          DEST_DISK: {{ .parameters.disk }}
+          WIPE: "{{ if .parameters.wipe }}yes{{ else }}no{{ end }}"
+`
+	params, err := ResolveParameters([]v1alpha1.TemplateParameter{
+		{Name: "worker_id", Required: true},
+		{Name: "disk", Default: raw(`"/dev/sda"`)},
+		{Name: "timeout", Type: v1alpha1.TemplateParameterInteger, Default: raw(`90`)},
+		{Name: "wipe", Type: v1alpha1.TemplateParameterBoolean, Default: raw(`false`)},
+	}, v1alpha1.WorkflowSpec{
+		HardwareMap: map[string]string{"worker_id": "00:00:00:00:00:01"},
+		Parameters:  map[string]v1alpha1.ParameterValue{"wipe": *raw(`true`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wf, err := Template("params", tmpl, map[string]interface{}{templateDataParameters: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wf.GlobalTimeout != 90 {
+		t.Errorf("expected global timeout 90, got %d", wf.GlobalTimeout)
+	}
+	if got := wf.Tasks[0].WorkerAddr; got != "00:00:00:00:00:01" {
+		t.Errorf("unexpected worker: %s", got)
+	}
+	want := map[string]string{"DEST_DISK": "/dev/sda", "WIPE": "yes"}
+	if diff := cmp.Diff(want, wf.Tasks[0].Actions[0].Environment); diff != "" {
+		t.Errorf("unexpected environment (-want +got):\n%s", diff)
+	}
+}
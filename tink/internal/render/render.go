@@ -0,0 +1,35 @@
+// Package render renders Templates into the Tasks and Actions of a Workflow. It's used by the Workflow controller
+// and by Tink Server's auto enrollment dry run, so that both render Templates the same way.
+package render
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	v1alpha1 "github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+)
+
+// Status renders tpl for the Workflow wf and the Hardware hw without reading from or writing to a cluster.
+// The returned status holds the Tasks and Actions that the Workflow would have once reconciled.
+// Parameters are resolved and validated the same way the Reconciler does. Hardware references are not resolved,
+// so Templates that use them will see an empty references map. hw may be nil when the Workflow has no Hardware.
+func Status(logger logr.Logger, tpl *v1alpha1.Template, wf *v1alpha1.Workflow, hw *v1alpha1.Hardware) (*v1alpha1.WorkflowStatus, error) {
+	params, err := ResolveParameters(tpl.Spec.Parameters, wf.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template parameters: template=%v; error: %w", tpl.Name, err)
+	}
+
+	var hardware v1alpha1.Hardware
+	if hw != nil {
+		hardware = *hw
+	}
+	data := Data(logger, tpl, wf.Spec, hardware, params)
+	data[KeyReferences] = map[string]interface{}{}
+
+	tinkWf, err := Template(wf.Name, pointerToValue(tpl.Spec.Data), data)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering template: %w", err)
+	}
+
+	return YAMLToStatus(tinkWf), nil
+}
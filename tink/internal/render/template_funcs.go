@@ -1,4 +1,4 @@
-package workflow
+package render
 
 import (
 	"fmt"
@@ -9,8 +9,8 @@ import (
 	"sigs.k8s.io/yaml"
 )
 
-// templateFuncs defines the custom functions available to workflow templates.
-var templateFuncs = map[string]interface{}{
+// Funcs defines the custom functions available to workflow templates.
+var Funcs = map[string]interface{}{
 	"formatPartition":       formatPartition,
 	"netmaskToPrefixLength": netmaskToPrefixLength,
 	"toYaml":                toYaml,
@@ -1,4 +1,4 @@
-package workflow
+package render
 
 import (
 	"bytes"
@@ -31,12 +31,12 @@ func parse(yamlContent []byte) (*Workflow, error) {
 	return &workflow, nil
 }
 
-// renderTemplateHardware renders the workflow template and returns the Workflow and the interpolated bytes.
-func renderTemplateHardware(templateID, templateData string, hardware map[string]interface{}) (*Workflow, error) {
+// Template renders the workflow template and returns the Workflow and the interpolated bytes.
+func Template(templateID, templateData string, hardware map[string]interface{}) (*Workflow, error) {
 	t := template.New("workflow-template").
 		Option("missingkey=error").
 		Funcs(sprig.FuncMap()).
-		Funcs(templateFuncs)
+		Funcs(Funcs)
 
 	_, err := t.Parse(templateData)
 	if err != nil {
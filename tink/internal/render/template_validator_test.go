@@ -1,4 +1,4 @@
-package workflow
+package render
 
 import (
 	"testing"
@@ -291,7 +291,7 @@ tasks:
 		},
 	}
 
-	wf, err := renderTemplateHardware("test-toYaml", templateWithToYaml, hardware)
+	wf, err := Template("test-toYaml", templateWithToYaml, hardware)
 	assert.NoError(t, err)
 	assert.NotNil(t, wf)
 	assert.Equal(t, "yaml_func_workflow", wf.Name)
@@ -327,7 +327,7 @@ tasks:
 		},
 	}
 
-	wf, err := renderTemplateHardware("test-fromYaml", templateWithFromYaml, hardware)
+	wf, err := Template("test-fromYaml", templateWithFromYaml, hardware)
 	assert.NoError(t, err)
 	assert.NotNil(t, wf)
 
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	grpcinternal "github.com/tinkerbell/tinkerbell/tink/server/internal/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxDryRunRequestSize is the maximum size of a dry run request body.
+const maxDryRunRequestSize = 1 << 20 // 1MB
+
+// DryRunHandler returns an http.Handler that reports what auto enrollment would do for an Agent without creating anything.
+// A GET request takes the Agent ID from the "agentID" query parameter. A POST request takes a JSON body with
+// an "agentID" and/or an "attributes" document in the format sent by Tink Agents.
+// The response is a JSON document with the selected WorkflowRuleSet and the rendered Workflow.
+// The handler doesn't authenticate requests, callers must wrap it with authentication.
+func (c *Config) DryRunHandler(log logr.Logger) http.Handler {
+	h := c.handler(log)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req grpcinternal.DryRunRequest
+		switch r.Method {
+		case http.MethodGet:
+			req.AgentID = r.URL.Query().Get("agentID")
+		case http.MethodPost:
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxDryRunRequestSize)).Decode(&req); err != nil {
+				http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		res, err := h.DryRun(r.Context(), req)
+		if err != nil {
+			http.Error(w, status.Convert(err).Message(), httpStatus(err))
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(res); err != nil {
+			log.Error(err, "failed to encode dry run response")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := buf.WriteTo(w); err != nil {
+			log.Error(err, "failed to write dry run response")
+		}
+	})
+}
+
+// httpStatus maps the gRPC status code of err to an HTTP status code.
+func httpStatus(err error) int {
+	var se interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &se) {
+		return http.StatusInternalServerError
+	}
+	switch se.GRPCStatus().Code() {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
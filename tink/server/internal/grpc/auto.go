@@ -1,5 +1,11 @@
 package grpc
 
+import (
+	"time"
+
+	v1alpha1 "github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+)
+
 type AutoCapabilities struct {
 	Enrollment AutoEnrollment
 	Discovery  AutoDiscovery
@@ -14,8 +20,22 @@ type AutoEnrollment struct {
 
 	WorkflowRuleSetLister
 	WorkflowCreator
+	// WorkflowRuleSetUpdater is optional. When set, WorkflowRuleSet statuses are updated with match and error information.
+	WorkflowRuleSetUpdater
+	// TemplateReader is optional. It is only used by dry runs to render the Template of the matched WorkflowRuleSet.
+	TemplateReader
+	// HardwareMatch lists, in order of preference, the Agent attributes used to find an existing Hardware object
+	// for an Agent when no Hardware object has a matching spec.agentID. A matched Hardware object gets its
+	// spec.agentID set to the Agent ID. An empty list disables matching by attributes.
+	HardwareMatch []HardwareMatch
+	// ReEnrollmentCooldown is the minimum time between creating a Workflow for an Agent and re-enrolling it.
+	// Zero uses DefaultReEnrollmentCooldown, a negative value disables the cooldown.
+	ReEnrollmentCooldown time.Duration
 }
 
+// DefaultReEnrollmentCooldown is used when AutoEnrollment.ReEnrollmentCooldown is zero.
+const DefaultReEnrollmentCooldown = 10 * time.Minute
+
 // AutoDiscovery is a struct that contains the auto discovery configuration.
 // Auto Discovery is defined as automatically creating a Hardware Object for an
 // Agent that does not have a Workflow or a Hardware Object assigned to it.
@@ -30,6 +50,10 @@ type AutoDiscovery struct {
 	// This sets the value of the tinkerbell.Hardware.Spec.Auto.EnrollmentEnabled field.
 	// If this is true, then auto enrollment will create Workflows for this Hardware.
 	EnrollmentEnabled bool
+	// InterfaceProfile is the template for each interface added to a created Hardware Object.
+	// The MAC address and interface name reported by the Agent are set on a copy of it.
+	// A nil profile results in interfaces with only the DHCP MAC address and interface name set.
+	InterfaceProfile *v1alpha1.Interface
 
 	HardwareCreator
 	HardwareFilterer
@@ -5,12 +5,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"strings"
 
 	v1alpha1 "github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
 	"github.com/tinkerbell/tinkerbell/pkg/constant"
 	"github.com/tinkerbell/tinkerbell/pkg/data"
 	"github.com/tinkerbell/tinkerbell/pkg/journal"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 const (
@@ -50,6 +53,14 @@ func (h *Handler) Discover(ctx context.Context, agentID string, attrs *data.Agen
 		return nil, fmt.Errorf("failed to check for existing hardware object %s/%s: %w", ns, hwName, err)
 	}
 
+	// Link to pre-registered Hardware that matches the Agent's attributes instead of creating a new one.
+	if linked, err := h.linkHardware(ctx, agentID, attrs); err != nil {
+		journal.Log(ctx, "Error finding hardware object by attributes", "error", err)
+		return nil, err
+	} else if linked != nil {
+		return linked, nil
+	}
+
 	// Hardware object does not exist, create it
 	journal.Log(ctx, "Hardware object does not exist, creating new one")
 
@@ -59,7 +70,7 @@ func (h *Handler) Discover(ctx context.Context, agentID string, attrs *data.Agen
 			Name:      hwName,
 			Namespace: ns,
 			Labels: map[string]string{
-				"tinkerbell.org/auto-discovered": "true",
+				constant.AutoDiscoveredLabel: "true",
 			},
 		},
 		Spec: v1alpha1.HardwareSpec{
@@ -86,7 +97,7 @@ func (h *Handler) Discover(ctx context.Context, agentID string, attrs *data.Agen
 	}
 
 	// Populate Hardware object with discovered attributes
-	updateHardware(ctx, hw, attrs)
+	updateHardware(ctx, hw, attrs, h.AutoCapabilities.Discovery.InterfaceProfile)
 	journal.Log(ctx, "Populated hardware object with discovered attributes", "hardware", hw)
 
 	// Create the Hardware object in the cluster
@@ -108,14 +119,19 @@ func (h *Handler) Discover(ctx context.Context, agentID string, attrs *data.Agen
 	return hw, nil
 }
 
-func updateHardware(ctx context.Context, hw *v1alpha1.Hardware, attrs *data.AgentAttributes) {
+// updateHardware populates hw with structured data derived from attrs.
+// Each discovered interface is a copy of profile with the MAC and interface name from attrs set.
+func updateHardware(ctx context.Context, hw *v1alpha1.Hardware, attrs *data.AgentAttributes, profile *v1alpha1.Interface) {
 	if hw == nil || attrs == nil {
 		return
 	}
+	if hw.Labels == nil {
+		hw.Labels = make(map[string]string)
+	}
 
 	// Add disks if they exist in the attributes
 	for _, disk := range attrs.BlockDevices {
-		if disk != nil {
+		if disk != nil && disk.Name != nil {
 			if disk.Size != nil && *disk.Size != "" {
 				hw.Spec.Disks = append(hw.Spec.Disks, v1alpha1.Disk{
 					Device: fmt.Sprintf("/dev/%s", *disk.Name),
@@ -133,12 +149,132 @@ func updateHardware(ctx context.Context, hw *v1alpha1.Hardware, attrs *data.Agen
 					journal.Log(ctx, "Invalid MAC address format", "mac", *iface.Mac)
 					continue
 				}
-				hw.Spec.Interfaces = append(hw.Spec.Interfaces, v1alpha1.Interface{
-					DHCP: &v1alpha1.DHCP{
-						MAC: *iface.Mac,
-					},
-				})
+				hw.Spec.Interfaces = append(hw.Spec.Interfaces, newInterface(profile, *iface.Mac, valueOf(iface.Name)))
 			}
 		}
 	}
+
+	// Add CPU and memory resources
+	if attrs.CPU != nil {
+		cpus := valueOf(attrs.CPU.TotalThreads)
+		if cpus == 0 {
+			cpus = valueOf(attrs.CPU.TotalCores)
+		}
+		if cpus > 0 {
+			setResource(hw, "cpu", *resource.NewQuantity(int64(cpus), resource.DecimalSI))
+		}
+	}
+	if attrs.Memory != nil {
+		if q, err := parseByteSize(valueOf(attrs.Memory.Total)); err == nil {
+			setResource(hw, "memory", q)
+		} else {
+			journal.Log(ctx, "Invalid memory size", "memory", valueOf(attrs.Memory.Total), "error", err)
+		}
+	}
+
+	// Add manufacturer metadata and labels
+	if vendor := vendorOf(attrs); vendor != "" {
+		if slug := labelValue(vendor); slug != "" {
+			hw.Labels[constant.VendorLabel] = slug
+			if hw.Spec.Metadata == nil {
+				hw.Spec.Metadata = &v1alpha1.HardwareMetadata{}
+			}
+			hw.Spec.Metadata.Manufacturer = &v1alpha1.MetadataManufacturer{Slug: strings.ToLower(slug)}
+		}
+	}
+	if attrs.Product != nil {
+		if model := labelValue(valueOf(attrs.Product.Name)); model != "" {
+			hw.Labels[constant.ModelLabel] = model
+		}
+		// Serial labels are only set when the serial is a valid label value so that they can be used to link Hardware.
+		if s := valueOf(attrs.Product.SerialNumber); s != "" && len(validation.IsValidLabelValue(s)) == 0 {
+			hw.Labels[constant.ProductSerialLabel] = s
+		}
+	}
+	if attrs.Chassis != nil {
+		if s := valueOf(attrs.Chassis.Serial); s != "" && len(validation.IsValidLabelValue(s)) == 0 {
+			hw.Labels[constant.ChassisSerialLabel] = s
+		}
+	}
+	if len(attrs.GPUDevices) > 0 {
+		hw.Labels[constant.GPULabel] = "true"
+	}
+}
+
+// newInterface returns a copy of profile with the DHCP MAC address and interface name set.
+func newInterface(profile *v1alpha1.Interface, mac, name string) v1alpha1.Interface {
+	iface := v1alpha1.Interface{}
+	if profile != nil {
+		iface = *profile.DeepCopy()
+	}
+	if iface.DHCP == nil {
+		iface.DHCP = &v1alpha1.DHCP{}
+	}
+	iface.DHCP.MAC = mac
+	if iface.DHCP.IfaceName == "" {
+		iface.DHCP.IfaceName = name
+	}
+	return iface
+}
+
+func setResource(hw *v1alpha1.Hardware, name string, q resource.Quantity) {
+	if hw.Spec.Resources == nil {
+		hw.Spec.Resources = make(map[string]resource.Quantity)
+	}
+	hw.Spec.Resources[name] = q
+}
+
+// vendorOf returns the first non empty vendor from the product, baseboard, and chassis attributes.
+func vendorOf(attrs *data.AgentAttributes) string {
+	if attrs.Product != nil && valueOf(attrs.Product.Vendor) != "" {
+		return *attrs.Product.Vendor
+	}
+	if attrs.Baseboard != nil && valueOf(attrs.Baseboard.Vendor) != "" {
+		return *attrs.Baseboard.Vendor
+	}
+	if attrs.Chassis != nil {
+		return valueOf(attrs.Chassis.Vendor)
+	}
+	return ""
+}
+
+// parseByteSize converts a size reported by an Agent, for example "16GB", to a Quantity.
+// Agents report sizes in multiples of 1024, so "16GB" is 16Gi.
+func parseByteSize(s string) (resource.Quantity, error) {
+	suffixes := []struct{ agent, quantity string }{
+		{"KB", "Ki"}, {"MB", "Mi"}, {"GB", "Gi"}, {"TB", "Ti"}, {"PB", "Pi"}, {"EB", "Ei"}, {"B", ""},
+	}
+	for _, sfx := range suffixes {
+		if n, found := strings.CutSuffix(s, sfx.agent); found {
+			return resource.ParseQuantity(n + sfx.quantity)
+		}
+	}
+	return resource.Quantity{}, fmt.Errorf("unknown size format %q", s)
+}
+
+// labelValue converts s into a valid Kubernetes label value.
+// Invalid characters are replaced with "-" and the value is truncated to 63 characters.
+// An empty string is returned if nothing valid remains.
+func labelValue(s string) string {
+	v := []byte(strings.TrimSpace(s))
+	for i, c := range v {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '-' && c != '_' && c != '.' {
+			v[i] = '-'
+		}
+	}
+	out := string(v)
+	if len(out) > validation.LabelValueMaxLength {
+		out = out[:validation.LabelValueMaxLength]
+	}
+	return strings.TrimFunc(out, func(r rune) bool {
+		return r == '-' || r == '_' || r == '.'
+	})
+}
+
+func valueOf[T any](v *T) T {
+	if v == nil {
+		var zero T
+		return zero
+	}
+	return *v
 }
@@ -12,6 +12,7 @@ import (
 	"github.com/tinkerbell/tinkerbell/pkg/constant"
 	"github.com/tinkerbell/tinkerbell/pkg/data"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -68,7 +69,11 @@ func TestHandlerDiscover(t *testing.T) {
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "discovery-test-id",
 					Namespace: "test-namespace",
-					Labels:    map[string]string{"tinkerbell.org/auto-discovered": "true"},
+					Labels: map[string]string{
+						constant.AutoDiscoveredLabel: "true",
+						constant.VendorLabel:         "TestManufacturer",
+						constant.ChassisSerialLabel:  "TestType",
+					},
 					Annotations: map[string]string{
 						constant.AttributesAnnotation: `{"cpu":{"totalCores":4,"totalThreads":8},"memory":{"total":"8GB","usable":"7GB"},"blockDevices":[{"name":"sda"}],"networkInterfaces":[{"name":"eth0","mac":"00:11:22:33:44:55"}],"chassis":{"serial":"TestType","vendor":"TestManufacturer"},"bios":{"vendor":"TestVendor","version":"1.0.0"}}`,
 					},
@@ -79,7 +84,14 @@ func TestHandlerDiscover(t *testing.T) {
 					Auto: tinkerbell.AutoCapabilities{
 						EnrollmentEnabled: true,
 					},
-					Interfaces: []tinkerbell.Interface{{DHCP: &tinkerbell.DHCP{MAC: "00:11:22:33:44:55"}}},
+					Interfaces: []tinkerbell.Interface{{DHCP: &tinkerbell.DHCP{MAC: "00:11:22:33:44:55", IfaceName: "eth0"}}},
+					Resources: map[string]resource.Quantity{
+						"cpu":    resource.MustParse("8"),
+						"memory": resource.MustParse("8Gi"),
+					},
+					Metadata: &tinkerbell.HardwareMetadata{
+						Manufacturer: &tinkerbell.MetadataManufacturer{Slug: "testmanufacturer"},
+					},
 				},
 			},
 		},
@@ -179,7 +191,11 @@ func TestHandlerDiscover(t *testing.T) {
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "discovery-test-id",
 					Namespace: "test-namespace",
-					Labels:    map[string]string{"tinkerbell.org/auto-discovered": "true"},
+					Labels: map[string]string{
+						constant.AutoDiscoveredLabel: "true",
+						constant.VendorLabel:         "TestManufacturer",
+						constant.ChassisSerialLabel:  "TestType",
+					},
 					Annotations: map[string]string{
 						constant.AttributesAnnotation: `{"cpu":{"totalCores":4,"totalThreads":8},"memory":{"total":"8GB","usable":"7GB"},"blockDevices":[{"name":"sda"}],"networkInterfaces":[{"name":"eth0","mac":"00:11:22:33:44:55"},{"name":"tunl0","mac":"00:00:00:00"}],"chassis":{"serial":"TestType","vendor":"TestManufacturer"},"bios":{"vendor":"TestVendor","version":"1.0.0"}}`,
 					},
@@ -190,7 +206,14 @@ func TestHandlerDiscover(t *testing.T) {
 					Auto: tinkerbell.AutoCapabilities{
 						EnrollmentEnabled: true,
 					},
-					Interfaces: []tinkerbell.Interface{{DHCP: &tinkerbell.DHCP{MAC: "00:11:22:33:44:55"}}},
+					Interfaces: []tinkerbell.Interface{{DHCP: &tinkerbell.DHCP{MAC: "00:11:22:33:44:55", IfaceName: "eth0"}}},
+					Resources: map[string]resource.Quantity{
+						"cpu":    resource.MustParse("8"),
+						"memory": resource.MustParse("8Gi"),
+					},
+					Metadata: &tinkerbell.HardwareMetadata{
+						Manufacturer: &tinkerbell.MetadataManufacturer{Slug: "testmanufacturer"},
+					},
 				},
 			},
 		},
@@ -279,6 +302,82 @@ func TestHandlerDiscover(t *testing.T) {
 	}
 }
 
+func TestUpdateHardware(t *testing.T) {
+	tests := map[string]struct {
+		attrs   *data.AgentAttributes
+		profile *tinkerbell.Interface
+		want    tinkerbell.Hardware
+	}{
+		"nil attributes": {
+			want: tinkerbell.Hardware{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{}}},
+		},
+		"interfaces from profile": {
+			attrs: &data.AgentAttributes{
+				NetworkInterfaces: []*data.Network{
+					{Name: toPtr("eth0"), Mac: toPtr("00:11:22:33:44:55")},
+					{Name: toPtr("eth1"), Mac: toPtr("not-a-mac")},
+					{Name: toPtr("eth2")},
+				},
+			},
+			profile: &tinkerbell.Interface{
+				Netboot: &tinkerbell.Netboot{AllowPXE: toPtr(true), AllowWorkflow: toPtr(true)},
+				DHCP:    &tinkerbell.DHCP{MAC: "ignored", LeaseTime: 86400},
+			},
+			want: tinkerbell.Hardware{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{}},
+				Spec: tinkerbell.HardwareSpec{
+					Interfaces: []tinkerbell.Interface{{
+						Netboot: &tinkerbell.Netboot{AllowPXE: toPtr(true), AllowWorkflow: toPtr(true)},
+						DHCP:    &tinkerbell.DHCP{MAC: "00:11:22:33:44:55", IfaceName: "eth0", LeaseTime: 86400},
+					}},
+				},
+			},
+		},
+		"resources, manufacturer and labels": {
+			attrs: &data.AgentAttributes{
+				CPU:        &data.CPU{TotalCores: toPtr(uint32(16))},
+				Memory:     &data.Memory{Total: toPtr("512MB")},
+				Product:    &data.Product{Vendor: toPtr("Dell Inc."), Name: toPtr("PowerEdge R640"), SerialNumber: toPtr("ABC123")},
+				Baseboard:  &data.Baseboard{Vendor: toPtr("Other")},
+				GPUDevices: []*data.GPU{{Vendor: toPtr("NVIDIA Corporation")}},
+			},
+			want: tinkerbell.Hardware{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+					constant.VendorLabel:        "Dell-Inc",
+					constant.ModelLabel:         "PowerEdge-R640",
+					constant.ProductSerialLabel: "ABC123",
+					constant.GPULabel:           "true",
+				}},
+				Spec: tinkerbell.HardwareSpec{
+					Resources: map[string]resource.Quantity{
+						"cpu":    resource.MustParse("16"),
+						"memory": resource.MustParse("512Mi"),
+					},
+					Metadata: &tinkerbell.HardwareMetadata{
+						Manufacturer: &tinkerbell.MetadataManufacturer{Slug: "dell-inc"},
+					},
+				},
+			},
+		},
+		"invalid values are skipped": {
+			attrs: &data.AgentAttributes{
+				Memory:  &data.Memory{Total: toPtr("lots")},
+				Chassis: &data.Chassis{Vendor: toPtr("..."), Serial: toPtr("not a valid label")},
+			},
+			want: tinkerbell.Hardware{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{}}},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			hw := tinkerbell.Hardware{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{}}}
+			updateHardware(context.Background(), &hw, tc.attrs, tc.profile)
+			if diff := cmp.Diff(tc.want, hw); diff != "" {
+				t.Errorf("unexpected hardware (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 // mockClient implements client.Client with the ability to inject errors.
 type mockClient struct {
 	client.Client
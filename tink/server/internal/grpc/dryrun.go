@@ -0,0 +1,169 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"github.com/tinkerbell/tinkerbell/pkg/constant"
+	"github.com/tinkerbell/tinkerbell/pkg/data"
+	"github.com/tinkerbell/tinkerbell/tink/internal/render"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// dryRunAgentID is the Agent ID used when a dry run request only contains attributes.
+const dryRunAgentID = "dry-run"
+
+// DryRunRequest is the input for an auto enrollment dry run.
+// At least one of AgentID or Attributes must be set. When Attributes is empty, the attributes
+// recorded on the Hardware object for AgentID are used.
+type DryRunRequest struct {
+	AgentID    string                `json:"agentID,omitempty"`
+	Attributes *data.AgentAttributes `json:"attributes,omitempty"`
+}
+
+// DryRunResult describes what auto enrollment would do for an Agent.
+type DryRunResult struct {
+	// AgentID is the Agent ID used for matching and rendering.
+	AgentID string `json:"agentID"`
+	// Attributes are the Agent attributes that were matched against the WorkflowRuleSets.
+	Attributes *data.AgentAttributes `json:"attributes,omitempty"`
+	// Hardware is the Hardware object found for the Agent, if any.
+	Hardware *DryRunObject `json:"hardware,omitempty"`
+	// WorkflowRuleSet is the WorkflowRuleSet that was selected, if any.
+	WorkflowRuleSet *DryRunObject `json:"workflowRuleSet,omitempty"`
+	// Matches is the number of rules of the selected WorkflowRuleSet that matched.
+	Matches int `json:"matches,omitempty"`
+	// RuleSetErrors are the WorkflowRuleSets that could not be evaluated.
+	RuleSetErrors []DryRunRuleSetError `json:"ruleSetErrors,omitempty"`
+	// Workflow is the Workflow that would be created, with its status populated from the rendered Template
+	// when rendering succeeds.
+	Workflow *tinkerbell.Workflow `json:"workflow,omitempty"`
+	// RenderError is set when the Template could not be read or rendered.
+	RenderError string `json:"renderError,omitempty"`
+	// Warnings are conditions that would prevent auto enrollment from creating the Workflow,
+	// including the re-enrollment policy when the Agent already has Workflows.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// DryRunObject identifies a Kubernetes object in a DryRunResult.
+type DryRunObject struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// DryRunRuleSetError is a WorkflowRuleSet that could not be evaluated.
+type DryRunRuleSetError struct {
+	DryRunObject `json:",inline"`
+	Error        string `json:"error"`
+}
+
+// DryRun evaluates all WorkflowRuleSets against an Agent, the same way auto enrollment does, and renders the
+// Template of the selected WorkflowRuleSet. Nothing is created or updated in the backend.
+func (h *Handler) DryRun(ctx context.Context, req DryRunRequest) (*DryRunResult, error) {
+	if req.AgentID == "" && req.Attributes == nil {
+		return nil, status.Error(codes.InvalidArgument, "an agent id or attributes are required")
+	}
+	if h.AutoCapabilities.Enrollment.WorkflowRuleSetLister == nil || h.AutoCapabilities.Enrollment.TemplateReader == nil {
+		return nil, status.Error(codes.Unimplemented, "dry run is not supported by the backend")
+	}
+
+	res := &DryRunResult{AgentID: req.AgentID, Attributes: req.Attributes}
+	if res.AgentID == "" {
+		res.AgentID = dryRunAgentID
+	}
+
+	var hw *tinkerbell.Hardware
+	if req.AgentID != "" && h.Backend != nil {
+		found, err := h.hardware(ctx, req.AgentID)
+		switch {
+		case err == nil:
+			hw = found
+			res.Hardware = &DryRunObject{Name: hw.Name, Namespace: hw.Namespace}
+		case !hardwareNotFound(err):
+			return nil, status.Errorf(codes.Internal, "error getting hardware: %v", err)
+		}
+	}
+	if hw == nil {
+		found, err := h.findHardwareByAttributes(ctx, req.AgentID, req.Attributes)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "error getting hardware: %v", err)
+		}
+		if found != nil {
+			hw = found
+			res.Hardware = &DryRunObject{Name: hw.Name, Namespace: hw.Namespace}
+		}
+	}
+	if res.Attributes == nil && hw != nil {
+		if a, ok := hw.Annotations[constant.AttributesAnnotation]; ok && a != "" {
+			attr := &data.AgentAttributes{}
+			if err := json.Unmarshal([]byte(a), attr); err != nil {
+				return nil, status.Errorf(codes.Internal, "error decoding attributes from hardware %s/%s: %v", hw.Namespace, hw.Name, err)
+			}
+			res.Attributes = attr
+		}
+	}
+	if res.Attributes == nil {
+		return nil, status.Errorf(codes.NotFound, "no attributes provided or found for agent %s", req.AgentID)
+	}
+
+	if !h.AutoCapabilities.Enrollment.Enabled {
+		res.Warnings = append(res.Warnings, "auto enrollment is disabled")
+	}
+	if hw != nil && !hw.Spec.Auto.EnrollmentEnabled {
+		res.Warnings = append(res.Warnings, "auto enrollment is disabled for this hardware")
+	}
+
+	wrs, err := h.AutoCapabilities.Enrollment.ListWorkflowRuleSets(ctx, data.WorkflowFilter{})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "error getting workflow rules: %v", err)
+	}
+	final, rsErrs := selectWorkflowRuleSet(wrs, res.Attributes)
+	for _, re := range rsErrs {
+		res.RuleSetErrors = append(res.RuleSetErrors, DryRunRuleSetError{
+			DryRunObject: DryRunObject{Name: re.wrs.Name, Namespace: re.wrs.Namespace},
+			Error:        re.err.Error(),
+		})
+	}
+	if final == nil {
+		return res, nil
+	}
+	res.WorkflowRuleSet = &DryRunObject{Name: final.wrs.Name, Namespace: final.wrs.Namespace}
+	res.Matches = final.numMatches
+
+	var existing []tinkerbell.Workflow
+	if req.AgentID != "" && h.Backend != nil {
+		existing, err = h.Backend.ListWorkflows(ctx, data.WorkflowFilter{ByAgentID: req.AgentID})
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "error getting workflows: %v", err)
+		}
+	}
+	if len(existing) > 0 {
+		if reason := h.reEnrollmentRefused(existing, final); reason != "" {
+			res.Warnings = append(res.Warnings, reason)
+		}
+	}
+
+	name, err := enrollmentName(res.AgentID, existing)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "error making agentID a valid Kubernetes name: %v", err)
+	}
+	awf := newEnrollmentWorkflow(ctx, name, res.AgentID, final.wrs, res.Attributes, hw)
+	res.Workflow = awf
+
+	tpl, err := h.AutoCapabilities.Enrollment.ReadTemplate(ctx, awf.Spec.TemplateRef, awf.Namespace)
+	if err != nil {
+		res.RenderError = fmt.Sprintf("error getting template %s/%s: %v", awf.Namespace, awf.Spec.TemplateRef, err)
+		return res, nil
+	}
+	st, err := render.Status(h.Logger, tpl, awf, hw)
+	if err != nil {
+		res.RenderError = err.Error()
+		return res, nil
+	}
+	awf.Status = *st
+
+	return res, nil
+}
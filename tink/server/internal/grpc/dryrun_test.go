@@ -0,0 +1,242 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"github.com/tinkerbell/tinkerbell/pkg/constant"
+	"github.com/tinkerbell/tinkerbell/pkg/data"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type mockTemplateReader struct {
+	templates map[string]*tinkerbell.Template
+}
+
+func (m *mockTemplateReader) ReadTemplate(_ context.Context, name, namespace string) (*tinkerbell.Template, error) {
+	if t, ok := m.templates[namespace+"/"+name]; ok {
+		return t, nil
+	}
+	return nil, apierrors.NewNotFound(schema.GroupResource{Group: "tinkerbell.org", Resource: "templates"}, name)
+}
+
+func TestDryRun(t *testing.T) {
+	tmpl := `version: "0.1"
+name: enrollment
+global_timeout: 600
+tasks:
+  - name: "install"
+    worker: "{{ .device_1 }}"
+    actions:
+      - name: "wipe"
+        image: quay.io/tinkerbell/actions/wipe:latest
+        timeout: 90
+        environment:
+          DEST_DISK: {{ .parameters.disk }}
+`
+	templates := &mockTemplateReader{templates: map[string]*tinkerbell.Template{
+		"default/install": {
+			ObjectMeta: metav1.ObjectMeta{Name: "install", Namespace: "default"},
+			Spec: tinkerbell.TemplateSpec{
+				Data:       &tmpl,
+				Parameters: []tinkerbell.TemplateParameter{{Name: "disk", Required: true}},
+			},
+		},
+	}}
+	ruleSets := []tinkerbell.WorkflowRuleSet{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "install", Namespace: "default"},
+			Spec: tinkerbell.WorkflowRuleSetSpec{
+				Rules: []string{`{"chassis": {"serial": ["12345"]}}`},
+				Workflow: tinkerbell.WorkflowRuleSetWorkflow{
+					Namespace: "default",
+					Template: tinkerbell.TemplateConfig{
+						Ref:        "install",
+						AgentValue: "device_1",
+						Parameters: map[string]tinkerbell.ParameterValue{"disk": {Raw: []byte(`"/dev/sda"`)}},
+					},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "missing-template", Namespace: "default"},
+			Spec: tinkerbell.WorkflowRuleSetSpec{
+				Rules: []string{`{"chassis": {"serial": ["67890"]}}`},
+				Workflow: tinkerbell.WorkflowRuleSetWorkflow{
+					Namespace: "default",
+					Template:  tinkerbell.TemplateConfig{Ref: "missing", AgentValue: "device_1"},
+				},
+			},
+		},
+	}
+	notFound := apierrors.NewNotFound(schema.GroupResource{Group: "tinkerbell.org", Resource: "hardware"}, "")
+
+	tests := map[string]struct {
+		req          DryRunRequest
+		hardware     *tinkerbell.Hardware
+		hardwareErr  error
+		workflows    []tinkerbell.Workflow
+		wantCode     codes.Code
+		wantRuleSet  string
+		wantHardware string
+		wantRender   string
+		wantTasks    int
+		wantWarnings []string
+	}{
+		"empty request": {
+			wantCode: codes.InvalidArgument,
+		},
+		"attributes only": {
+			req:         DryRunRequest{Attributes: &data.AgentAttributes{Chassis: &data.Chassis{Serial: toPtr("12345")}}},
+			hardwareErr: notFound,
+			wantRuleSet: "install",
+			wantTasks:   1,
+		},
+		"no match": {
+			req:         DryRunRequest{Attributes: &data.AgentAttributes{Chassis: &data.Chassis{Serial: toPtr("00000")}}},
+			hardwareErr: notFound,
+		},
+		"attributes from hardware": {
+			req: DryRunRequest{AgentID: "00:00:00:00:00:01"},
+			hardware: &tinkerbell.Hardware{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "machine1",
+					Namespace:   "default",
+					Annotations: map[string]string{constant.AttributesAnnotation: `{"chassis":{"serial":"12345"}}`},
+				},
+			},
+			wantRuleSet:  "install",
+			wantHardware: "machine1",
+			wantTasks:    1,
+			wantWarnings: []string{"auto enrollment is disabled for this hardware"},
+		},
+		"re-enrollment not allowed": {
+			req: DryRunRequest{AgentID: "00:00:00:00:00:01", Attributes: &data.AgentAttributes{Chassis: &data.Chassis{Serial: toPtr("12345")}}},
+			hardware: &tinkerbell.Hardware{
+				ObjectMeta: metav1.ObjectMeta{Name: "machine1", Namespace: "default"},
+				Spec:       tinkerbell.HardwareSpec{Auto: tinkerbell.AutoCapabilities{EnrollmentEnabled: true}},
+			},
+			workflows: []tinkerbell.Workflow{{
+				ObjectMeta: metav1.ObjectMeta{Name: "enrollment-00-00-00-00-00-01", Namespace: "default", CreationTimestamp: metav1.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+				Status:     tinkerbell.WorkflowStatus{State: tinkerbell.WorkflowStateSuccess},
+			}},
+			wantRuleSet:  "install",
+			wantHardware: "machine1",
+			wantTasks:    1,
+			wantWarnings: []string{`re-enrollment policy "never" of WorkflowRuleSet install doesn't allow re-enrollment after Workflow enrollment-00-00-00-00-00-01 in state SUCCESS`},
+		},
+		"unfinished workflow": {
+			req: DryRunRequest{AgentID: "00:00:00:00:00:01", Attributes: &data.AgentAttributes{Chassis: &data.Chassis{Serial: toPtr("12345")}}},
+			hardware: &tinkerbell.Hardware{
+				ObjectMeta: metav1.ObjectMeta{Name: "machine1", Namespace: "default"},
+				Spec:       tinkerbell.HardwareSpec{Auto: tinkerbell.AutoCapabilities{EnrollmentEnabled: true}},
+			},
+			workflows: []tinkerbell.Workflow{{
+				ObjectMeta: metav1.ObjectMeta{Name: "enrollment-00-00-00-00-00-01", Namespace: "default"},
+			}},
+			wantRuleSet:  "install",
+			wantHardware: "machine1",
+			wantTasks:    1,
+			wantWarnings: []string{"Workflow enrollment-00-00-00-00-00-01 is not finished"},
+		},
+		"no attributes for agent": {
+			req:         DryRunRequest{AgentID: "00:00:00:00:00:01"},
+			hardwareErr: notFound,
+			wantCode:    codes.NotFound,
+		},
+		"hardware error": {
+			req:         DryRunRequest{AgentID: "00:00:00:00:00:01"},
+			hardwareErr: errors.New("boom"),
+			wantCode:    codes.Internal,
+		},
+		"template not found": {
+			req:         DryRunRequest{Attributes: &data.AgentAttributes{Chassis: &data.Chassis{Serial: toPtr("67890")}}},
+			hardwareErr: notFound,
+			wantRuleSet: "missing-template",
+			wantRender:  `error getting template default/missing: templates.tinkerbell.org "missing" not found`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mock := &mockAutoCapabilities{
+				ListWorkflowRuleSetsFunc: func(_ context.Context, _ data.WorkflowFilter) ([]tinkerbell.WorkflowRuleSet, error) {
+					return ruleSets, nil
+				},
+			}
+			h := &Handler{
+				Backend: &mockBackendReadWriter{hardware: tt.hardware, hardwareErr: tt.hardwareErr, workflows: tt.workflows},
+				AutoCapabilities: AutoCapabilities{
+					Enrollment: AutoEnrollment{
+						Enabled:               true,
+						WorkflowRuleSetLister: mock,
+						TemplateReader:        templates,
+					},
+				},
+			}
+
+			got, err := h.DryRun(context.Background(), tt.req)
+			if tt.wantCode != codes.OK {
+				if status.Code(err) != tt.wantCode {
+					t.Fatalf("expected code %v, got: %v", tt.wantCode, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(mock.updated) > 0 {
+				t.Errorf("dry run must not update WorkflowRuleSets")
+			}
+
+			var gotRuleSet, gotHardware string
+			if got.WorkflowRuleSet != nil {
+				gotRuleSet = got.WorkflowRuleSet.Name
+			}
+			if got.Hardware != nil {
+				gotHardware = got.Hardware.Name
+			}
+			if gotRuleSet != tt.wantRuleSet {
+				t.Errorf("expected WorkflowRuleSet %q, got %q", tt.wantRuleSet, gotRuleSet)
+			}
+			if gotHardware != tt.wantHardware {
+				t.Errorf("expected Hardware %q, got %q", tt.wantHardware, gotHardware)
+			}
+			if got.RenderError != tt.wantRender {
+				t.Errorf("expected render error %q, got %q", tt.wantRender, got.RenderError)
+			}
+			if diff := cmp.Diff(tt.wantWarnings, got.Warnings); diff != "" {
+				t.Errorf("unexpected warnings (-want +got):\n%s", diff)
+			}
+			if tt.wantRuleSet == "" {
+				if got.Workflow != nil {
+					t.Errorf("expected no Workflow, got %v", got.Workflow.Name)
+				}
+				return
+			}
+			if got.Workflow == nil {
+				t.Fatal("expected a Workflow")
+			}
+			if len(got.Workflow.Status.Tasks) != tt.wantTasks {
+				t.Fatalf("expected %d tasks, got %d", tt.wantTasks, len(got.Workflow.Status.Tasks))
+			}
+			if tt.wantTasks > 0 {
+				task := got.Workflow.Status.Tasks[0]
+				if task.AgentID != got.AgentID {
+					t.Errorf("expected task agent %q, got %q", got.AgentID, task.AgentID)
+				}
+				if d := task.Actions[0].Environment["DEST_DISK"]; d != "/dev/sda" {
+					t.Errorf("expected DEST_DISK /dev/sda, got %q", d)
+				}
+			}
+		})
+	}
+}
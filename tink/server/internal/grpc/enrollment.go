@@ -1,11 +1,14 @@
 package grpc
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"maps"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/cenkalti/backoff/v5"
@@ -31,15 +34,18 @@ type match struct {
 }
 
 // enroll creates a Workflow for an agentID by matching the attr against WorkflowRuleSets.
+// existing are the Workflows the Agent already has. When there are any, the Agent is being re-enrolled and
+// the matched WorkflowRuleSet's ReEnrollmentPolicy decides, based on the most recently created finished Workflow,
+// whether a new Workflow is created.
 // auto enrollment does not support Templates with multiple Agents defined.
-func (h *Handler) enroll(ctx context.Context, agentID string, attr *data.AgentAttributes, hardware *tinkerbell.Hardware) (*proto.ActionResponse, error) {
+func (h *Handler) enroll(ctx context.Context, agentID string, attr *data.AgentAttributes, hardware *tinkerbell.Hardware, existing []tinkerbell.Workflow) (*proto.ActionResponse, error) {
 	log := h.Logger.WithValues("agentID", agentID)
-	// If auto enrollment is not enabled, then we do not create a Workflow for the AgentID.
-	if hardware != nil && !hardware.Spec.Auto.EnrollmentEnabled {
-		journal.Log(ctx, "auto enrollment is disabled for this hardware", "hardware", hardware.Name)
-		return nil, status.Errorf(codes.FailedPrecondition, "auto enrollment is disabled for this hardware")
+	if len(existing) > 0 {
+		if reason := h.reEnrollmentBlocked(existing); reason != "" {
+			journal.Log(ctx, "re-enrollment not possible", "reason", reason)
+			return nil, status.Error(codes.FailedPrecondition, "Workflow not in pending or running state")
+		}
 	}
-
 	// Get all WorkflowRuleSets and check if there is a match to the AgentID or the Attributes (if Attributes are provided by request)
 	// using github.com/timbray/quamina
 	// If there is a match, create a Workflow for the AgentID.
@@ -48,72 +54,54 @@ func (h *Handler) enroll(ctx context.Context, agentID string, attr *data.AgentAt
 		journal.Log(ctx, "error getting workflow rules", "error", err)
 		return nil, errors.Join(ErrBackendRead, status.Errorf(codes.Internal, "error getting workflow rules: %v", err))
 	}
-	name, err := makeValidName(agentID, workflowPrefix)
+	var last *tinkerbell.Workflow
+	if len(existing) > 0 {
+		// An Agent whose Workflows are all finished polls for Actions every few seconds.
+		// Refuse before linking Hardware, matching and recording status when no policy can allow re-enrollment.
+		last = latestFinishedWorkflow(existing)
+		if last == nil || !reEnrollmentAllowed(wrs, last.Status.State) {
+			journal.Log(ctx, "re-enrollment not allowed by any policy")
+			return nil, status.Error(codes.FailedPrecondition, "Workflow not in pending or running state")
+		}
+	}
+	if hardware == nil {
+		// No Hardware has the Agent ID, look for pre-registered Hardware that matches the Agent's attributes.
+		hw, err := h.linkHardware(ctx, agentID, attr)
+		if err != nil {
+			journal.Log(ctx, "error finding hardware by attributes", "error", err)
+			log.Error(err, "error finding hardware by attributes")
+		}
+		hardware = hw
+	}
+	// If auto enrollment is not enabled, then we do not create a Workflow for the AgentID.
+	if hardware != nil && !hardware.Spec.Auto.EnrollmentEnabled {
+		journal.Log(ctx, "auto enrollment is disabled for this hardware", "hardware", hardware.Name)
+		return nil, status.Errorf(codes.FailedPrecondition, "auto enrollment is disabled for this hardware")
+	}
+
+	name, err := enrollmentName(agentID, existing)
 	if err != nil {
 		journal.Log(ctx, "error making agentID a valid Kubernetes name", "error", err)
 		return nil, status.Errorf(codes.Internal, "error making agentID a valid Kubernetes name: %v", err)
 	}
 	log = log.WithValues("workflowName", name)
 
-	final := &match{}
-	for _, wr := range wrs {
-		m, err := findMatch(wr, attr, final.numMatches)
-		if err != nil {
-			journal.Log(ctx, "error matching pattern", "error", err)
-			log.Error(err, "error matching pattern")
-			continue
-		}
-		if m != nil {
-			final.numMatches = m.numMatches
-			final.wrs = m.wrs
+	final, rsErrs := selectWorkflowRuleSet(wrs, attr)
+	for _, re := range rsErrs {
+		journal.Log(ctx, "error matching pattern", "error", re.err, "workflowRuleSet", re.wrs.Name)
+		log.Error(re.err, "error matching pattern", "workflowRuleSet", re.wrs.Name, "namespace", re.wrs.Namespace)
+		h.recordRuleSetError(ctx, re.wrs, re.err)
+	}
+	if len(existing) > 0 {
+		if reason := h.reEnrollmentRefused(existing, final); reason != "" {
+			journal.Log(ctx, "re-enrollment not allowed", "reason", reason)
+			return nil, status.Error(codes.FailedPrecondition, "Workflow not in pending or running state")
 		}
+		journal.Log(ctx, "re-enrolling Agent", "previousWorkflow", last.Name, "previousState", last.Status.State)
 	}
-	if final.numMatches > 0 { //nolint:nestif // TODO: look into this.
+	if final != nil { //nolint:nestif // TODO: look into this.
 		// Create a Workflow for the AgentID
-		awf := &tinkerbell.Workflow{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      name,
-				Namespace: final.wrs.Spec.Workflow.Namespace,
-				Labels: map[string]string{
-					"tinkerbell.org/auto-enrollment": "true",
-				},
-				OwnerReferences: []metav1.OwnerReference{
-					{
-						APIVersion: final.wrs.APIVersion,
-						Kind:       final.wrs.Kind,
-						Name:       final.wrs.Name,
-						UID:        final.wrs.UID,
-					},
-				},
-			},
-			Spec: tinkerbell.WorkflowSpec{
-				TemplateRef: final.wrs.Spec.Workflow.Template.Ref,
-				Disabled:    final.wrs.Spec.Workflow.Disabled,
-			},
-		}
-		if hardware != nil {
-			awf.Spec.HardwareRef = hardware.Name
-		}
-
-		if final.wrs.Spec.Workflow.AddAttributes {
-			// Add attributes to the Workflow as an annotation.
-			if awf.Annotations == nil {
-				awf.Annotations = make(map[string]string)
-			}
-			if a, err := json.Marshal(attr); err == nil {
-				awf.Annotations[constant.AttributesAnnotation] = string(a)
-			} else {
-				journal.Log(ctx, "error marshalling attributes to json", "error", err)
-			}
-		}
-
-		if awf.Spec.HardwareMap == nil {
-			awf.Spec.HardwareMap = make(map[string]string)
-		}
-		awf.Spec.HardwareMap[final.wrs.Spec.Workflow.Template.AgentValue] = agentID
-		maps.Copy(awf.Spec.HardwareMap, final.wrs.Spec.Workflow.Template.KVs)
-		// TODO: if the awf.Spec.HardwareRef is an empty string, then query for a Hardware object with some corresponding value from the attributes.
-		// If a Hardware object is found add it to the awf.Spec.HardwareRef.
+		awf := newEnrollmentWorkflow(ctx, name, agentID, final.wrs, attr, hardware)
 		if err := h.AutoCapabilities.Enrollment.CreateWorkflow(ctx, awf); err != nil {
 			if apierrors.IsAlreadyExists(err) {
 				journal.Log(ctx, "workflow already exists", "workflow", name, "namespace", final.wrs.Spec.Workflow.Namespace)
@@ -123,8 +111,10 @@ func (h *Handler) enroll(ctx context.Context, agentID string, attr *data.AgentAt
 				return nil, status.Error(codes.FailedPrecondition, "existing workflow found")
 			}
 			journal.Log(ctx, "error creating enrollment workflow", "error", err)
+			h.recordRuleSetError(ctx, final.wrs, fmt.Errorf("error creating enrollment workflow %s/%s: %w", awf.Namespace, awf.Name, err))
 			return nil, errors.Join(ErrBackendWrite, status.Errorf(codes.Internal, "error creating enrollment workflow: %v", err))
 		}
+		h.recordRuleSetMatch(ctx, final.wrs, agentID)
 
 		ar := &proto.ActionRequest{
 			AgentId: &agentID,
@@ -137,6 +127,192 @@ func (h *Handler) enroll(ctx context.Context, agentID string, attr *data.AgentAt
 	return nil, status.Errorf(codes.NotFound, "no Workflow Rule Sets found or matched for Agent %s", agentID)
 }
 
+// enrollmentName returns the name of the auto enrollment Workflow for agentID.
+// The first Workflow is named enrollment-<agentID>. When the Agent already has Workflows, a numeric suffix is added
+// so that previous Workflows are kept as history and the new Workflow does not collide with them.
+func enrollmentName(agentID string, existing []tinkerbell.Workflow) (string, error) {
+	name, err := makeValidName(agentID, workflowPrefix)
+	if err != nil || len(existing) == 0 {
+		return name, err
+	}
+
+	names := make(map[string]bool, len(existing))
+	for _, w := range existing {
+		names[w.Name] = true
+	}
+	for n := len(existing) + 1; ; n++ {
+		suffix := fmt.Sprintf("-%d", n)
+		base := name
+		if len(base)+len(suffix) > 63 {
+			base = strings.TrimRight(base[:63-len(suffix)], "-")
+		}
+		if candidate := base + suffix; !names[candidate] {
+			return candidate, nil
+		}
+	}
+}
+
+// latestFinishedWorkflow returns the most recently created finished Workflow in wfs or nil if there is none.
+func latestFinishedWorkflow(wfs []tinkerbell.Workflow) *tinkerbell.Workflow {
+	var latest *tinkerbell.Workflow
+	for i := range wfs {
+		w := &wfs[i]
+		if !isWorkflowFinished(*w) {
+			continue
+		}
+		if latest == nil || latest.CreationTimestamp.Before(&w.CreationTimestamp) ||
+			(latest.CreationTimestamp.Equal(&w.CreationTimestamp) && latest.Name < w.Name) {
+			latest = w
+		}
+	}
+	return latest
+}
+
+// reEnrollmentBlocked returns why an Agent with the existing Workflows can't be re-enrolled yet, or an empty string.
+// Any Workflow that isn't finished blocks re-enrollment, including a new one that has no Tasks until it is reconciled.
+// So does a Workflow created less than the re-enrollment cooldown ago, so that an Agent that keeps polling after
+// its Workflow finished doesn't get a new Workflow on every poll.
+func (h *Handler) reEnrollmentBlocked(existing []tinkerbell.Workflow) string {
+	var newest time.Time
+	for _, w := range existing {
+		if !isWorkflowFinished(w) {
+			return fmt.Sprintf("Workflow %s is not finished", w.Name)
+		}
+		if w.CreationTimestamp.After(newest) {
+			newest = w.CreationTimestamp.Time
+		}
+	}
+	cooldown := cmp.Or(h.AutoCapabilities.Enrollment.ReEnrollmentCooldown, DefaultReEnrollmentCooldown)
+	if cooldown > 0 && h.now().Sub(newest) < cooldown {
+		return fmt.Sprintf("last Workflow was created less than %v ago", cooldown)
+	}
+	return ""
+}
+
+// reEnrollmentRefused returns why an Agent with the existing Workflows isn't re-enrolled with the matched WorkflowRuleSet final,
+// or an empty string when it is. final is nil when no WorkflowRuleSet matched. existing must not be empty.
+// It's used by both enroll and DryRun so that a dry run reports what re-enrollment would do.
+func (h *Handler) reEnrollmentRefused(existing []tinkerbell.Workflow, final *match) string {
+	if reason := h.reEnrollmentBlocked(existing); reason != "" {
+		return reason
+	}
+	if final == nil {
+		return "no WorkflowRuleSet matched for re-enrollment"
+	}
+	last := latestFinishedWorkflow(existing)
+	if policy := cmp.Or(final.wrs.Spec.Workflow.ReEnrollmentPolicy, tinkerbell.ReEnrollmentNever); !policy.Allows(last.Status.State) {
+		return fmt.Sprintf("re-enrollment policy %q of WorkflowRuleSet %s doesn't allow re-enrollment after Workflow %s in state %s", policy, final.wrs.Name, last.Name, last.Status.State)
+	}
+	return ""
+}
+
+// reEnrollmentAllowed reports whether the ReEnrollmentPolicy of any of wrs allows re-enrollment after a Workflow in state.
+func reEnrollmentAllowed(wrs []tinkerbell.WorkflowRuleSet, state tinkerbell.WorkflowState) bool {
+	for _, w := range wrs {
+		if w.Spec.Workflow.ReEnrollmentPolicy.Allows(state) {
+			return true
+		}
+	}
+	return false
+}
+
+// newEnrollmentWorkflow returns the Workflow that auto enrollment creates for agentID from the matched WorkflowRuleSet wrs.
+func newEnrollmentWorkflow(ctx context.Context, name, agentID string, wrs tinkerbell.WorkflowRuleSet, attr *data.AgentAttributes, hardware *tinkerbell.Hardware) *tinkerbell.Workflow {
+	awf := &tinkerbell.Workflow{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: wrs.Spec.Workflow.Namespace,
+			Labels: map[string]string{
+				"tinkerbell.org/auto-enrollment": "true",
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: wrs.APIVersion,
+					Kind:       wrs.Kind,
+					Name:       wrs.Name,
+					UID:        wrs.UID,
+				},
+			},
+		},
+		Spec: tinkerbell.WorkflowSpec{
+			TemplateRef: wrs.Spec.Workflow.Template.Ref,
+			Disabled:    wrs.Spec.Workflow.Disabled,
+		},
+	}
+	if hardware != nil {
+		awf.Spec.HardwareRef = hardware.Name
+	}
+
+	if wrs.Spec.Workflow.AddAttributes {
+		// Add attributes to the Workflow as an annotation.
+		if awf.Annotations == nil {
+			awf.Annotations = make(map[string]string)
+		}
+		if a, err := json.Marshal(attr); err == nil {
+			awf.Annotations[constant.AttributesAnnotation] = string(a)
+		} else {
+			journal.Log(ctx, "error marshalling attributes to json", "error", err)
+		}
+	}
+
+	if awf.Spec.HardwareMap == nil {
+		awf.Spec.HardwareMap = make(map[string]string)
+	}
+	awf.Spec.HardwareMap[wrs.Spec.Workflow.Template.AgentValue] = agentID
+	maps.Copy(awf.Spec.HardwareMap, wrs.Spec.Workflow.Template.KVs)
+	if len(wrs.Spec.Workflow.Template.Parameters) > 0 {
+		awf.Spec.Parameters = make(map[string]tinkerbell.ParameterValue, len(wrs.Spec.Workflow.Template.Parameters))
+		maps.Copy(awf.Spec.Parameters, wrs.Spec.Workflow.Template.Parameters)
+	}
+
+	return awf
+}
+
+// ruleSetError is an error encountered while evaluating a single WorkflowRuleSet.
+type ruleSetError struct {
+	wrs tinkerbell.WorkflowRuleSet
+	err error
+}
+
+// selectWorkflowRuleSet evaluates WorkflowRuleSets against attr and returns the one to use for enrollment.
+// WorkflowRuleSets are considered in order of descending Priority and the first Priority with a match wins.
+// Within a Priority, the WorkflowRuleSet with the most matching rules wins and ties are broken by namespace and name.
+// A nil match means no WorkflowRuleSet matched. WorkflowRuleSets that could not be evaluated are returned as errors.
+func selectWorkflowRuleSet(wrs []tinkerbell.WorkflowRuleSet, attr *data.AgentAttributes) (*match, []ruleSetError) {
+	sorted := slices.Clone(wrs)
+	slices.SortStableFunc(sorted, func(a, b tinkerbell.WorkflowRuleSet) int {
+		return cmp.Or(
+			cmp.Compare(b.Spec.Priority, a.Spec.Priority),
+			cmp.Compare(a.Namespace, b.Namespace),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+
+	var (
+		final *match
+		errs  []ruleSetError
+	)
+	for _, wr := range sorted {
+		if final != nil && wr.Spec.Priority < final.wrs.Spec.Priority {
+			break
+		}
+		cur := 0
+		if final != nil {
+			cur = final.numMatches
+		}
+		m, err := findMatch(wr, attr, cur)
+		if err != nil {
+			errs = append(errs, ruleSetError{wrs: wr, err: err})
+			continue
+		}
+		if m != nil {
+			final = m
+		}
+	}
+
+	return final, errs
+}
+
 func findMatch(wr tinkerbell.WorkflowRuleSet, attr *data.AgentAttributes, curMatches int) (*match, error) {
 	q, _ := quamina.New() // errors are ignored because they can only happen when passing in options.
 	for idx, r := range wr.Spec.Rules {
@@ -158,6 +334,9 @@ func findMatch(wr tinkerbell.WorkflowRuleSet, attr *data.AgentAttributes, curMat
 	if err != nil {
 		return nil, fmt.Errorf("error matching pattern: %w", err)
 	}
+	if wr.Spec.MatchAll && len(matches) < len(wr.Spec.Rules) {
+		return nil, nil
+	}
 	if len(matches) > curMatches {
 		return &match{
 			numMatches: len(matches),
@@ -168,6 +347,43 @@ func findMatch(wr tinkerbell.WorkflowRuleSet, attr *data.AgentAttributes, curMat
 	return nil, nil
 }
 
+// recordRuleSetMatch records in the WorkflowRuleSet status that a Workflow was created from it for agentID.
+func (h *Handler) recordRuleSetMatch(ctx context.Context, wrs tinkerbell.WorkflowRuleSet, agentID string) {
+	h.updateRuleSetStatus(ctx, wrs, func(s *tinkerbell.WorkflowRuleSetStatus) {
+		s.MatchCount++
+		s.LastMatchedAgentID = agentID
+		s.LastMatchedTime = &metav1.Time{Time: h.now()}
+	})
+}
+
+// recordRuleSetError records err in the WorkflowRuleSet status.
+// The status is not updated when the error is the same as the last recorded one so that
+// repeated Agent requests don't cause a write for every request.
+func (h *Handler) recordRuleSetError(ctx context.Context, wrs tinkerbell.WorkflowRuleSet, err error) {
+	if wrs.Status.LastError == err.Error() {
+		return
+	}
+	h.updateRuleSetStatus(ctx, wrs, func(s *tinkerbell.WorkflowRuleSetStatus) {
+		s.LastError = err.Error()
+		s.LastErrorTime = &metav1.Time{Time: h.now()}
+	})
+}
+
+// updateRuleSetStatus applies fn to the status of wrs and patches it in the backend.
+// Errors are logged and not returned as status reporting must not block enrollment.
+func (h *Handler) updateRuleSetStatus(ctx context.Context, wrs tinkerbell.WorkflowRuleSet, fn func(*tinkerbell.WorkflowRuleSetStatus)) {
+	if h.AutoCapabilities.Enrollment.WorkflowRuleSetUpdater == nil {
+		return
+	}
+	original := wrs.DeepCopy()
+	updated := wrs.DeepCopy()
+	fn(&updated.Status)
+	if err := h.AutoCapabilities.Enrollment.UpdateWorkflowRuleSet(ctx, updated, data.UpdateOptions{StatusOnly: true, PatchFrom: original}); err != nil {
+		journal.Log(ctx, "error updating workflow rule set status", "error", err)
+		h.Logger.Error(err, "error updating workflow rule set status", "workflowRuleSet", wrs.Name, "namespace", wrs.Namespace)
+	}
+}
+
 // getActionNoAuto calls to the doGetAction method with a retry mechanism that disables auto capabilities.
 func (h *Handler) getActionNoAuto(ctx context.Context, req *proto.ActionRequest) (*proto.ActionResponse, error) {
 	operation := func() (*proto.ActionResponse, error) {
@@ -3,14 +3,19 @@ package grpc
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/cenkalti/backoff/v5"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
 	"github.com/tinkerbell/tinkerbell/pkg/data"
 	"github.com/tinkerbell/tinkerbell/pkg/proto"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestEnroll(t *testing.T) {
@@ -123,7 +128,7 @@ func TestEnroll(t *testing.T) {
 				},
 			}
 
-			_, err := handler.enroll(context.Background(), tt.workerID, convert(tt.attributes), nil)
+			_, err := handler.enroll(context.Background(), tt.workerID, convert(tt.attributes), nil, nil)
 			if err == nil {
 				t.Fatalf("expected error, got nil")
 			}
@@ -143,6 +148,7 @@ func TestEnroll(t *testing.T) {
 type mockAutoCapabilities struct {
 	ListWorkflowRuleSetsFunc func(ctx context.Context, opts data.WorkflowFilter) ([]tinkerbell.WorkflowRuleSet, error)
 	CreateWorkflowFunc       func(ctx context.Context, wf *tinkerbell.Workflow) error
+	updated                  []*tinkerbell.WorkflowRuleSet
 }
 
 func (m *mockAutoCapabilities) ListWorkflowRuleSets(ctx context.Context, opts data.WorkflowFilter) ([]tinkerbell.WorkflowRuleSet, error) {
@@ -152,3 +158,329 @@ func (m *mockAutoCapabilities) ListWorkflowRuleSets(ctx context.Context, opts da
 func (m *mockAutoCapabilities) CreateWorkflow(ctx context.Context, wf *tinkerbell.Workflow) error {
 	return m.CreateWorkflowFunc(ctx, wf)
 }
+
+func (m *mockAutoCapabilities) UpdateWorkflowRuleSet(_ context.Context, wrs *tinkerbell.WorkflowRuleSet, _ data.UpdateOptions) error {
+	m.updated = append(m.updated, wrs)
+	return nil
+}
+
+func TestSelectWorkflowRuleSet(t *testing.T) {
+	wrs := func(name string, priority int32, matchAll bool, rules ...string) tinkerbell.WorkflowRuleSet {
+		return tinkerbell.WorkflowRuleSet{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: tinkerbell.WorkflowRuleSetSpec{
+				Rules:    rules,
+				MatchAll: matchAll,
+				Priority: priority,
+			},
+		}
+	}
+	serial := `{"chassis": {"serial": ["12345"]}}`
+	vendor := `{"chassis": {"vendor": ["acme"]}}`
+	other := `{"chassis": {"serial": ["67890"]}}`
+	attr := &data.AgentAttributes{Chassis: &data.Chassis{Serial: toPtr("12345"), Vendor: toPtr("acme")}}
+
+	tests := map[string]struct {
+		ruleSets  []tinkerbell.WorkflowRuleSet
+		want      string
+		wantCount int
+		wantErrs  []string
+	}{
+		"no rule sets": {},
+		"no match": {
+			ruleSets: []tinkerbell.WorkflowRuleSet{wrs("a", 0, false, other)},
+		},
+		"most matches wins within a priority": {
+			ruleSets:  []tinkerbell.WorkflowRuleSet{wrs("a", 0, false, serial), wrs("b", 0, false, serial, vendor)},
+			want:      "b",
+			wantCount: 2,
+		},
+		"ties broken by name": {
+			ruleSets:  []tinkerbell.WorkflowRuleSet{wrs("b", 0, false, serial), wrs("a", 0, false, vendor)},
+			want:      "a",
+			wantCount: 1,
+		},
+		"higher priority wins over more matches": {
+			ruleSets:  []tinkerbell.WorkflowRuleSet{wrs("a", 0, false, serial, vendor), wrs("b", 10, false, serial)},
+			want:      "b",
+			wantCount: 1,
+		},
+		"lower priority used when higher does not match": {
+			ruleSets:  []tinkerbell.WorkflowRuleSet{wrs("a", 0, false, serial), wrs("b", 10, false, other)},
+			want:      "a",
+			wantCount: 1,
+		},
+		"match all requires every rule": {
+			ruleSets: []tinkerbell.WorkflowRuleSet{wrs("a", 10, true, serial, other), wrs("b", 0, true, serial, vendor)},
+			want:     "b", wantCount: 2,
+		},
+		"errors are reported and other rule sets still evaluated": {
+			ruleSets:  []tinkerbell.WorkflowRuleSet{wrs("a", 10, false, `im a bad pattern`), wrs("b", 0, false, serial)},
+			want:      "b",
+			wantCount: 1,
+			wantErrs:  []string{"a"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, errs := selectWorkflowRuleSet(tt.ruleSets, attr)
+			var gotErrs []string
+			for _, e := range errs {
+				gotErrs = append(gotErrs, e.wrs.Name)
+			}
+			if diff := cmp.Diff(tt.wantErrs, gotErrs); diff != "" {
+				t.Errorf("unexpected errors (-want +got):\n%s", diff)
+			}
+			if tt.want == "" {
+				if got != nil {
+					t.Fatalf("expected no match, got %q", got.wrs.Name)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatalf("expected match %q, got nil", tt.want)
+			}
+			if got.wrs.Name != tt.want || got.numMatches != tt.wantCount {
+				t.Errorf("expected %q with %d matches, got %q with %d matches", tt.want, tt.wantCount, got.wrs.Name, got.numMatches)
+			}
+		})
+	}
+}
+
+func TestEnrollRuleSetStatus(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ruleSets := []tinkerbell.WorkflowRuleSet{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "bad", Namespace: "default"},
+			Spec:       tinkerbell.WorkflowRuleSetSpec{Rules: []string{`im a bad pattern`}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "good", Namespace: "default"},
+			Spec: tinkerbell.WorkflowRuleSetSpec{
+				Rules:    []string{`{"chassis": {"serial": ["12345"]}}`},
+				Workflow: tinkerbell.WorkflowRuleSetWorkflow{Namespace: "default"},
+			},
+			Status: tinkerbell.WorkflowRuleSetStatus{MatchCount: 2},
+		},
+	}
+	mock := &mockAutoCapabilities{
+		ListWorkflowRuleSetsFunc: func(_ context.Context, _ data.WorkflowFilter) ([]tinkerbell.WorkflowRuleSet, error) {
+			return ruleSets, nil
+		},
+		CreateWorkflowFunc: func(_ context.Context, _ *tinkerbell.Workflow) error {
+			return nil
+		},
+	}
+	handler := &Handler{
+		AutoCapabilities: AutoCapabilities{
+			Enrollment: AutoEnrollment{
+				Enabled:                true,
+				WorkflowRuleSetLister:  mock,
+				WorkflowCreator:        mock,
+				WorkflowRuleSetUpdater: mock,
+			},
+		},
+		Backend:      &mockBackendReadWriter{},
+		NowFunc:      func() time.Time { return now },
+		RetryOptions: []backoff.RetryOption{backoff.WithMaxTries(1)},
+	}
+
+	_, _ = handler.enroll(context.Background(), "worker-123", &data.AgentAttributes{Chassis: &data.Chassis{Serial: toPtr("12345")}}, nil, nil)
+
+	want := map[string]tinkerbell.WorkflowRuleSetStatus{
+		"bad": {
+			LastError:     `error adding Workflow matching pattern: pattern-0 err: invalid character 'i' looking for beginning of value`,
+			LastErrorTime: &metav1.Time{Time: now},
+		},
+		"good": {
+			MatchCount:         3,
+			LastMatchedAgentID: "worker-123",
+			LastMatchedTime:    &metav1.Time{Time: now},
+		},
+	}
+	got := map[string]tinkerbell.WorkflowRuleSetStatus{}
+	for _, u := range mock.updated {
+		got[u.Name] = u.Status
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(tinkerbell.WorkflowRuleSetStatus{}, "LastError")); diff != "" {
+		t.Errorf("unexpected status updates (-want +got):\n%s", diff)
+	}
+	if got["bad"].LastError == "" {
+		t.Error("expected LastError to be recorded")
+	}
+}
+
+func TestReEnrollment(t *testing.T) {
+	finished := func(name string, state tinkerbell.WorkflowState, created time.Time) tinkerbell.Workflow {
+		return tinkerbell.Workflow{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", CreationTimestamp: metav1.Time{Time: created}},
+			Status:     tinkerbell.WorkflowStatus{State: state},
+		}
+	}
+	t1 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+
+	tests := map[string]struct {
+		policy      tinkerbell.ReEnrollmentPolicy
+		existing    []tinkerbell.Workflow
+		wantCreated string
+	}{
+		"first enrollment": {
+			wantCreated: "enrollment-00-00-00-00-00-01",
+		},
+		"never": {
+			policy:   tinkerbell.ReEnrollmentNever,
+			existing: []tinkerbell.Workflow{finished("enrollment-00-00-00-00-00-01", tinkerbell.WorkflowStateSuccess, t1)},
+		},
+		"default is never": {
+			existing: []tinkerbell.Workflow{finished("enrollment-00-00-00-00-00-01", tinkerbell.WorkflowStateFailed, t1)},
+		},
+		"after success": {
+			policy:      tinkerbell.ReEnrollmentAfterSuccess,
+			existing:    []tinkerbell.Workflow{finished("enrollment-00-00-00-00-00-01", tinkerbell.WorkflowStateSuccess, t1)},
+			wantCreated: "enrollment-00-00-00-00-00-01-2",
+		},
+		"after success, last failed": {
+			policy: tinkerbell.ReEnrollmentAfterSuccess,
+			existing: []tinkerbell.Workflow{
+				finished("enrollment-00-00-00-00-00-01", tinkerbell.WorkflowStateSuccess, t1),
+				finished("enrollment-00-00-00-00-00-01-2", tinkerbell.WorkflowStateFailed, t2),
+			},
+		},
+		"after failure, last timed out": {
+			policy: tinkerbell.ReEnrollmentAfterFailure,
+			existing: []tinkerbell.Workflow{
+				finished("enrollment-00-00-00-00-00-01-2", tinkerbell.WorkflowStateTimeout, t2),
+				finished("enrollment-00-00-00-00-00-01", tinkerbell.WorkflowStateSuccess, t1),
+			},
+			wantCreated: "enrollment-00-00-00-00-00-01-3",
+		},
+		"always skips names in use": {
+			policy: tinkerbell.ReEnrollmentAlways,
+			existing: []tinkerbell.Workflow{
+				finished("enrollment-00-00-00-00-00-01", tinkerbell.WorkflowStateSuccess, t1),
+				finished("enrollment-00-00-00-00-00-01-2", tinkerbell.WorkflowStateFailed, t2),
+				finished("enrollment-00-00-00-00-00-01-3", tinkerbell.WorkflowStateFailed, t2),
+			},
+			wantCreated: "enrollment-00-00-00-00-00-01-4",
+		},
+		"always, new Workflow not reconciled yet": {
+			policy: tinkerbell.ReEnrollmentAlways,
+			existing: []tinkerbell.Workflow{
+				finished("enrollment-00-00-00-00-00-01", tinkerbell.WorkflowStateSuccess, t1),
+				{ObjectMeta: metav1.ObjectMeta{Name: "enrollment-00-00-00-00-00-01-2", Namespace: "default", CreationTimestamp: metav1.Time{Time: t2}}},
+			},
+		},
+		"always, within cooldown": {
+			policy:   tinkerbell.ReEnrollmentAlways,
+			existing: []tinkerbell.Workflow{finished("enrollment-00-00-00-00-00-01", tinkerbell.WorkflowStateSuccess, time.Now())},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var created string
+			mock := &mockAutoCapabilities{
+				ListWorkflowRuleSetsFunc: func(_ context.Context, _ data.WorkflowFilter) ([]tinkerbell.WorkflowRuleSet, error) {
+					return []tinkerbell.WorkflowRuleSet{{
+						ObjectMeta: metav1.ObjectMeta{Name: "wrs", Namespace: "default"},
+						Spec: tinkerbell.WorkflowRuleSetSpec{
+							Rules: []string{`{"chassis": {"serial": ["12345"]}}`},
+							Workflow: tinkerbell.WorkflowRuleSetWorkflow{
+								Namespace:          "default",
+								ReEnrollmentPolicy: tt.policy,
+							},
+						},
+					}}, nil
+				},
+				CreateWorkflowFunc: func(_ context.Context, wf *tinkerbell.Workflow) error {
+					created = wf.Name
+					return nil
+				},
+			}
+			handler := &Handler{
+				AutoCapabilities: AutoCapabilities{
+					Enrollment: AutoEnrollment{
+						Enabled:               true,
+						WorkflowRuleSetLister: mock,
+						WorkflowCreator:       mock,
+					},
+				},
+				Backend:      &mockBackendReadWriter{},
+				RetryOptions: []backoff.RetryOption{backoff.WithMaxTries(1)},
+			}
+
+			_, err := handler.enroll(context.Background(), "00:00:00:00:00:01", &data.AgentAttributes{Chassis: &data.Chassis{Serial: toPtr("12345")}}, nil, tt.existing)
+			if created != tt.wantCreated {
+				t.Errorf("expected Workflow %q to be created, got %q", tt.wantCreated, created)
+			}
+			if tt.wantCreated == "" && status.Code(err) != codes.FailedPrecondition {
+				t.Errorf("expected FailedPrecondition, got %v", err)
+			}
+		})
+	}
+}
+
+func TestReEnrollmentNotAllowedSkipsMatching(t *testing.T) {
+	mock := &mockAutoCapabilities{
+		ListWorkflowRuleSetsFunc: func(_ context.Context, _ data.WorkflowFilter) ([]tinkerbell.WorkflowRuleSet, error) {
+			return []tinkerbell.WorkflowRuleSet{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "bad", Namespace: "default"},
+					Spec:       tinkerbell.WorkflowRuleSetSpec{Rules: []string{`im a bad pattern`}},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "after-failure", Namespace: "default"},
+					Spec: tinkerbell.WorkflowRuleSetSpec{
+						Rules:    []string{`{"chassis": {"serial": ["12345"]}}`},
+						Workflow: tinkerbell.WorkflowRuleSetWorkflow{Namespace: "default", ReEnrollmentPolicy: tinkerbell.ReEnrollmentAfterFailure},
+					},
+				},
+			}, nil
+		},
+		CreateWorkflowFunc: func(_ context.Context, wf *tinkerbell.Workflow) error {
+			t.Errorf("unexpected Workflow %q created", wf.Name)
+			return nil
+		},
+	}
+	handler := &Handler{
+		AutoCapabilities: AutoCapabilities{
+			Enrollment: AutoEnrollment{
+				Enabled:                true,
+				WorkflowRuleSetLister:  mock,
+				WorkflowCreator:        mock,
+				WorkflowRuleSetUpdater: mock,
+			},
+		},
+		Backend:      &mockBackendReadWriter{},
+		RetryOptions: []backoff.RetryOption{backoff.WithMaxTries(1)},
+	}
+	existing := []tinkerbell.Workflow{{
+		ObjectMeta: metav1.ObjectMeta{Name: "enrollment-00-00-00-00-00-01", Namespace: "default"},
+		Status:     tinkerbell.WorkflowStatus{State: tinkerbell.WorkflowStateSuccess},
+	}}
+
+	for range 3 {
+		_, err := handler.enroll(context.Background(), "00:00:00:00:00:01", &data.AgentAttributes{Chassis: &data.Chassis{Serial: toPtr("12345")}}, nil, existing)
+		if status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("expected FailedPrecondition, got %v", err)
+		}
+	}
+	// The bad pattern would be recorded if the rule sets were matched.
+	if len(mock.updated) != 0 {
+		t.Errorf("expected no status updates, got %d", len(mock.updated))
+	}
+}
+
+func TestEnrollmentNameTruncation(t *testing.T) {
+	agentID := strings.Repeat("a", 70)
+	got, err := enrollmentName(agentID, []tinkerbell.Workflow{{ObjectMeta: metav1.ObjectMeta{Name: "enrollment-" + agentID[:52]}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "enrollment-" + agentID[:50] + "-2"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -65,6 +65,14 @@ type WorkflowRuleSetLister interface {
 	ListWorkflowRuleSets(ctx context.Context, opts data.WorkflowFilter) ([]tinkerbell.WorkflowRuleSet, error)
 }
 
+type WorkflowRuleSetUpdater interface {
+	UpdateWorkflowRuleSet(ctx context.Context, wrs *tinkerbell.WorkflowRuleSet, opts data.UpdateOptions) error
+}
+
+type TemplateReader interface {
+	ReadTemplate(ctx context.Context, name, namespace string) (*tinkerbell.Template, error)
+}
+
 type HardwareReader interface {
 	ReadHardware(ctx context.Context, name, namespace string) (*tinkerbell.Hardware, error)
 }
@@ -81,6 +89,10 @@ type HardwareCreator interface {
 	CreateHardware(ctx context.Context, hw *tinkerbell.Hardware) error
 }
 
+type HardwareEventRecorder interface {
+	RecordHardwareEvent(ctx context.Context, hw *tinkerbell.Hardware, eventType, reason, message string)
+}
+
 // Handler is a server that implements a workflow API.
 type Handler struct {
 	Logger           logr.Logger
@@ -88,6 +100,8 @@ type Handler struct {
 	NowFunc          func() time.Time
 	AutoCapabilities AutoCapabilities
 	RetryOptions     []backoff.RetryOption
+	// EventRecorder is optional. When set, Events are recorded for Hardware inventory drift.
+	EventRecorder HardwareEventRecorder
 
 	proto.UnimplementedWorkflowServiceServer
 }
@@ -108,6 +122,14 @@ func NewHandler(opts ...Option) *Handler {
 	return h
 }
 
+// now returns the current time using NowFunc when set.
+func (h *Handler) now() time.Time {
+	if h.NowFunc != nil {
+		return h.NowFunc()
+	}
+	return time.Now()
+}
+
 func (h *Handler) GetAction(ctx context.Context, req *proto.ActionRequest) (*proto.ActionResponse, error) {
 	operation := func() (*proto.ActionResponse, error) {
 		opts := options{
@@ -150,8 +172,21 @@ func (h *Handler) doGetAction(ctx context.Context, req *proto.ActionRequest, opt
 
 	attrs := convert(req.GetAgentAttributes())
 
-	// hwRef is used in auto discovery and enrollment to avoid multiple lookups of the Hardware object.
-	var hwRef *tinkerbell.Hardware
+	// hwRef is used in auto discovery, inventory recording and enrollment to avoid multiple lookups of the Hardware object.
+	var (
+		hwRef      *tinkerbell.Hardware
+		hwLookedUp bool
+	)
+	// lookupHardware returns hwRef, looking it up first if neither auto discovery nor a previous call did.
+	lookupHardware := func() *tinkerbell.Hardware {
+		if !hwLookedUp {
+			hwLookedUp = true
+			if hw, err := h.hardware(ctx, req.GetAgentId()); err == nil {
+				hwRef = hw
+			}
+		}
+		return hwRef
+	}
 	// handle auto discovery
 	if opts.AutoCapabilities.Discovery.Enabled {
 		journal.Log(ctx, "auto discovery triggered")
@@ -165,6 +200,12 @@ func (h *Handler) doGetAction(ctx context.Context, req *proto.ActionRequest, opt
 			// We don't return the error here as we don't want to disrupt any Workflows from running.
 		}
 		hwRef = hw
+		hwLookedUp = true
+	}
+
+	// Record the Agent's inventory on every check-in.
+	if attrs != nil {
+		h.updateInventory(ctx, log, lookupHardware(), attrs)
 	}
 
 	wfs, err := h.Backend.ListWorkflows(ctx, data.WorkflowFilter{ByAgentID: req.GetAgentId()})
@@ -173,25 +214,27 @@ func (h *Handler) doGetAction(ctx context.Context, req *proto.ActionRequest, opt
 		journal.Log(ctx, "error getting Workflows", "error", err)
 		return nil, errors.Join(ErrBackendRead, status.Errorf(codes.Internal, "error getting workflows: %v", err))
 	}
+	// autoEnroll runs auto enrollment for the Agent. existing are the Agent's Workflows, if any.
+	autoEnroll := func(existing []tinkerbell.Workflow) (*proto.ActionResponse, error) {
+		// If auto discovery is disabled, we do a Hardware object lookup, unless recording the inventory already did.
+		// If auto discovery is enabled, we rely on the lookup and/or creation of a Hardware object from the Discover method.
+		// This means that only one Hardware lookup call is every made to the backend.
+		return h.enroll(ctx, req.GetAgentId(), attrs, lookupHardware(), existing)
+	}
 	if len(wfs) == 0 {
 		if opts.AutoCapabilities.Enrollment.Enabled {
 			journal.Log(ctx, "auto enrollment triggered")
-			// If auto discovery is disabled, we do a Hardware object lookup.
-			// If auto discovery is enabled, we rely on the lookup and/or creation of a Hardware object from the Discover method.
-			// This means that only one Hardware lookup call is every made to the backend.
-			if !opts.AutoCapabilities.Discovery.Enabled {
-				if hw, err := h.hardware(ctx, req.GetAgentId()); err == nil {
-					hwRef = hw
-				}
-			}
-			return h.enroll(ctx, req.GetAgentId(), attrs, hwRef)
+			return autoEnroll(nil)
 		}
 		journal.Log(ctx, "no Workflow found")
 		return nil, status.Error(codes.NotFound, "no Workflows found")
 	}
 
 	journal.Log(ctx, "found Workflows", "workflows", len(wfs))
-	var wf tinkerbell.Workflow
+	var (
+		wf       tinkerbell.Workflow
+		finished int
+	)
 	for _, w := range wfs {
 		if len(w.Status.Tasks) == 0 {
 			continue
@@ -202,6 +245,11 @@ func (h *Handler) doGetAction(ctx context.Context, req *proto.ActionRequest, opt
 			journal.Log(ctx, "Workflow is in preparing state")
 			return nil, status.Error(codes.FailedPrecondition, "Workflow is in preparing state")
 		}
+		// Finished Workflows are skipped so that a new Workflow created by re-enrollment, or manually, is found.
+		if isWorkflowFinished(w) {
+			finished++
+			continue
+		}
 		if w.Status.State != tinkerbell.WorkflowStatePending && w.Status.State != tinkerbell.WorkflowStateRunning {
 			journal.Log(ctx, "Workflow not in pending or running state")
 			return nil, status.Error(codes.FailedPrecondition, "Workflow not in pending or running state")
@@ -210,6 +258,14 @@ func (h *Handler) doGetAction(ctx context.Context, req *proto.ActionRequest, opt
 		journal.Log(ctx, "found Workflow", "workflow", wf.Name)
 		break
 	}
+	if len(wf.Status.Tasks) == 0 && finished > 0 {
+		if opts.AutoCapabilities.Enrollment.Enabled {
+			journal.Log(ctx, "auto re-enrollment triggered")
+			return autoEnroll(wfs)
+		}
+		journal.Log(ctx, "Workflow not in pending or running state")
+		return nil, status.Error(codes.FailedPrecondition, "Workflow not in pending or running state")
+	}
 	if len(wf.Status.Tasks) == 0 {
 		journal.Log(ctx, "no Tasks found in Workflow")
 		return nil, status.Error(codes.NotFound, "no Tasks found in Workflow")
@@ -348,6 +404,16 @@ func isTaskSuccessful(t tinkerbell.Task) bool {
 	return false
 }
 
+// isWorkflowFinished reports whether w has run to completion, successfully or not.
+func isWorkflowFinished(w tinkerbell.Workflow) bool {
+	switch w.Status.State {
+	case tinkerbell.WorkflowStateSuccess, tinkerbell.WorkflowStateFailed, tinkerbell.WorkflowStateTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
 func (h *Handler) ReportActionStatus(ctx context.Context, req *proto.ActionStatusRequest) (*proto.ActionStatusResponse, error) {
 	operation := func() (*proto.ActionStatusResponse, error) {
 		return h.doReportActionStatus(ctx, req)
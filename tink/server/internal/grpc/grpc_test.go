@@ -5,6 +5,7 @@ import (
 	"errors"
 	"log/slog"
 	"os"
+	"slices"
 	"testing"
 	"time"
 
@@ -201,12 +202,15 @@ func compareErrors(t *testing.T, got, want error) {
 
 type mockBackendReadWriter struct {
 	workflow    *tinkerbell.Workflow
+	workflows   []tinkerbell.Workflow // returned by ListWorkflows when set
 	writeErr    error
 	hardware    *tinkerbell.Hardware
 	hardwareErr error
+	filterCalls int // counts the calls to FilterHardware
 
 	updatedHardware *tinkerbell.Hardware // captures the hardware passed to UpdateHardware
 	updateOpts      data.UpdateOptions   // captures the options passed to UpdateHardware
+	updatedStatus   *tinkerbell.Hardware // captures the hardware passed to UpdateHardware with StatusOnly
 }
 
 func (m *mockBackendReadWriter) ReadWorkflow(_ context.Context, _ string, _ string) (*tinkerbell.Workflow, error) {
@@ -217,6 +221,9 @@ func (m *mockBackendReadWriter) ReadWorkflow(_ context.Context, _ string, _ stri
 }
 
 func (m *mockBackendReadWriter) ListWorkflows(_ context.Context, _ data.WorkflowFilter) ([]tinkerbell.Workflow, error) {
+	if m.workflows != nil {
+		return slices.Clone(m.workflows), nil
+	}
 	if m.workflow != nil {
 		return []tinkerbell.Workflow{*m.workflow}, nil
 	}
@@ -238,6 +245,7 @@ func (m *mockBackendReadWriter) ReadHardware(_ context.Context, _ string, _ stri
 }
 
 func (m *mockBackendReadWriter) FilterHardware(_ context.Context, _ data.HardwareFilter) (*tinkerbell.Hardware, error) {
+	m.filterCalls++
 	if m.hardware != nil {
 		return m.hardware, nil
 	}
@@ -248,6 +256,10 @@ func (m *mockBackendReadWriter) FilterHardware(_ context.Context, _ data.Hardwar
 }
 
 func (m *mockBackendReadWriter) UpdateHardware(_ context.Context, hw *tinkerbell.Hardware, opts data.UpdateOptions) error {
+	if opts.StatusOnly {
+		m.updatedStatus = hw
+		return nil
+	}
 	m.updatedHardware = hw
 	m.updateOpts = opts
 	return nil
@@ -438,6 +450,88 @@ func TestGetActionHardwareAttributes(t *testing.T) {
 	}
 }
 
+func TestGetActionLooksUpHardwareOnce(t *testing.T) {
+	backend := &mockBackendReadWriter{}
+	rulesets := &mockAutoCapabilities{
+		ListWorkflowRuleSetsFunc: func(_ context.Context, _ data.WorkflowFilter) ([]tinkerbell.WorkflowRuleSet, error) {
+			return nil, nil
+		},
+	}
+	server := &Handler{
+		Logger:  logr.Discard(),
+		Backend: backend,
+		AutoCapabilities: AutoCapabilities{
+			Enrollment: AutoEnrollment{Enabled: true, WorkflowRuleSetLister: rulesets, WorkflowCreator: rulesets},
+		},
+		NowFunc:      func() time.Time { return time.Time{} },
+		RetryOptions: []backoff.RetryOption{backoff.WithMaxTries(1)},
+	}
+
+	// The Agent has no Hardware, so neither inventory recording nor enrollment find one.
+	_, _ = server.doGetAction(context.Background(), &proto.ActionRequest{
+		AgentId:         toPtr("machine-mac-1"),
+		AgentAttributes: &proto.AgentAttributes{Cpu: &proto.CPU{TotalCores: toPtr(uint32(4))}},
+	}, options{AutoCapabilities: server.AutoCapabilities})
+	if backend.filterCalls != 1 {
+		t.Errorf("expected 1 Hardware lookup, got %d", backend.filterCalls)
+	}
+}
+
+func TestGetActionReEnrollsOnce(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, policy := range []tinkerbell.ReEnrollmentPolicy{tinkerbell.ReEnrollmentAfterSuccess, tinkerbell.ReEnrollmentAlways} {
+		t.Run(string(policy), func(t *testing.T) {
+			backend := &mockBackendReadWriter{
+				workflows: []tinkerbell.Workflow{{
+					ObjectMeta: metav1.ObjectMeta{Name: "enrollment-machine-mac-1", Namespace: "default", CreationTimestamp: metav1.Time{Time: now.Add(-time.Hour)}},
+					Status: tinkerbell.WorkflowStatus{
+						State: tinkerbell.WorkflowStateSuccess,
+						Tasks: []tinkerbell.Task{{ID: "task1", AgentID: "machine-mac-1"}},
+					},
+				}},
+			}
+			var created int
+			rulesets := &mockAutoCapabilities{
+				ListWorkflowRuleSetsFunc: func(_ context.Context, _ data.WorkflowFilter) ([]tinkerbell.WorkflowRuleSet, error) {
+					return []tinkerbell.WorkflowRuleSet{{
+						ObjectMeta: metav1.ObjectMeta{Name: "wrs", Namespace: "default"},
+						Spec: tinkerbell.WorkflowRuleSetSpec{
+							Rules:    []string{`{"chassis": {"serial": ["12345"]}}`},
+							Workflow: tinkerbell.WorkflowRuleSetWorkflow{Namespace: "default", ReEnrollmentPolicy: policy},
+						},
+					}}, nil
+				},
+				CreateWorkflowFunc: func(_ context.Context, wf *tinkerbell.Workflow) error {
+					created++
+					// A new Workflow has no Tasks until the controller reconciles it.
+					wf.CreationTimestamp = metav1.Time{Time: now}
+					backend.workflows = append(backend.workflows, *wf)
+					return nil
+				},
+			}
+			server := &Handler{
+				Logger:  logr.Discard(),
+				Backend: backend,
+				AutoCapabilities: AutoCapabilities{
+					Enrollment: AutoEnrollment{Enabled: true, WorkflowRuleSetLister: rulesets, WorkflowCreator: rulesets, ReEnrollmentCooldown: -1},
+				},
+				NowFunc:      func() time.Time { return now },
+				RetryOptions: []backoff.RetryOption{backoff.WithMaxTries(1)},
+			}
+
+			for range 2 {
+				_, _ = server.doGetAction(context.Background(), &proto.ActionRequest{
+					AgentId:         toPtr("machine-mac-1"),
+					AgentAttributes: &proto.AgentAttributes{Chassis: &proto.Chassis{Serial: toPtr("12345")}},
+				}, options{AutoCapabilities: server.AutoCapabilities})
+			}
+			if created != 1 {
+				t.Errorf("expected 1 Workflow to be created, got %d", created)
+			}
+		})
+	}
+}
+
 func TestReportActionStatus(t *testing.T) {
 	tests := map[string]struct {
 		request      *proto.ActionStatusRequest
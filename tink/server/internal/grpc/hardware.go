@@ -4,13 +4,38 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 
 	v1alpha1 "github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"github.com/tinkerbell/tinkerbell/pkg/constant"
 	"github.com/tinkerbell/tinkerbell/pkg/data"
 	"github.com/tinkerbell/tinkerbell/pkg/journal"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
+// HardwareMatch is an Agent attribute used to find an existing Hardware object for an Agent
+// when no Hardware object has a matching spec.agentID.
+type HardwareMatch string
+
+const (
+	// HardwareMatchChassisSerial matches the Agent's chassis serial number against the tinkerbell.org/chassis-serial Hardware label.
+	HardwareMatchChassisSerial HardwareMatch = "chassis-serial"
+	// HardwareMatchProductSerial matches the Agent's product serial number against the tinkerbell.org/product-serial Hardware label.
+	HardwareMatchProductSerial HardwareMatch = "product-serial"
+	// HardwareMatchMAC matches the Agent's network interface MAC addresses against Hardware interfaces.
+	HardwareMatchMAC HardwareMatch = "mac"
+)
+
+// ParseHardwareMatch returns the HardwareMatch for s.
+func ParseHardwareMatch(s string) (HardwareMatch, error) {
+	switch m := HardwareMatch(s); m {
+	case HardwareMatchChassisSerial, HardwareMatchProductSerial, HardwareMatchMAC:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown hardware match %q, must be one of %q, %q, %q", s, HardwareMatchChassisSerial, HardwareMatchProductSerial, HardwareMatchMAC)
+	}
+}
+
 // hardware returns the Hardware object for the given agentID.
 func (h *Handler) hardware(ctx context.Context, agentID string) (*v1alpha1.Hardware, error) {
 	// Check if Hardware object already exists.
@@ -31,6 +56,82 @@ func (h *Handler) hardware(ctx context.Context, agentID string) (*v1alpha1.Hardw
 	return nil, err
 }
 
+// findHardwareByAttributes looks for a Hardware object that matches attrs using the configured HardwareMatch list, in order.
+// Hardware that is already linked to a different Agent and selectors matching more than one Hardware object are skipped.
+// A nil Hardware and nil error means no Hardware matched.
+func (h *Handler) findHardwareByAttributes(ctx context.Context, agentID string, attrs *data.AgentAttributes) (*v1alpha1.Hardware, error) {
+	if attrs == nil || h.Backend == nil {
+		return nil, nil
+	}
+	for _, m := range h.AutoCapabilities.Enrollment.HardwareMatch {
+		for _, f := range hardwareFilters(m, attrs) {
+			f.InNamespace = h.AutoCapabilities.Discovery.Namespace
+			hw, err := h.Backend.FilterHardware(ctx, f)
+			switch {
+			case err == nil:
+			case hardwareNotFound(err):
+				continue
+			case foundMultipleHardware(err):
+				journal.Log(ctx, "multiple Hardware objects match attributes, skipping", "match", m, "error", err)
+				continue
+			default:
+				return nil, fmt.Errorf("error finding hardware by %s: %w", m, err)
+			}
+			if hw.Spec.AgentID != "" && agentID != "" && hw.Spec.AgentID != agentID {
+				journal.Log(ctx, "Hardware matching attributes belongs to another Agent, skipping", "match", m, "hardware", hw.Name, "hardwareAgentID", hw.Spec.AgentID)
+				continue
+			}
+			journal.Log(ctx, "found Hardware by attributes", "match", m, "hardware", hw.Name, "namespace", hw.Namespace)
+			return hw, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// hardwareFilters returns the Hardware filters for the HardwareMatch m built from attrs.
+func hardwareFilters(m HardwareMatch, attrs *data.AgentAttributes) []data.HardwareFilter {
+	var fs []data.HardwareFilter
+	switch m {
+	case HardwareMatchChassisSerial:
+		if attrs.Chassis != nil && attrs.Chassis.Serial != nil && *attrs.Chassis.Serial != "" {
+			fs = append(fs, data.HardwareFilter{ByLabels: map[string]string{constant.ChassisSerialLabel: *attrs.Chassis.Serial}})
+		}
+	case HardwareMatchProductSerial:
+		if attrs.Product != nil && attrs.Product.SerialNumber != nil && *attrs.Product.SerialNumber != "" {
+			fs = append(fs, data.HardwareFilter{ByLabels: map[string]string{constant.ProductSerialLabel: *attrs.Product.SerialNumber}})
+		}
+	case HardwareMatchMAC:
+		for _, n := range attrs.NetworkInterfaces {
+			if n == nil || n.Mac == nil {
+				continue
+			}
+			if mac, err := net.ParseMAC(*n.Mac); err == nil {
+				fs = append(fs, data.HardwareFilter{ByMACAddress: mac.String()})
+			}
+		}
+	}
+	return fs
+}
+
+// linkHardware finds a Hardware object for agentID using attrs and sets its spec.agentID to agentID so that
+// future lookups by Agent ID find it. An error updating the Hardware is logged and the Hardware is still returned.
+func (h *Handler) linkHardware(ctx context.Context, agentID string, attrs *data.AgentAttributes) (*v1alpha1.Hardware, error) {
+	hw, err := h.findHardwareByAttributes(ctx, agentID, attrs)
+	if err != nil || hw == nil || hw.Spec.AgentID == agentID {
+		return hw, err
+	}
+
+	original := hw.DeepCopy()
+	hw.Spec.AgentID = agentID
+	if err := h.Backend.UpdateHardware(ctx, hw, data.UpdateOptions{PatchFrom: original}); err != nil {
+		journal.Log(ctx, "error setting Agent ID on Hardware", "error", err)
+		h.Logger.Error(err, "error setting Agent ID on Hardware", "agentID", agentID, "hardware", hw.Name, "namespace", hw.Namespace)
+	}
+
+	return hw, nil
+}
+
 func foundMultipleHardware(e error) bool {
 	type foundMultiple interface {
 		MultipleFound() bool
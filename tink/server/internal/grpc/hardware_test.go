@@ -0,0 +1,167 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"github.com/tinkerbell/tinkerbell/pkg/constant"
+	"github.com/tinkerbell/tinkerbell/pkg/data"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// mockHardwareIndex is a Backend that filters Hardware by labels and MAC address.
+type mockHardwareIndex struct {
+	mockBackendReadWriter
+	hardware []tinkerbell.Hardware
+	err      error
+}
+
+type multipleFound struct{}
+
+func (multipleFound) Error() string       { return "multiple found" }
+func (multipleFound) MultipleFound() bool { return true }
+
+func (m *mockHardwareIndex) FilterHardware(_ context.Context, opts data.HardwareFilter) (*tinkerbell.Hardware, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	var found []tinkerbell.Hardware
+	for _, hw := range m.hardware {
+		match := true
+		for k, v := range opts.ByLabels {
+			if hw.Labels[k] != v {
+				match = false
+			}
+		}
+		if opts.ByMACAddress != "" {
+			match = match && len(hw.Spec.Interfaces) > 0 && hw.Spec.Interfaces[0].DHCP.MAC == opts.ByMACAddress
+		}
+		if match {
+			found = append(found, hw)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, apierrors.NewNotFound(schema.GroupResource{Group: "tinkerbell.org", Resource: "hardware"}, "")
+	case 1:
+		return &found[0], nil
+	default:
+		return nil, multipleFound{}
+	}
+}
+
+func TestLinkHardware(t *testing.T) {
+	hw := func(name, agentID string, labels map[string]string, mac string) tinkerbell.Hardware {
+		return tinkerbell.Hardware{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+			Spec: tinkerbell.HardwareSpec{
+				AgentID:    agentID,
+				Interfaces: []tinkerbell.Interface{{DHCP: &tinkerbell.DHCP{MAC: mac}}},
+			},
+		}
+	}
+	attrs := &data.AgentAttributes{
+		Chassis:           &data.Chassis{Serial: toPtr("CS-1")},
+		Product:           &data.Product{SerialNumber: toPtr("PS-1")},
+		NetworkInterfaces: []*data.Network{{Mac: toPtr("zz")}, {Mac: toPtr("00:00:00:00:00:02")}},
+	}
+
+	tests := map[string]struct {
+		match      []HardwareMatch
+		hardware   []tinkerbell.Hardware
+		backendErr error
+		want       string
+		wantUpdate bool
+		wantErr    bool
+	}{
+		"matching disabled": {
+			hardware: []tinkerbell.Hardware{hw("hw1", "", map[string]string{constant.ChassisSerialLabel: "CS-1"}, "")},
+		},
+		"chassis serial": {
+			match:      []HardwareMatch{HardwareMatchChassisSerial},
+			hardware:   []tinkerbell.Hardware{hw("hw1", "", map[string]string{constant.ChassisSerialLabel: "CS-1"}, "")},
+			want:       "hw1",
+			wantUpdate: true,
+		},
+		"product serial": {
+			match:      []HardwareMatch{HardwareMatchChassisSerial, HardwareMatchProductSerial},
+			hardware:   []tinkerbell.Hardware{hw("hw1", "", map[string]string{constant.ProductSerialLabel: "PS-1"}, "")},
+			want:       "hw1",
+			wantUpdate: true,
+		},
+		"second mac matches": {
+			match:      []HardwareMatch{HardwareMatchMAC},
+			hardware:   []tinkerbell.Hardware{hw("hw1", "", nil, "00:00:00:00:00:02")},
+			want:       "hw1",
+			wantUpdate: true,
+		},
+		"match order is respected": {
+			match: []HardwareMatch{HardwareMatchMAC, HardwareMatchChassisSerial},
+			hardware: []tinkerbell.Hardware{
+				hw("by-serial", "", map[string]string{constant.ChassisSerialLabel: "CS-1"}, ""),
+				hw("by-mac", "", nil, "00:00:00:00:00:02"),
+			},
+			want:       "by-mac",
+			wantUpdate: true,
+		},
+		"already linked to this agent": {
+			match:    []HardwareMatch{HardwareMatchChassisSerial},
+			hardware: []tinkerbell.Hardware{hw("hw1", "agent-1", map[string]string{constant.ChassisSerialLabel: "CS-1"}, "")},
+			want:     "hw1",
+		},
+		"linked to another agent": {
+			match:    []HardwareMatch{HardwareMatchChassisSerial},
+			hardware: []tinkerbell.Hardware{hw("hw1", "agent-2", map[string]string{constant.ChassisSerialLabel: "CS-1"}, "")},
+		},
+		"ambiguous match skipped": {
+			match: []HardwareMatch{HardwareMatchChassisSerial, HardwareMatchMAC},
+			hardware: []tinkerbell.Hardware{
+				hw("hw1", "", map[string]string{constant.ChassisSerialLabel: "CS-1"}, ""),
+				hw("hw2", "", map[string]string{constant.ChassisSerialLabel: "CS-1"}, "00:00:00:00:00:02"),
+			},
+			want:       "hw2",
+			wantUpdate: true,
+		},
+		"backend error": {
+			match:      []HardwareMatch{HardwareMatchChassisSerial},
+			backendErr: errors.New("boom"),
+			wantErr:    true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			backend := &mockHardwareIndex{hardware: tt.hardware, err: tt.backendErr}
+			h := &Handler{
+				Backend:          backend,
+				AutoCapabilities: AutoCapabilities{Enrollment: AutoEnrollment{HardwareMatch: tt.match}},
+			}
+
+			got, err := h.linkHardware(context.Background(), "agent-1", attrs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var gotName string
+			if got != nil {
+				gotName = got.Name
+				if got.Spec.AgentID != "agent-1" {
+					t.Errorf("expected agentID to be set, got %q", got.Spec.AgentID)
+				}
+			}
+			if diff := cmp.Diff(tt.want, gotName); diff != "" {
+				t.Errorf("unexpected Hardware (-want +got):\n%s", diff)
+			}
+			if updated := backend.updatedHardware != nil; updated != tt.wantUpdate {
+				t.Errorf("expected update %v, got %v", tt.wantUpdate, updated)
+			}
+			if tt.wantUpdate && backend.updateOpts.PatchFrom == nil {
+				t.Error("expected Hardware to be patched")
+			}
+		})
+	}
+}
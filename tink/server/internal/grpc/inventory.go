@@ -0,0 +1,327 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"github.com/tinkerbell/tinkerbell/pkg/constant"
+	"github.com/tinkerbell/tinkerbell/pkg/data"
+	"github.com/tinkerbell/tinkerbell/pkg/journal"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ReasonInventoryRecorded is the condition reason used when the first inventory of a Hardware object is recorded.
+	ReasonInventoryRecorded = "InventoryRecorded"
+	// ReasonInventoryChanged is the condition and event reason used when the inventory of a Hardware object changed.
+	ReasonInventoryChanged = "InventoryChanged"
+	// ReasonInventoryDriftAcknowledged is the condition reason used when inventory drift was acknowledged.
+	ReasonInventoryDriftAcknowledged = "InventoryDriftAcknowledged"
+
+	// maxConditionMessageSize is the maximum size of the message of the InventoryDrift condition and event.
+	maxConditionMessageSize = 1024
+)
+
+// updateInventory records the inventory in attrs in the status of hw.
+// The status is only written when the inventory differs from the one already recorded or drift was acknowledged.
+// When a previously recorded inventory drifted, the InventoryDrift condition is set to True
+// and a Warning Event is recorded, if an EventRecorder is configured.
+// The condition stays True until the drift is acknowledged with the constant.InventoryDriftAcknowledgedAnnotation annotation.
+// Errors are logged and not returned so that inventory updates never block Workflows.
+func (h *Handler) updateInventory(ctx context.Context, log logr.Logger, hw *tinkerbell.Hardware, attrs *data.AgentAttributes) {
+	if hw == nil || attrs == nil {
+		return
+	}
+	inv := newInventory(attrs)
+	changed := !inventoryEqual(hw.Status.Inventory, inv)
+	acknowledged := driftAcknowledged(hw)
+	if !changed && !acknowledged {
+		return
+	}
+
+	original := hw.DeepCopy()
+	now := &metav1.Time{Time: h.now()}
+	if acknowledged {
+		hw.Status.SetCondition(tinkerbell.HardwareCondition{
+			Type:    tinkerbell.InventoryDrift,
+			Status:  metav1.ConditionFalse,
+			Reason:  ReasonInventoryDriftAcknowledged,
+			Message: "inventory drift acknowledged",
+			Time:    now,
+		})
+	}
+	var (
+		cond    tinkerbell.HardwareCondition
+		changes []string
+	)
+	if changed {
+		inv.UpdatedAt = now
+		cond = tinkerbell.HardwareCondition{
+			Type:    tinkerbell.InventoryDrift,
+			Status:  metav1.ConditionFalse,
+			Reason:  ReasonInventoryRecorded,
+			Message: "inventory recorded",
+			Time:    now,
+		}
+		if hw.Status.Inventory != nil {
+			changes = inventoryChanges(hw.Status.Inventory, inv)
+			cond.Status = metav1.ConditionTrue
+			cond.Reason = ReasonInventoryChanged
+			cond.Message = truncate(strings.Join(changes, "; "), maxConditionMessageSize)
+		}
+		// The condition is left as is when only fields that don't indicate drift changed.
+		if hw.Status.Inventory == nil || len(changes) > 0 {
+			hw.Status.SetCondition(cond)
+		}
+		hw.Status.Inventory = inv
+	}
+
+	if err := h.Backend.UpdateHardware(ctx, hw, data.UpdateOptions{StatusOnly: true, PatchFrom: original}); err != nil {
+		journal.Log(ctx, "error updating Hardware inventory", "error", err)
+		log.Error(err, "error updating Hardware inventory", "hardware", hw.Name)
+		return
+	}
+	journal.Log(ctx, "updated Hardware inventory", "hardware", hw.Name, "changes", changes)
+
+	if len(changes) == 0 {
+		return
+	}
+	log.Info("Hardware inventory changed", "hardware", hw.Name, "namespace", hw.Namespace, "changes", changes)
+	if h.EventRecorder != nil {
+		h.EventRecorder.RecordHardwareEvent(ctx, hw, corev1.EventTypeWarning, ReasonInventoryChanged, cond.Message)
+	}
+}
+
+// driftAcknowledged reports whether the InventoryDrift condition of hw is True and the drift was recorded
+// at or before the time in the constant.InventoryDriftAcknowledgedAnnotation annotation.
+func driftAcknowledged(hw *tinkerbell.Hardware) bool {
+	v, ok := hw.Annotations[constant.InventoryDriftAcknowledgedAnnotation]
+	if !ok {
+		return false
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return false
+	}
+	for _, c := range hw.Status.Conditions {
+		if c.Type == tinkerbell.InventoryDrift {
+			return c.Status == metav1.ConditionTrue && (c.Time == nil || !t.Before(c.Time.Time))
+		}
+	}
+
+	return false
+}
+
+// newInventory converts Agent attributes to a Hardware inventory.
+// Lists are sorted so that Agents enumerating devices in a different order report the same inventory.
+func newInventory(attrs *data.AgentAttributes) *tinkerbell.HardwareInventory {
+	inv := &tinkerbell.HardwareInventory{}
+	if attrs.CPU != nil {
+		inv.CPU = &tinkerbell.InventoryCPU{
+			Cores:   int64(valueOf(attrs.CPU.TotalCores)),
+			Threads: int64(valueOf(attrs.CPU.TotalThreads)),
+		}
+		for _, p := range attrs.CPU.Processors {
+			if p == nil {
+				continue
+			}
+			inv.CPU.Processors = append(inv.CPU.Processors, tinkerbell.InventoryProcessor{
+				ID:      int64(valueOf(p.ID)),
+				Cores:   int64(valueOf(p.Cores)),
+				Threads: int64(valueOf(p.Threads)),
+				Vendor:  valueOf(p.Vendor),
+				Model:   valueOf(p.Model),
+			})
+		}
+	}
+	if attrs.Memory != nil {
+		inv.Memory = &tinkerbell.InventoryMemory{
+			Total:  valueOf(attrs.Memory.Total),
+			Usable: valueOf(attrs.Memory.Usable),
+		}
+	}
+	for _, b := range attrs.BlockDevices {
+		if b == nil || valueOf(b.Name) == "" {
+			continue
+		}
+		inv.Disks = append(inv.Disks, tinkerbell.InventoryDisk{
+			Name:           *b.Name,
+			Size:           valueOf(b.Size),
+			DriveType:      valueOf(b.DriveType),
+			ControllerType: valueOf(b.ControllerType),
+			Vendor:         valueOf(b.Vendor),
+			Model:          valueOf(b.Model),
+			SerialNumber:   valueOf(b.SerialNumber),
+			WWN:            valueOf(b.WWN),
+		})
+	}
+	for _, n := range attrs.NetworkInterfaces {
+		if n == nil || valueOf(n.Name) == "" {
+			continue
+		}
+		inv.NICs = append(inv.NICs, tinkerbell.InventoryNIC{
+			Name:  *n.Name,
+			MAC:   valueOf(n.Mac),
+			Speed: valueOf(n.Speed),
+		})
+	}
+	for _, p := range attrs.PCIDevices {
+		if p == nil {
+			continue
+		}
+		inv.PCIDevices = append(inv.PCIDevices, tinkerbell.InventoryPCIDevice{
+			Vendor:  valueOf(p.Vendor),
+			Product: valueOf(p.Product),
+			Class:   valueOf(p.Class),
+			Driver:  valueOf(p.Driver),
+		})
+	}
+	sort.SliceStable(inv.Disks, func(i, j int) bool { return inv.Disks[i].Name < inv.Disks[j].Name })
+	sort.SliceStable(inv.NICs, func(i, j int) bool { return inv.NICs[i].Name < inv.NICs[j].Name })
+	sort.SliceStable(inv.PCIDevices, func(i, j int) bool {
+		a, b := inv.PCIDevices[i], inv.PCIDevices[j]
+		return strings.Join([]string{a.Vendor, a.Product, a.Class, a.Driver}, " ") < strings.Join([]string{b.Vendor, b.Product, b.Class, b.Driver}, " ")
+	})
+	if attrs.BIOS != nil {
+		inv.BIOS = &tinkerbell.InventoryBIOS{
+			Vendor:      valueOf(attrs.BIOS.Vendor),
+			Version:     valueOf(attrs.BIOS.Version),
+			ReleaseDate: valueOf(attrs.BIOS.ReleaseDate),
+		}
+	}
+	if attrs.Baseboard != nil {
+		inv.Baseboard = &tinkerbell.InventoryBaseboard{
+			Vendor:       valueOf(attrs.Baseboard.Vendor),
+			Product:      valueOf(attrs.Baseboard.Product),
+			Version:      valueOf(attrs.Baseboard.Version),
+			SerialNumber: valueOf(attrs.Baseboard.SerialNumber),
+		}
+	}
+
+	return inv
+}
+
+// inventoryEqual reports whether a and b are the same inventory, ignoring UpdatedAt.
+func inventoryEqual(a, b *tinkerbell.HardwareInventory) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	ac, bc := *a, *b
+	ac.UpdatedAt, bc.UpdatedAt = nil, nil
+
+	return equality.Semantic.DeepEqual(ac, bc)
+}
+
+// inventoryChanges returns a human readable description of the differences between the old and new inventory
+// that indicate drift. Changes to other fields, like disk models or usable memory, are not returned.
+func inventoryChanges(oldInv, newInv *tinkerbell.HardwareInventory) []string {
+	var changes []string
+	changed := func(what, o, n string) {
+		if o != n {
+			changes = append(changes, fmt.Sprintf("%s changed from %q to %q", what, o, n))
+		}
+	}
+
+	oc, nc := valueOf(oldInv.CPU), valueOf(newInv.CPU)
+	changed("cpu cores", fmt.Sprint(oc.Cores), fmt.Sprint(nc.Cores))
+	changed("cpu threads", fmt.Sprint(oc.Threads), fmt.Sprint(nc.Threads))
+
+	om, nm := valueOf(oldInv.Memory), valueOf(newInv.Memory)
+	changed("memory total", om.Total, nm.Total)
+
+	oldDisks := make(map[string]tinkerbell.InventoryDisk, len(oldInv.Disks))
+	for _, d := range oldInv.Disks {
+		oldDisks[d.Name] = d
+	}
+	newDisks := make(map[string]tinkerbell.InventoryDisk, len(newInv.Disks))
+	for _, d := range newInv.Disks {
+		newDisks[d.Name] = d
+		o, ok := oldDisks[d.Name]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("disk %s added", d.Name))
+			continue
+		}
+		changed(fmt.Sprintf("disk %s size", d.Name), o.Size, d.Size)
+		changed(fmt.Sprintf("disk %s serial number", d.Name), o.SerialNumber, d.SerialNumber)
+	}
+	for _, d := range oldInv.Disks {
+		if _, ok := newDisks[d.Name]; !ok {
+			changes = append(changes, fmt.Sprintf("disk %s removed", d.Name))
+		}
+	}
+
+	oldNICs := make(map[string]tinkerbell.InventoryNIC, len(oldInv.NICs))
+	for _, n := range oldInv.NICs {
+		oldNICs[n.Name] = n
+	}
+	newNICs := make(map[string]tinkerbell.InventoryNIC, len(newInv.NICs))
+	for _, n := range newInv.NICs {
+		newNICs[n.Name] = n
+		o, ok := oldNICs[n.Name]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("nic %s added", n.Name))
+			continue
+		}
+		changed(fmt.Sprintf("nic %s mac", n.Name), o.MAC, n.MAC)
+	}
+	for _, n := range oldInv.NICs {
+		if _, ok := newNICs[n.Name]; !ok {
+			changes = append(changes, fmt.Sprintf("nic %s removed", n.Name))
+		}
+	}
+
+	changes = append(changes, pciChanges(oldInv.PCIDevices, newInv.PCIDevices)...)
+
+	ob, nb := valueOf(oldInv.BIOS), valueOf(newInv.BIOS)
+	changed("bios version", ob.Version, nb.Version)
+	obb, nbb := valueOf(oldInv.Baseboard), valueOf(newInv.Baseboard)
+	changed("baseboard serial number", obb.SerialNumber, nbb.SerialNumber)
+
+	return changes
+}
+
+// pciChanges describes PCI devices that were added or removed. PCI devices have no stable identifier
+// in the Agent attributes so devices are compared by vendor, product and class.
+func pciChanges(oldDevs, newDevs []tinkerbell.InventoryPCIDevice) []string {
+	key := func(d tinkerbell.InventoryPCIDevice) string {
+		return strings.Join([]string{d.Vendor, d.Product, d.Class}, " ")
+	}
+	count := make(map[string]int)
+	for _, d := range oldDevs {
+		count[key(d)]--
+	}
+	for _, d := range newDevs {
+		count[key(d)]++
+	}
+	keys := make([]string, 0, len(count))
+	for k := range count {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var changes []string
+	for _, k := range keys {
+		switch n := count[k]; {
+		case n < 0:
+			changes = append(changes, fmt.Sprintf("%d pci device(s) %q removed", -n, k))
+		case n > 0:
+			changes = append(changes, fmt.Sprintf("%d pci device(s) %q added", n, k))
+		}
+	}
+
+	return changes
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
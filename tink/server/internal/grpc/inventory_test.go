@@ -0,0 +1,232 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
+	"github.com/tinkerbell/tinkerbell/pkg/constant"
+	"github.com/tinkerbell/tinkerbell/pkg/data"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type mockEventRecorder struct {
+	events []string
+}
+
+func (m *mockEventRecorder) RecordHardwareEvent(_ context.Context, _ *tinkerbell.Hardware, eventType, reason, message string) {
+	m.events = append(m.events, eventType+" "+reason+" "+message)
+}
+
+func TestUpdateInventory(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	attrs := func(memory, mac string, disks ...string) *data.AgentAttributes {
+		a := &data.AgentAttributes{
+			CPU:               &data.CPU{TotalCores: toPtr(uint32(8)), TotalThreads: toPtr(uint32(16))},
+			Memory:            &data.Memory{Total: toPtr(memory), Usable: toPtr(memory)},
+			NetworkInterfaces: []*data.Network{{Name: toPtr("eth0"), Mac: toPtr(mac)}},
+		}
+		for _, d := range disks {
+			a.BlockDevices = append(a.BlockDevices, &data.Block{Name: toPtr(d), Size: toPtr("1TB")})
+		}
+		return a
+	}
+	recorded := func(a *data.AgentAttributes, conds ...tinkerbell.HardwareCondition) *tinkerbell.Hardware {
+		return &tinkerbell.Hardware{
+			ObjectMeta: metav1.ObjectMeta{Name: "hw", Namespace: "default"},
+			Status: tinkerbell.HardwareStatus{
+				Inventory:  newInventory(a),
+				Conditions: conds,
+			},
+		}
+	}
+	driftFalse := tinkerbell.HardwareCondition{
+		Type:    tinkerbell.InventoryDrift,
+		Status:  metav1.ConditionFalse,
+		Reason:  ReasonInventoryRecorded,
+		Message: "inventory recorded",
+		Time:    &metav1.Time{Time: now},
+	}
+
+	tests := map[string]struct {
+		hardware      *tinkerbell.Hardware
+		attrs         *data.AgentAttributes
+		wantUpdate    bool
+		wantCondition *tinkerbell.HardwareCondition
+		wantEvents    []string
+	}{
+		"no hardware": {
+			attrs: attrs("16GB", "00:00:00:00:00:01", "sda"),
+		},
+		"first inventory is recorded": {
+			hardware:      &tinkerbell.Hardware{ObjectMeta: metav1.ObjectMeta{Name: "hw", Namespace: "default"}},
+			attrs:         attrs("16GB", "00:00:00:00:00:01", "sda"),
+			wantUpdate:    true,
+			wantCondition: &driftFalse,
+		},
+		"unchanged inventory is not written": {
+			hardware: recorded(attrs("16GB", "00:00:00:00:00:01", "sda")),
+			attrs:    attrs("16GB", "00:00:00:00:00:01", "sda"),
+		},
+		"drift sets condition and records event": {
+			hardware:   recorded(attrs("16GB", "00:00:00:00:00:01", "sda", "sdb"), driftFalse),
+			attrs:      attrs("8GB", "00:00:00:00:00:02", "sda"),
+			wantUpdate: true,
+			wantCondition: &tinkerbell.HardwareCondition{
+				Type:    tinkerbell.InventoryDrift,
+				Status:  metav1.ConditionTrue,
+				Reason:  ReasonInventoryChanged,
+				Message: `memory total changed from "16GB" to "8GB"; disk sdb removed; nic eth0 mac changed from "00:00:00:00:00:01" to "00:00:00:00:00:02"`,
+				Time:    &metav1.Time{Time: now},
+			},
+			wantEvents: []string{`Warning InventoryChanged memory total changed from "16GB" to "8GB"; disk sdb removed; nic eth0 mac changed from "00:00:00:00:00:01" to "00:00:00:00:00:02"`},
+		},
+		"reordered devices are not written": {
+			hardware: recorded(attrs("16GB", "00:00:00:00:00:01", "sda", "sdb")),
+			attrs:    attrs("16GB", "00:00:00:00:00:01", "sdb", "sda"),
+		},
+		"changes that are not drift leave the condition": {
+			hardware: recorded(attrs("16GB", "00:00:00:00:00:01", "sda"), driftFalse),
+			attrs: func() *data.AgentAttributes {
+				a := attrs("16GB", "00:00:00:00:00:01", "sda")
+				a.Memory.Usable = toPtr("15GB")
+				return a
+			}(),
+			wantUpdate:    true,
+			wantCondition: &driftFalse,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			backend := &mockBackendReadWriter{}
+			recorder := &mockEventRecorder{}
+			h := &Handler{
+				Backend:       backend,
+				NowFunc:       func() time.Time { return now },
+				EventRecorder: recorder,
+			}
+			h.updateInventory(context.Background(), logr.Discard(), tt.hardware, tt.attrs)
+
+			if updated := backend.updatedStatus != nil; updated != tt.wantUpdate {
+				t.Fatalf("status updated = %v, want %v", updated, tt.wantUpdate)
+			}
+			if !tt.wantUpdate {
+				return
+			}
+			got := backend.updatedStatus.Status
+			if diff := cmp.Diff(newInventory(tt.attrs).CPU, got.Inventory.CPU); diff != "" {
+				t.Errorf("unexpected inventory (-want +got):\n%s", diff)
+			}
+			if got.Inventory.UpdatedAt == nil || !got.Inventory.UpdatedAt.Time.Equal(now) {
+				t.Errorf("expected inventory updatedAt to be %v, got %v", now, got.Inventory.UpdatedAt)
+			}
+			var cond *tinkerbell.HardwareCondition
+			for i := range got.Conditions {
+				if got.Conditions[i].Type == tinkerbell.InventoryDrift {
+					cond = &got.Conditions[i]
+				}
+			}
+			if diff := cmp.Diff(tt.wantCondition, cond); diff != "" {
+				t.Errorf("unexpected condition (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantEvents, recorder.events); diff != "" {
+				t.Errorf("unexpected events (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInventoryChanges(t *testing.T) {
+	oldInv := &tinkerbell.HardwareInventory{
+		CPU:        &tinkerbell.InventoryCPU{Cores: 8, Threads: 16},
+		Disks:      []tinkerbell.InventoryDisk{{Name: "sda", Size: "1TB", SerialNumber: "A"}},
+		NICs:       []tinkerbell.InventoryNIC{{Name: "eth0", MAC: "00:00:00:00:00:01"}},
+		PCIDevices: []tinkerbell.InventoryPCIDevice{{Vendor: "NVIDIA", Product: "A100", Class: "3D"}, {Vendor: "NVIDIA", Product: "A100", Class: "3D"}},
+		BIOS:       &tinkerbell.InventoryBIOS{Version: "1.0"},
+	}
+	newInv := &tinkerbell.HardwareInventory{
+		CPU:        &tinkerbell.InventoryCPU{Cores: 8, Threads: 8},
+		Disks:      []tinkerbell.InventoryDisk{{Name: "sda", Size: "1TB", SerialNumber: "B"}, {Name: "nvme0n1"}},
+		NICs:       []tinkerbell.InventoryNIC{{Name: "eth1", MAC: "00:00:00:00:00:01"}},
+		PCIDevices: []tinkerbell.InventoryPCIDevice{{Vendor: "NVIDIA", Product: "A100", Class: "3D"}},
+		BIOS:       &tinkerbell.InventoryBIOS{Version: "1.0"},
+	}
+	want := []string{
+		`cpu threads changed from "16" to "8"`,
+		`disk sda serial number changed from "A" to "B"`,
+		"disk nvme0n1 added",
+		"nic eth1 added",
+		"nic eth0 removed",
+		`1 pci device(s) "NVIDIA A100 3D" removed`,
+	}
+	if diff := cmp.Diff(want, inventoryChanges(oldInv, newInv)); diff != "" {
+		t.Errorf("unexpected changes (-want +got):\n%s", diff)
+	}
+}
+
+func TestUpdateInventoryDriftAcknowledged(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	drifted := now.Add(-time.Hour)
+	attrs := &data.AgentAttributes{Memory: &data.Memory{Total: toPtr("16GB")}}
+	hardware := func(ack string) *tinkerbell.Hardware {
+		hw := &tinkerbell.Hardware{
+			ObjectMeta: metav1.ObjectMeta{Name: "hw", Namespace: "default"},
+			Status: tinkerbell.HardwareStatus{
+				Inventory: newInventory(attrs),
+				Conditions: []tinkerbell.HardwareCondition{{
+					Type:    tinkerbell.InventoryDrift,
+					Status:  metav1.ConditionTrue,
+					Reason:  ReasonInventoryChanged,
+					Message: `memory total changed from "8GB" to "16GB"`,
+					Time:    &metav1.Time{Time: drifted},
+				}},
+			},
+		}
+		if ack != "" {
+			hw.Annotations = map[string]string{constant.InventoryDriftAcknowledgedAnnotation: ack}
+		}
+		return hw
+	}
+
+	tests := map[string]struct {
+		ack         string
+		wantCleared bool
+	}{
+		"not acknowledged":              {},
+		"acknowledged after the drift":  {ack: drifted.Add(time.Minute).Format(time.RFC3339), wantCleared: true},
+		"acknowledged at the drift":     {ack: drifted.Format(time.RFC3339), wantCleared: true},
+		"acknowledged before the drift": {ack: drifted.Add(-time.Minute).Format(time.RFC3339)},
+		"invalid acknowledgement":       {ack: "yes"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			backend := &mockBackendReadWriter{}
+			h := &Handler{Backend: backend, NowFunc: func() time.Time { return now }}
+			h.updateInventory(context.Background(), logr.Discard(), hardware(tt.ack), attrs)
+
+			if cleared := backend.updatedStatus != nil; cleared != tt.wantCleared {
+				t.Fatalf("status updated = %v, want %v", cleared, tt.wantCleared)
+			}
+			if !tt.wantCleared {
+				return
+			}
+			want := []tinkerbell.HardwareCondition{{
+				Type:    tinkerbell.InventoryDrift,
+				Status:  metav1.ConditionFalse,
+				Reason:  ReasonInventoryDriftAcknowledged,
+				Message: "inventory drift acknowledged",
+				Time:    &metav1.Time{Time: now},
+			}}
+			if diff := cmp.Diff(want, backend.updatedStatus.Status.Conditions); diff != "" {
+				t.Errorf("unexpected conditions (-want +got):\n%s", diff)
+			}
+			if backend.updatedStatus.Status.Inventory.UpdatedAt != nil {
+				t.Errorf("expected the inventory to be left as is, got updatedAt %v", backend.updatedStatus.Status.Inventory.UpdatedAt)
+			}
+		})
+	}
+}
@@ -5,17 +5,20 @@ import (
 	"fmt"
 	"net"
 	"net/netip"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	grpcprometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/prometheus/client_golang/prometheus"
+	v1alpha1 "github.com/tinkerbell/tinkerbell/api/v1alpha1/tinkerbell"
 	"github.com/tinkerbell/tinkerbell/pkg/proto"
 	grpcinternal "github.com/tinkerbell/tinkerbell/tink/server/internal/grpc"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/reflection"
+	"sigs.k8s.io/yaml"
 )
 
 // Registry is the Prometheus registry for all Tink server gRPC metrics.
@@ -37,6 +40,10 @@ type Config struct {
 	Logger       logr.Logger
 	Auto         AutoCapabilities
 	TLS          TLS
+	// EnableDryRun enables the auto enrollment dry run HTTP handler. See DryRunHandler.
+	EnableDryRun bool
+	// EventRecorder is optional. When set, Events are recorded for Hardware inventory drift.
+	EventRecorder grpcinternal.HardwareEventRecorder
 }
 
 type AutoCapabilities struct {
@@ -45,17 +52,28 @@ type AutoCapabilities struct {
 }
 
 type Enrollment struct {
-	Enabled               bool
-	WorkflowRuleSetLister grpcinternal.WorkflowRuleSetLister
-	WorkflowCreator       grpcinternal.WorkflowCreator
+	Enabled                bool
+	WorkflowRuleSetLister  grpcinternal.WorkflowRuleSetLister
+	WorkflowCreator        grpcinternal.WorkflowCreator
+	WorkflowRuleSetUpdater grpcinternal.WorkflowRuleSetUpdater
+	TemplateReader         grpcinternal.TemplateReader
+	// HardwareMatch lists the Agent attributes used to link an Agent to existing Hardware.
+	// Valid values are "chassis-serial", "product-serial", and "mac".
+	HardwareMatch []string
+	// ReEnrollmentCooldown is the minimum time between creating a Workflow for an Agent and re-enrolling it.
+	// Zero uses a default of 10 minutes, a negative value disables the cooldown.
+	ReEnrollmentCooldown time.Duration
 }
 
 type Discovery struct {
 	Enabled           bool
 	Namespace         string
 	EnrollmentEnabled bool
-	HardwareCreator   grpcinternal.HardwareCreator
-	HardwareFilterer  grpcinternal.HardwareFilterer
+	// InterfaceProfile is a JSON or YAML encoded tinkerbell.Interface used as the template for
+	// each interface of a discovered Hardware object. For example: {"netboot":{"allowPXE":true,"allowWorkflow":true}}.
+	InterfaceProfile string
+	HardwareCreator  grpcinternal.HardwareCreator
+	HardwareFilterer grpcinternal.HardwareFilterer
 }
 
 type TLS struct {
@@ -115,26 +133,46 @@ func NewConfig(opts ...Option) *Config {
 	return c
 }
 
-func (c *Config) Start(ctx context.Context, log logr.Logger) error {
-	s := &grpcinternal.Handler{
-		Backend: c.Backend,
-		Logger:  log,
-		NowFunc: time.Now,
+// handler returns a gRPC handler configured from c.
+func (c *Config) handler(log logr.Logger) *grpcinternal.Handler {
+	// Invalid values are rejected by Start.
+	hm, _ := c.hardwareMatch()
+	ip, _ := c.interfaceProfile()
+	return &grpcinternal.Handler{
+		Backend:       c.Backend,
+		Logger:        log,
+		NowFunc:       time.Now,
+		EventRecorder: c.EventRecorder,
 		AutoCapabilities: grpcinternal.AutoCapabilities{
 			Enrollment: grpcinternal.AutoEnrollment{
-				Enabled:               c.Auto.Enrollment.Enabled,
-				WorkflowRuleSetLister: c.Auto.Enrollment.WorkflowRuleSetLister,
-				WorkflowCreator:       c.Auto.Enrollment.WorkflowCreator,
+				Enabled:                c.Auto.Enrollment.Enabled,
+				WorkflowRuleSetLister:  c.Auto.Enrollment.WorkflowRuleSetLister,
+				WorkflowCreator:        c.Auto.Enrollment.WorkflowCreator,
+				WorkflowRuleSetUpdater: c.Auto.Enrollment.WorkflowRuleSetUpdater,
+				TemplateReader:         c.Auto.Enrollment.TemplateReader,
+				HardwareMatch:          hm,
+				ReEnrollmentCooldown:   c.Auto.Enrollment.ReEnrollmentCooldown,
 			},
 			Discovery: grpcinternal.AutoDiscovery{
 				Enabled:           c.Auto.Discovery.Enabled,
 				Namespace:         c.Auto.Discovery.Namespace,
 				EnrollmentEnabled: c.Auto.Discovery.EnrollmentEnabled,
+				InterfaceProfile:  ip,
 				HardwareCreator:   c.Auto.Discovery.HardwareCreator,
 				HardwareFilterer:  c.Auto.Discovery.HardwareFilterer,
 			},
 		},
 	}
+}
+
+func (c *Config) Start(ctx context.Context, log logr.Logger) error {
+	if _, err := c.hardwareMatch(); err != nil {
+		return err
+	}
+	if _, err := c.interfaceProfile(); err != nil {
+		return err
+	}
+	s := c.handler(log)
 
 	params := []grpc.ServerOption{
 		grpc.StatsHandler(otelgrpc.NewServerHandler()),
@@ -179,12 +217,45 @@ func (c *Config) Start(ctx context.Context, log logr.Logger) error {
 	return nil
 }
 
+// hardwareMatch parses c.Auto.Enrollment.HardwareMatch. Each value can be a comma separated list.
+func (c *Config) hardwareMatch() ([]grpcinternal.HardwareMatch, error) {
+	var hm []grpcinternal.HardwareMatch
+	for _, v := range c.Auto.Enrollment.HardwareMatch {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			m, err := grpcinternal.ParseHardwareMatch(s)
+			if err != nil {
+				return hm, err
+			}
+			hm = append(hm, m)
+		}
+	}
+	return hm, nil
+}
+
+// interfaceProfile parses c.Auto.Discovery.InterfaceProfile. An empty value returns a nil profile.
+func (c *Config) interfaceProfile() (*v1alpha1.Interface, error) {
+	if strings.TrimSpace(c.Auto.Discovery.InterfaceProfile) == "" {
+		return nil, nil
+	}
+	ip := &v1alpha1.Interface{}
+	if err := yaml.UnmarshalStrict([]byte(c.Auto.Discovery.InterfaceProfile), ip); err != nil {
+		return nil, fmt.Errorf("invalid auto discovery interface profile: %w", err)
+	}
+	return ip, nil
+}
+
 type allInterfaces interface {
 	grpcinternal.Backend
 	grpcinternal.HardwareCreator
 	grpcinternal.HardwareFilterer
 	grpcinternal.WorkflowRuleSetLister
 	grpcinternal.WorkflowCreator
+	grpcinternal.WorkflowRuleSetUpdater
+	grpcinternal.TemplateReader
+	grpcinternal.HardwareEventRecorder
 }
 
 // SetBackends is a helper function to set a single backend implementation for all backend interfaces.
@@ -195,4 +266,7 @@ func (c *Config) SetBackends(b allInterfaces) {
 	c.Auto.Discovery.HardwareFilterer = b
 	c.Auto.Enrollment.WorkflowRuleSetLister = b
 	c.Auto.Enrollment.WorkflowCreator = b
+	c.Auto.Enrollment.WorkflowRuleSetUpdater = b
+	c.Auto.Enrollment.TemplateReader = b
+	c.EventRecorder = b
 }
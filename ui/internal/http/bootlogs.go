@@ -0,0 +1,74 @@
+package webhttp
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+	"github.com/tinkerbell/tinkerbell/ui/templates"
+)
+
+// ContextKeyBootLogs is the key used to store the BootLogReader in Gin context.
+const ContextKeyBootLogs = "bootLogs"
+
+// BootLogReader returns the syslog messages received from a machine while it netbooted.
+type BootLogReader interface {
+	// BootLogs returns the messages received from the interface with the MAC address, oldest first.
+	BootLogs(mac string) []templates.BootLog
+}
+
+// GetBootLogReader retrieves the BootLogReader from the Gin context.
+// Returns nil when boot logs are not available.
+func GetBootLogReader(c *gin.Context) BootLogReader {
+	if r, exists := c.Get(ContextKeyBootLogs); exists {
+		if br, ok := r.(BootLogReader); ok {
+			return br
+		}
+	}
+	return nil
+}
+
+// HandleHardwareBootLogs renders the boot logs of all interfaces of a Hardware.
+// It is loaded by the Hardware detail page with HTMX.
+func HandleHardwareBootLogs(c *gin.Context, log logr.Logger) {
+	ctx := c.Request.Context()
+	namespace := c.Param("namespace")
+	name := c.Param("name")
+
+	reader := GetBootLogReader(c)
+	if reader == nil {
+		c.Status(404)
+		return
+	}
+
+	client, err := GetKubeClientFromGinContext(c)
+	if err != nil {
+		log.V(1).Info("Failed to get Kubernetes client from context", "error", err)
+		if HandleAuthError(c, err, log) {
+			return
+		}
+		c.Status(500)
+		return
+	}
+	hw, err := client.GetHardware(ctx, namespace, name)
+	if err != nil {
+		log.V(1).Info("Failed to fetch "+nameSingularHardware, "namespace", namespace, "name", name, "error", err)
+		if HandleAuthError(c, err, log) {
+			return
+		}
+		c.Status(404)
+		return
+	}
+
+	var logs []templates.BootLog
+	for _, iface := range GetHardwareInterfaces(*hw) {
+		if iface.MAC != "" {
+			logs = append(logs, reader.BootLogs(strings.ToLower(iface.MAC))...)
+		}
+	}
+	slices.SortStableFunc(logs, func(a, b templates.BootLog) int { return a.Time.Compare(b.Time) })
+
+	c.Header("Content-Type", "text/html")
+	RenderComponent(ctx, c.Writer, templates.BootLogsContent(logs), log)
+}
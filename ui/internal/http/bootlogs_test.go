@@ -0,0 +1,61 @@
+package webhttp
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tinkerbell/tinkerbell/ui/templates"
+)
+
+type fakeBootLogs map[string][]templates.BootLog
+
+func (f fakeBootLogs) BootLogs(mac string) []templates.BootLog {
+	return f[mac]
+}
+
+func TestHandleHardwareBootLogs(t *testing.T) {
+	kubeClient := newFakeKubeClient(
+		newTestNamespace("default"),
+		newTestHardware("hw-1", "default", "AA:BB:CC:DD:EE:01", "192.168.1.1"),
+	)
+
+	c, w := setupTestContext("/hardware/default/hw-1/logs", kubeClient)
+	c.Params = gin.Params{
+		{Key: "namespace", Value: "default"},
+		{Key: "name", Value: "hw-1"},
+	}
+	c.Set(ContextKeyBootLogs, fakeBootLogs{
+		"aa:bb:cc:dd:ee:01": {{Time: time.Now(), Host: "192.168.1.1", Severity: "ERR", App: "tink-agent", Message: "action failed"}},
+	})
+
+	HandleHardwareBootLogs(c, testLog)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if body := w.Body.String(); !contains(body, "action failed") {
+		t.Errorf("response should contain the boot log message, got %s", body)
+	}
+}
+
+func TestHandleHardwareBootLogs_NoReader(t *testing.T) {
+	kubeClient := newFakeKubeClient(
+		newTestNamespace("default"),
+		newTestHardware("hw-1", "default", "aa:bb:cc:dd:ee:01", "192.168.1.1"),
+	)
+
+	c, _ := setupTestContext("/hardware/default/hw-1/logs", kubeClient)
+	c.Params = gin.Params{
+		{Key: "namespace", Value: "default"},
+		{Key: "name", Value: "hw-1"},
+	}
+
+	HandleHardwareBootLogs(c, testLog)
+
+	// Nothing is written, so the status is only set on the gin writer.
+	if got := c.Writer.Status(); got != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", got, http.StatusNotFound)
+	}
+}
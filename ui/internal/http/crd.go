@@ -56,7 +56,8 @@ func parseCRDs() *templates.DashboardData {
 
 	for _, rawYAML := range crd.TinkerbellDefaults {
 		crdInfo := parseSingleCRD(rawYAML)
-		if crdInfo == nil {
+		// CRDs without pages in the UI, like IPXEScript, are not shown on the dashboard.
+		if crdInfo == nil || crdInfo.Route == "" {
 			continue
 		}
 
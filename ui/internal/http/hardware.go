@@ -248,6 +248,7 @@ func HandleHardwareDetail(c *gin.Context, log logr.Logger) {
 		SpecYAML:        string(specYAML),
 		StatusYAML:      string(statusYAML),
 		YAML:            string(yamlBytes),
+		BootLogs:        GetBootLogReader(c) != nil,
 	}
 
 	cfg := templates.PageConfig{
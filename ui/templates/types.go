@@ -1,5 +1,7 @@
 package templates
 
+import "time"
+
 // PageConfig holds common page configuration.
 type PageConfig struct {
 	BaseURL    string   // URL prefix for all routes (e.g., "/ui")
@@ -159,6 +161,15 @@ type HardwareInterface struct {
 	IP  string
 }
 
+// BootLog represents a syslog message received from a machine.
+type BootLog struct {
+	Time     time.Time
+	Host     string
+	Severity string
+	App      string
+	Message  string
+}
+
 // AgentProcessor represents a processor from agent attributes.
 type AgentProcessor struct {
 	ID           int      `json:"id"`
@@ -274,6 +285,8 @@ type HardwareDetail struct {
 	SpecYAML        string
 	StatusYAML      string
 	YAML            string
+	// BootLogs is true when the boot logs of the Hardware can be loaded.
+	BootLogs bool
 }
 
 // WorkflowDetail is the data for the workflow detail page.
@@ -34,8 +34,14 @@ type Config struct {
 	AutoLoginRestConfig *rest.Config
 	// AutoLoginNamespace is the namespace to use for namespace-scoped fallbacks when EnableAutoLogin is true.
 	AutoLoginNamespace string
+	// BootLogs provides the syslog messages received from machines while they netbooted.
+	// The boot logs section of the Hardware detail page is shown only when it is set.
+	BootLogs BootLogReader
 }
 
+// BootLogReader returns the syslog messages received from a machine while it netbooted.
+type BootLogReader = webhttp.BootLogReader
+
 type Option func(*Config)
 
 func WithURLPrefix(prefix string) Option {
@@ -103,6 +109,9 @@ func (c *Config) Handler(log logr.Logger) (http.Handler, error) {
 	// Set baseURL in context for all routes under base
 	base.Use(func(gc *gin.Context) {
 		gc.Set(webhttp.ContextKeyBaseURL, templateBaseURL)
+		if c.BootLogs != nil {
+			gc.Set(webhttp.ContextKeyBootLogs, c.BootLogs)
+		}
 		gc.Next()
 	})
 
@@ -251,6 +260,9 @@ func (c *Config) Handler(log logr.Logger) (http.Handler, error) {
 		protected.GET("/hardware/:namespace/:name", func(c *gin.Context) {
 			webhttp.HandleHardwareDetail(c, log)
 		})
+		protected.GET("/hardware/:namespace/:name/logs", func(c *gin.Context) {
+			webhttp.HandleHardwareBootLogs(c, log)
+		})
 
 		// Workflow routes
 		protected.GET("/workflows", func(c *gin.Context) {